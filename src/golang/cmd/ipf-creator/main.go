@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/joao-paulo-santos/GE-Library/pkg/creator"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
 )
 
 func main() {
@@ -14,23 +15,55 @@ func main() {
 	encrypt := flag.Bool("encrypt", true, "Encrypt filenames (true=IPF, false=ZIP)")
 	compression := flag.Int("compression", 6, "Compression level (0-9, default 6)")
 	verbose := flag.Bool("verbose", false, "Enable verbose output")
+	skipEmpty := flag.Bool("skip-empty", false, "Skip zero-byte files")
+	hostSystem := flag.String("host", "dos", "Host system recorded in version-made-by (dos, unix)")
+	singleRoot := flag.Bool("flatten-single-root", false, "Do not store the top-level directory when it wraps every file")
+	normalizeNames := flag.Bool("normalize-names", false, "Store names as NFC-normalized UTF-8 without a BOM")
+	emptyDirs := flag.Bool("empty-dirs", false, "Store empty folders as directory entries")
+	followSymlinks := flag.Bool("follow-symlinks", false, "Pack the files and folders symbolic links point to instead of skipping the links")
+	decryptInput := flag.String("decrypt", "", "Convert this existing IPF into a plain ZIP instead of packing a folder")
+	update := flag.Bool("update", false, "Add new and changed files to the existing -output archive instead of rebuilding it")
+	deterministic := flag.Bool("deterministic", false, "Produce byte-identical output for identical input (fixed timestamps, content-derived encryption headers)")
+	workerCount := flag.Int("workers", 0, "Number of files compressed in parallel (0 = one per CPU, 1 = sequential)")
+	passwordValue := flag.String("password", "", "Archive password (raw text, or hex:... for binary; default: built-in IPF key)")
+
+	var excludePatterns []string
+	flag.Func("exclude", "Leave out files and folders matching this glob (repeatable; ** matches any directories)", func(pattern string) error {
+		excludePatterns = append(excludePatterns, pattern)
+		return nil
+	})
 
 	flag.Parse()
 
-	if *folder == "" || *output == "" {
+	if (*folder == "" && *decryptInput == "") || *output == "" {
 		fmt.Println("IPF Creator v1.0.0")
 		fmt.Println("Create IPF or ZIP archives from folders")
 		fmt.Println()
 		fmt.Println("Usage:")
 		fmt.Println("  ipf-creator -folder <folder> -output <output.ipf> [options]")
+		fmt.Println("  ipf-creator -decrypt <input.ipf> -output <output.zip> [options]")
 		fmt.Println()
 		fmt.Println("Required:")
 		fmt.Println("  -folder string   Input folder to create IPF from")
 		fmt.Println("  -output string   Output IPF/ZIP file path")
 		fmt.Println()
+		fmt.Println("Conversion:")
+		fmt.Println("  -decrypt string  Existing IPF to convert into a plain ZIP (replaces -folder)")
+		fmt.Println()
 		fmt.Println("Options:")
 		fmt.Println("  -encrypt        Encrypt filenames (default true, false=plain ZIP)")
 		fmt.Println("  -compression int Compression level 0-9 (default 6)")
+		fmt.Println("  -skip-empty      Skip zero-byte files")
+		fmt.Println("  -host string     Host system: dos (default) or unix to preserve file modes")
+		fmt.Println("  -flatten-single-root  Omit a top-level directory that wraps every file")
+		fmt.Println("  -empty-dirs      Keep empty folders as directory entries")
+		fmt.Println("  -exclude glob    Leave out matching files and folders, e.g. '*.tmp' or '.git/**' (repeatable)")
+		fmt.Println("  -follow-symlinks Pack what symbolic links point to (default: skip links)")
+		fmt.Println("  -normalize-names Store names as NFC UTF-8 without a leading BOM")
+		fmt.Println("  -password string Archive password, or hex:... for binary keys (default: built-in IPF key)")
+		fmt.Println("  -update          Append new and changed files to the existing -output archive")
+		fmt.Println("  -deterministic   Reproducible output: fixed timestamps and content-derived encryption headers")
+		fmt.Println("  -workers int     Files compressed in parallel (default: one per CPU)")
 		fmt.Println("  -verbose         Enable verbose output")
 		fmt.Println()
 		os.Exit(1)
@@ -38,10 +71,15 @@ func main() {
 
 	if *verbose {
 		fmt.Println("IPF Creator v1.0.0")
-		fmt.Printf("Input folder: %s\n", *folder)
+		if *decryptInput != "" {
+			fmt.Printf("Input IPF: %s\n", *decryptInput)
+		} else {
+			fmt.Printf("Input folder: %s\n", *folder)
+		}
 		fmt.Printf("Output file: %s\n", *output)
 		fmt.Printf("Encrypt filenames: %v\n", *encrypt)
 		fmt.Printf("Compression level: %d\n", *compression)
+		fmt.Printf("Skip empty files: %v\n", *skipEmpty)
 	}
 
 	if *compression < 0 || *compression > 9 {
@@ -49,19 +87,76 @@ func main() {
 		os.Exit(1)
 	}
 
+	var host creator.HostSystem
+	switch *hostSystem {
+	case "dos":
+		host = creator.HostDOS
+	case "unix":
+		host = creator.HostUnix
+	default:
+		fmt.Println("Error: Host system must be dos or unix")
+		os.Exit(1)
+	}
+
+	password, err := zipcipher.ParsePassword(*passwordValue)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var source creator.Source
+	if *decryptInput != "" {
+		ipfSource, err := creator.NewIPFSource(*decryptInput, password)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer ipfSource.Close()
+		source = ipfSource
+		*encrypt = false
+	}
+
 	creator := creator.NewCreator(*folder, *output, *encrypt)
+	creator.Source = source
+	creator.Password = password
+	creator.StripSingleRoot = *singleRoot
+	creator.IncludeEmptyDirs = *emptyDirs
+	creator.FollowSymlinks = *followSymlinks
+	creator.ExcludePatterns = excludePatterns
+	creator.NormalizeNames = *normalizeNames
 	creator.CompressionLevel = *compression
+	creator.SkipEmpty = *skipEmpty
+	creator.HostSystem = host
+	creator.WorkerCount = *workerCount
+	creator.Deterministic = *deterministic
+
+	if *update {
+		stats, err := creator.UpdateIPF(*output)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("IPF archive updated: %d added, %d replaced, %d unchanged\n", stats.Added, stats.Replaced, stats.Unchanged)
+		return
+	}
 
 	if *verbose {
 		fmt.Println()
 		fmt.Println("Creating IPF archive...")
 	}
 
-	err := creator.CreateIPF()
-	if err != nil {
+	if err := creator.CreateIPF(); err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
+	if *verbose && *skipEmpty {
+		fmt.Printf("Skipped %d empty files\n", creator.SkippedEmpty)
+	}
+	if creator.SkippedSymlinks > 0 {
+		fmt.Printf("Skipped %d symbolic links\n", creator.SkippedSymlinks)
+	}
+
 	fmt.Println("IPF archive created successfully!")
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// Exit codes follow diff(1)
+const (
+	ExitSame        = 0
+	ExitDifferences = 1
+	ExitError       = 2
+)
+
+// jsonEntry describes one file in the -json output
+type jsonEntry struct {
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	CRC32 string `json:"crc32"`
+}
+
+// jsonChange describes a modified file in the -json output
+type jsonChange struct {
+	Name     string `json:"name"`
+	OldSize  int64  `json:"old_size"`
+	NewSize  int64  `json:"new_size"`
+	OldCRC32 string `json:"old_crc32"`
+	NewCRC32 string `json:"new_crc32"`
+}
+
+type jsonDiff struct {
+	Added     []jsonEntry  `json:"added"`
+	Removed   []jsonEntry  `json:"removed"`
+	Modified  []jsonChange `json:"modified"`
+	Unchanged int          `json:"unchanged"`
+}
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "Print the differences as a JSON object")
+	passwordValue := flag.String("password", "", "Archive password (raw text, or hex:... for binary; default: built-in IPF key)")
+	flag.Parse()
+
+	if len(flag.Args()) != 2 {
+		fmt.Println("Usage: ipf-diff [--json] [--password key] <old.ipf> <new.ipf>")
+		fmt.Println("Lists files added (+), removed (-) and modified (M) between two archives")
+		os.Exit(ExitError)
+	}
+
+	password, err := zipcipher.ParsePassword(*passwordValue)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(ExitError)
+	}
+
+	result, err := diffArchives(flag.Arg(0), flag.Arg(1), password)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(ExitError)
+	}
+
+	if *jsonOutput {
+		if err := printJSON(result); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(ExitError)
+		}
+	} else {
+		printText(result)
+	}
+
+	if result.Changed() {
+		os.Exit(ExitDifferences)
+	}
+}
+
+// diffArchives opens both archives and compares them
+func diffArchives(oldPath, newPath string, password []byte) (*ipf.DiffResult, error) {
+	oldReader, err := ipf.NewIPFReader(oldPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", oldPath, err)
+	}
+	defer oldReader.Close()
+
+	newReader, err := ipf.NewIPFReader(newPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", newPath, err)
+	}
+	defer newReader.Close()
+
+	return ipf.Diff(oldReader, newReader, password)
+}
+
+func printText(result *ipf.DiffResult) {
+	for _, entry := range result.Added {
+		fmt.Printf("+ %s (%d bytes)\n", entry.Name, entry.Size)
+	}
+	for _, entry := range result.Removed {
+		fmt.Printf("- %s (%d bytes)\n", entry.Name, entry.Size)
+	}
+	for _, change := range result.Modified {
+		fmt.Printf("M %s (%d -> %d bytes, CRC32 %08x -> %08x)\n", change.Name, change.Old.Size, change.New.Size, change.Old.CRC32, change.New.CRC32)
+	}
+	fmt.Printf("%d added, %d removed, %d modified, %d unchanged\n", len(result.Added), len(result.Removed), len(result.Modified), result.Unchanged)
+}
+
+func printJSON(result *ipf.DiffResult) error {
+	output := jsonDiff{
+		Added:     make([]jsonEntry, 0, len(result.Added)),
+		Removed:   make([]jsonEntry, 0, len(result.Removed)),
+		Modified:  make([]jsonChange, 0, len(result.Modified)),
+		Unchanged: result.Unchanged,
+	}
+	for _, entry := range result.Added {
+		output.Added = append(output.Added, jsonEntry{Name: entry.Name, Size: entry.Size, CRC32: fmt.Sprintf("%08x", entry.CRC32)})
+	}
+	for _, entry := range result.Removed {
+		output.Removed = append(output.Removed, jsonEntry{Name: entry.Name, Size: entry.Size, CRC32: fmt.Sprintf("%08x", entry.CRC32)})
+	}
+	for _, change := range result.Modified {
+		output.Modified = append(output.Modified, jsonChange{
+			Name:     change.Name,
+			OldSize:  change.Old.Size,
+			NewSize:  change.New.Size,
+			OldCRC32: fmt.Sprintf("%08x", change.Old.CRC32),
+			NewCRC32: fmt.Sprintf("%08x", change.New.CRC32),
+		})
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
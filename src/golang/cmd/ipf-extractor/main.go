@@ -2,15 +2,25 @@ package main
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/joao-paulo-santos/GE-Library/pkg/compare"
 	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
 	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
 )
@@ -20,8 +30,19 @@ const (
 	AppName    = "IPF Extractor"
 	AppVersion = "1.0.0"
 	AppDesc    = "High-performance IPF archive extractor using Go"
+
+	// ExitFailures is the exit code of a -keep-going run that finished with failed files, and of
+	// a -verify run that found damaged files
+	ExitFailures = 3
+
+	// ExitInterrupted is the exit code when Ctrl-C or SIGTERM stopped the run, as shells report
+	// a SIGINT death
+	ExitInterrupted = 130
 )
 
+// errFilesFailed reports that extraction ran to completion but some files failed
+var errFilesFailed = errors.New("some files failed to extract")
+
 // Config holds the application configuration
 type Config struct {
 	InputFile    string
@@ -30,10 +51,33 @@ type Config struct {
 	BatchSize    int
 	Verbose      bool
 	Quiet        bool
+	JSON         bool // Print a single JSON summary instead of the human-readable report
 	ShowVersion  bool
+	SelfTest     bool
 	ShowProgress bool
 	ValidateOnly bool
+	Verify       bool // Decode every file and check its CRC32 without writing anything
+	Probe        bool
+	List         bool  // Print the archive's entries as a table instead of extracting
 	MaxMemory    int64 // Maximum memory usage in MB
+	HexdumpIndex int   // Index of the entry whose raw local header is dumped (-1 = disabled)
+	CASStore     string
+	CASManifest  string
+	ExportZip    string // Standard ZIP to export into instead of extracting
+	JunkPaths    bool
+	SingleRoot   bool
+	Template     string   // Output path template, e.g. {ext}/{name}
+	Include      []string // Only extract entries matching one of these globs
+	IgnoreCase   bool
+	Preallocate  bool
+	OffsetOrder  bool
+	KeepGoing    bool
+	MinSize      uint64 // Minimum uncompressed entry size in bytes (0 = no limit)
+	MaxSize      uint64 // Maximum uncompressed entry size in bytes (0 = no limit)
+	CompareDir   string // Reference directory the extracted tree is verified against
+	OnConflict   ipf.OverwritePolicy
+	Password     []byte // Archive password, the IPF default unless -password is given
+	PasswordList string // File of candidate passwords to auto-detect from
 }
 
 func main() {
@@ -44,18 +88,47 @@ func main() {
 		return
 	}
 
+	if config.SelfTest {
+		if err := zipcipher.SelfTest(); err != nil {
+			log.Fatalf("Self-test failed: %v", err)
+		}
+		fmt.Println("Self-test passed")
+		return
+	}
+
 	if config.InputFile == "" {
 		printUsage()
 		os.Exit(1)
 	}
 
 	// Validate input file
-	if err := validateInput(config.InputFile); err != nil {
+	if err := validateInput(config.InputFile, config.Quiet); err != nil {
 		log.Fatalf("Error: %v", err)
 	}
 
+	if config.Probe {
+		if err := probeArchive(config); err != nil {
+			log.Fatalf("Probe failed: %v", err)
+		}
+		return
+	}
+
+	if config.List {
+		if err := listArchive(config); err != nil {
+			log.Fatalf("Listing failed: %v", err)
+		}
+		return
+	}
+
 	// Run extraction
 	if err := runExtraction(config); err != nil {
+		if errors.Is(err, errFilesFailed) {
+			os.Exit(ExitFailures)
+		}
+		if errors.Is(err, context.Canceled) {
+			fmt.Fprintf(os.Stderr, "Interrupted: %v\n", err)
+			os.Exit(ExitInterrupted)
+		}
 		log.Fatalf("Extraction failed: %v", err)
 	}
 }
@@ -70,19 +143,69 @@ func parseFlags() *Config {
 	flag.IntVar(&config.BatchSize, "batch", 1000, "Batch size for processing")
 	flag.BoolVar(&config.Verbose, "verbose", false, "Enable verbose output")
 	flag.BoolVar(&config.Quiet, "quiet", false, "Suppress all output except errors")
+	flag.BoolVar(&config.JSON, "json", false, "Print one JSON object with counts, timings, statistics and errors instead of the report")
 	flag.BoolVar(&config.ShowVersion, "version", false, "Show version information")
+	flag.BoolVar(&config.SelfTest, "selftest", false, "Verify the decryption pipeline against a built-in fixture and exit")
 	flag.BoolVar(&config.ShowProgress, "progress", true, "Show progress bar")
 	flag.BoolVar(&config.ValidateOnly, "validate", false, "Only validate IPF file, don't extract")
+	flag.BoolVar(&config.Verify, "verify", false, "Decode every file and check its CRC32 without writing output; exits with code 3 on damage")
+	flag.BoolVar(&config.List, "list", false, "List entry names, sizes, CRC32s and timestamps without extracting")
+	flag.BoolVar(&config.Probe, "probe", false, "Identify the archive variant (encryption, methods, ZIP64, SFX stub) and exit")
 	flag.Int64Var(&config.MaxMemory, "max-memory", 0, "Maximum memory usage in MB (0 = no limit)")
+	flag.IntVar(&config.HexdumpIndex, "hexdump", -1, "Print the raw local header of the entry at this index and exit")
+	flag.StringVar(&config.CASStore, "cas-store", "", "Extract into a content-addressed store directory instead of -output")
+	flag.StringVar(&config.CASManifest, "cas-manifest", "", "Manifest path for -cas-store (default: <store>/<archive>.manifest.json)")
+	flag.StringVar(&config.ExportZip, "export-zip", "", "Write the decrypted entries into this standard ZIP file instead of -output")
+
+	flag.BoolVar(&config.JunkPaths, "junk-paths", false, "Discard directory structure and extract all files into the output root")
+	flag.BoolVar(&config.KeepGoing, "keep-going", false, "Extract every file possible, then summarize failures and exit with code 3 if any failed")
+	flag.BoolVar(&config.OffsetOrder, "offset-order", false, "Extract in physical archive order to minimize seeks (helps on HDDs)")
+	flag.BoolVar(&config.Preallocate, "preallocate", false, "Reserve each output file's size before writing (Linux fallocate)")
+	flag.BoolVar(&config.SingleRoot, "flatten-single-root", false, "Strip the top-level directory when all entries share a single one")
+	flag.StringVar(&config.Template, "output-template", "", "Output path template using {name}, {base}, {ext}, {dir}, {index} and {crc}")
+	flag.StringVar(&config.CompareDir, "compare", "", "After extracting, verify the output against this reference directory")
+	password := flag.String("password", "", "Archive password for other games' IPF keys (raw text, or hex:... for binary)")
+	flag.StringVar(&config.PasswordList, "password-list", "", "File of candidate passwords (one per line, hex: prefix for binary) to auto-detect from")
+	flag.Func("include", "Only extract entries matching this glob (repeatable; ** matches any directories)", func(pattern string) error {
+		config.Include = append(config.Include, pattern)
+		return nil
+	})
+	flag.BoolVar(&config.IgnoreCase, "ignore-case", false, "Match -include patterns case-insensitively")
+	onConflict := flag.String("on-conflict", "overwrite", "What to do with existing output files: overwrite, skip, newer or changed")
+	minSize := flag.String("min-size", "", "Only extract entries at least this large (e.g. 512KB, 10MB)")
+	maxSize := flag.String("max-size", "", "Only extract entries at most this large (e.g. 512KB, 10MB)")
 
 	flag.Parse()
 
-	// Auto-detect worker count if not specified
-	if config.WorkerCount <= 0 {
-		config.WorkerCount = runtime.NumCPU()
-		if config.WorkerCount > 32 {
-			config.WorkerCount = 32 // Cap at 32 for optimal performance
-		}
+	var err error
+	if config.OnConflict, err = ipf.ParseOverwritePolicy(*onConflict); err != nil {
+		printUsage()
+		log.Fatalf("Error: invalid -on-conflict: %v", err)
+	}
+	if config.MinSize, err = parseSize(*minSize); err != nil {
+		log.Fatalf("Error: invalid -min-size: %v", err)
+	}
+	if config.MaxSize, err = parseSize(*maxSize); err != nil {
+		log.Fatalf("Error: invalid -max-size: %v", err)
+	}
+	if config.MaxSize > 0 && config.MinSize > config.MaxSize {
+		log.Fatalf("Error: -min-size must not exceed -max-size")
+	}
+	if config.Password, err = zipcipher.ParsePassword(*password); err != nil {
+		log.Fatalf("Error: invalid -password: %v", err)
+	}
+	if *password != "" && config.PasswordList != "" {
+		log.Fatalf("Error: -password and -password-list cannot be combined")
+	}
+
+	// The JSON summary is the only output
+	if config.JSON {
+		config.Quiet = true
+	}
+
+	// A worker count of 0 is resolved from the archive once its structure is read
+	if config.WorkerCount < 0 {
+		config.WorkerCount = 0
 	}
 
 	// Set output directory if not specified
@@ -116,9 +239,34 @@ Options:
   -batch <n>         Batch size for processing (default: 1000)
   -verbose          Enable verbose output
   -quiet            Suppress all output except errors
+  -json             Print a single JSON summary (counts, timings, statistics, errors) for scripts
   -progress         Show progress bar (default: true)
   -validate         Only validate IPF file, don't extract
+  -verify           Decode every file and report CRC32 mismatches and corrupt data without extracting
+  -list             List entries (size, packed size, CRC32, modified time, name) and exit
+  -probe            Report the archive variant (encryption, methods, ZIP64, SFX stub, name encoding) and exit
   -max-memory <mb>  Maximum memory usage in MB (default: no limit)
+  -hexdump <index>  Print the raw local header of an entry and exit
+  -cas-store <dir>  Extract into a content-addressed store (ab/cd/<sha256>)
+  -cas-manifest <f> Manifest path for -cas-store (default: <store>/<archive>.manifest.json)
+  -export-zip <f>   Write a standard (ZIP64-capable) ZIP instead of extracting to -output
+  -junk-paths       Extract all files into the output root, like unzip -j
+  -keep-going       Extract everything possible, then report failures grouped by cause (exit code 3)
+  -offset-order     Read entries in physical archive order (faster on spinning disks)
+  -preallocate      Reserve output file sizes up front to reduce fragmentation (Linux)
+  -flatten-single-root  Strip the top-level directory when it wraps every entry
+  -output-template <t>  Build output paths from {name}, {base}, {ext}, {dir}, {index}, {crc}
+  -include <glob>   Only extract matching entries, e.g. 'data/item/*.xml' or 'ui/**/*.png' (repeatable)
+  -ignore-case      Match -include patterns case-insensitively
+  -min-size <size>  Only extract entries at least this large (supports KB/MB/GB)
+  -max-size <size>  Only extract entries at most this large (supports KB/MB/GB)
+  -on-conflict <p>  Existing output files: overwrite, skip, newer or changed (default: overwrite);
+                    changed keeps files whose size and CRC32 match, to resume an extraction
+  -password <p>     Archive password as text, or hex:... for binary keys (default: built-in IPF key)
+  -password-list <f> Auto-detect the password from a file of candidates (one per line, hex:... for binary),
+                    by header check byte or else by the share of filenames each one decrypts
+  -compare <dir>    Verify the extracted files against a reference directory
+  -selftest         Verify the decryption pipeline against a built-in fixture
   -version          Show version information
 
 Examples:
@@ -131,10 +279,25 @@ Examples:
   # Validate only, don't extract
   %s -input archive.ipf -validate
 
+  # List the entries without extracting
+  %s -input archive.ipf -list
+
+  # Identify what kind of archive this is
+  %s -input archive.ipf -probe
+
   # Large archive with more workers and larger batch
   %s -input large_archive.ipf -workers 32 -batch 2000
 
-`, AppName, AppVersion, AppDesc, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
+  # Extract and verify the result against a known-good tree
+  %s -input archive.ipf -output extracted_files -compare reference_files
+
+  # Sort files into one folder per extension
+  %s -input archive.ipf -output-template '{ext}/{base}.{ext}'
+
+  # Inspect the raw local header of the first entry
+  %s -input archive.ipf -hexdump 0
+
+`, AppName, AppVersion, AppDesc, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
 }
 
 // printVersion prints version information
@@ -148,7 +311,7 @@ func printVersion() {
 }
 
 // validateInput validates the input file
-func validateInput(inputFile string) error {
+func validateInput(inputFile string, quiet bool) error {
 	if _, err := os.Stat(inputFile); os.IsNotExist(err) {
 		return fmt.Errorf("input file does not exist: %s", inputFile)
 	}
@@ -162,7 +325,7 @@ func validateInput(inputFile string) error {
 
 	// Check file extension (optional)
 	ext := strings.ToLower(filepath.Ext(inputFile))
-	if ext != ".ipf" {
+	if ext != ".ipf" && !quiet {
 		fmt.Printf("Warning: Input file does not have .ipf extension: %s\n", inputFile)
 		fmt.Printf("         IPF files typically have .ipf extension, but continuing anyway...\n")
 	}
@@ -170,9 +333,72 @@ func validateInput(inputFile string) error {
 	return nil
 }
 
+// probeArchive prints the archive profile, decrypting filenames with the -password key or the
+// one detected from -password-list
+func probeArchive(config *Config) error {
+	password := config.Password
+	if config.PasswordList != "" {
+		reader, err := ipf.NewIPFReader(config.InputFile)
+		if err != nil {
+			return fmt.Errorf("failed to open IPF file: %w", err)
+		}
+		defer reader.Close()
+		if err := reader.ReadFileStructure(); err != nil {
+			return fmt.Errorf("failed to read file structure: %w", err)
+		}
+		if err := reader.ReadEncryptedFilenames(); err != nil {
+			return fmt.Errorf("failed to read encrypted filenames: %w", err)
+		}
+		if password, err = detectPassword(config, reader); err != nil {
+			return fmt.Errorf("failed to detect password: %w", err)
+		}
+	}
+
+	profile, err := ipf.Probe(config.InputFile, password)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Archive profile of %s:\n%s", config.InputFile, profile)
+	return nil
+}
+
+// listArchive prints every entry of the input archive as a table
+func listArchive(config *Config) error {
+	reader, err := ipf.NewIPFReader(config.InputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open IPF file: %w", err)
+	}
+	defer reader.Close()
+
+	entries, err := reader.ListFiles(config.Password)
+	if err != nil {
+		return err
+	}
+
+	var totalSize, totalPacked int64
+	fmt.Printf("%12s %12s %-8s %-19s %s\n", "Size", "Packed", "CRC32", "Modified", "Name")
+	for _, entry := range entries {
+		modified := "-"
+		if !entry.Modified.IsZero() {
+			modified = entry.Modified.Format("2006-01-02 15:04:05")
+		}
+		name := entry.Name
+		if entry.Encrypted {
+			name += " *"
+		}
+		fmt.Printf("%12d %12d %08x %-19s %s\n", entry.Size, entry.CompressedSize, entry.CRC32, modified, name)
+		totalSize += entry.Size
+		totalPacked += entry.CompressedSize
+	}
+	fmt.Printf("%12d %12d %d files (* = encrypted data)\n", totalSize, totalPacked, len(entries))
+	return nil
+}
+
 // runExtraction runs the main extraction process
 func runExtraction(config *Config) error {
-	ctx := context.Background()
+	// Ctrl-C cancels the filename pass, decryption and extraction instead of killing the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	totalStartTime := time.Now()
 
 	// Print header
@@ -180,7 +406,11 @@ func runExtraction(config *Config) error {
 		fmt.Printf("%s v%s\n", AppName, AppVersion)
 		fmt.Printf("Input: %s\n", config.InputFile)
 		fmt.Printf("Output: %s\n", config.OutputDir)
-		fmt.Printf("Workers: %d\n", config.WorkerCount)
+		if config.WorkerCount > 0 {
+			fmt.Printf("Workers: %d\n", config.WorkerCount)
+		} else {
+			fmt.Printf("Workers: auto\n")
+		}
 		fmt.Printf("Batch Size: %d\n", config.BatchSize)
 		fmt.Printf("\n")
 	}
@@ -208,20 +438,57 @@ func runExtraction(config *Config) error {
 		fmt.Printf("   Found %d files in archive\n", fileCount)
 	}
 
+	// Auto-detect worker count from the archive's makeup
+	if config.WorkerCount == 0 {
+		config.WorkerCount = ipf.RecommendWorkers(reader)
+		if !config.Quiet {
+			fmt.Printf("   Using %d workers\n", config.WorkerCount)
+		}
+	}
+
+	// Dump a raw local header if requested
+	if config.HexdumpIndex >= 0 {
+		raw, err := reader.RawLocalHeader(config.HexdumpIndex)
+		if err != nil {
+			return fmt.Errorf("failed to read raw local header: %w", err)
+		}
+		fmt.Printf("Local header of file %d (%d bytes):\n", config.HexdumpIndex, len(raw))
+		fmt.Print(hex.Dump(raw))
+		return nil
+	}
+
 	// Step 3: Read encrypted filenames
 	printStep(config, "Reading encrypted filenames...")
 	filenameReadStart := time.Now()
-	if err := reader.ReadEncryptedFilenames(); err != nil {
+	if err := reader.ReadEncryptedFilenamesCtx(ctx); err != nil {
 		return fmt.Errorf("failed to read encrypted filenames: %w", err)
 	}
 	filenameReadTime = time.Since(filenameReadStart)
 
+	if !config.Quiet && len(reader.Warnings) > 0 {
+		fmt.Printf("   WARNING: %d header inconsistencies found\n", len(reader.Warnings))
+		if config.Verbose || config.ValidateOnly {
+			for _, warning := range reader.Warnings {
+				fmt.Printf("   - %s\n", warning)
+			}
+		}
+	}
+
+	// Pick the archive password
+	password := config.Password
+	if config.PasswordList != "" {
+		printStep(config, "Detecting password...")
+		password, err = detectPassword(config, reader)
+		if err != nil {
+			return fmt.Errorf("failed to detect password: %w", err)
+		}
+	}
+
 	// Get file infos
 	fileInfos := reader.GetFileInfos()
 
 	// Step 4: Parallel filename decryption
 	printStep(config, "Decrypting filenames...")
-	password := zipcipher.GetIPFPassword()
 	decryptor := ipf.NewFilenameDecryptor(password, config.WorkerCount)
 
 	decryptStartTime := time.Now()
@@ -239,18 +506,33 @@ func runExtraction(config *Config) error {
 	successRate := resultProcessor.GetSuccessRate()
 
 	// Update file infos with decrypted names
-	ipf.UpdateFileInfos(fileInfos, decryptionResults)
+	reader.UpdateFileInfos(decryptionResults)
 
 	if !config.Quiet {
 		fmt.Printf("   Decrypted %d/%d filenames (%.1f%%) in %.2fs\n",
 			successCount, fileCount, successRate, decryptTime.Seconds())
-		if successRate < 100.0 {
+		switch resultProcessor.Classify() {
+		case ipf.DecryptOutcomeWrongPassword:
+			fmt.Printf("   WARNING: almost no filenames could be decrypted, the password is likely wrong\n")
+		case ipf.DecryptOutcomePartial:
 			fmt.Printf("   WARNING: %.1f%% filenames could not be decrypted\n", 100.0-successRate)
 		}
+		if config.Verbose {
+			printEncodings(resultProcessor.EncodingBreakdown())
+		}
 	}
 
 	// Step 5: Validate if requested
 	if config.ValidateOnly {
+		if err := reader.ValidateIPF(); err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+		if config.JSON {
+			report := newJSONReport(config, fileCount, successCount)
+			report.Validated = true
+			report.Timings = newJSONTimings(ipfReadTime, filenameReadTime, decryptTime, 0, time.Since(totalStartTime))
+			return printJSON(report)
+		}
 		printStep(config, "Validation complete!")
 		fmt.Printf("   IPF file is valid and contains %d files\n", fileCount)
 		fmt.Printf("   Successfully decrypted %d filenames (%.1f%%)\n", successCount, successRate)
@@ -264,38 +546,124 @@ func runExtraction(config *Config) error {
 	}
 
 	// Step 6: Extract files
-	printStep(config, "Extracting files...")
+	if config.Verify {
+		printStep(config, "Verifying files...")
+	} else {
+		printStep(config, "Extracting files...")
+	}
 	var extractionResults []ipf.ExtractionResult
 
 	// Get IPF password for extraction
-	extractPasswordBytes := zipcipher.GetIPFPassword()
+	extractPasswordBytes := password
 
 	extractStartTime := time.Now()
 
 	// Use standard concurrent extractor
 	extractor := ipf.NewConcurrentExtractor(reader, reader.ZipReader, config.WorkerCount)
-	extractionResults, err = extractor.ExtractBatch(ctx, config.OutputDir, config.BatchSize, extractPasswordBytes)
+	extractor.Flatten = config.JunkPaths
+	extractor.StripSingleRoot = config.SingleRoot
+	extractor.OutputTemplate = config.Template
+	extractor.Preallocate = config.Preallocate
+	extractor.OffsetOrder = config.OffsetOrder
+	extractor.KeepGoing = config.KeepGoing
+	extractor.MinSize = config.MinSize
+	extractor.MaxSize = config.MaxSize
+	extractor.Overwrite = config.OnConflict
+	extractor.IgnoreCase = config.IgnoreCase
+	extractor.MaxMemory = config.MaxMemory * 1024 * 1024
+	var bar *progressBar
+	if config.ShowProgress && !config.Quiet && isTerminal(os.Stdout) {
+		bar = &progressBar{}
+		extractor.SetProgressFunc(bar.update)
+	}
+	if config.Verify {
+		extractionResults, err = extractor.VerifyAll(ctx, extractPasswordBytes)
+	} else if config.CASStore != "" {
+		manifestPath := config.CASManifest
+		if manifestPath == "" {
+			manifestPath = filepath.Join(config.CASStore, filepath.Base(config.InputFile)+".manifest.json")
+		}
+		config.OutputDir = config.CASStore
+		extractionResults, err = extractor.ExtractToCAS(ctx, config.CASStore, manifestPath, extractPasswordBytes)
+	} else if config.ExportZip != "" {
+		config.OutputDir = config.ExportZip
+		extractionResults, err = exportZip(ctx, extractor, config.ExportZip, extractPasswordBytes)
+	} else if len(config.Include) > 0 {
+		extractionResults, err = extractor.ExtractMatching(ctx, config.OutputDir, config.Include, extractPasswordBytes)
+	} else {
+		extractionResults, err = extractor.ExtractBatch(ctx, config.OutputDir, config.BatchSize, extractPasswordBytes)
+	}
+	if bar != nil {
+		bar.finish()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract files: %w", err)
+	}
 
 	extractTime = time.Since(extractStartTime)
 
 	// Calculate statistics
 	stats := ipf.CalculateStats(extractionResults, extractTime.Milliseconds())
 
+	if config.Verify {
+		mismatched, corrupt := countDamaged(extractionResults)
+		if config.JSON {
+			report := newJSONReport(config, fileCount, successCount)
+			report.Output = ""
+			report.Timings = newJSONTimings(ipfReadTime, filenameReadTime, decryptTime, extractTime, time.Since(totalStartTime))
+			report.setStats(stats)
+			report.CRCMismatches = mismatched
+			report.Corrupt = corrupt
+			if err := printJSON(report); err != nil {
+				return err
+			}
+		} else {
+			printVerification(reader, extractionResults, mismatched, corrupt)
+		}
+		if mismatched+corrupt > 0 {
+			return errFilesFailed
+		}
+		return nil
+	}
+
 	// Print final results
 	printStep(config, "Extraction complete!")
 
 	if !config.Quiet {
 		fmt.Printf("   Files extracted: %d/%d (%.1f%%)\n",
 			stats.ExtractedFiles, stats.TotalFiles, stats.SuccessRate)
+		if stats.SkippedFiles > 0 {
+			fmt.Printf("   Files skipped: %d\n", stats.SkippedFiles)
+		}
+		if config.Verbose && len(stats.MethodCounts) > 0 {
+			methods := make([]int, 0, len(stats.MethodCounts))
+			for method := range stats.MethodCounts {
+				methods = append(methods, int(method))
+			}
+			sort.Ints(methods)
+			fmt.Printf("   Compression methods:\n")
+			for _, method := range methods {
+				fmt.Printf("     %-10s %d\n", ipf.MethodName(uint16(method)), stats.MethodCounts[uint16(method)])
+			}
+		}
 		fmt.Printf("   Total size: %.1f MB\n", float64(stats.TotalSize)/1024/1024)
 		fmt.Printf("   Extraction time: %.2fs\n", extractTime.Seconds())
 		fmt.Printf("   Average speed: %.1f MB/s\n", stats.AverageSpeedMBs)
 
+		if len(stats.Warnings) > 0 {
+			fmt.Printf("   Warnings: %d\n", len(stats.Warnings))
+			if config.Verbose {
+				for _, warning := range stats.Warnings {
+					fmt.Printf("   - %s\n", warning)
+				}
+			}
+		}
+
 		if len(stats.Errors) > 0 && config.Verbose {
 			fmt.Printf("   Errors encountered: %d\n", len(stats.Errors))
 			for i, err := range stats.Errors {
-				if i >= 10 { // Limit error output
-					fmt.Printf("   ... and %d more errors\n", len(stats.Errors)-10)
+				if i >= maxReportedErrors {
+					fmt.Printf("   ... and %d more errors\n", len(stats.Errors)-maxReportedErrors)
 					break
 				}
 				fmt.Printf("   - %v\n", err)
@@ -323,9 +691,439 @@ func runExtraction(config *Config) error {
 		}
 	}
 
+	if config.CompareDir != "" {
+		if err := compareOutput(config); err != nil {
+			return err
+		}
+	}
+
+	if config.JSON {
+		report := newJSONReport(config, fileCount, successCount)
+		report.Timings = newJSONTimings(ipfReadTime, filenameReadTime, decryptTime, extractTime, time.Since(totalStartTime))
+		report.setStats(stats)
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	}
+
+	if config.KeepGoing && stats.ExtractedFiles < stats.TotalFiles {
+		if !config.JSON {
+			printFailureSummary(reader, extractionResults)
+		}
+		return errFilesFailed
+	}
+
+	return nil
+}
+
+// maxReportedErrors caps the errors listed in the verbose report and the JSON summary
+const maxReportedErrors = 10
+
+// jsonReport is the summary printed by -json
+type jsonReport struct {
+	Input          string      `json:"input"`
+	Output         string      `json:"output,omitempty"`
+	Files          int         `json:"files"`
+	DecryptedNames int64       `json:"decrypted_names"`
+	Validated      bool        `json:"validated,omitempty"`
+	Timings        jsonTimings `json:"timings"`
+	Stats          *jsonStats  `json:"stats,omitempty"`
+	CRCMismatches  int         `json:"crc_mismatches,omitempty"` // -verify only
+	Corrupt        int         `json:"corrupt,omitempty"`        // -verify only
+	ErrorCount     int         `json:"error_count"`
+	Errors         []string    `json:"errors,omitempty"` // The first maxReportedErrors errors
+}
+
+// jsonTimings holds the duration of each phase in seconds
+type jsonTimings struct {
+	StructureRead float64 `json:"structure_read"`
+	FilenameRead  float64 `json:"filename_read"`
+	Decrypt       float64 `json:"decrypt"`
+	Extract       float64 `json:"extract"`
+	Total         float64 `json:"total"`
+}
+
+// jsonStats mirrors ipf.ExtractionStats with method names as keys
+type jsonStats struct {
+	TotalFiles      int64          `json:"total_files"`
+	ExtractedFiles  int64          `json:"extracted_files"`
+	SkippedFiles    int64          `json:"skipped_files"`
+	TotalSize       int64          `json:"total_size"`
+	SuccessRate     float64        `json:"success_rate"`
+	AverageSpeedMBs float64        `json:"average_speed_mbs"`
+	MethodCounts    map[string]int `json:"method_counts"`
+	Warnings        []string       `json:"warnings,omitempty"`
+}
+
+func newJSONReport(config *Config, fileCount int, decrypted int64) *jsonReport {
+	report := &jsonReport{
+		Input:          config.InputFile,
+		Files:          fileCount,
+		DecryptedNames: decrypted,
+	}
+	if !config.ValidateOnly {
+		report.Output = config.OutputDir
+	}
+	return report
+}
+
+func newJSONTimings(structureRead, filenameRead, decrypt, extract, total time.Duration) jsonTimings {
+	return jsonTimings{
+		StructureRead: structureRead.Seconds(),
+		FilenameRead:  filenameRead.Seconds(),
+		Decrypt:       decrypt.Seconds(),
+		Extract:       extract.Seconds(),
+		Total:         total.Seconds(),
+	}
+}
+
+// setStats records the extraction statistics and the first errors
+func (r *jsonReport) setStats(stats ipf.ExtractionStats) {
+	methodCounts := make(map[string]int, len(stats.MethodCounts))
+	for method, count := range stats.MethodCounts {
+		methodCounts[ipf.MethodName(method)] += count
+	}
+
+	r.Stats = &jsonStats{
+		TotalFiles:      stats.TotalFiles,
+		ExtractedFiles:  stats.ExtractedFiles,
+		SkippedFiles:    stats.SkippedFiles,
+		TotalSize:       stats.TotalSize,
+		SuccessRate:     stats.SuccessRate,
+		AverageSpeedMBs: stats.AverageSpeedMBs,
+		MethodCounts:    methodCounts,
+		Warnings:        stats.Warnings,
+	}
+
+	r.ErrorCount = len(stats.Errors)
+	for i, err := range stats.Errors {
+		if i >= maxReportedErrors {
+			break
+		}
+		r.Errors = append(r.Errors, err.Error())
+	}
+}
+
+// printJSON writes report to stdout as indented JSON
+func printJSON(report *jsonReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON report: %w", err)
+	}
+	fmt.Println(string(data))
 	return nil
 }
 
+// countDamaged counts the -verify results whose data did not match its CRC32 or did not decode
+func countDamaged(results []ipf.ExtractionResult) (mismatched, corrupt int) {
+	for _, result := range results {
+		switch result.Integrity {
+		case ipf.IntegrityCRCMismatch:
+			mismatched++
+		case ipf.IntegrityCorrupt:
+			corrupt++
+		}
+	}
+	return mismatched, corrupt
+}
+
+// printVerification lists the damaged files found by -verify, followed by the totals
+func printVerification(reader *ipf.IPFReader, results []ipf.ExtractionResult, mismatched, corrupt int) {
+	verified := 0
+	for _, result := range results {
+		if result.Integrity == ipf.IntegrityOK {
+			verified++
+		}
+		if result.Integrity != ipf.IntegrityCRCMismatch && result.Integrity != ipf.IntegrityCorrupt {
+			continue
+		}
+
+		name := fmt.Sprintf("file %d", result.Index)
+		if fileInfo, err := reader.GetFileByIndex(result.Index); err == nil {
+			name = fileInfo.SafeFilename
+		}
+		fmt.Printf("   %s: %s (%v)\n", result.Integrity, name, result.Error)
+	}
+
+	fmt.Printf("Verified %d files: %d OK, %d CRC mismatches, %d corrupt\n", verified+mismatched+corrupt, verified, mismatched, corrupt)
+}
+
+// printFailureSummary lists every failed file, grouped by the kind of failure
+func printFailureSummary(reader *ipf.IPFReader, results []ipf.ExtractionResult) {
+	groups := make(map[string][]string)
+	for _, result := range results {
+		if result.Success || result.Skipped {
+			continue
+		}
+
+		name := fmt.Sprintf("file %d", result.Index)
+		if fileInfo, err := reader.GetFileByIndex(result.Index); err == nil {
+			name = fileInfo.SafeFilename
+		}
+		if result.Error != nil {
+			name = fmt.Sprintf("%s: %v", name, result.Error)
+		}
+
+		kind := failureKind(result.Error)
+		groups[kind] = append(groups[kind], name)
+	}
+
+	kinds := make([]string, 0, len(groups))
+	for kind := range groups {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	fmt.Printf("\nFailure summary:\n")
+	for _, kind := range kinds {
+		fmt.Printf("   %s (%d):\n", kind, len(groups[kind]))
+		for _, name := range groups[kind] {
+			fmt.Printf("   - %s\n", name)
+		}
+	}
+}
+
+// failureKind buckets an extraction error by its cause
+func failureKind(err error) string {
+	if err == nil {
+		return "unknown"
+	}
+
+	var pathErr *os.PathError
+	switch {
+	case errors.Is(err, ipf.ErrPasswordVerification):
+		return "wrong password"
+	case errors.Is(err, ipf.ErrCRCMismatch), errors.Is(err, ipf.ErrSizeMismatch):
+		return "checksum mismatch"
+	case errors.Is(err, ipf.ErrUnsupportedMethod):
+		return "unsupported method"
+	case errors.Is(err, ipf.ErrCorruptData):
+		return "corrupt data"
+	case errors.Is(err, ipf.ErrPanic):
+		return "internal error"
+	case errors.Is(err, ipf.ErrUnsafePath):
+		return "unsafe path"
+	case errors.As(err, &pathErr):
+		return "file system"
+	default:
+		return "other"
+	}
+}
+
+// exportZip writes the archive into a standard ZIP file at path
+func exportZip(ctx context.Context, extractor *ipf.ConcurrentExtractor, path string, password []byte) ([]ipf.ExtractionResult, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ZIP file: %w", err)
+	}
+
+	results, err := extractor.ExportZip(ctx, file, password)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close ZIP file: %w", closeErr)
+	}
+	return results, err
+}
+
+// compareOutput verifies the extracted tree against the reference directory
+func compareOutput(config *Config) error {
+	printStep(config, "Comparing against reference directory...")
+
+	differences, err := compare.CompareTree(config.CompareDir, config.OutputDir)
+	if err != nil {
+		return fmt.Errorf("failed to compare output: %w", err)
+	}
+
+	if len(differences) == 0 {
+		printStep(config, "   Output matches reference directory")
+		return nil
+	}
+
+	if !config.JSON {
+		for _, difference := range differences {
+			fmt.Printf("   %s\n", difference)
+		}
+	}
+	return fmt.Errorf("output differs from %s in %d files", config.CompareDir, len(differences))
+}
+
+// printEncodings prints how many filenames each encoding decoded, most common first
+func printEncodings(breakdown map[string]int) {
+	encodings := make([]string, 0, len(breakdown))
+	for encoding := range breakdown {
+		encodings = append(encodings, encoding)
+	}
+	sort.Slice(encodings, func(i, j int) bool {
+		if breakdown[encodings[i]] != breakdown[encodings[j]] {
+			return breakdown[encodings[i]] > breakdown[encodings[j]]
+		}
+		return encodings[i] < encodings[j]
+	})
+
+	parts := make([]string, len(encodings))
+	for i, encoding := range encodings {
+		parts[i] = fmt.Sprintf("%s %d", encoding, breakdown[encoding])
+	}
+	fmt.Printf("   Filename encodings: %s\n", strings.Join(parts, ", "))
+}
+
+// detectPassword picks the password from the -password-list candidates by the encryption header
+// check byte, falling back to the rate at which each candidate decrypts filenames
+func detectPassword(config *Config, reader *ipf.IPFReader) ([]byte, error) {
+	candidates, err := readPasswordList(config.PasswordList)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := ipf.DetectPassword(reader, candidates)
+	if err == nil {
+		if !config.Quiet {
+			fmt.Printf("   Detected password %x\n", password)
+		}
+		return password, nil
+	}
+	if !errors.Is(err, ipf.ErrNoPasswordMatch) {
+		return nil, err
+	}
+
+	password, score, err := ipf.RecoverPassword(reader.GetFileInfos(), candidates)
+	if err != nil {
+		return nil, err
+	}
+	if !config.Quiet {
+		fmt.Printf("   No header check match; best candidate %x decrypts %.0f%% of sampled filenames\n", password, score*100)
+	}
+	return password, nil
+}
+
+// readPasswordList reads candidate passwords, one per line. Lines starting with "hex:" are
+// hex-decoded; blank lines and lines starting with # are ignored.
+func readPasswordList(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read password list: %w", err)
+	}
+
+	var candidates [][]byte
+	for lineNumber, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		candidate, err := zipcipher.ParsePassword(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid password on line %d of %s: %w", lineNumber+1, path, err)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("password list %s is empty", path)
+	}
+	return candidates, nil
+}
+
+// parseSize parses a byte size with an optional B/KB/MB/GB suffix (binary multiples)
+func parseSize(value string) (uint64, error) {
+	value = strings.TrimSpace(strings.ToUpper(value))
+	if value == "" {
+		return 0, nil
+	}
+
+	multiplier := uint64(1)
+	for _, unit := range []struct {
+		suffix string
+		factor uint64
+	}{
+		{"GB", 1024 * 1024 * 1024},
+		{"MB", 1024 * 1024},
+		{"KB", 1024},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(value, unit.suffix) {
+			multiplier = unit.factor
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			break
+		}
+	}
+
+	number, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+
+	return number * multiplier, nil
+}
+
+// progressBarWidth and progressNameWidth are the widths of the bar and of the file name shown after it
+const (
+	progressBarWidth  = 30
+	progressNameWidth = 40
+)
+
+// progressInterval is the minimum time between redraws of the progress bar
+const progressInterval = 100 * time.Millisecond
+
+// progressBar draws extraction progress on one terminal line. update is called from the
+// extraction workers; a worker that finds another one drawing skips its redraw rather than wait.
+type progressBar struct {
+	mu       sync.Mutex // Held while drawing
+	lastDraw time.Time
+	done     atomic.Int64
+	total    atomic.Int64
+}
+
+func (p *progressBar) update(done, total int, current string) {
+	// Workers finish out of order, so keep the highest count seen. It is recorded outside the
+	// lock so an update arriving mid-redraw is not lost, only its redraw is skipped.
+	p.total.Store(int64(total))
+	for {
+		seen := p.done.Load()
+		if int64(done) <= seen || p.done.CompareAndSwap(seen, int64(done)) {
+			break
+		}
+	}
+
+	if !p.mu.TryLock() {
+		return
+	}
+	defer p.mu.Unlock()
+
+	if p.done.Load() < p.total.Load() && time.Since(p.lastDraw) < progressInterval {
+		return
+	}
+	p.lastDraw = time.Now()
+	p.draw(current)
+}
+
+// finish draws the final state and ends the progress line
+func (p *progressBar) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.total.Load() > 0 {
+		p.draw("")
+		fmt.Println()
+	}
+}
+
+func (p *progressBar) draw(current string) {
+	done, total := int(p.done.Load()), int(p.total.Load())
+	filled := 0
+	if total > 0 {
+		filled = min(done, total) * progressBarWidth / total
+	}
+	if len(current) > progressNameWidth {
+		current = "..." + current[len(current)-progressNameWidth+3:]
+	}
+	fmt.Printf("\r   [%s%s] %d/%d %-*s", strings.Repeat("#", filled), strings.Repeat(".", progressBarWidth-filled), done, total, progressNameWidth, current)
+}
+
+// isTerminal reports whether f is a character device, so that redrawing a line with \r works
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 // printStep prints a step message if not in quiet mode
 func printStep(config *Config, message string) {
 	if !config.Quiet {
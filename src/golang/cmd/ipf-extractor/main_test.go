@@ -0,0 +1,241 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// testConfig returns the configuration parseFlags builds without flags, reading archive
+// and extracting into a fresh directory
+func testConfig(t *testing.T, archive []byte) *Config {
+	t.Helper()
+
+	return &Config{
+		InputFile:    ipftest.WriteFile(t, t.TempDir(), "test.ipf", archive),
+		OutputDir:    filepath.Join(t.TempDir(), "out"),
+		WorkerCount:  2,
+		BatchSize:    1000,
+		Quiet:        true,
+		HexdumpIndex: -1,
+		Password:     zipcipher.GetIPFPassword(),
+	}
+}
+
+// readTree returns the files under dir keyed by slash-separated path
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", dir, err)
+	}
+	return files
+}
+
+// equalTrees reports the differences between two trees from readTree
+func equalTrees(t *testing.T, got, want map[string]string) {
+	t.Helper()
+
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("%s = %q, want %q", name, got[name], content)
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("unexpected %s", name)
+		}
+	}
+}
+
+func TestJunkPaths(t *testing.T) {
+	archive := ipftest.Build(t, zipcipher.GetIPFPassword(),
+		ipftest.Entry{Name: "a/b/x.txt", Data: []byte("x")},
+		ipftest.Entry{Name: "c/y.txt", Data: []byte("y")},
+		ipftest.Entry{Name: "a/z.txt", Data: []byte("first z")},
+		ipftest.Entry{Name: "b/z.txt", Data: []byte("second z")},
+	)
+
+	tests := []struct {
+		name      string
+		junkPaths bool
+		want      map[string]string
+	}{
+		{
+			name: "paths kept",
+			want: map[string]string{"a/b/x.txt": "x", "c/y.txt": "y", "a/z.txt": "first z", "b/z.txt": "second z"},
+		},
+		{
+			name:      "paths junked",
+			junkPaths: true,
+			want:      map[string]string{"x.txt": "x", "y.txt": "y", "z.txt": "first z", "z_3.txt": "second z"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(t, archive)
+			config.JunkPaths = tt.junkPaths
+			if err := runExtraction(config); err != nil {
+				t.Fatalf("runExtraction: %v", err)
+			}
+			equalTrees(t, readTree(t, config.OutputDir), tt.want)
+		})
+	}
+}
+
+func TestOnConflictPolicies(t *testing.T) {
+	archive := ipftest.Build(t, zipcipher.GetIPFPassword(),
+		ipftest.Entry{Name: "old.txt", Data: []byte("archived old")},
+		ipftest.Entry{Name: "new.txt", Data: []byte("archived new")},
+	)
+	// The entries carry an ipftest.DefaultModDate timestamp, between these two
+	oldTime := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	newTime := time.Date(2090, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		flag   string
+		policy ipf.OverwritePolicy
+		want   map[string]string
+	}{
+		{"overwrite", ipf.OverwriteAlways, map[string]string{"old.txt": "archived old", "new.txt": "archived new"}},
+		{"skip", ipf.OverwriteSkip, map[string]string{"old.txt": "existing old", "new.txt": "existing new"}},
+		{"newer", ipf.OverwriteIfNewer, map[string]string{"old.txt": "archived old", "new.txt": "existing new"}},
+		{"changed", ipf.OverwriteIfChanged, map[string]string{"old.txt": "archived old", "new.txt": "archived new"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			policy, err := ipf.ParseOverwritePolicy(tt.flag)
+			if err != nil || policy != tt.policy {
+				t.Fatalf("ParseOverwritePolicy(%q) = %v, %v, want %v", tt.flag, policy, err, tt.policy)
+			}
+
+			config := testConfig(t, archive)
+			config.OnConflict = policy
+			if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, modTime := range map[string]time.Time{"old.txt": oldTime, "new.txt": newTime} {
+				path := filepath.Join(config.OutputDir, name)
+				if err := os.WriteFile(path, []byte("existing "+strings.TrimSuffix(name, ".txt")), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := runExtraction(config); err != nil {
+				t.Fatalf("runExtraction: %v", err)
+			}
+			equalTrees(t, readTree(t, config.OutputDir), tt.want)
+		})
+	}
+
+	if _, err := ipf.ParseOverwritePolicy("sometimes"); err == nil {
+		t.Errorf("ParseOverwritePolicy accepted an invalid policy")
+	}
+}
+
+func TestPasswordList(t *testing.T) {
+	regional := []byte{0x52, 0x00, 0xfe, 0x4b}
+	archive := ipftest.Build(t, regional,
+		ipftest.Entry{Name: "a.txt", Data: []byte("regional a")},
+		ipftest.Entry{Name: "dir/b.txt", Data: []byte("regional b")},
+		ipftest.Entry{Name: "c.txt", Data: []byte("regional c")},
+	)
+
+	tests := []struct {
+		name    string
+		list    string
+		wantErr bool
+	}{
+		{"hex entry matches", "# candidates\nwrong\n\nhex:5200fe4b\nalso wrong\n", false},
+		{"no entry matches", "wrong\nalso wrong\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(t, archive)
+			config.PasswordList = filepath.Join(t.TempDir(), "passwords.txt")
+			if err := os.WriteFile(config.PasswordList, []byte(tt.list), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := runExtraction(config)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("runExtraction succeeded without a matching password")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runExtraction: %v", err)
+			}
+			equalTrees(t, readTree(t, config.OutputDir), map[string]string{
+				"a.txt":     "regional a",
+				"dir/b.txt": "regional b",
+				"c.txt":     "regional c",
+			})
+		})
+	}
+}
+
+func TestKeepGoing(t *testing.T) {
+	archive := ipftest.Build(t, zipcipher.GetIPFPassword(),
+		ipftest.Entry{Name: "good/a.txt", Data: []byte("good a")},
+		ipftest.Entry{Name: "bad/crc.txt", Data: []byte("flipped byte breaks the CRC32")},
+		ipftest.Entry{Name: "good/b.xml", Data: []byte(strings.Repeat("<b/>", 50)), Method: zip.Deflate},
+		ipftest.Entry{Name: "bad/method.bin", Data: []byte("unsupported method"), Method: 12},
+		ipftest.Entry{Name: "bad/deflate.xml", Data: []byte(strings.Repeat("<d/>", 50)), Method: zip.Deflate},
+		ipftest.Entry{Name: "good/c.bin", Data: []byte{0, 1, 2}},
+	)
+	// Flip a byte of the stored entry and garble the deflate stream of the other
+	archive[ipftest.DataOffset(t, archive, 1)+12+3] ^= 0x20
+	deflateStart := ipftest.DataOffset(t, archive, 4) + 12
+	for i := 0; i < 4; i++ {
+		archive[deflateStart+i] ^= 0xff
+	}
+	want := map[string]string{
+		"good/a.txt": "good a",
+		"good/b.xml": strings.Repeat("<b/>", 50),
+		"good/c.bin": "\x00\x01\x02",
+	}
+
+	for _, keepGoing := range []bool{false, true} {
+		t.Run(fmt.Sprintf("keep-going %t", keepGoing), func(t *testing.T) {
+			config := testConfig(t, archive)
+			config.KeepGoing = keepGoing
+
+			err := runExtraction(config)
+			if keepGoing && !errors.Is(err, errFilesFailed) {
+				t.Errorf("runExtraction = %v, want errFilesFailed", err)
+			}
+			if !keepGoing && err != nil {
+				t.Errorf("runExtraction = %v, want nil", err)
+			}
+			equalTrees(t, readTree(t, config.OutputDir), want)
+		})
+	}
+}
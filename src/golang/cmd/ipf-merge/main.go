@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/optimize"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+func main() {
+	output := flag.String("output", "", "Merged IPF file path (required)")
+	passwordValue := flag.String("password", "", "Archive password (raw text, or hex:... for binary; default: built-in IPF key)")
+	flag.Parse()
+
+	if *output == "" || len(flag.Args()) < 1 {
+		fmt.Println("Usage: ipf-merge --output <merged.ipf> [--password key] <base.ipf> <patch.ipf>...")
+		fmt.Println("Later inputs override files with the same name in earlier ones; entry data is copied without recompression")
+		os.Exit(1)
+	}
+
+	password, err := zipcipher.ParsePassword(*passwordValue)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, inputFile := range flag.Args() {
+		if _, err := os.Stat(inputFile); os.IsNotExist(err) {
+			fmt.Printf("Error: File not found: %s\n", inputFile)
+			os.Exit(1)
+		}
+	}
+
+	stats, err := optimize.Merge(*output, flag.Args(), password)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Merge: %s\n", stats.String())
+
+	fmt.Printf("Merged %d archives into %s\n", len(flag.Args()), *output)
+}
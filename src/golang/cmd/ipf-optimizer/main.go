@@ -6,28 +6,100 @@ import (
 	"os"
 
 	"github.com/joao-paulo-santos/GE-Library/pkg/optimize"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
 )
 
 func main() {
 	createBackup := flag.Bool("backup", false, "Create backup file (.ipf.bak)")
+	scrub := flag.Bool("scrub", false, "Verify the CRC32 of every retained file while copying (slower)")
+	versionMadeBy := flag.Int("version-made-by", -1, "Force this version-made-by value on every entry (default: keep original)")
+	genPurpose := flag.Int("gen-purpose", -1, "Force this general purpose flag value on every entry (default: keep original)")
+	comment := flag.String("comment", "", "Replace the archive comment (use --comment \"\" to remove it; default: keep original)")
+	mergeOutput := flag.String("merge", "", "Merge all input IPFs into this file (later inputs override earlier ones)")
+	dryRun := flag.Bool("dry-run", false, "Report what deduplication would remove without modifying the file")
+	passwordValue := flag.String("password", "", "Archive password (raw text, or hex:... for binary; default: built-in IPF key)")
 	flag.Parse()
 
+	password, err := zipcipher.ParsePassword(*passwordValue)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	if len(flag.Args()) < 1 {
-		fmt.Println("Usage: ipf-optimizer [--backup] <input.ipf>")
+		fmt.Println("Usage: ipf-optimizer [--backup] [--scrub] [--version-made-by N --gen-purpose N] [--comment text] [--password key] [--dry-run] <input.ipf>")
+		fmt.Println("       ipf-optimizer --merge <output.ipf> <base.ipf> <patch.ipf>...")
 		os.Exit(1)
 	}
 
+	for _, inputFile := range flag.Args() {
+		if _, err := os.Stat(inputFile); os.IsNotExist(err) {
+			fmt.Printf("Error: File not found: %s\n", inputFile)
+			os.Exit(1)
+		}
+	}
+
+	if *mergeOutput != "" {
+		stats, err := optimize.Merge(*mergeOutput, flag.Args(), password)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Merge: %s\n", stats.String())
+
+		fmt.Println("Merge complete!")
+		return
+	}
+
 	inputFile := flag.Args()[0]
 
-	if _, err := os.Stat(inputFile); os.IsNotExist(err) {
-		fmt.Printf("Error: File not found: %s\n", inputFile)
-		os.Exit(1)
+	if *dryRun {
+		report, err := optimize.AnalyzeIPFWithPassword(inputFile, password)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, name := range report.RemovedNames {
+			fmt.Printf("Would remove: %s\n", name)
+		}
+		fmt.Printf("Deduplication: %s\n", report.Stats.String())
+		fmt.Printf("Would keep %d files and reclaim %d bytes\n", report.FinalFiles, report.ReclaimedBytes)
+		return
+	}
+
+	opts := optimize.OptimizeOptions{
+		CreateBackup: *createBackup,
+		Scrub:        *scrub,
+		Password:     password,
 	}
 
-	if err := optimize.OptimizeIPF(inputFile, *createBackup); err != nil {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "comment" {
+			opts.Comment = comment
+		}
+	})
+
+	if *versionMadeBy >= 0 || *genPurpose >= 0 {
+		if *versionMadeBy < 0 || *versionMadeBy > 0xFFFF || *genPurpose < 0 || *genPurpose > 0xFFFF {
+			fmt.Println("Error: --version-made-by and --gen-purpose must both be set to values between 0 and 65535")
+			os.Exit(1)
+		}
+		opts.Override = &optimize.HeaderOverride{
+			VersionMadeBy: uint16(*versionMadeBy),
+			GenPurpose:    uint16(*genPurpose),
+		}
+	}
+
+	report, err := optimize.OptimizeIPFWithReport(inputFile, opts)
+	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
+	if report.RepairedEntries > 0 {
+		fmt.Printf("Repaired sizes of %d entries from data descriptors\n", report.RepairedEntries)
+	}
 
 	fmt.Println("Optimization complete!")
 }
@@ -1,3 +1,8 @@
 module github.com/joao-paulo-santos/GE-Library
 
 go 1.22
+
+require (
+	golang.org/x/sys v0.30.0
+	golang.org/x/text v0.21.0
+)
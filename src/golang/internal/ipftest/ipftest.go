@@ -0,0 +1,205 @@
+// Package ipftest builds small IPF archives in memory for tests
+package ipftest
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// Default MS-DOS timestamp given to entries that do not set one
+const (
+	DefaultModTime = 0x6a21
+	DefaultModDate = 0x5b50
+)
+
+// Entry describes one file to store in a test archive
+type Entry struct {
+	Name       string
+	Data       []byte
+	Method     uint16 // zip.Store or zip.Deflate
+	Plain      bool   // Store the name and data unencrypted, as in a standard ZIP
+	Descriptor bool   // Write the sizes and CRC32 in a data descriptor after the data
+	ModTime    uint16 // MS-DOS time; DefaultModTime when both ModTime and ModDate are zero
+	ModDate    uint16 // MS-DOS date
+	Extra      []byte
+	// CreatorVersion and ExternalAttrs are copied to the central directory as they are
+	CreatorVersion uint16
+	ExternalAttrs  uint32
+}
+
+// Build returns an archive holding entries in order, with names and data encrypted the way
+// IPF files are: the name with the stream cipher and the data behind a 12-byte header
+func Build(tb testing.TB, password []byte, entries ...Entry) []byte {
+	tb.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		modTime, modDate := entry.ModTime, entry.ModDate
+		if modTime == 0 && modDate == 0 {
+			modTime, modDate = DefaultModTime, DefaultModDate
+		}
+
+		data := entry.Data
+		if entry.Method == zip.Deflate {
+			data = deflate(tb, data)
+		}
+
+		name := entry.Name
+		var flags uint16
+		if !entry.Plain {
+			cipher := &zipcipher.ZipCipher{}
+			cipher.InitKeys(password)
+			name = string(cipher.EncryptData([]byte(name)))
+
+			encrypted, err := zipcipher.EncryptPayload(data, password, byte(modTime>>8))
+			if err != nil {
+				tb.Fatalf("failed to encrypt %s: %v", entry.Name, err)
+			}
+			data = encrypted
+			flags |= 0x1
+		}
+		if entry.Descriptor {
+			flags |= 0x8
+		}
+
+		// Encrypted names are patched in afterwards, since archive/zip treats names ending in a
+		// slash as directories and refuses their data
+		names[i] = name
+		header := &zip.FileHeader{
+			Name:               strings.Repeat("_", len(name)),
+			NonUTF8:            true,
+			Method:             entry.Method,
+			Flags:              flags,
+			ModifiedTime:       modTime,
+			ModifiedDate:       modDate,
+			CRC32:              crc32.ChecksumIEEE(entry.Data),
+			CompressedSize64:   uint64(len(data)),
+			UncompressedSize64: uint64(len(entry.Data)),
+			Extra:              entry.Extra,
+			CreatorVersion:     entry.CreatorVersion,
+			ExternalAttrs:      entry.ExternalAttrs,
+		}
+		fw, err := w.CreateRaw(header)
+		if err != nil {
+			tb.Fatalf("failed to add %s: %v", entry.Name, err)
+		}
+		if _, err := fw.Write(data); err != nil {
+			tb.Fatalf("failed to write %s: %v", entry.Name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		tb.Fatalf("failed to finish archive: %v", err)
+	}
+
+	archive := buf.Bytes()
+	for i, record := range CentralRecords(tb, archive) {
+		offset := binary.LittleEndian.Uint32(record[42:])
+		copy(archive[offset+30:], names[i])
+		copy(record[46:], names[i])
+	}
+	return archive
+}
+
+// deflate compresses data the way the creator does
+func deflate(tb testing.TB, data []byte) []byte {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		tb.Fatalf("failed to create deflater: %v", err)
+	}
+	fw.Write(data)
+	if err := fw.Close(); err != nil {
+		tb.Fatalf("failed to deflate: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// WriteFile stores archive as name in dir and returns its path
+func WriteFile(tb testing.TB, dir, name string, archive []byte) string {
+	tb.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, archive, 0644); err != nil {
+		tb.Fatalf("failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+// CentralDirectory returns the offset and size of the central directory and the offset of the
+// end of central directory record
+func CentralDirectory(tb testing.TB, archive []byte) (offset, size, eocd int) {
+	tb.Helper()
+
+	for i := len(archive) - 22; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(archive[i:]) == 0x06054b50 {
+			size := int(binary.LittleEndian.Uint32(archive[i+12:]))
+			offset := int(binary.LittleEndian.Uint32(archive[i+16:]))
+			return offset, size, i
+		}
+	}
+	tb.Fatalf("no end of central directory record")
+	return 0, 0, 0
+}
+
+// CentralRecords splits the central directory of archive into its file header records
+func CentralRecords(tb testing.TB, archive []byte) [][]byte {
+	tb.Helper()
+
+	offset, size, _ := CentralDirectory(tb, archive)
+	var records [][]byte
+	for pos := offset; pos < offset+size; {
+		if binary.LittleEndian.Uint32(archive[pos:]) != 0x02014b50 {
+			tb.Fatalf("no central directory header at %d", pos)
+		}
+		length := 46 + int(binary.LittleEndian.Uint16(archive[pos+28:])) +
+			int(binary.LittleEndian.Uint16(archive[pos+30:])) +
+			int(binary.LittleEndian.Uint16(archive[pos+32:]))
+		records = append(records, archive[pos:pos+length])
+		pos += length
+	}
+	return records
+}
+
+// ReorderCentralDirectory returns a copy of archive whose central directory lists the entries
+// in order, so that entry i of the result is entry order[i] of archive. The data is not moved,
+// leaving the local headers out of index order.
+func ReorderCentralDirectory(tb testing.TB, archive []byte, order []int) []byte {
+	tb.Helper()
+
+	records := CentralRecords(tb, archive)
+	if len(order) != len(records) {
+		tb.Fatalf("order has %d entries, archive has %d", len(order), len(records))
+	}
+
+	offset, _, _ := CentralDirectory(tb, archive)
+	result := append([]byte(nil), archive...)
+	pos := offset
+	for _, i := range order {
+		pos += copy(result[pos:], records[i])
+	}
+	return result
+}
+
+// DataOffset returns the offset of the stored data of entry index, which for encrypted entries
+// starts with the 12-byte encryption header
+func DataOffset(tb testing.TB, archive []byte, index int) int {
+	tb.Helper()
+
+	records := CentralRecords(tb, archive)
+	if index >= len(records) {
+		tb.Fatalf("entry %d out of range (%d entries)", index, len(records))
+	}
+	offset := int(binary.LittleEndian.Uint32(records[index][42:]))
+	return offset + 30 + int(binary.LittleEndian.Uint16(archive[offset+26:])) + int(binary.LittleEndian.Uint16(archive[offset+28:]))
+}
@@ -0,0 +1,166 @@
+package compare
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// DifferenceKind classifies a difference between two directory trees
+type DifferenceKind int
+
+const (
+	// Missing means the file exists in the reference tree but not in the compared tree
+	Missing DifferenceKind = iota
+	// Extra means the file exists in the compared tree but not in the reference tree
+	Extra
+	// SizeMismatch means both files exist but their sizes differ
+	SizeMismatch
+	// ContentMismatch means both files have the same size but different SHA-256 hashes
+	ContentMismatch
+)
+
+// String returns a human-readable name for the difference kind
+func (k DifferenceKind) String() string {
+	switch k {
+	case Missing:
+		return "missing"
+	case Extra:
+		return "extra"
+	case SizeMismatch:
+		return "size mismatch"
+	case ContentMismatch:
+		return "content mismatch"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(k))
+	}
+}
+
+// Difference describes a single file that differs between two trees
+type Difference struct {
+	Path   string // Slash-separated path relative to the tree roots
+	Kind   DifferenceKind
+	Detail string
+}
+
+// String formats the difference for reports
+func (d Difference) String() string {
+	if d.Detail == "" {
+		return fmt.Sprintf("%s: %s", d.Kind, d.Path)
+	}
+	return fmt.Sprintf("%s: %s (%s)", d.Kind, d.Path, d.Detail)
+}
+
+// CompareTree compares the regular files under reference directory a against directory b by size
+// and SHA-256. Differences are returned sorted by path; an empty slice means the trees match.
+func CompareTree(a, b string) ([]Difference, error) {
+	reference, err := listFiles(a)
+	if err != nil {
+		return nil, err
+	}
+	compared, err := listFiles(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var differences []Difference
+	for path, refSize := range reference {
+		size, ok := compared[path]
+		if !ok {
+			differences = append(differences, Difference{Path: path, Kind: Missing})
+			continue
+		}
+
+		if size != refSize {
+			differences = append(differences, Difference{
+				Path:   path,
+				Kind:   SizeMismatch,
+				Detail: fmt.Sprintf("expected %d bytes, got %d", refSize, size),
+			})
+			continue
+		}
+
+		refHash, err := hashFile(filepath.Join(a, filepath.FromSlash(path)))
+		if err != nil {
+			return nil, err
+		}
+		hash, err := hashFile(filepath.Join(b, filepath.FromSlash(path)))
+		if err != nil {
+			return nil, err
+		}
+		if refHash != hash {
+			differences = append(differences, Difference{
+				Path:   path,
+				Kind:   ContentMismatch,
+				Detail: fmt.Sprintf("expected sha256 %x, got %x", refHash, hash),
+			})
+		}
+	}
+
+	for path := range compared {
+		if _, ok := reference[path]; !ok {
+			differences = append(differences, Difference{Path: path, Kind: Extra})
+		}
+	}
+
+	sort.Slice(differences, func(i, j int) bool {
+		return differences[i].Path < differences[j].Path
+	})
+
+	return differences, nil
+}
+
+// listFiles maps the slash-separated relative path of every regular file under root to its size
+func listFiles(root string) (map[string]int64, error) {
+	files := make(map[string]int64)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(relPath)] = info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk directory %s: %w", root, err)
+	}
+
+	return files, nil
+}
+
+// hashFile computes the SHA-256 of a file's contents
+func hashFile(path string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+
+	file, err := os.Open(path)
+	if err != nil {
+		return sum, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return sum, fmt.Errorf("failed to hash file %s: %w", path, err)
+	}
+
+	copy(sum[:], hasher.Sum(nil))
+	return sum, nil
+}
@@ -0,0 +1,95 @@
+package compare
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTree creates the files under dir from slash-separated paths
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCompareTree(t *testing.T) {
+	reference := map[string]string{
+		"a.txt":         "alpha",
+		"dir/b.txt":     "bravo",
+		"dir/sub/c.txt": "charlie",
+	}
+
+	tests := []struct {
+		name    string
+		changes map[string]string // Files written over a copy of reference; "" removes the file
+		want    []Difference
+	}{
+		{name: "identical"},
+		{
+			name:    "missing file",
+			changes: map[string]string{"dir/b.txt": ""},
+			want:    []Difference{{Path: "dir/b.txt", Kind: Missing}},
+		},
+		{
+			name:    "extra file",
+			changes: map[string]string{"dir/new.txt": "new"},
+			want:    []Difference{{Path: "dir/new.txt", Kind: Extra}},
+		},
+		{
+			name:    "size mismatch",
+			changes: map[string]string{"a.txt": "alphabet"},
+			want:    []Difference{{Path: "a.txt", Kind: SizeMismatch}},
+		},
+		{
+			name:    "content mismatch",
+			changes: map[string]string{"dir/sub/c.txt": "charlik"},
+			want:    []Difference{{Path: "dir/sub/c.txt", Kind: ContentMismatch}},
+		},
+		{
+			name:    "several differences sorted by path",
+			changes: map[string]string{"dir/sub/c.txt": "", "a.txt": "ALPHA", "b.txt": "b"},
+			want: []Difference{
+				{Path: "a.txt", Kind: ContentMismatch},
+				{Path: "b.txt", Kind: Extra},
+				{Path: "dir/sub/c.txt", Kind: Missing},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := t.TempDir(), t.TempDir()
+			writeTree(t, a, reference)
+			writeTree(t, b, reference)
+			for name, content := range tt.changes {
+				path := filepath.Join(b, filepath.FromSlash(name))
+				if content == "" {
+					os.Remove(path)
+					continue
+				}
+				writeTree(t, b, map[string]string{name: content})
+			}
+
+			got, err := CompareTree(a, b)
+			if err != nil {
+				t.Fatalf("CompareTree: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("CompareTree = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Path != tt.want[i].Path || got[i].Kind != tt.want[i].Kind {
+					t.Errorf("difference %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
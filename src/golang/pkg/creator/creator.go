@@ -2,25 +2,61 @@ package creator
 
 import (
 	"bytes"
-	"compress/flate"
 	"fmt"
-	"hash/crc32"
 	"io"
 	"os"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
 	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
 	"github.com/joao-paulo-santos/GE-Library/pkg/zipwriter"
+	"golang.org/x/text/unicode/norm"
+)
+
+type HostSystem uint8
+
+// Header values written for every new entry
+const (
+	versionNeeded = uint16(0x0014)
+	methodStore   = uint16(0x0000)
+	methodDeflate = uint16(0x0008)
+)
+
+const (
+	HostDOS  HostSystem = 0
+	HostUnix HostSystem = 3
 )
 
 type Creator struct {
 	RootDir          string
 	OutputFile       string
-	Password         []byte
+	Password         []byte // Key for names and data; NewCreator sets the default IPF password
 	GenPurpose       uint16
 	VersionMadeBy    uint16
 	CompressionLevel int
+	SkipEmpty        bool
+	SkippedEmpty     int
+	HostSystem       HostSystem
+	Source           Source   // Files to pack; defaults to a Walker over RootDir
+	StripSingleRoot  bool     // Drop the top-level directory when every file lives under the same one
+	IncludeEmptyDirs bool     // Store empty directories as zero-size entries ending in a slash
+	FollowSymlinks   bool     // Pack the targets of symbolic links; otherwise links are skipped
+	ExcludePatterns  []string // Globs for walked files and directories to leave out (see Walker.ExcludePatterns)
+	SkippedSymlinks  int      // Links the walker left out, reported after CreateIPF
+	NormalizeNames   bool     // Store names as NFC-normalized UTF-8 without a leading BOM
+	WorkerCount      int      // Files compressed in parallel (0 = one per CPU, 1 = sequential)
+
+	// Deterministic makes identical inputs produce byte-identical archives: every entry is
+	// stamped with FixedModTime (the MS-DOS epoch when zero) and the encryption header is derived
+	// from the entry's name and content instead of crypto/rand. The random header is what keeps
+	// two encryptions of the same data apart, so deterministic archives reveal which entries are
+	// identical across builds; with the well-known IPF password this gives away little else.
+	Deterministic bool
+	FixedModTime  time.Time
+
+	stripPrefix string
 }
 
 func NewCreator(rootDir, outputFile string, encrypt bool) *Creator {
@@ -37,318 +73,290 @@ func NewCreator(rootDir, outputFile string, encrypt bool) *Creator {
 		GenPurpose:       genPurpose,
 		VersionMadeBy:    0x0000,
 		CompressionLevel: 6,
+		HostSystem:       HostDOS,
 	}
 }
 
 func (c *Creator) CreateIPF() error {
-	walker := NewWalker(c.RootDir)
-	err := walker.Walk()
+	source, entries, err := c.listEntries()
 	if err != nil {
-		return fmt.Errorf("failed to walk directory: %w", err)
-	}
-
-	fileCount := walker.GetFileCount()
-	if fileCount == 0 {
-		return fmt.Errorf("no files found in directory")
-	}
-
-	sort.Slice(walker.FileInfos, func(i, j int) bool {
-		return walker.FileInfos[i].RelativePath < walker.FileInfos[j].RelativePath
-	})
-
-	if c.GenPurpose == 0x0000 {
-		return c.createPlainZIP(walker.FileInfos)
+		return err
 	}
-	return c.createEncryptedZIP(walker.FileInfos)
-}
 
-func (c *Creator) createPlainZIP(fileInfos []FileInfo) error {
 	outputFile, err := os.Create(c.OutputFile)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
 	defer outputFile.Close()
 
-	versionNeeded := uint16(0x0014)
-	genPurpose := uint16(0x0000)
-	method := uint16(0x0008)
-	extraLen := uint16(0x0000)
-	var extraField []byte
+	return c.writeArchive(newFileArchiveWriter(outputFile, 0), source, entries)
+}
 
-	var localHeaderOffsets []uint64
-	var centralDirEntries []centralDirEntry
+// WriteTo writes the archive to w instead of OutputFile, so it can go into a buffer, an HTTP
+// response or any other stream that cannot seek. Each entry is compressed into memory before
+// its local header is written, so the largest file must fit in memory; the bytes are the same
+// CreateIPF would write.
+func (c *Creator) WriteTo(w io.Writer) (int64, error) {
+	source, entries, err := c.listEntries()
+	if err != nil {
+		return 0, err
+	}
 
-	for _, fileInfo := range fileInfos {
-		data, err := os.ReadFile(fileInfo.Path)
-		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", fileInfo.Path, err)
-		}
+	out := &archiveWriter{w: w}
+	err = c.writeArchive(out, source, entries)
+	return out.offset, err
+}
 
-		crc32Val := crc32.ChecksumIEEE(data)
+// writeArchive writes entries followed by the central directory
+func (c *Creator) writeArchive(out *archiveWriter, source Source, entries []Entry) error {
+	written, err := c.writeEntries(out, source, entries)
+	if err != nil {
+		return err
+	}
+	return c.writeCentralDirectory(out, nil, written, "")
+}
 
-		uncompressedSize := uint64(len(data))
-		var compressedData []byte
-		var compressedSize uint64
+// listEntries returns the source and its files to pack sorted by name, applying SkipEmpty and
+// StripSingleRoot
+func (c *Creator) listEntries() (Source, []Entry, error) {
+	source := c.Source
+	var walker *Walker
+	if source == nil {
+		walker = NewWalker(c.RootDir)
+		walker.SkipEmpty = c.SkipEmpty
+		walker.IncludeEmptyDirs = c.IncludeEmptyDirs
+		walker.FollowSymlinks = c.FollowSymlinks
+		walker.ExcludePatterns = c.ExcludePatterns
+		source = walker
+	}
 
-		if c.CompressionLevel > 0 {
-			var buf bytes.Buffer
-			writer, err := flate.NewWriter(&buf, c.CompressionLevel)
-			if err != nil {
-				return fmt.Errorf("failed to create compressor: %w", err)
-			}
+	listed, err := source.List()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list source files: %w", err)
+	}
 
-			_, err = writer.Write(data)
-			if err != nil {
-				writer.Close()
-				return fmt.Errorf("failed to compress data: %w", err)
-			}
-			err = writer.Close()
-			if err != nil {
-				return fmt.Errorf("failed to close compressor: %w", err)
-			}
+	c.SkippedEmpty = 0
+	c.SkippedSymlinks = 0
+	if walker != nil {
+		c.SkippedEmpty = walker.SkippedEmpty
+		c.SkippedSymlinks = walker.SkippedSymlinks
+	}
 
-			compressedData = buf.Bytes()
-			compressedSize = uint64(len(compressedData))
-		} else {
-			compressedData = data
-			compressedSize = uncompressedSize
+	entries := make([]Entry, 0, len(listed))
+	for _, entry := range listed {
+		if c.SkipEmpty && entry.Size == 0 && !entry.Mode.IsDir() {
+			c.SkippedEmpty++
+			continue
 		}
+		entries = append(entries, entry)
+	}
 
-		filename := []byte(fileInfo.RelativePath)
-		filenameLen := uint16(len(filename))
-
-		modTime, modDate := timestampToMSDOS(time.Unix(fileInfo.ModTime, 0))
-
-		offset, err := outputFile.Seek(0, io.SeekCurrent)
-		if err != nil {
-			return fmt.Errorf("failed to get offset: %w", err)
-		}
+	if len(entries) == 0 {
+		return nil, nil, fmt.Errorf("no files found in source")
+	}
 
-		err = zipwriter.WriteLocalFileHeaderFromParams(
-			outputFile,
-			versionNeeded,
-			genPurpose,
-			method,
-			modTime,
-			modDate,
-			crc32Val,
-			compressedSize,
-			uncompressedSize,
-			filenameLen,
-			extraLen,
-			filename,
-			extraField,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to write local file header: %w", err)
+	c.stripPrefix = ""
+	if c.StripSingleRoot {
+		names := make([]string, len(entries))
+		for i, entry := range entries {
+			names[i] = entry.Name
 		}
-
-		if _, err := outputFile.Write(compressedData); err != nil {
-			return fmt.Errorf("failed to write compressed data: %w", err)
+		if root, ok := ipf.SingleRoot(names); ok {
+			c.stripPrefix = root + "/"
 		}
-
-		localHeaderOffsets = append(localHeaderOffsets, uint64(offset))
-		centralDirEntries = append(centralDirEntries, centralDirEntry{
-			modTime:          modTime,
-			modDate:          modDate,
-			crc32:            crc32Val,
-			compressedSize:   compressedSize,
-			uncompressedSize: uncompressedSize,
-			filenameLen:      filenameLen,
-			filename:         filename,
-		})
 	}
 
-	cdOffset, err := outputFile.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return fmt.Errorf("failed to get central directory offset: %w", err)
-	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
 
-	for i, entry := range centralDirEntries {
-		err = zipwriter.WriteCentralDirectoryEntryFromParams(
-			outputFile,
-			versionNeeded,
-			c.VersionMadeBy,
-			genPurpose,
-			method,
-			entry.modTime,
-			entry.modDate,
-			entry.crc32,
-			entry.compressedSize,
-			entry.uncompressedSize,
-			entry.filenameLen,
-			extraLen,
-			entry.filename,
-			extraField,
-			localHeaderOffsets[i],
-		)
-		if err != nil {
-			return fmt.Errorf("failed to write central directory entry: %w", err)
+	if c.NormalizeNames {
+		if err := c.checkNormalizedNames(entries); err != nil {
+			return nil, nil, err
 		}
 	}
 
-	cdEndOffset, err := outputFile.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return fmt.Errorf("failed to get central directory end offset: %w", err)
-	}
-
-	cdSize := uint64(cdEndOffset - cdOffset)
+	return source, entries, nil
+}
 
-	err = zipwriter.WriteEndOfCentralDirectory(
-		outputFile,
-		uint64(cdOffset),
-		cdSize,
-		uint16(len(centralDirEntries)),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to write end of central directory: %w", err)
+// checkNormalizedNames fails when two distinct source names normalize to the same archive name,
+// e.g. composed and decomposed spellings, or names differing only in their invalid UTF-8 bytes;
+// packing both would leave two entries readers cannot tell apart
+func (c *Creator) checkNormalizedNames(entries []Entry) error {
+	seen := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		name := c.archiveName(entry)
+		if previous, ok := seen[name]; ok && previous != entry.Name {
+			return fmt.Errorf("source names %+q and %+q both normalize to %q", previous, entry.Name, name)
+		}
+		seen[name] = entry.Name
 	}
-
 	return nil
 }
 
-func (c *Creator) createEncryptedZIP(fileInfos []FileInfo) error {
-	outputFile, err := os.Create(c.OutputFile)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+// encrypted reports whether names and data are encrypted; a zero GenPurpose writes a plain ZIP
+func (c *Creator) encrypted() bool {
+	return c.GenPurpose != 0x0000
+}
+
+// writeEntries writes the local headers and data of entries at the current offset of out and
+// returns their central directory records
+func (c *Creator) writeEntries(out *archiveWriter, source Source, entries []Entry) ([]centralDirEntry, error) {
+	var password []byte
+	if c.encrypted() {
+		password = c.Password
 	}
-	defer outputFile.Close()
 
-	versionNeeded := uint16(0x0014)
-	genPurpose := c.GenPurpose
-	method := uint16(0x0008)
-	extraLen := uint16(0x0000)
-	var extraField []byte
+	centralDirEntries := make([]centralDirEntry, 0, len(entries))
+	pipeline := c.newCompressionPipeline(source, entries, password)
+	for i, entry := range entries {
+		filename := []byte(c.archiveName(entry))
+		if c.encrypted() {
+			filename = EncryptFilename(string(filename), c.Password)
+		}
+		filenameLen := uint16(len(filename))
 
-	var localHeaderOffsets []uint64
-	var centralDirEntries []centralDirEntry
+		modTime, modDate := zipcipher.MSDOSTimestamp(c.modTime(entry))
 
-	for _, fileInfo := range fileInfos {
-		data, err := os.ReadFile(fileInfo.Path)
-		if err != nil {
-			return fmt.Errorf("failed to read file %s: %w", fileInfo.Path, err)
+		// Directories are zero-length stored entries without data or an encryption header
+		method := methodDeflate
+		if entry.Mode.IsDir() {
+			method = methodStore
 		}
 
-		crc32Val := crc32.ChecksumIEEE(data)
+		offset := out.offset
 
-		uncompressedSize := uint64(len(data))
-		var compressedData []byte
-
-		if c.CompressionLevel > 0 {
-			var buf bytes.Buffer
-			writer, err := flate.NewWriter(&buf, c.CompressionLevel)
+		// Streams cannot patch the header afterwards, so their entry data is compressed first
+		var data entryData
+		var payload *bytes.Buffer
+		if out.at == nil && !entry.Mode.IsDir() {
+			payload = &bytes.Buffer{}
+			var err error
+			data, err = pipeline.write(payload, i)
 			if err != nil {
-				return fmt.Errorf("failed to create compressor: %w", err)
+				return nil, fmt.Errorf("failed to write file %s: %w", entry.Name, err)
 			}
-
-			_, err = writer.Write(data)
-			if err != nil {
-				writer.Close()
-				return fmt.Errorf("failed to compress data: %w", err)
-			}
-			err = writer.Close()
-			if err != nil {
-				return fmt.Errorf("failed to close compressor: %w", err)
-			}
-
-			compressedData = buf.Bytes()
-		} else {
-			compressedData = data
 		}
 
-		modTime, modDate := timestampToMSDOS(time.Unix(fileInfo.ModTime, 0))
-
-		plaintextFilename := fileInfo.RelativePath
-		encryptedFilename := EncryptFilename(plaintextFilename, c.Password)
-		encryptedFilenameLen := uint16(len(encryptedFilename))
-
-		modTimeHighByte := byte(modTime >> 8)
-		encryptedData, err := EncryptData(compressedData, c.Password, modTimeHighByte)
-		if err != nil {
-			return fmt.Errorf("failed to encrypt data: %w", err)
+		// Files that may reach 4 GiB get a ZIP64 extra up front, as the local header cannot grow
+		// once their data follows it
+		zip64 := reserveZip64(entry) || data.exceeds32Bits()
+		version := versionNeeded
+		compressedSize, uncompressedSize := data.compressedSize, data.uncompressedSize
+		var extra []byte
+		if zip64 {
+			version = zipwriter.Zip64Version
+			compressedSize, uncompressedSize = zip64Marker, zip64Marker
+			extra = zipwriter.Zip64LocalExtra(data.uncompressedSize, data.compressedSize)
 		}
-		encryptedSize := uint64(len(encryptedData))
 
-		offset, err := outputFile.Seek(0, io.SeekCurrent)
-		if err != nil {
-			return fmt.Errorf("failed to get offset: %w", err)
-		}
-
-		err = zipwriter.WriteLocalFileHeaderFromParams(
-			outputFile,
-			versionNeeded,
-			genPurpose,
+		// Otherwise the CRC32 and sizes are patched in once the data has been streamed
+		err := zipwriter.WriteLocalFileHeaderFromParams(
+			out,
+			version,
+			c.GenPurpose,
 			method,
 			modTime,
 			modDate,
-			crc32Val,
-			encryptedSize,
+			data.crc32,
+			compressedSize,
 			uncompressedSize,
-			encryptedFilenameLen,
-			extraLen,
-			encryptedFilename,
-			extraField,
+			filenameLen,
+			uint16(len(extra)),
+			filename,
+			extra,
 		)
 		if err != nil {
-			return fmt.Errorf("failed to write local file header: %w", err)
+			return nil, fmt.Errorf("failed to write local file header: %w", err)
 		}
 
-		if _, err := outputFile.Write(encryptedData); err != nil {
-			return fmt.Errorf("failed to write encrypted data: %w", err)
+		switch {
+		case payload != nil:
+			if _, err := out.Write(payload.Bytes()); err != nil {
+				return nil, fmt.Errorf("failed to write file %s: %w", entry.Name, err)
+			}
+		case !entry.Mode.IsDir():
+			data, err = pipeline.write(out, i)
+			if err != nil {
+				return nil, fmt.Errorf("failed to write file %s: %w", entry.Name, err)
+			}
+			if !zip64 && data.exceeds32Bits() {
+				return nil, fmt.Errorf("failed to write file %s: it grew to %d bytes while being packed, past the size its header can hold", entry.Name, data.uncompressedSize)
+			}
+			if err := patchLocalHeader(out.at, offset, filenameLen, zip64, data); err != nil {
+				return nil, err
+			}
 		}
 
-		localHeaderOffsets = append(localHeaderOffsets, uint64(offset))
 		centralDirEntries = append(centralDirEntries, centralDirEntry{
-			modTime:          modTime,
-			modDate:          modDate,
-			crc32:            crc32Val,
-			compressedSize:   encryptedSize,
-			uncompressedSize: uncompressedSize,
-			filenameLen:      encryptedFilenameLen,
-			filename:         encryptedFilename,
+			method:            method,
+			modTime:           modTime,
+			modDate:           modDate,
+			crc32:             data.crc32,
+			compressedSize:    data.compressedSize,
+			uncompressedSize:  data.uncompressedSize,
+			filenameLen:       filenameLen,
+			filename:          filename,
+			externalAttrs:     c.externalAttrs(entry),
+			localHeaderOffset: uint64(offset),
+			zip64:             zip64,
 		})
 	}
 
-	cdOffset, err := outputFile.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return fmt.Errorf("failed to get central directory offset: %w", err)
+	return centralDirEntries, nil
+}
+
+// writeCentralDirectory writes the central directory at the current offset of out, listing the
+// kept entries of an existing archive before the newly written ones, followed by the end record
+func (c *Creator) writeCentralDirectory(out *archiveWriter, kept []ipf.FileInfo, written []centralDirEntry, comment string) error {
+	cdOffset := out.offset
+
+	for i := range kept {
+		file := &kept[i]
+		err := zipwriter.WriteCentralDirectoryEntryFromIPF(out, file, uint64(file.LocalHeaderOffset), file.ZipInfo.CreatorVersion, file.ZipInfo.Flags)
+		if err != nil {
+			return fmt.Errorf("failed to write central directory entry: %w", err)
+		}
 	}
 
-	for i, entry := range centralDirEntries {
-		err = zipwriter.WriteCentralDirectoryEntryFromParams(
-			outputFile,
-			versionNeeded,
-			c.VersionMadeBy,
-			genPurpose,
-			method,
+	for _, entry := range written {
+		extra, zip64 := zipwriter.Zip64CentralExtra(nil, entry.uncompressedSize, entry.compressedSize, entry.localHeaderOffset)
+		version := versionNeeded
+		if zip64 || entry.zip64 {
+			version = zipwriter.Zip64Version
+		}
+
+		err := zipwriter.WriteCentralDirectoryEntryWithAttrs(
+			out,
+			version,
+			c.versionMadeBy(),
+			c.GenPurpose,
+			entry.method,
 			entry.modTime,
 			entry.modDate,
 			entry.crc32,
 			entry.compressedSize,
 			entry.uncompressedSize,
 			entry.filenameLen,
-			extraLen,
+			uint16(len(extra)),
 			entry.filename,
-			extraField,
-			localHeaderOffsets[i],
+			extra,
+			entry.externalAttrs,
+			entry.localHeaderOffset,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to write central directory entry: %w", err)
 		}
 	}
 
-	cdEndOffset, err := outputFile.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return fmt.Errorf("failed to get central directory end offset: %w", err)
-	}
-
-	cdSize := uint64(cdEndOffset - cdOffset)
+	cdSize := uint64(out.offset - cdOffset)
 
-	err = zipwriter.WriteEndOfCentralDirectory(
-		outputFile,
+	err := zipwriter.WriteEndOfCentralDirectory64(
+		out,
 		uint64(cdOffset),
 		cdSize,
-		uint16(len(centralDirEntries)),
+		len(kept)+len(written),
+		comment,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to write end of central directory: %w", err)
@@ -357,21 +365,72 @@ func (c *Creator) createEncryptedZIP(fileInfos []FileInfo) error {
 	return nil
 }
 
-type centralDirEntry struct {
-	modTime          uint16
-	modDate          uint16
-	crc32            uint32
-	compressedSize   uint64
-	uncompressedSize uint64
-	filenameLen      uint16
-	filename         []byte
+// modTime returns the modification time recorded for entry
+func (c *Creator) modTime(entry Entry) time.Time {
+	if !c.Deterministic {
+		return time.Unix(entry.ModTime, 0)
+	}
+	if c.FixedModTime.IsZero() {
+		return time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return c.FixedModTime
 }
 
-func timestampToMSDOS(t time.Time) (uint16, uint16) {
-	date := uint16(t.Day()) | uint16(t.Month())<<5 | uint16(t.Year()-1980)<<9
-	hour := uint16(t.Hour())
-	minute := uint16(t.Minute())
-	second := uint16(t.Second() / 2)
-	timeVal := second | minute<<5 | hour<<11
-	return uint16(timeVal), date
+// archiveName returns the name stored in the archive for entry
+func (c *Creator) archiveName(entry Entry) string {
+	name := strings.TrimPrefix(entry.Name, c.stripPrefix)
+	if c.NormalizeNames {
+		name = normalizeName(name)
+	}
+	return name
+}
+
+// normalizeName replaces invalid UTF-8, drops a leading byte order mark and converts to NFC,
+// so that composed and decomposed spellings of a name are stored identically
+func normalizeName(name string) string {
+	name = strings.ToValidUTF8(name, "\uFFFD")
+	name = strings.TrimPrefix(name, "\uFEFF")
+	return norm.NFC.String(name)
+}
+
+// versionMadeBy combines the configured spec version with the host system in the high byte
+func (c *Creator) versionMadeBy() uint16 {
+	return c.VersionMadeBy&0x00FF | uint16(c.HostSystem)<<8
+}
+
+// externalAttrs returns the external attributes for a file; Unix modes are only
+// recorded when the host system is Unix, since readers ignore them otherwise.
+// Directories also carry the MS-DOS directory attribute.
+func (c *Creator) externalAttrs(entry Entry) uint32 {
+	const (
+		unixRegularFile = 0100000
+		unixDirectory   = 0040000
+		msdosDirectory  = 0x10
+	)
+
+	if entry.Mode.IsDir() {
+		if c.HostSystem != HostUnix {
+			return msdosDirectory
+		}
+		return (unixDirectory|uint32(entry.Mode.Perm()))<<16 | msdosDirectory
+	}
+
+	if c.HostSystem != HostUnix {
+		return 0
+	}
+	return (unixRegularFile | uint32(entry.Mode.Perm())) << 16
+}
+
+type centralDirEntry struct {
+	method            uint16
+	modTime           uint16
+	modDate           uint16
+	crc32             uint32
+	compressedSize    uint64
+	uncompressedSize  uint64
+	filenameLen       uint16
+	filename          []byte
+	externalAttrs     uint32
+	localHeaderOffset uint64
+	zip64             bool // The local header carries a ZIP64 extra
 }
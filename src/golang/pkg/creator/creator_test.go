@@ -0,0 +1,286 @@
+package creator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+func TestSkipEmpty(t *testing.T) {
+	files := map[string]string{
+		"a.txt":       "content",
+		"empty.txt":   "",
+		"dir/b.txt":   "more content",
+		"dir/nil.dat": "",
+	}
+
+	tests := []struct {
+		name        string
+		skipEmpty   bool
+		wantSkipped int
+		want        map[string]string
+	}{
+		{"keep empty files", false, 0, files},
+		{"skip empty files", true, 2, map[string]string{"a.txt": "content", "dir/b.txt": "more content"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceDir := t.TempDir()
+			writeTree(t, sourceDir, files)
+
+			c := NewCreator(sourceDir, filepath.Join(t.TempDir(), "out.ipf"), true)
+			c.SkipEmpty = tt.skipEmpty
+			path := createArchive(t, c)
+
+			if c.SkippedEmpty != tt.wantSkipped {
+				t.Errorf("SkippedEmpty = %d, want %d", c.SkippedEmpty, tt.wantSkipped)
+			}
+			equalTrees(t, extractArchive(t, path, testPassword), tt.want)
+		})
+	}
+}
+
+func TestHostSystemModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     HostSystem
+		wantMode os.FileMode
+		wantOK   bool
+	}{
+		{"unix", HostUnix, 0640, true},
+		{"msdos", HostDOS, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceDir := t.TempDir()
+			writeTree(t, sourceDir, map[string]string{"script.sh": "#!/bin/sh\n"})
+			if err := os.Chmod(filepath.Join(sourceDir, "script.sh"), 0640); err != nil {
+				t.Fatal(err)
+			}
+
+			c := NewCreator(sourceDir, filepath.Join(t.TempDir(), "out.ipf"), true)
+			c.HostSystem = tt.host
+			reader := openArchive(t, createArchive(t, c), testPassword)
+
+			fileInfo, err := reader.GetFileByName("script.sh")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fileInfo.ZipInfo.CreatorVersion >> 8; got != uint16(tt.host) {
+				t.Errorf("host system = %d, want %d", got, tt.host)
+			}
+			if mode, ok := fileInfo.UnixMode(); mode != tt.wantMode || ok != tt.wantOK {
+				t.Errorf("UnixMode() = %v, %v; want %v, %v", mode, ok, tt.wantMode, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestStripSingleRoot(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  map[string]string
+	}{
+		{
+			name:  "single root stripped",
+			files: map[string]string{"root/a.txt": "a", "root/sub/b.txt": "b"},
+			want:  map[string]string{"a.txt": "a", "sub/b.txt": "b"},
+		},
+		{
+			name:  "multiple roots untouched",
+			files: map[string]string{"root/a.txt": "a", "other/b.txt": "b"},
+			want:  map[string]string{"root/a.txt": "a", "other/b.txt": "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceDir := t.TempDir()
+			writeTree(t, sourceDir, tt.files)
+
+			c := NewCreator(sourceDir, filepath.Join(t.TempDir(), "out.ipf"), true)
+			c.StripSingleRoot = true
+			equalTrees(t, extractArchive(t, createArchive(t, c), testPassword), tt.want)
+		})
+	}
+}
+
+func TestIncludeEmptyDirs(t *testing.T) {
+	files := map[string]string{
+		"a.txt":             "content",
+		"empty/":            "",
+		"dir/b.txt":         "more content",
+		"dir/empty/":        "",
+		"outer/inner/deep/": "",
+	}
+
+	tests := []struct {
+		name             string
+		includeEmptyDirs bool
+		want             map[string]string
+	}{
+		{"empty folders dropped", false, map[string]string{"a.txt": "content", "dir/b.txt": "more content"}},
+		{"empty folders kept", true, files},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceDir := t.TempDir()
+			writeTree(t, sourceDir, files)
+
+			c := NewCreator(sourceDir, filepath.Join(t.TempDir(), "out.ipf"), true)
+			c.IncludeEmptyDirs = tt.includeEmptyDirs
+			path := createArchive(t, c)
+
+			if tt.includeEmptyDirs {
+				reader := openArchive(t, path, testPassword)
+				for _, name := range []string{"empty/", "dir/empty/", "outer/inner/deep/"} {
+					fileInfo, err := reader.GetFileByName(name)
+					if err != nil || !fileInfo.IsDir() || fileInfo.ZipInfo.UncompressedSize64 != 0 {
+						t.Errorf("%s is not stored as an empty directory entry: %+v, %v", name, fileInfo, err)
+					}
+				}
+			}
+			equalTrees(t, extractArchive(t, path, testPassword), tt.want)
+		})
+	}
+}
+
+func TestCustomPassword(t *testing.T) {
+	files := map[string]string{"a.txt": "content", "dir/b.xml": "<b/>"}
+
+	for _, value := range []string{"ToS key", "hex:00ff1080", ""} {
+		t.Run(value, func(t *testing.T) {
+			password, err := zipcipher.ParsePassword(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sourceDir := t.TempDir()
+			writeTree(t, sourceDir, files)
+
+			c := NewCreator(sourceDir, filepath.Join(t.TempDir(), "out.ipf"), true)
+			c.Password = password
+			path := createArchive(t, c)
+			equalTrees(t, extractArchive(t, path, password), files)
+
+			if value == "" {
+				return
+			}
+			// The default password can no longer read the archive
+			reader := openArchive(t, path, testPassword)
+			outputDir := t.TempDir()
+			results, err := ipf.NewConcurrentExtractor(reader, reader.ZipReader, 2).ExtractAllParallel(context.Background(), outputDir, testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, result := range results {
+				if result.Success {
+					t.Errorf("file %d extracted with the default password", result.Index)
+				}
+			}
+		})
+	}
+}
+
+func TestEmptyDirEntries(t *testing.T) {
+	files := map[string]string{
+		"a.txt":             "content",
+		"skipped/empty.txt": "",
+		"nested/inner/":     "",
+	}
+	want := map[string]string{"a.txt": "content", "skipped/": "", "nested/inner/": ""}
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("workers %d", workers), func(t *testing.T) {
+			sourceDir := t.TempDir()
+			writeTree(t, sourceDir, files)
+
+			// skipped/ only holds a file SkipEmpty leaves out, so it is stored as empty too
+			c := NewCreator(sourceDir, filepath.Join(t.TempDir(), "out.ipf"), true)
+			c.IncludeEmptyDirs = true
+			c.SkipEmpty = true
+			c.WorkerCount = workers
+			path := createArchive(t, c)
+
+			reader := openArchive(t, path, testPassword)
+			for _, name := range []string{"skipped/", "nested/inner/"} {
+				fileInfo, err := reader.GetFileByName(name)
+				if err != nil {
+					t.Fatalf("%s is missing: %v", name, err)
+				}
+				if fileInfo.Method() != 0 || fileInfo.ZipInfo.CompressedSize64 != 0 || fileInfo.ZipInfo.CRC32 != 0 {
+					t.Errorf("%s stored with method %d, %d bytes and CRC32 %08x; want an empty stored entry",
+						name, fileInfo.Method(), fileInfo.ZipInfo.CompressedSize64, fileInfo.ZipInfo.CRC32)
+				}
+			}
+			if _, err := reader.GetFileByName("nested/"); err == nil {
+				t.Errorf("nested/ has a child directory but was stored as empty")
+			}
+			equalTrees(t, extractArchive(t, path, testPassword), want)
+		})
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	files := map[string]string{"a.txt": "content", "dir/b.xml": "<b/>", "dir/c.bin": "\x00\x01"}
+	fixed := time.Date(2020, time.June, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		encrypt       bool
+		deterministic bool
+		fixedModTime  time.Time
+		wantIdentical bool
+	}{
+		{"encrypted", true, true, time.Time{}, true},
+		{"plain", false, true, time.Time{}, true},
+		{"fixed timestamp", true, true, fixed, true},
+		{"random headers", true, false, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceDir := t.TempDir()
+			writeTree(t, sourceDir, files)
+
+			var archives [][]byte
+			for run := 0; run < 2; run++ {
+				// Files get new timestamps between runs, as after a fresh checkout
+				later := time.Now().Add(time.Duration(run+1) * time.Hour)
+				for name := range files {
+					if err := os.Chtimes(filepath.Join(sourceDir, filepath.FromSlash(name)), later, later); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				c := NewCreator(sourceDir, filepath.Join(t.TempDir(), "out.ipf"), tt.encrypt)
+				c.Deterministic = tt.deterministic
+				c.FixedModTime = tt.fixedModTime
+				archive, err := os.ReadFile(createArchive(t, c))
+				if err != nil {
+					t.Fatal(err)
+				}
+				archives = append(archives, archive)
+			}
+
+			if identical := bytes.Equal(archives[0], archives[1]); identical != tt.wantIdentical {
+				t.Errorf("runs produced identical bytes: %v, want %v", identical, tt.wantIdentical)
+			}
+
+			if !tt.fixedModTime.IsZero() {
+				path := ipftest.WriteFile(t, t.TempDir(), "out.ipf", archives[0])
+				for _, fileInfo := range openArchive(t, path, testPassword).FileInfos {
+					if got := fileInfo.ZipInfo.Modified; !got.Equal(tt.fixedModTime) {
+						t.Errorf("%s stamped %v, want %v", fileInfo.SafeFilename, got, tt.fixedModTime)
+					}
+				}
+			}
+		})
+	}
+}
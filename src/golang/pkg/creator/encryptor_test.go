@@ -0,0 +1,31 @@
+package creator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+func TestEncryptorRoundTrip(t *testing.T) {
+	password := zipcipher.GetIPFPassword()
+
+	for _, name := range []string{"a.txt", "data/item/sword.xml", "日本語/画像.png"} {
+		decrypted, ok := zipcipher.DecryptFilename(EncryptFilename(name, password), password)
+		if !ok || decrypted != name {
+			t.Errorf("EncryptFilename(%q) decrypts to %q (%v)", name, decrypted, ok)
+		}
+	}
+
+	data := bytes.Repeat([]byte("payload "), 1000)
+	encrypted, err := EncryptData(data, password, 0x6a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher := &zipcipher.ZipCipher{}
+	cipher.InitKeys(password)
+	decrypted := cipher.DecryptData(encrypted)
+	if decrypted[11] != 0x6a || !bytes.Equal(decrypted[12:], data) {
+		t.Errorf("EncryptData does not decrypt to a header checked by 0x6a and the data")
+	}
+}
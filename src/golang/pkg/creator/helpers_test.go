@@ -0,0 +1,129 @@
+package creator
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+var testPassword = zipcipher.GetIPFPassword()
+
+// writeTree creates files under dir from slash-separated paths; paths ending in a slash
+// become directories
+func writeTree(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readTree returns the files under dir keyed by slash-separated path, with empty directories
+// listed under their path plus a trailing slash
+func readTree(t testing.TB, dir string) map[string]string {
+	t.Helper()
+
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if children, err := os.ReadDir(path); err == nil && len(children) == 0 {
+				files[rel+"/"] = ""
+			}
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		files[rel] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", dir, err)
+	}
+	return files
+}
+
+// openArchive opens the archive at path with its filenames decrypted
+func openArchive(t testing.TB, path string, password []byte) *ipf.IPFReader {
+	t.Helper()
+
+	reader, err := ipf.NewIPFReader(path)
+	if err != nil {
+		t.Fatalf("NewIPFReader: %v", err)
+	}
+	t.Cleanup(func() { reader.Close() })
+	if _, err := reader.ListFiles(password); err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+	return reader
+}
+
+// extractArchive extracts the archive at path and returns the extracted tree (see readTree)
+func extractArchive(t testing.TB, path string, password []byte) map[string]string {
+	t.Helper()
+
+	reader := openArchive(t, path, password)
+	outputDir := t.TempDir()
+	results, err := ipf.NewConcurrentExtractor(reader, reader.ZipReader, 2).ExtractAllParallel(context.Background(), outputDir, password)
+	if err != nil {
+		t.Fatalf("ExtractAllParallel: %v", err)
+	}
+	for _, result := range results {
+		if !result.Success && !result.Skipped {
+			t.Errorf("file %d failed: %v", result.Index, result.Error)
+		}
+	}
+	return readTree(t, outputDir)
+}
+
+// createArchive runs c.CreateIPF and returns the path of the archive
+func createArchive(t testing.TB, c *Creator) string {
+	t.Helper()
+
+	if err := c.CreateIPF(); err != nil {
+		t.Fatalf("CreateIPF: %v", err)
+	}
+	return c.OutputFile
+}
+
+// equalTrees reports the differences between two trees from readTree
+func equalTrees(t testing.TB, got, want map[string]string) {
+	t.Helper()
+
+	for name, content := range want {
+		if gotContent, ok := got[name]; !ok {
+			t.Errorf("%s is missing", name)
+		} else if gotContent != content {
+			t.Errorf("%s = %q, want %q", name, gotContent, content)
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("unexpected %s", name)
+		}
+	}
+}
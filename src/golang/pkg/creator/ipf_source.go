@@ -0,0 +1,123 @@
+package creator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// IPFSource packs the decrypted contents of an existing IPF archive, so that a Creator with
+// encryption disabled converts the IPF into a plain ZIP. Only the newest copy of each file is kept.
+type IPFSource struct {
+	reader   *ipf.IPFReader
+	password []byte
+	files    map[string]*ipf.FileInfo
+}
+
+func NewIPFSource(path string, password []byte) (*IPFSource, error) {
+	reader, err := ipf.NewIPFReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := reader.ReadFileStructure(); err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("failed to read file structure: %w", err)
+	}
+	if err := reader.ReadEncryptedFilenames(); err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("failed to read encrypted filenames: %w", err)
+	}
+
+	fileInfos := reader.GetFileInfos()
+	results, err := ipf.NewFilenameDecryptor(password, 4).DecryptAllParallel(context.Background(), fileInfos)
+	if err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("failed to decrypt filenames: %w", err)
+	}
+	ipf.UpdateFileInfos(fileInfos, results)
+
+	files := make(map[string]*ipf.FileInfo)
+	retained := ipf.NewDeduplicator(fileInfos).Run()
+	for i := range retained {
+		if retained[i].IsDir() {
+			continue
+		}
+		files[sourceName(&retained[i])] = &retained[i]
+	}
+
+	return &IPFSource{
+		reader:   reader,
+		password: password,
+		files:    files,
+	}, nil
+}
+
+func (s *IPFSource) List() ([]Entry, error) {
+	entries := make([]Entry, 0, len(s.files))
+	for name, fileInfo := range s.files {
+		mode, ok := fileInfo.UnixMode()
+		if !ok {
+			mode = 0644
+		}
+
+		modTime := zipcipher.MSDOSTime(fileInfo.ZipInfo.ModifiedDate, fileInfo.ZipInfo.ModifiedTime)
+		if modTime.IsZero() {
+			// Entries without a timestamp keep the MS-DOS epoch rather than year 1
+			modTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.Local)
+		}
+
+		entries = append(entries, Entry{
+			Name:    name,
+			ModTime: modTime.Unix(),
+			Mode:    mode,
+			Size:    int64(fileInfo.ZipInfo.UncompressedSize64),
+		})
+	}
+	return entries, nil
+}
+
+func (s *IPFSource) Open(entry Entry) (io.ReadCloser, error) {
+	fileInfo, ok := s.files[entry.Name]
+	if !ok {
+		return nil, fmt.Errorf("file not found in IPF source: %s", entry.Name)
+	}
+
+	fileSize, err := s.reader.GetFileSize()
+	if err != nil {
+		return nil, err
+	}
+
+	section := io.NewSectionReader(s.reader.ReaderAt(), fileInfo.LocalHeaderOffset, fileSize-fileInfo.LocalHeaderOffset)
+	entryReader := zipcipher.NewEncryptedFileReader(section, s.password)
+	if _, err := entryReader.ReadLocalHeader(); err != nil {
+		return nil, err
+	}
+	entryReader.SetKnownSizes(fileInfo.ZipInfo.CRC32, fileInfo.ZipInfo.CompressedSize64, fileInfo.ZipInfo.UncompressedSize64)
+
+	data, err := entryReader.ExtractFile()
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *IPFSource) Close() error {
+	return s.reader.Close()
+}
+
+// sourceName returns the archive path for a decrypted entry, with backslashes normalized
+func sourceName(fileInfo *ipf.FileInfo) string {
+	name := fileInfo.DecryptedFilename
+	if name == "" {
+		name = fileInfo.SafeFilename
+	}
+	return strings.ReplaceAll(name, "\\", "/")
+}
@@ -0,0 +1,59 @@
+package creator
+
+import (
+	"archive/zip"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestConvertIPFToZip(t *testing.T) {
+	dir := t.TempDir()
+	input := ipftest.WriteFile(t, dir, "input.ipf", ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "xml/a.xml", Data: []byte("<a/>"), Method: zip.Deflate},
+		ipftest.Entry{Name: "b.bin", Data: []byte{0, 1, 2, 3}},
+		ipftest.Entry{Name: "xml/c.xml", Data: []byte("<c/>"), Method: zip.Deflate, Descriptor: true},
+		ipftest.Entry{Name: "xml/a.xml", Data: []byte("<a>patched</a>"), Method: zip.Deflate},
+	))
+
+	source, err := NewIPFSource(input, testPassword)
+	if err != nil {
+		t.Fatalf("NewIPFSource: %v", err)
+	}
+	defer source.Close()
+
+	c := NewCreator("", filepath.Join(dir, "output.zip"), false)
+	c.Source = source
+	output := createArchive(t, c)
+
+	// The plain ZIP must open with the standard library alone
+	zr, err := zip.OpenReader(output)
+	if err != nil {
+		t.Fatalf("zip.OpenReader: %v", err)
+	}
+	defer zr.Close()
+
+	got := make(map[string]string)
+	for _, file := range zr.File {
+		if file.Flags&0x1 != 0 {
+			t.Errorf("%s is still encrypted", file.Name)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", file.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", file.Name, err)
+		}
+		got[file.Name] = string(data)
+	}
+	equalTrees(t, got, map[string]string{
+		"xml/a.xml": "<a>patched</a>",
+		"b.bin":     "\x00\x01\x02\x03",
+		"xml/c.xml": "<c/>",
+	})
+}
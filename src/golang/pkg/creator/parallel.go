@@ -0,0 +1,105 @@
+package creator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/workers"
+)
+
+const (
+	// parallelEntryLimit is the largest file compressed ahead of time on a worker; larger files
+	// are streamed straight into the archive
+	parallelEntryLimit = 8 << 20
+	// parallelBatchBytes bounds the uncompressed bytes compressed ahead of time at once
+	parallelBatchBytes = 64 << 20
+)
+
+// preparedEntry is an entry compressed (and encrypted) ahead of time
+type preparedEntry struct {
+	data    entryData
+	payload []byte
+}
+
+// compressionPipeline hands out entry payloads in archive order while compressing batches of
+// small files on parallel workers, so the output stays identical to sequential packing
+type compressionPipeline struct {
+	creator   *Creator
+	source    Source
+	entries   []Entry
+	password  []byte
+	processor *workers.ParallelProcessor[int, preparedEntry]
+
+	batchStart int
+	batch      []preparedEntry
+}
+
+func (c *Creator) newCompressionPipeline(source Source, entries []Entry, password []byte) *compressionPipeline {
+	return &compressionPipeline{
+		creator:   c,
+		source:    source,
+		entries:   entries,
+		password:  password,
+		processor: workers.NewParallelProcessor[int, preparedEntry](c.WorkerCount, len(entries)),
+	}
+}
+
+// write writes the payload of entries[i] to w; entries must be written in order
+func (p *compressionPipeline) write(w io.Writer, i int) (entryData, error) {
+	entry := p.entries[i]
+	if !p.parallel(entry) {
+		return p.creator.writeEntryData(w, p.source, entry, p.password)
+	}
+
+	if i >= p.batchStart+len(p.batch) {
+		if err := p.prepareFrom(i); err != nil {
+			return entryData{}, err
+		}
+	}
+
+	prepared := p.batch[i-p.batchStart]
+	if _, err := w.Write(prepared.payload); err != nil {
+		return entryData{}, fmt.Errorf("failed to write compressed data: %w", err)
+	}
+	return prepared.data, nil
+}
+
+// parallel reports whether entry is compressed ahead of time
+func (p *compressionPipeline) parallel(entry Entry) bool {
+	return p.creator.WorkerCount != 1 && !entry.Mode.IsDir() && entry.Size <= parallelEntryLimit
+}
+
+// prepareFrom compresses the run of small entries starting at start, up to the batch budget
+func (p *compressionPipeline) prepareFrom(start int) error {
+	end := start
+	var batchBytes int64
+	for end < len(p.entries) && p.parallel(p.entries[end]) && (end == start || batchBytes+p.entries[end].Size <= parallelBatchBytes) {
+		batchBytes += p.entries[end].Size
+		end++
+	}
+
+	indices := make([]int, end-start)
+	for i := range indices {
+		indices[i] = start + i
+	}
+
+	batch, err := p.processor.ProcessWithError(context.Background(), indices, func(index int) (preparedEntry, error) {
+		entry := p.entries[index]
+		var buf bytes.Buffer
+		buf.Grow(int(entry.Size))
+		data, err := p.creator.writeEntryData(&buf, p.source, entry, p.password)
+		if err != nil {
+			return preparedEntry{}, fmt.Errorf("failed to write file %s: %w", entry.Name, err)
+		}
+		return preparedEntry{data: data, payload: buf.Bytes()}, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	p.batchStart = start
+	p.batch = batch
+	return nil
+}
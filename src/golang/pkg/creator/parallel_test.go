@@ -0,0 +1,74 @@
+package creator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// manyFiles returns count small files spread over a few directories, plus one file above
+// parallelEntryLimit that is streamed rather than compressed on a worker
+func manyFiles(count int) map[string]string {
+	files := make(map[string]string, count+1)
+	for i := 0; i < count; i++ {
+		files[fmt.Sprintf("dir%02d/file%05d.txt", i%17, i)] = strings.Repeat(fmt.Sprintf("content of file %d\n", i), i%50+1)
+	}
+	files["large/streamed.bin"] = strings.Repeat("streamed between batches ", parallelEntryLimit/25+1)
+	return files
+}
+
+func TestParallelMatchesSequential(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeTree(t, sourceDir, manyFiles(500))
+
+	tests := []struct {
+		name    string
+		encrypt bool
+		level   int
+	}{
+		{"encrypted", true, 6},
+		{"plain", false, 6},
+		{"no compression", true, 0},
+		{"best compression", true, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var archives [][]byte
+			for _, workers := range []int{1, 8} {
+				c := NewCreator(sourceDir, filepath.Join(t.TempDir(), "out.ipf"), tt.encrypt)
+				c.Deterministic = true
+				c.CompressionLevel = tt.level
+				c.WorkerCount = workers
+				archive, err := os.ReadFile(createArchive(t, c))
+				if err != nil {
+					t.Fatal(err)
+				}
+				archives = append(archives, archive)
+			}
+
+			if !bytes.Equal(archives[0], archives[1]) {
+				t.Errorf("parallel archive (%d bytes) differs from the sequential one (%d bytes)", len(archives[1]), len(archives[0]))
+			}
+		})
+	}
+}
+
+func BenchmarkCreateParallel(b *testing.B) {
+	sourceDir := b.TempDir()
+	files := manyFiles(5000)
+	delete(files, "large/streamed.bin")
+	writeTree(b, sourceDir, files)
+
+	for _, workers := range []int{1, 2, 4, 0} {
+		b.Run(fmt.Sprintf("workers %d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				c := NewCreator(sourceDir, filepath.Join(b.TempDir(), "out.ipf"), true)
+				c.WorkerCount = workers
+				createArchive(b, c)
+			}
+		})
+	}
+}
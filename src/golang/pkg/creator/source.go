@@ -0,0 +1,140 @@
+package creator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Entry is a file offered by a Source for packing
+type Entry struct {
+	Name    string // Slash-separated path stored in the archive
+	ModTime int64  // Unix seconds
+	Mode    os.FileMode
+	Size    int64
+}
+
+// Source supplies the files packed by a Creator
+type Source interface {
+	List() ([]Entry, error)
+	Open(entry Entry) (io.ReadCloser, error)
+}
+
+// List walks the root directory (once) and returns its files as entries
+func (w *Walker) List() ([]Entry, error) {
+	if len(w.FileInfos) == 0 {
+		if err := w.Walk(); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make([]Entry, 0, len(w.FileInfos))
+	for _, fileInfo := range w.FileInfos {
+		entries = append(entries, Entry{
+			Name:    fileInfo.RelativePath,
+			ModTime: fileInfo.ModTime,
+			Mode:    fileInfo.Mode,
+			Size:    fileInfo.Size,
+		})
+	}
+	return entries, nil
+}
+
+// Open opens the file behind entry relative to the root directory
+func (w *Walker) Open(entry Entry) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(w.RootDir, filepath.FromSlash(entry.Name)))
+}
+
+// MemorySource packs files held in memory, keyed by slash-separated archive path
+type MemorySource struct {
+	Files   map[string][]byte
+	ModTime time.Time
+	Mode    os.FileMode
+}
+
+func NewMemorySource(files map[string][]byte) *MemorySource {
+	return &MemorySource{
+		Files:   files,
+		ModTime: time.Now(),
+		Mode:    0644,
+	}
+}
+
+func (m *MemorySource) List() ([]Entry, error) {
+	entries := make([]Entry, 0, len(m.Files))
+	for name, data := range m.Files {
+		entries = append(entries, Entry{
+			Name:    name,
+			ModTime: m.ModTime.Unix(),
+			Mode:    m.Mode,
+			Size:    int64(len(data)),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+func (m *MemorySource) Open(entry Entry) (io.ReadCloser, error) {
+	data, ok := m.Files[entry.Name]
+	if !ok {
+		return nil, fmt.Errorf("file not found in memory source: %s", entry.Name)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// FSSource packs the regular files of an fs.FS, such as an embed.FS or os.DirFS
+type FSSource struct {
+	FS fs.FS
+}
+
+func NewFSSource(fsys fs.FS) *FSSource {
+	return &FSSource{FS: fsys}
+}
+
+func (s *FSSource) List() ([]Entry, error) {
+	var entries []Entry
+
+	err := fs.WalkDir(s.FS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if base := path.Base(name); base[0] == '.' || base == "Thumbs.db" {
+			return nil
+		}
+
+		entries = append(entries, Entry{
+			Name:    name,
+			ModTime: info.ModTime().Unix(),
+			Mode:    info.Mode(),
+			Size:    info.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (s *FSSource) Open(entry Entry) (io.ReadCloser, error) {
+	return s.FS.Open(entry.Name)
+}
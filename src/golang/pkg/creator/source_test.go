@@ -0,0 +1,170 @@
+package creator
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+func TestCreateFromSource(t *testing.T) {
+	files := map[string]string{
+		"a.txt":            "memory a",
+		"dir/b.xml":        "<b/>",
+		"dir/nested/c.bin": "\x00\x01\x02",
+	}
+	memory := make(map[string][]byte)
+	mapFS := make(fstest.MapFS)
+	for name, content := range files {
+		memory[name] = []byte(content)
+		mapFS[name] = &fstest.MapFile{Data: []byte(content), Mode: 0644}
+	}
+
+	tests := []struct {
+		name   string
+		source Source
+	}{
+		{"memory", NewMemorySource(memory)},
+		{"fs", NewFSSource(mapFS)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCreator("", filepath.Join(t.TempDir(), "out.ipf"), true)
+			c.Source = tt.source
+			path := createArchive(t, c)
+
+			equalTrees(t, extractArchive(t, path, testPassword), files)
+		})
+	}
+}
+
+func TestNormalizeNames(t *testing.T) {
+	const (
+		composed   = "caf\u00e9.txt"
+		decomposed = "cafe\u0301.txt"
+	)
+
+	tests := []struct {
+		name      string
+		files     []string
+		normalize bool
+		want      []string
+		wantErr   bool
+	}{
+		{"names kept by default", []string{decomposed, "\uFEFFbom.txt"}, false, []string{decomposed, "\uFEFFbom.txt"}, false},
+		{"decomposed name composed", []string{"dir/" + decomposed}, true, []string{"dir/" + composed}, false},
+		{"leading BOM dropped", []string{"\uFEFFbom.txt"}, true, []string{"bom.txt"}, false},
+		{"invalid UTF-8 replaced", []string{"bad\xff.txt"}, true, []string{"bad\uFFFD.txt"}, false},
+		{"spellings collide", []string{composed, decomposed}, true, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make(map[string][]byte)
+			for _, name := range tt.files {
+				files[name] = []byte("content of " + name)
+			}
+
+			c := NewCreator("", filepath.Join(t.TempDir(), "out.ipf"), true)
+			c.Source = NewMemorySource(files)
+			c.NormalizeNames = tt.normalize
+			err := c.CreateIPF()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("CreateIPF packed colliding names")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateIPF: %v", err)
+			}
+
+			// Compare the stored bytes, before any decoding by the reader
+			var stored []string
+			for _, fileInfo := range openArchive(t, c.OutputFile, testPassword).GetFileInfos() {
+				stored = append(stored, string(zipcipher.DecryptFilenameBytes(fileInfo.EncryptedFilename, testPassword)))
+			}
+			slices.Sort(stored)
+			slices.Sort(tt.want)
+			if !slices.Equal(stored, tt.want) {
+				t.Errorf("stored names %+q, want %+q", stored, tt.want)
+			}
+		})
+	}
+}
+
+// repeatingReader endlessly repeats block
+type repeatingReader struct {
+	block []byte
+	pos   int
+}
+
+func (r *repeatingReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		copied := copy(p[n:], r.block[r.pos:])
+		n += copied
+		r.pos = (r.pos + copied) % len(r.block)
+	}
+	return n, nil
+}
+
+// generatedSource offers a single large file produced on the fly, never held in memory
+type generatedSource struct {
+	size  int64
+	block []byte
+}
+
+func (g *generatedSource) List() ([]Entry, error) {
+	return []Entry{{Name: "large.bin", ModTime: 1700000000, Mode: 0644, Size: g.size}}, nil
+}
+
+func (g *generatedSource) Open(Entry) (io.ReadCloser, error) {
+	return io.NopCloser(io.LimitReader(&repeatingReader{block: g.block}, g.size)), nil
+}
+
+func TestCreateLargeFileBoundedMemory(t *testing.T) {
+	const size = 64 << 20
+	block := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(block)
+	checksum := crc32.NewIEEE()
+	io.Copy(checksum, io.LimitReader(&repeatingReader{block: block}, size))
+
+	for _, encrypt := range []bool{false, true} {
+		t.Run(fmt.Sprintf("encrypt %v", encrypt), func(t *testing.T) {
+			c := NewCreator("", filepath.Join(t.TempDir(), "large.ipf"), encrypt)
+			c.Source = &generatedSource{size: size, block: block}
+
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			path := createArchive(t, c)
+			runtime.ReadMemStats(&after)
+
+			// Reading the file whole would allocate at least its size, twice with the compressed copy
+			if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/4 {
+				t.Errorf("packing a %d MB file allocated %d MB", size>>20, allocated>>20)
+			}
+
+			reader := openArchive(t, path, testPassword)
+			fileInfo := reader.FileInfos[0]
+			if fileInfo.ZipInfo.UncompressedSize64 != size || fileInfo.ZipInfo.CRC32 != checksum.Sum32() {
+				t.Errorf("large.bin recorded as %d bytes with CRC32 %08x, want %d bytes with %08x",
+					fileInfo.ZipInfo.UncompressedSize64, fileInfo.ZipInfo.CRC32, size, checksum.Sum32())
+			}
+			data, err := reader.ReadEntry(0, testPassword)
+			if err != nil {
+				t.Fatalf("ReadEntry: %v", err)
+			}
+			if len(data) != size || crc32.ChecksumIEEE(data) != checksum.Sum32() {
+				t.Errorf("large.bin does not read back as packed")
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package creator
+
+import (
+	"compress/flate"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipwriter"
+)
+
+const (
+	// zip64Marker replaces sizes and offsets that do not fit in 32 bits
+	zip64Marker = 0xFFFFFFFF
+	// zip64ReserveSize is the smallest file given a ZIP64 local extra before it is packed
+	zip64ReserveSize = zip64Marker - 64<<20
+)
+
+// entryData describes the data of an entry once it has been streamed into the archive
+type entryData struct {
+	crc32            uint32
+	compressedSize   uint64
+	uncompressedSize uint64
+}
+
+// writeEntryData streams entry from source through the compressor, and through the cipher when
+// password is set, into w. Only the compressor's window and copy buffers are held in memory, so
+// files of any size can be packed.
+func (c *Creator) writeEntryData(w io.Writer, source Source, entry Entry, password []byte) (entryData, error) {
+	var result entryData
+
+	counter := &countingWriter{w: w}
+	var sink io.Writer = counter
+	if password != nil {
+		// The last header byte is checked against the high byte of the DOS modification time
+		modTime, _ := zipcipher.MSDOSTimestamp(c.modTime(entry))
+		header, err := c.encryptionHeader(source, entry, byte(modTime>>8))
+		if err != nil {
+			return result, err
+		}
+		encrypter, err := newEncryptingWriter(counter, password, header)
+		if err != nil {
+			return result, err
+		}
+		sink = encrypter
+	}
+
+	// Level 0 still produces a valid deflate stream of stored blocks, matching the method field
+	compressor, err := flate.NewWriter(sink, c.CompressionLevel)
+	if err != nil {
+		return result, fmt.Errorf("failed to create compressor: %w", err)
+	}
+
+	reader, err := source.Open(entry)
+	if err != nil {
+		return result, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer reader.Close()
+
+	checksum := crc32.NewIEEE()
+	copied, err := io.Copy(io.MultiWriter(compressor, checksum), reader)
+	if err != nil {
+		return result, fmt.Errorf("failed to compress data: %w", err)
+	}
+	result.uncompressedSize = uint64(copied)
+
+	if err := compressor.Close(); err != nil {
+		return result, fmt.Errorf("failed to close compressor: %w", err)
+	}
+
+	result.crc32 = checksum.Sum32()
+	result.compressedSize = counter.n
+	return result, nil
+}
+
+// exceeds32Bits reports whether either size needs ZIP64 records
+func (d entryData) exceeds32Bits() bool {
+	return d.compressedSize >= zip64Marker || d.uncompressedSize >= zip64Marker
+}
+
+// reserveZip64 reports whether entry is large enough that its sizes may not fit in 32 bits once
+// packed, leaving room for deflate overhead, the encryption header and a file that grows
+func reserveZip64(entry Entry) bool {
+	return !entry.Mode.IsDir() && entry.Size >= zip64ReserveSize
+}
+
+// patchLocalHeader fills in the CRC32 and sizes of the local header written at offset, which
+// are only known once the entry data has been streamed. ZIP64 headers keep the size markers and
+// take the sizes in the extra block reserved after the filename.
+func patchLocalHeader(file io.WriterAt, offset int64, filenameLen uint16, zip64 bool, data entryData) error {
+	fields := make([]byte, 12)
+	binary.LittleEndian.PutUint32(fields[0:4], data.crc32)
+	binary.LittleEndian.PutUint32(fields[4:8], uint32(data.compressedSize))
+	binary.LittleEndian.PutUint32(fields[8:12], uint32(data.uncompressedSize))
+	if zip64 {
+		fields = fields[:4]
+	}
+
+	if _, err := file.WriteAt(fields, offset+14); err != nil {
+		return fmt.Errorf("failed to update local file header: %w", err)
+	}
+
+	if zip64 {
+		extra := zipwriter.Zip64LocalExtra(data.uncompressedSize, data.compressedSize)
+		if _, err := file.WriteAt(extra, offset+30+int64(filenameLen)); err != nil {
+			return fmt.Errorf("failed to update local file header: %w", err)
+		}
+	}
+	return nil
+}
+
+// encryptionHeader returns the plaintext 12-byte encryption header for entry: 11 random bytes, or
+// in deterministic mode the leading bytes of a SHA-256 over the name and content, followed by
+// checkByte. Deterministic headers read the file an extra time before it is compressed.
+func (c *Creator) encryptionHeader(source Source, entry Entry, checkByte byte) ([]byte, error) {
+	header := make([]byte, 12)
+	header[11] = checkByte
+
+	if !c.Deterministic {
+		if _, err := rand.Read(header[:11]); err != nil {
+			return nil, fmt.Errorf("failed to generate random header: %w", err)
+		}
+		return header, nil
+	}
+
+	reader, err := source.Open(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer reader.Close()
+
+	digest := sha256.New()
+	digest.Write([]byte(entry.Name))
+	digest.Write([]byte{0})
+	if _, err := io.Copy(digest, reader); err != nil {
+		return nil, fmt.Errorf("failed to hash file: %w", err)
+	}
+	copy(header[:11], digest.Sum(nil))
+	return header, nil
+}
+
+// archiveWriter writes an archive sequentially and tracks the offset of the next byte, so no
+// Seek is needed. at is set for files, whose local headers are patched in place after their data
+// is streamed; streams leave it nil.
+type archiveWriter struct {
+	w      io.Writer
+	at     io.WriterAt
+	offset int64
+}
+
+// newFileArchiveWriter returns an archiveWriter for file, positioned at offset
+func newFileArchiveWriter(file *os.File, offset int64) *archiveWriter {
+	return &archiveWriter{w: file, at: file, offset: offset}
+}
+
+func (aw *archiveWriter) Write(p []byte) (int, error) {
+	n, err := aw.w.Write(p)
+	aw.offset += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += uint64(n)
+	return n, err
+}
+
+// encryptingWriter encrypts everything written through it with the PKZIP stream cipher, behind
+// the 12-byte encryption header written on creation
+type encryptingWriter struct {
+	w      io.Writer
+	cipher *zipcipher.ZipCipher
+}
+
+func newEncryptingWriter(w io.Writer, password []byte, header []byte) (*encryptingWriter, error) {
+	cipher := &zipcipher.ZipCipher{}
+	cipher.InitKeys(password)
+
+	if _, err := w.Write(cipher.EncryptData(header)); err != nil {
+		return nil, fmt.Errorf("failed to write encryption header: %w", err)
+	}
+	return &encryptingWriter{w: w, cipher: cipher}, nil
+}
+
+func (e *encryptingWriter) Write(p []byte) (int, error) {
+	return e.w.Write(e.cipher.EncryptData(p))
+}
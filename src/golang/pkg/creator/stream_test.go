@@ -0,0 +1,99 @@
+package creator
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+// failingWriter accepts limit bytes, then fails every write
+type failingWriter struct {
+	limit int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errWriteFailed
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestWriteToMatchesFile(t *testing.T) {
+	files := map[string]string{
+		"a.txt":         "content",
+		"dir/b.xml":     strings.Repeat("<b/>", 500),
+		"dir/sub/c.bin": "\x00\x01\x02",
+		"empty/":        "",
+	}
+
+	tests := []struct {
+		name    string
+		encrypt bool
+		dirs    bool
+	}{
+		{"encrypted", true, false},
+		{"plain", false, false},
+		{"empty directories", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceDir := t.TempDir()
+			writeTree(t, sourceDir, files)
+			newCreator := func() *Creator {
+				c := NewCreator(sourceDir, filepath.Join(t.TempDir(), "out.ipf"), tt.encrypt)
+				c.Deterministic = true // Random encryption headers would differ between the runs
+				c.IncludeEmptyDirs = tt.dirs
+				return c
+			}
+
+			want, err := os.ReadFile(createArchive(t, newCreator()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			n, err := newCreator().WriteTo(&buf)
+			if err != nil {
+				t.Fatalf("WriteTo: %v", err)
+			}
+			if n != int64(buf.Len()) {
+				t.Errorf("WriteTo returned %d, wrote %d bytes", n, buf.Len())
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Fatalf("WriteTo wrote %d bytes differing from the %d byte file", buf.Len(), len(want))
+			}
+
+			// The reader runs every name through the name cipher, so only encrypted archives read back
+			if !tt.encrypt {
+				return
+			}
+			path := ipftest.WriteFile(t, t.TempDir(), "buffer.ipf", buf.Bytes())
+			wantTree := map[string]string{"a.txt": files["a.txt"], "dir/b.xml": files["dir/b.xml"], "dir/sub/c.bin": files["dir/sub/c.bin"]}
+			if tt.dirs {
+				wantTree["empty/"] = ""
+			}
+			equalTrees(t, extractArchive(t, path, testPassword), wantTree)
+		})
+	}
+
+	t.Run("failing writer", func(t *testing.T) {
+		sourceDir := t.TempDir()
+		writeTree(t, sourceDir, files)
+		for _, limit := range []int{0, 10, 100} {
+			_, err := NewCreator(sourceDir, "", true).WriteTo(&failingWriter{limit: limit})
+			if !errors.Is(err, errWriteFailed) {
+				t.Errorf("WriteTo after %d bytes: error = %v, want errWriteFailed", limit, err)
+			}
+		}
+	})
+}
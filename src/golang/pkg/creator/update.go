@@ -0,0 +1,210 @@
+package creator
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipwriter"
+)
+
+// UpdateStats summarizes the changes made by UpdateIPF
+type UpdateStats struct {
+	Added     int
+	Replaced  int
+	Unchanged int
+}
+
+// UpdateIPF brings an existing archive up to date with the source (RootDir unless Source is set)
+// without rebuilding it. Entries whose files are unchanged keep their data where it is; new and
+// changed files are appended after the last existing entry and the central directory is
+// rewritten after them. A file counts as changed when its size or timestamp differ from the entry
+// and its CRC32 does too. Entries whose files are gone from the source are kept, and the data of
+// replaced entries stays in the file unreferenced until the archive is optimized. The changes are
+// made to a copy renamed over the archive once complete.
+func (c *Creator) UpdateIPF(existingIPF string) (UpdateStats, error) {
+	var stats UpdateStats
+
+	source, entries, err := c.listEntries()
+	if err != nil {
+		return stats, err
+	}
+
+	fileInfos, comment, err := c.readExistingEntries(existingIPF)
+	if err != nil {
+		return stats, err
+	}
+
+	byName := make(map[string]int, len(fileInfos))
+	for i := range fileInfos {
+		byName[c.existingName(&fileInfos[i])] = i
+	}
+
+	superseded := make(map[int]bool)
+	var changed []Entry
+	for _, entry := range entries {
+		index, exists := byName[c.archiveName(entry)]
+		if !exists {
+			changed = append(changed, entry)
+			stats.Added++
+			continue
+		}
+
+		same, err := c.sameContent(source, entry, &fileInfos[index])
+		if err != nil {
+			return stats, fmt.Errorf("failed to compare %s: %w", entry.Name, err)
+		}
+		if same {
+			stats.Unchanged++
+			continue
+		}
+		superseded[index] = true
+		changed = append(changed, entry)
+		stats.Replaced++
+	}
+
+	if len(changed) == 0 {
+		return stats, nil
+	}
+
+	// Work on a copy renamed over the archive once complete, so an interrupted update leaves the
+	// original readable
+	tempPath := existingIPF + ".tmp"
+	file, err := zipwriter.CopyArchive(existingIPF, tempPath)
+	if err != nil {
+		return stats, err
+	}
+	defer os.Remove(tempPath)
+	defer file.Close()
+
+	// Start after the data of every existing entry, including the ones being replaced
+	var appendOffset int64
+	for i := range fileInfos {
+		end, err := entryEnd(file, &fileInfos[i])
+		if err != nil {
+			return stats, err
+		}
+		appendOffset = max(appendOffset, end)
+	}
+	if _, err := file.Seek(appendOffset, io.SeekStart); err != nil {
+		return stats, fmt.Errorf("failed to seek to append offset: %w", err)
+	}
+
+	out := newFileArchiveWriter(file, appendOffset)
+	written, err := c.writeEntries(out, source, changed)
+	if err != nil {
+		return stats, err
+	}
+
+	kept := make([]ipf.FileInfo, 0, len(fileInfos)-len(superseded))
+	for i := range fileInfos {
+		if !superseded[i] {
+			kept = append(kept, fileInfos[i])
+		}
+	}
+	if err := c.writeCentralDirectory(out, kept, written, comment); err != nil {
+		return stats, err
+	}
+
+	if err := file.Truncate(out.offset); err != nil {
+		return stats, fmt.Errorf("failed to truncate archive: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return stats, fmt.Errorf("failed to sync archive: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return stats, fmt.Errorf("failed to close archive: %w", err)
+	}
+	if err := os.Rename(tempPath, existingIPF); err != nil {
+		return stats, fmt.Errorf("failed to replace archive: %w", err)
+	}
+	return stats, nil
+}
+
+// readExistingEntries reads the entries and comment of an archive, checking that its encryption
+// matches the creator's
+func (c *Creator) readExistingEntries(path string) ([]ipf.FileInfo, string, error) {
+	reader, err := ipf.NewIPFReader(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open IPF reader: %w", err)
+	}
+	defer reader.Close()
+
+	if err := reader.ReadFileStructure(); err != nil {
+		return nil, "", fmt.Errorf("failed to read file structure: %w", err)
+	}
+	if err := reader.ReadEncryptedFilenames(); err != nil {
+		return nil, "", fmt.Errorf("failed to read encrypted filenames: %w", err)
+	}
+
+	fileInfos := reader.GetFileInfos()
+	for i := range fileInfos {
+		if fileInfos[i].ZipInfo == nil || fileInfos[i].HeaderSize == 0 {
+			return nil, "", fmt.Errorf("entry %d has no readable local header", i)
+		}
+		if encrypted := fileInfos[i].ZipInfo.Flags&0x1 != 0; encrypted != c.encrypted() {
+			return nil, "", fmt.Errorf("entry %d encryption does not match the creator's (encrypted: %v)", i, encrypted)
+		}
+	}
+
+	return fileInfos, reader.Zip.Comment, nil
+}
+
+// existingName returns the plaintext name of an existing entry
+func (c *Creator) existingName(fileInfo *ipf.FileInfo) string {
+	if !c.encrypted() {
+		return string(fileInfo.EncryptedFilename)
+	}
+	return string(zipcipher.DecryptFilenameBytes(fileInfo.EncryptedFilename, c.Password))
+}
+
+// sameContent reports whether entry still matches the archived fileInfo. Matching size and
+// timestamp are trusted; otherwise, and always in deterministic mode where the stored timestamp
+// is fixed, the file's CRC32 decides.
+func (c *Creator) sameContent(source Source, entry Entry, fileInfo *ipf.FileInfo) (bool, error) {
+	if entry.Mode.IsDir() {
+		return true, nil
+	}
+	if uint64(entry.Size) != fileInfo.ZipInfo.UncompressedSize64 {
+		return false, nil
+	}
+
+	modTime, modDate := zipcipher.MSDOSTimestamp(time.Unix(entry.ModTime, 0))
+	if !c.Deterministic && modTime == fileInfo.ZipInfo.ModifiedTime && modDate == fileInfo.ZipInfo.ModifiedDate {
+		return true, nil
+	}
+
+	reader, err := source.Open(entry)
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	checksum := crc32.NewIEEE()
+	if _, err := io.Copy(checksum, reader); err != nil {
+		return false, err
+	}
+	return checksum.Sum32() == fileInfo.ZipInfo.CRC32, nil
+}
+
+// entryEnd returns the offset just past an entry's data and data descriptor
+func entryEnd(file *os.File, fileInfo *ipf.FileInfo) (int64, error) {
+	end := fileInfo.LocalHeaderOffset + int64(fileInfo.HeaderSize) + int64(fileInfo.ZipInfo.CompressedSize64)
+	if fileInfo.ZipInfo.Flags&0x8 == 0 {
+		return end, nil
+	}
+
+	// The descriptor signature is optional
+	signature := make([]byte, 4)
+	if _, err := file.ReadAt(signature, end); err != nil {
+		return 0, fmt.Errorf("failed to read data descriptor of entry %d: %w", fileInfo.Index, err)
+	}
+	if zipcipher.IsDataDescriptorSignature(signature) {
+		end += 4
+	}
+	return end + int64(zipcipher.DataDescriptorSize(zipcipher.HasZip64Extra(fileInfo.ZipInfo.Extra))), nil
+}
@@ -0,0 +1,113 @@
+package creator
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUpdateIPF(t *testing.T) {
+	original := map[string]string{"a.txt": "content a", "dir/b.xml": "<b/>"}
+
+	tests := []struct {
+		name      string
+		change    func(t *testing.T, sourceDir string)
+		wantStats UpdateStats
+		want      map[string]string
+	}{
+		{
+			name: "append one file",
+			change: func(t *testing.T, sourceDir string) {
+				writeTree(t, sourceDir, map[string]string{"dir/new.txt": "appended"})
+			},
+			wantStats: UpdateStats{Added: 1, Unchanged: 2},
+			want:      map[string]string{"a.txt": "content a", "dir/b.xml": "<b/>", "dir/new.txt": "appended"},
+		},
+		{
+			name: "replace a changed file",
+			change: func(t *testing.T, sourceDir string) {
+				writeTree(t, sourceDir, map[string]string{"dir/b.xml": "<b>patched</b>"})
+			},
+			wantStats: UpdateStats{Replaced: 1, Unchanged: 1},
+			want:      map[string]string{"a.txt": "content a", "dir/b.xml": "<b>patched</b>"},
+		},
+		{
+			name: "touched but identical",
+			change: func(t *testing.T, sourceDir string) {
+				later := time.Now().Add(time.Hour)
+				if err := os.Chtimes(filepath.Join(sourceDir, "a.txt"), later, later); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantStats: UpdateStats{Unchanged: 2},
+			want:      original,
+		},
+		{
+			name: "removed files are kept",
+			change: func(t *testing.T, sourceDir string) {
+				if err := os.Remove(filepath.Join(sourceDir, "a.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantStats: UpdateStats{Unchanged: 1},
+			want:      original,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceDir := t.TempDir()
+			writeTree(t, sourceDir, original)
+			path := createArchive(t, NewCreator(sourceDir, filepath.Join(t.TempDir(), "out.ipf"), true))
+			before, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tt.change(t, sourceDir)
+			stats, err := NewCreator(sourceDir, "", true).UpdateIPF(path)
+			if err != nil {
+				t.Fatalf("UpdateIPF: %v", err)
+			}
+			if stats != tt.wantStats {
+				t.Errorf("stats = %+v, want %+v", stats, tt.wantStats)
+			}
+			equalTrees(t, extractArchive(t, path, testPassword), tt.want)
+
+			// Existing entries are left in place; only the central directory after them is rewritten
+			after, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader := openArchive(t, path, testPassword)
+			dataEnd := reader.FileInfos[len(original)-1].LocalHeaderOffset
+			if !bytes.Equal(after[:dataEnd], before[:dataEnd]) {
+				t.Errorf("update rewrote existing entry data")
+			}
+			if stats.Added+stats.Replaced == 0 && !bytes.Equal(after, before) {
+				t.Errorf("update without changes modified the archive")
+			}
+		})
+	}
+}
+
+func TestUpdateIPFTwice(t *testing.T) {
+	sourceDir := t.TempDir()
+	files := map[string]string{"a.txt": "v1"}
+	writeTree(t, sourceDir, files)
+	path := createArchive(t, NewCreator(sourceDir, filepath.Join(t.TempDir(), "out.ipf"), true))
+
+	// Each version differs in size, since files matching an entry's size and timestamp are taken
+	// as unchanged without reading them
+	want := maps.Clone(files)
+	for _, version := range []string{"v22", "v333"} {
+		writeTree(t, sourceDir, map[string]string{"a.txt": version, version + ".txt": version})
+		want["a.txt"], want[version+".txt"] = version, version
+		if _, err := NewCreator(sourceDir, "", true).UpdateIPF(path); err != nil {
+			t.Fatalf("UpdateIPF: %v", err)
+		}
+	}
+	equalTrees(t, extractArchive(t, path, testPassword), want)
+}
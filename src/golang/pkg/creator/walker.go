@@ -4,17 +4,32 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
 )
 
 type FileInfo struct {
 	Path         string
 	RelativePath string
 	ModTime      int64
+	Mode         os.FileMode
+	Size         int64
 }
 
 type Walker struct {
-	RootDir   string
-	FileInfos []FileInfo
+	RootDir      string
+	FileInfos    []FileInfo
+	SkipEmpty    bool
+	SkippedEmpty int
+
+	IncludeEmptyDirs bool // Record empty directories as entries named with a trailing slash
+	FollowSymlinks   bool // Pack the targets of symbolic links instead of skipping them
+	SkippedSymlinks  int  // Links left out: all of them when not following, else dangling links and cycles
+
+	// ExcludePatterns are globs (see ipf.MatchGlob) for files and directories to leave out. A
+	// pattern with a slash is matched against the whole relative path, e.g. ".git/**"; one
+	// without is matched against the base name at any depth, e.g. "*.tmp".
+	ExcludePatterns []string
 }
 
 func NewWalker(rootDir string) *Walker {
@@ -25,32 +40,193 @@ func NewWalker(rootDir string) *Walker {
 }
 
 func (w *Walker) Walk() error {
-	return filepath.Walk(w.RootDir, func(path string, info os.FileInfo, err error) error {
+	return filepath.Walk(w.RootDir, w.visit)
+}
+
+// visit is the filepath.WalkFunc recording the files and empty directories under the root
+func (w *Walker) visit(path string, info os.FileInfo, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if w.excluded(path) {
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		return w.visitSymlink(path)
+	}
+
+	if info.IsDir() {
+		if w.IncludeEmptyDirs && path != w.RootDir && w.FilterHiddenFiles(path) {
+			return w.addEmptyDir(path, info)
+		}
+		return nil
+	}
+
+	if w.SkipEmpty && info.Mode().IsRegular() && info.Size() == 0 {
+		w.SkippedEmpty++
+		return nil
+	}
+
+	if w.FilterHiddenFiles(path) {
+		relPath, err := filepath.Rel(w.RootDir, path)
 		if err != nil {
 			return err
 		}
 
-		if info.IsDir() {
+		relPath = filepath.ToSlash(relPath)
+
+		w.FileInfos = append(w.FileInfos, FileInfo{
+			Path:         path,
+			RelativePath: relPath,
+			ModTime:      info.ModTime().Unix(),
+			Mode:         info.Mode(),
+			Size:         info.Size(),
+		})
+	}
+
+	return nil
+}
+
+// visitSymlink records the target of the link at path under the link's own name. Links are
+// skipped unless FollowSymlinks is set, except for the root itself; a link to one of its own
+// ancestors is skipped as well, since following it would never end.
+func (w *Walker) visitSymlink(path string) error {
+	if !w.FollowSymlinks && path != w.RootDir {
+		w.SkippedSymlinks++
+		return nil
+	}
+
+	target, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			w.SkippedSymlinks++
 			return nil
 		}
+		return err
+	}
+	if !target.IsDir() {
+		return w.visit(path, target, nil)
+	}
 
-		if w.FilterHiddenFiles(path) {
-			relPath, err := filepath.Rel(w.RootDir, path)
-			if err != nil {
-				return err
-			}
+	cycle, err := w.linksToAncestor(path, target)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		w.SkippedSymlinks++
+		return nil
+	}
 
-			relPath = filepath.ToSlash(relPath)
+	// filepath.Walk does not descend into links, so walk the target's children under the link
+	if err := w.visit(path, target, nil); err != nil {
+		return err
+	}
+	children, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := filepath.Walk(filepath.Join(path, child.Name()), w.visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			w.FileInfos = append(w.FileInfos, FileInfo{
-				Path:         path,
-				RelativePath: relPath,
-				ModTime:      info.ModTime().Unix(),
-			})
+// linksToAncestor reports whether target, the directory behind the link at path, is the root or
+// one of the directories the walk passed through to reach the link. Directories are compared by
+// device and inode, so the check also sees through earlier links on the path.
+func (w *Walker) linksToAncestor(path string, target os.FileInfo) (bool, error) {
+	root := filepath.Clean(w.RootDir)
+	for dir := filepath.Dir(path); len(dir) >= len(root); dir = filepath.Dir(dir) {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return false, err
+		}
+		if os.SameFile(info, target) {
+			return true, nil
+		}
+		if dir == root {
+			break
 		}
+	}
+	return false, nil
+}
 
-		return nil
+// addEmptyDir records the directory at path if none of its children end up in the archive
+func (w *Walker) addEmptyDir(path string, info os.FileInfo) error {
+	children, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if w.packs(filepath.Join(path, child.Name()), child) {
+			return nil
+		}
+	}
+
+	relPath, err := filepath.Rel(w.RootDir, path)
+	if err != nil {
+		return err
+	}
+
+	w.FileInfos = append(w.FileInfos, FileInfo{
+		Path:         path,
+		RelativePath: filepath.ToSlash(relPath) + "/",
+		ModTime:      info.ModTime().Unix(),
+		Mode:         info.Mode(),
 	})
+	return nil
+}
+
+// packs reports whether the directory entry at path contributes an entry of its own; hidden and
+// skipped empty files do not, so a directory holding only those is still stored as empty
+func (w *Walker) packs(path string, child os.DirEntry) bool {
+	if w.excluded(path) {
+		return false
+	}
+	if child.IsDir() {
+		return true
+	}
+	if child.Type()&os.ModeSymlink != 0 {
+		return w.FollowSymlinks
+	}
+	if !w.FilterHiddenFiles(path) {
+		return false
+	}
+	if w.SkipEmpty && child.Type().IsRegular() {
+		info, err := child.Info()
+		return err != nil || info.Size() > 0
+	}
+	return true
+}
+
+// excluded reports whether path matches one of the ExcludePatterns
+func (w *Walker) excluded(path string) bool {
+	if len(w.ExcludePatterns) == 0 {
+		return false
+	}
+	relPath, err := filepath.Rel(w.RootDir, path)
+	if err != nil || relPath == "." {
+		return false
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	for _, pattern := range w.ExcludePatterns {
+		name := relPath
+		if !strings.Contains(pattern, "/") {
+			name = filepath.Base(path)
+		}
+		if ipf.MatchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
 }
 
 func (w *Walker) FilterHiddenFiles(path string) bool {
@@ -0,0 +1,154 @@
+package creator
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestWalkerSymlinks(t *testing.T) {
+	tests := []struct {
+		name        string
+		follow      bool
+		want        []string
+		wantSkipped int
+	}{
+		{
+			name:        "skipped",
+			follow:      false,
+			want:        []string{"data/b.txt", "real.txt"},
+			wantSkipped: 4, // file_link, dir_link, data/loop and dangling
+		},
+		{
+			name:        "followed",
+			follow:      true,
+			want:        []string{"data/b.txt", "dir_link/b.txt", "file_link", "real.txt"},
+			wantSkipped: 3, // data/loop and dir_link/loop lead back to the root; dangling has no target
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, map[string]string{"real.txt": "real", "data/b.txt": "b"})
+			links := map[string]string{
+				"file_link": "real.txt",
+				"dir_link":  "data",
+				"data/loop": "..",
+				"dangling":  "missing.txt",
+			}
+			for link, target := range links {
+				if err := os.Symlink(target, filepath.Join(root, filepath.FromSlash(link))); err != nil {
+					t.Skipf("symbolic links unavailable: %v", err)
+				}
+			}
+
+			walker := NewWalker(root)
+			walker.FollowSymlinks = tt.follow
+			if err := walker.Walk(); err != nil {
+				t.Fatalf("Walk: %v", err)
+			}
+
+			var got []string
+			for _, fileInfo := range walker.FileInfos {
+				got = append(got, fileInfo.RelativePath)
+			}
+			sort.Strings(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("walked %v, want %v", got, tt.want)
+			}
+			if walker.SkippedSymlinks != tt.wantSkipped {
+				t.Errorf("SkippedSymlinks = %d, want %d", walker.SkippedSymlinks, tt.wantSkipped)
+			}
+
+			// Followed links are packed with their targets' content under the link's name
+			c := NewCreator(root, filepath.Join(t.TempDir(), "out.ipf"), true)
+			c.FollowSymlinks = tt.follow
+			extracted := extractArchive(t, createArchive(t, c), testPassword)
+			if tt.follow && (extracted["file_link"] != "real" || extracted["dir_link/b.txt"] != "b") {
+				t.Errorf("extracted %v, want the link targets' content", extracted)
+			}
+			if len(extracted) != len(tt.want) {
+				t.Errorf("extracted %d files, want %d", len(extracted), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestWalkerSymlinkRoot(t *testing.T) {
+	target := t.TempDir()
+	writeTree(t, target, map[string]string{"a.txt": "a"})
+	root := filepath.Join(t.TempDir(), "root_link")
+	if err := os.Symlink(target, root); err != nil {
+		t.Skipf("symbolic links unavailable: %v", err)
+	}
+
+	// The root is always followed, even when other links are not
+	walker := NewWalker(root)
+	if err := walker.Walk(); err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	if len(walker.FileInfos) != 1 || walker.FileInfos[0].RelativePath != "a.txt" {
+		t.Errorf("walked %+v, want a.txt", walker.FileInfos)
+	}
+}
+
+func TestWalkerExcludes(t *testing.T) {
+	files := map[string]string{
+		"a.txt":              "a",
+		"b.tmp":              "b",
+		"logs/run.log":       "log",
+		"logs/keep.txt":      "keep",
+		".git/config":        "git",
+		".git/objects/ab/cd": "object",
+		"build/out/x.o":      "object",
+		"src/main.go":        "package main",
+		"src/tmp/cache.tmp":  "cache",
+	}
+	all := []string{".git/config", ".git/objects/ab/cd", "a.txt", "b.tmp", "build/out/x.o", "logs/keep.txt", "logs/run.log", "src/main.go", "src/tmp/cache.tmp"}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{"no patterns", nil, all},
+		{"base name at any depth", []string{"*.tmp"}, []string{".git/config", ".git/objects/ab/cd", "a.txt", "build/out/x.o", "logs/keep.txt", "logs/run.log", "src/main.go"}},
+		{"nested directory", []string{"out"}, []string{".git/config", ".git/objects/ab/cd", "a.txt", "b.tmp", "logs/keep.txt", "logs/run.log", "src/main.go", "src/tmp/cache.tmp"}},
+		{"relative path", []string{"src/**/*.tmp"}, []string{".git/config", ".git/objects/ab/cd", "a.txt", "b.tmp", "build/out/x.o", "logs/keep.txt", "logs/run.log", "src/main.go"}},
+		{"several patterns", []string{"*.tmp", "*.log", ".git/**"}, []string{"a.txt", "build/out/x.o", "logs/keep.txt", "src/main.go"}},
+		{"whole directory", []string{".git"}, []string{"a.txt", "b.tmp", "build/out/x.o", "logs/keep.txt", "logs/run.log", "src/main.go", "src/tmp/cache.tmp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, files)
+
+			walker := NewWalker(root)
+			walker.ExcludePatterns = tt.patterns
+			if err := walker.Walk(); err != nil {
+				t.Fatalf("Walk: %v", err)
+			}
+			var got []string
+			for _, fileInfo := range walker.FileInfos {
+				got = append(got, fileInfo.RelativePath)
+			}
+			sort.Strings(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("walked %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// An excluded folder's parent counts as empty when nothing else is in it
+	t.Run("empty after excludes", func(t *testing.T) {
+		root := t.TempDir()
+		writeTree(t, root, map[string]string{"a.txt": "a", "cache/x.tmp": "x"})
+
+		c := NewCreator(root, filepath.Join(t.TempDir(), "out.ipf"), true)
+		c.ExcludePatterns = []string{"*.tmp"}
+		c.IncludeEmptyDirs = true
+		equalTrees(t, extractArchive(t, createArchive(t, c), testPassword), map[string]string{"a.txt": "a", "cache/": ""})
+	})
+}
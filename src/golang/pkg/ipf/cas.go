@@ -0,0 +1,129 @@
+package ipf
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/workers"
+)
+
+// CASManifest maps logical file names to the SHA-256 of their content in a content-addressed store
+type CASManifest struct {
+	Files map[string]string `json:"files"`
+}
+
+// CASBlobPath returns the path of a blob inside storeDir for the given hex SHA-256 (ab/cd/<sha256>)
+func CASBlobPath(storeDir, hash string) string {
+	return filepath.Join(storeDir, hash[0:2], hash[2:4], hash)
+}
+
+// ExtractToCAS extracts all files into a content-addressed store where each blob is named by the
+// SHA-256 of its content, and writes a JSON manifest mapping logical names to hashes.
+// Identical content (within or across archives sharing storeDir) is stored only once.
+func (ce *ConcurrentExtractor) ExtractToCAS(ctx context.Context, storeDir, manifestPath string, password []byte) ([]ExtractionResult, error) {
+	tracker := ce.startProgress()
+	defer tracker.finish()
+
+	tasks, skipped, err := ce.prepareTasks(storeDir, password)
+	if err != nil {
+		return nil, err
+	}
+
+	tracker.total = len(tasks)
+
+	manifest := CASManifest{Files: make(map[string]string, len(tasks))}
+	var manifestMu sync.Mutex
+
+	results, err := workers.Map(ctx, tasks, ce.workerCount, tracker.track(func(task ExtractionTask) ExtractionResult {
+		result, hash := ce.extractToStore(task, storeDir)
+		if result.Success {
+			manifestMu.Lock()
+			manifest.Files[task.FileInfo.SafeFilename] = hash
+			manifestMu.Unlock()
+		}
+		return result
+	}))
+	ce.restoreIndexOrder(results)
+	results = append(results, skipped...)
+	if err != nil {
+		// The manifest would miss the files that never ran
+		return results, err
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return results, fmt.Errorf("failed to encode CAS manifest: %w", err)
+	}
+	if err := os.WriteFile(manifestPath, manifestData, 0644); err != nil {
+		return results, fmt.Errorf("failed to write CAS manifest %s: %w", manifestPath, err)
+	}
+
+	return results, nil
+}
+
+// extractToStore extracts a single file into the store, returning its result and content hash
+func (ce *ConcurrentExtractor) extractToStore(task ExtractionTask, storeDir string) (ExtractionResult, string) {
+	startTime := getTimeMillis()
+
+	if task.FileInfo == nil || task.FileInfo.ZipInfo == nil {
+		return ExtractionResult{
+			Index:   task.Index,
+			Success: false,
+			Error:   fmt.Errorf("file %d has no ZIP info", task.Index),
+		}, ""
+	}
+
+	// Directories have no content to store
+	if task.FileInfo.IsDir() {
+		return ExtractionResult{Index: task.Index, Skipped: true}, ""
+	}
+
+	data, err := ce.extractWithCustomDecryption(task)
+	if err != nil {
+		return ExtractionResult{
+			Index:   task.Index,
+			Success: false,
+			Error:   fmt.Errorf("custom extraction failed: %w", err),
+		}, ""
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	blobPath := CASBlobPath(storeDir, hash)
+
+	// Blob already stored by another entry or archive
+	if stat, err := os.Stat(blobPath); err == nil && stat.Size() == int64(len(data)) {
+		return ExtractionResult{
+			Index:      task.Index,
+			Success:    true,
+			FilePath:   blobPath,
+			Size:       int64(len(data)),
+			DurationMs: getTimeMillis() - startTime,
+		}, hash
+	}
+
+	// Write to a temporary file first so concurrent writers of the same blob never see partial data
+	tempPath := fmt.Sprintf("%s.%d.tmp", blobPath, task.Index)
+	result := ce.writeExtractedData(data, task.openFiles, tempPath, task.Index, startTime)
+	if !result.Success {
+		return result, ""
+	}
+
+	if err := os.Rename(tempPath, blobPath); err != nil {
+		os.Remove(tempPath)
+		return ExtractionResult{
+			Index:   task.Index,
+			Success: false,
+			Error:   fmt.Errorf("failed to store blob %s: %w", blobPath, err),
+		}, ""
+	}
+
+	result.FilePath = blobPath
+	return result, hash
+}
@@ -0,0 +1,60 @@
+package ipf
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestExtractToCAS(t *testing.T) {
+	shared := []byte("identical content")
+	archive := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "a/one.txt", Data: shared},
+		ipftest.Entry{Name: "b/two.txt", Data: shared},
+		ipftest.Entry{Name: "c/three.txt", Data: []byte("something else")},
+	)
+	reader := openArchive(t, archive, testPassword)
+
+	storeDir := t.TempDir()
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	results, err := NewConcurrentExtractor(reader, nil, 3).ExtractToCAS(context.Background(), storeDir, manifestPath, testPassword)
+	requireSuccess(t, results, err)
+
+	blobs := readTree(t, storeDir)
+	if len(blobs) != 2 {
+		t.Errorf("store holds %d blobs, want 2: %v", len(blobs), blobs)
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	var manifest CASManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("failed to decode manifest: %v", err)
+	}
+
+	sum := sha256.Sum256(shared)
+	sharedHash := hex.EncodeToString(sum[:])
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"a/one.txt", sharedHash},
+		{"b/two.txt", sharedHash},
+	}
+	for _, tt := range tests {
+		if got := manifest.Files[tt.name]; got != tt.hash {
+			t.Errorf("manifest[%s] = %s, want %s", tt.name, got, tt.hash)
+		}
+	}
+	if blob, err := os.ReadFile(CASBlobPath(storeDir, sharedHash)); err != nil || string(blob) != string(shared) {
+		t.Errorf("shared blob = %q, %v", blob, err)
+	}
+}
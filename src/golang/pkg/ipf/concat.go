@@ -0,0 +1,127 @@
+package ipf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// concatPart is the decrypted content of one entry waiting to be written by ExtractConcat
+type concatPart struct {
+	data []byte
+	err  error
+}
+
+// ExtractConcat extracts every file whose name matches one of patterns (all files when patterns is
+// empty) and writes their contents sequentially to out in archive index order, separated by
+// ConcatSeparator. Decryption runs in parallel while writes stay serialized and ordered; at most
+// ConcatBuffer decrypted entries are held in memory at once. Extraction stops at the
+// first failed entry so out never receives a concatenation with a silent gap.
+func (ce *ConcurrentExtractor) ExtractConcat(ctx context.Context, out io.Writer, patterns []string, password []byte) ([]ExtractionResult, error) {
+	tracker := ce.startProgress()
+	defer tracker.finish()
+
+	candidates, skipped := ce.selectTasks("", password)
+	tasks := make([]ExtractionTask, 0, len(candidates))
+	for _, task := range candidates {
+		if task.FileInfo.IsDir() || !matchesAny(patterns, matchName(task.FileInfo), ce.IgnoreCase) {
+			skipped = append(skipped, ExtractionResult{Index: task.Index, Skipped: true})
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Index < tasks[j].Index
+	})
+	tracker.total = len(tasks)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Each slot is buffered so workers never block on a writer that gave up
+	slots := make([]chan concatPart, len(tasks))
+	for i := range slots {
+		slots[i] = make(chan concatPart, 1)
+	}
+
+	// window bounds the number of entries decrypted but not yet written
+	window := make(chan struct{}, ce.concatBuffer())
+	go func() {
+		for i, task := range tasks {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(slot chan concatPart, task ExtractionTask) {
+				data, err := ce.extractWithCustomDecryption(task)
+				slot <- concatPart{data: data, err: err}
+			}(slots[i], task)
+		}
+	}()
+
+	results := make([]ExtractionResult, 0, len(tasks)+len(skipped))
+	for i, task := range tasks {
+		var part concatPart
+		select {
+		case part = <-slots[i]:
+		case <-ctx.Done():
+			return append(results, skipped...), ctx.Err()
+		}
+
+		result := tracker.track(func(task ExtractionTask) ExtractionResult {
+			return ce.writeConcatPart(out, task, part, i > 0)
+		})(task)
+		<-window
+
+		results = append(results, result)
+		if !result.Success {
+			return append(results, skipped...), result.Error
+		}
+	}
+
+	return append(results, skipped...), nil
+}
+
+// writeConcatPart writes one decrypted entry to out, preceded by the separator when needed
+func (ce *ConcurrentExtractor) writeConcatPart(out io.Writer, task ExtractionTask, part concatPart, separate bool) ExtractionResult {
+	startTime := getTimeMillis()
+
+	if part.err != nil {
+		return ExtractionResult{
+			Index:   task.Index,
+			Success: false,
+			Error:   fmt.Errorf("custom extraction failed for %s: %w", task.FileInfo.SafeFilename, part.err),
+		}
+	}
+
+	if separate && len(ce.ConcatSeparator) > 0 {
+		if _, err := out.Write(ce.ConcatSeparator); err != nil {
+			return ExtractionResult{
+				Index:   task.Index,
+				Success: false,
+				Error:   fmt.Errorf("failed to write separator: %w", err),
+			}
+		}
+	}
+
+	written, err := out.Write(part.data)
+	if err != nil {
+		return ExtractionResult{
+			Index:   task.Index,
+			Success: false,
+			Error:   fmt.Errorf("failed to write %s: %w", task.FileInfo.SafeFilename, err),
+		}
+	}
+
+	return ExtractionResult{
+		Index:      task.Index,
+		Success:    true,
+		Method:     task.FileInfo.Method(),
+		FilePath:   task.FileInfo.SafeFilename,
+		Size:       int64(written),
+		DurationMs: getTimeMillis() - startTime,
+	}
+}
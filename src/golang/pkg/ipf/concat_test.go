@@ -0,0 +1,169 @@
+package ipf
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+// concatEntries returns count numbered text entries whose content names their position
+func concatEntries(count int) []ipftest.Entry {
+	entries := make([]ipftest.Entry, count)
+	for i := range entries {
+		method := uint16(zip.Store)
+		if i%2 == 0 {
+			method = zip.Deflate
+		}
+		entries[i] = ipftest.Entry{
+			Name:   fmt.Sprintf("part/%02d.txt", i),
+			Data:   []byte(fmt.Sprintf("[part %02d]", i)),
+			Method: method,
+		}
+	}
+	return entries
+}
+
+func TestExtractConcat(t *testing.T) {
+	entries := append(concatEntries(20), ipftest.Entry{Name: "other/skip.bin", Data: []byte("skip")})
+	reader := openArchive(t, ipftest.Build(t, testPassword, entries...), testPassword)
+
+	// want concatenates the contents of the part entries in index order
+	want := func(separator string) string {
+		var parts []string
+		for _, entry := range entries[:20] {
+			parts = append(parts, string(entry.Data))
+		}
+		return strings.Join(parts, separator)
+	}
+
+	tests := []struct {
+		name      string
+		patterns  []string
+		separator string
+		workers   int
+		want      string
+	}{
+		{"every entry", nil, "", 4, want("") + "skip"},
+		{"matching entries", []string{"part/*.txt"}, "", 8, want("")},
+		{"with separator", []string{"part/*.txt"}, "\n", 8, want("\n")},
+		{"single worker", []string{"part/*.txt"}, "|", 1, want("|")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractor := NewConcurrentExtractor(reader, nil, tt.workers)
+			extractor.ConcatSeparator = []byte(tt.separator)
+
+			var out bytes.Buffer
+			results, err := extractor.ExtractConcat(context.Background(), &out, tt.patterns, testPassword)
+			if err != nil {
+				t.Fatalf("ExtractConcat: %v", err)
+			}
+			for _, result := range results {
+				if !result.Success && !result.Skipped {
+					t.Errorf("file %d failed: %v", result.Index, result.Error)
+				}
+			}
+			if out.String() != tt.want {
+				t.Errorf("ExtractConcat wrote\n%q\nwant\n%q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+// dataReadRecorder records which entries had their stored data read through it
+type dataReadRecorder struct {
+	io.ReaderAt
+	starts []int64 // Offset of the stored data of each entry
+
+	mu   sync.Mutex
+	read map[int]bool
+}
+
+func (r *dataReadRecorder) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	for i, start := range r.starts {
+		if off == start {
+			r.read[i] = true
+		}
+	}
+	r.mu.Unlock()
+	return r.ReaderAt.ReadAt(p, off)
+}
+
+func (r *dataReadRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.read)
+}
+
+// blockingWriter holds its first Write until release is closed
+type blockingWriter struct {
+	bytes.Buffer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	if w.Len() == 0 {
+		close(w.started)
+		<-w.release
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestExtractConcatBufferBound(t *testing.T) {
+	entries := concatEntries(24)
+	for i := range entries {
+		entries[i].Method = zip.Store
+	}
+	archive := ipftest.Build(t, testPassword, entries...)
+
+	for _, buffer := range []int{1, 3, 8} {
+		t.Run(fmt.Sprintf("buffer %d", buffer), func(t *testing.T) {
+			recorder := &dataReadRecorder{ReaderAt: bytes.NewReader(archive), read: make(map[int]bool)}
+			for i := range entries {
+				recorder.starts = append(recorder.starts, int64(ipftest.DataOffset(t, archive, i)))
+			}
+			reader := openArchive(t, archive, testPassword)
+			reader.source = recorder
+
+			extractor := NewConcurrentExtractor(reader, nil, 8)
+			extractor.ConcatBuffer = buffer
+			out := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := extractor.ExtractConcat(context.Background(), out, nil, testPassword)
+				done <- err
+			}()
+
+			// While the first entry is being written, give the workers time to run ahead as far
+			// as the buffer lets them
+			<-out.started
+			deadline := time.Now().Add(time.Second)
+			for recorder.count() < buffer && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			time.Sleep(50 * time.Millisecond)
+			if got := recorder.count(); got > buffer {
+				t.Errorf("%d entries decrypted while the first was written, want at most %d", got, buffer)
+			}
+			close(out.release)
+
+			if err := <-done; err != nil {
+				t.Fatalf("ExtractConcat: %v", err)
+			}
+			if recorder.count() != len(entries) {
+				t.Errorf("%d entries read, want %d", recorder.count(), len(entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package ipf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/workers"
+)
+
+// ExtractWithDeadline extracts all files like ExtractAllParallel but stops scheduling new files
+// once d has elapsed. Files already being extracted are finished. The returned results cover only
+// the files that ran (plus skipped ones); unstarted files are absent rather than failed. The error
+// wraps context.DeadlineExceeded when the deadline cut extraction short.
+func (ce *ConcurrentExtractor) ExtractWithDeadline(outputDir string, password []byte, d time.Duration) ([]ExtractionResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	tracker := ce.startProgress()
+	defer tracker.finish()
+
+	tasks, skipped, err := ce.prepareTasks(outputDir, password)
+	if err != nil {
+		return nil, err
+	}
+
+	tracker.total = len(tasks)
+
+	// Unstarted tasks are left as zero results by Map
+	started := make([]bool, len(tasks))
+	positions := make(map[int]int, len(tasks))
+	for i := range tasks {
+		positions[tasks[i].Index] = i
+	}
+
+	extract := tracker.track(ce.ExtractSingle)
+	results, err := workers.Map(ctx, tasks, ce.workerCount, func(task ExtractionTask) ExtractionResult {
+		started[positions[task.Index]] = true
+		return extract(task)
+	})
+	var panicErr *workers.PanicError
+	if errors.As(err, &panicErr) {
+		return nil, err
+	}
+	ce.applyDirModes(tasks, results)
+
+	completed := make([]ExtractionResult, 0, len(results)+len(skipped))
+	for i, result := range results {
+		if started[i] {
+			completed = append(completed, result)
+		}
+	}
+	ce.restoreIndexOrder(completed)
+	completed = append(completed, skipped...)
+
+	if len(completed)-len(skipped) < len(tasks) {
+		return completed, fmt.Errorf("extraction stopped after %d of %d files: %w", len(completed)-len(skipped), len(tasks), ctx.Err())
+	}
+
+	return completed, nil
+}
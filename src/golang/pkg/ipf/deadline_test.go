@@ -0,0 +1,59 @@
+package ipf
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestExtractWithDeadline(t *testing.T) {
+	entries := sizedEntries(40, 64)
+	reader := openArchive(t, ipftest.Build(t, testPassword, entries...), testPassword)
+
+	tests := []struct {
+		name     string
+		deadline time.Duration
+		partial  bool
+	}{
+		{"deadline cuts extraction short", 50 * time.Millisecond, true},
+		{"deadline not reached", time.Minute, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractor := NewConcurrentExtractor(reader, nil, 2)
+			// Each file takes 10ms, so 40 files on 2 workers need about 200ms
+			extractor.AfterExtract = func(fi *FileInfo, path string) error {
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			}
+
+			start := time.Now()
+			results, err := extractor.ExtractWithDeadline(t.TempDir(), testPassword, tt.deadline)
+			if elapsed := time.Since(start); tt.partial && elapsed > tt.deadline+time.Second {
+				t.Errorf("extraction ran %v past a %v deadline", elapsed, tt.deadline)
+			}
+
+			if tt.partial {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Errorf("error = %v, want context.DeadlineExceeded", err)
+				}
+				if len(results) == 0 || len(results) >= len(entries) {
+					t.Errorf("got %d results, want a partial extraction of %d files", len(results), len(entries))
+				}
+			} else if err != nil || len(results) != len(entries) {
+				t.Errorf("got %d results and error %v, want all %d files", len(results), err, len(entries))
+			}
+			for i, result := range results {
+				if !result.Success {
+					t.Errorf("file %d failed: %v", result.Index, result.Error)
+				}
+				if i > 0 && result.Index <= results[i-1].Index {
+					t.Errorf("results out of index order: %d after %d", result.Index, results[i-1].Index)
+				}
+			}
+		})
+	}
+}
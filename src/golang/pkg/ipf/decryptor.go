@@ -6,6 +6,8 @@ import (
 	"runtime"
 	"sync"
 	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/joao-paulo-santos/GE-Library/pkg/workers"
 	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
@@ -24,12 +26,24 @@ type DecryptionResult struct {
 	DecryptedFilename string
 	SafeFilename      string
 	Success           bool
+	Encoding          string // Encoding that decoded the name ("utf-8", "cp932", "cp1252" or EncodingCustom); empty on failure
 }
 
+// EncodingCustom is the DecryptionResult.Encoding of names decoded by FilenameDecryptor.CustomDecoder
+const EncodingCustom = "custom"
+
 // FilenameDecryptor handles parallel decryption of filenames
 type FilenameDecryptor struct {
 	password    []byte
 	workerCount int
+
+	// CustomDecoder is tried on the decrypted filename bytes when none of the built-in
+	// encodings produce a valid name, e.g. to plug in EUC-KR or GBK decoding
+	CustomDecoder func(decrypted []byte) (string, bool)
+
+	// MinValidRatio is the share of printable runes a decrypted name needs to count as decoded.
+	// Lowering it accepts more unusual names at the cost of more garbage with a wrong password.
+	MinValidRatio float64
 }
 
 // NewFilenameDecryptor creates a new filename decryptor
@@ -39,8 +53,9 @@ func NewFilenameDecryptor(password []byte, workerCount int) *FilenameDecryptor {
 	}
 
 	return &FilenameDecryptor{
-		password:    password,
-		workerCount: workerCount,
+		password:      password,
+		workerCount:   workerCount,
+		MinValidRatio: zipcipher.DefaultMinValidFilenameRatio,
 	}
 }
 
@@ -55,7 +70,13 @@ func (fd *FilenameDecryptor) DecryptSingle(task DecryptionTask) DecryptionResult
 	}
 
 	// Decrypt filename
-	decrypted, success := zipcipher.DecryptFilename(task.EncryptedFilename, fd.password)
+	decrypted, encoding, success := zipcipher.DecryptFilenameWithRatio(task.EncryptedFilename, fd.password, fd.MinValidRatio)
+
+	// Fall back to the user-supplied decoder
+	if !success && fd.CustomDecoder != nil {
+		decrypted, success = fd.CustomDecoder(zipcipher.DecryptFilenameBytes(task.EncryptedFilename, fd.password))
+		encoding = EncodingCustom
+	}
 
 	if !success {
 		return DecryptionResult{
@@ -76,6 +97,7 @@ func (fd *FilenameDecryptor) DecryptSingle(task DecryptionTask) DecryptionResult
 		DecryptedFilename: decrypted,
 		SafeFilename:      safeFilename,
 		Success:           success,
+		Encoding:          encoding,
 	}
 }
 
@@ -95,14 +117,11 @@ func (fd *FilenameDecryptor) DecryptAllParallel(ctx context.Context, fileInfos [
 		}
 	}
 
-	// Create parallel processor
-	processor := workers.NewParallelProcessor[DecryptionTask, DecryptionResult](
-		fd.workerCount,
-		len(tasks),
-	)
-
 	// Process all tasks in parallel
-	results := processor.Process(ctx, tasks, fd.DecryptSingle)
+	results, err := workers.Map(ctx, tasks, fd.workerCount, fd.DecryptSingle)
+	if err != nil {
+		return nil, err
+	}
 
 	// Validate results
 	if len(results) != len(fileInfos) {
@@ -113,18 +132,57 @@ func (fd *FilenameDecryptor) DecryptAllParallel(ctx context.Context, fileInfos [
 	return results, nil
 }
 
+// DecryptOutcome classifies a whole filename decryption run
+type DecryptOutcome int
+
+const (
+	DecryptOutcomeOK            DecryptOutcome = iota // Every filename decoded
+	DecryptOutcomePartial                             // Some filenames failed, e.g. corrupt entries or an unknown encoding
+	DecryptOutcomeWrongPassword                       // Almost nothing decoded, the password is most likely wrong
+)
+
+func (o DecryptOutcome) String() string {
+	switch o {
+	case DecryptOutcomeOK:
+		return "ok"
+	case DecryptOutcomePartial:
+		return "partial"
+	case DecryptOutcomeWrongPassword:
+		return "wrong password"
+	default:
+		return fmt.Sprintf("DecryptOutcome(%d)", int(o))
+	}
+}
+
+// Default thresholds used by Classify
+const (
+	DefaultWrongPasswordRate       = 10.0
+	DefaultWrongPasswordMinEntries = 3
+)
+
 // DecryptResultProcessor handles processing and organizing decryption results
 type DecryptResultProcessor struct {
 	results      []DecryptionResult
 	successCount int64
 	totalCount   int
+
+	// WrongPasswordRate is the share (percent) of plausible names at or below which Classify
+	// blames the password. Short scrambled names occasionally come out printable, so a wrong
+	// password rarely scores exactly zero.
+	WrongPasswordRate float64
+
+	// WrongPasswordMinEntries is the fewest entries needed before Classify reports a wrong password;
+	// smaller archives only ever report DecryptOutcomePartial
+	WrongPasswordMinEntries int
 }
 
 // NewDecryptResultProcessor creates a new result processor
 func NewDecryptResultProcessor(expectedCount int) *DecryptResultProcessor {
 	return &DecryptResultProcessor{
-		results:    make([]DecryptionResult, expectedCount),
-		totalCount: expectedCount,
+		results:                 make([]DecryptionResult, expectedCount),
+		totalCount:              expectedCount,
+		WrongPasswordRate:       DefaultWrongPasswordRate,
+		WrongPasswordMinEntries: DefaultWrongPasswordMinEntries,
 	}
 }
 
@@ -150,6 +208,17 @@ func (drp *DecryptResultProcessor) GetSuccessCount() int64 {
 	return atomic.LoadInt64(&drp.successCount)
 }
 
+// EncodingBreakdown counts the successfully decrypted names by the encoding that decoded them
+func (drp *DecryptResultProcessor) EncodingBreakdown() map[string]int {
+	breakdown := make(map[string]int)
+	for _, result := range drp.results {
+		if result.Success {
+			breakdown[result.Encoding]++
+		}
+	}
+	return breakdown
+}
+
 // GetSuccessRate returns the success rate as a percentage
 func (drp *DecryptResultProcessor) GetSuccessRate() float64 {
 	if drp.totalCount == 0 {
@@ -158,7 +227,54 @@ func (drp *DecryptResultProcessor) GetSuccessRate() float64 {
 	return float64(drp.successCount) / float64(drp.totalCount) * 100.0
 }
 
-// UpdateFileInfos updates the original FileInfo structs with decrypted names
+// Classify tells a wrong password apart from an archive with some undecodable names. The
+// single-byte fallbacks accept nearly any bytes, so a wrong key still yields "successful" names;
+// Classify therefore also requires names to be free of control and unprintable characters.
+func (drp *DecryptResultProcessor) Classify() DecryptOutcome {
+	if drp.totalCount == 0 {
+		return DecryptOutcomeOK
+	}
+
+	plausible := 0
+	for _, result := range drp.results {
+		if result.Success && plausibleName(result.DecryptedFilename) {
+			plausible++
+		}
+	}
+
+	plausibleRate := float64(plausible) / float64(drp.totalCount) * 100.0
+	switch {
+	case drp.totalCount >= drp.WrongPasswordMinEntries && plausibleRate <= drp.WrongPasswordRate:
+		return DecryptOutcomeWrongPassword
+	case plausible < drp.totalCount:
+		return DecryptOutcomePartial
+	default:
+		return DecryptOutcomeOK
+	}
+}
+
+// plausibleName reports whether a decoded name looks like a real entry name: only printable
+// characters, at least half of them ASCII. Even CP932 names keep their separators and extensions
+// in ASCII, while scrambled bytes decoded as CP1252 are mostly accented letters and symbols.
+func plausibleName(name string) bool {
+	if name == "" {
+		return false
+	}
+	ascii, total := 0, 0
+	for _, r := range name {
+		if r == utf8.RuneError || !unicode.IsPrint(r) {
+			return false
+		}
+		if r <= unicode.MaxASCII {
+			ascii++
+		}
+		total++
+	}
+	return ascii*2 >= total
+}
+
+// UpdateFileInfos updates the original FileInfo structs with decrypted names. For the FileInfos
+// of an IPFReader use its UpdateFileInfos method, which also refreshes GetFileByName.
 func UpdateFileInfos(fileInfos []FileInfo, results []DecryptionResult) {
 	for i, result := range results {
 		if i < len(fileInfos) {
@@ -232,3 +348,87 @@ func (fd *FilenameDecryptor) DecryptFilenamesBatch(ctx context.Context, fileInfo
 
 	return results, nil
 }
+
+// DecryptStream decrypts filenames in parallel and delivers the results in index order as soon
+// as each prefix is complete, so consumers can start before all names are decrypted. At most
+// window results (default 4 per worker) are in flight or buffered for reordering at any time.
+// The channel is closed when all results are delivered or ctx is done; consumers that stop early
+// must cancel ctx.
+func (fd *FilenameDecryptor) DecryptStream(ctx context.Context, fileInfos []FileInfo, window int) <-chan DecryptionResult {
+	if window <= 0 {
+		window = fd.workerCount * 4
+	}
+
+	out := make(chan DecryptionResult, window)
+	tasks := make(chan DecryptionTask)
+	// Buffered to the window size so workers never block on the reorderer
+	unordered := make(chan DecryptionResult, window)
+	// Each slot is held from dispatch until the result leaves the reorder buffer
+	slots := make(chan struct{}, window)
+
+	go func() {
+		defer close(tasks)
+		for i, fileInfo := range fileInfos {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			task := DecryptionTask{
+				Index:             i,
+				EncryptedFilename: fileInfo.EncryptedFilename,
+				FallbackName:      fileInfo.SafeFilename,
+			}
+			select {
+			case tasks <- task:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < fd.workerCount; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range tasks {
+				unordered <- fd.DecryptSingle(task)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(unordered)
+	}()
+
+	go func() {
+		defer close(out)
+
+		pending := make(map[int]DecryptionResult, window)
+		next := 0
+		for result := range unordered {
+			pending[result.Index] = result
+
+			for {
+				ready, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+
+				select {
+				case out <- ready:
+				case <-ctx.Done():
+					return
+				}
+				<-slots
+				next++
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,220 @@
+package ipf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// encryptName encrypts a filename the way IPF archives store it
+func encryptName(name string, password []byte) []byte {
+	cipher := &zipcipher.ZipCipher{}
+	cipher.InitKeys(password)
+	return cipher.EncryptData([]byte(name))
+}
+
+func TestCustomDecoder(t *testing.T) {
+	// A decoder for names that use 0x01 as the path separator, which no built-in encoding accepts
+	separatorDecoder := func(decrypted []byte) (string, bool) {
+		return string(bytes.ReplaceAll(decrypted, []byte{0x01}, []byte("/"))), true
+	}
+	refusingDecoder := func([]byte) (string, bool) { return "", false }
+
+	tests := []struct {
+		name         string
+		stored       string
+		decoder      func([]byte) (string, bool)
+		wantName     string
+		wantEncoding string
+		wantSuccess  bool
+	}{
+		{"defaults fail without decoder", "dir\x01file.txt", nil, "", "", false},
+		{"decoder rescues name", "dir\x01file.txt", separatorDecoder, "dir/file.txt", EncodingCustom, true},
+		{"decoder refuses name", "dir\x01file.txt", refusingDecoder, "", "", false},
+		{"defaults win over decoder", "dir/plain.txt", separatorDecoder, "dir/plain.txt", "utf-8", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decryptor := NewFilenameDecryptor(testPassword, 1)
+			decryptor.CustomDecoder = tt.decoder
+
+			result := decryptor.DecryptSingle(DecryptionTask{
+				Index:             7,
+				EncryptedFilename: encryptName(tt.stored, testPassword),
+				FallbackName:      "file_0007.bin",
+			})
+			if result.Success != tt.wantSuccess || result.DecryptedFilename != tt.wantName || result.Encoding != tt.wantEncoding {
+				t.Errorf("DecryptSingle = %q (%q, %v), want %q (%q, %v)",
+					result.DecryptedFilename, result.Encoding, result.Success, tt.wantName, tt.wantEncoding, tt.wantSuccess)
+			}
+			if !tt.wantSuccess && result.SafeFilename != "file_0007.bin" {
+				t.Errorf("SafeFilename = %q, want the fallback name", result.SafeFilename)
+			}
+		})
+	}
+}
+
+func TestDecryptStreamOrder(t *testing.T) {
+	fileInfos := make([]FileInfo, 2000)
+	for i := range fileInfos {
+		fileInfos[i].EncryptedFilename = encryptName(fmt.Sprintf("dir%d/file%04d.txt", i%13, i), testPassword)
+	}
+
+	tests := []struct {
+		name    string
+		workers int
+		window  int
+	}{
+		{"default window", 8, 0},
+		{"window of one", 8, 1},
+		{"window smaller than workers", 16, 3},
+		{"single worker", 1, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decryptor := NewFilenameDecryptor(testPassword, tt.workers)
+			next := 0
+			for result := range decryptor.DecryptStream(context.Background(), fileInfos, tt.window) {
+				if result.Index != next {
+					t.Fatalf("got result %d, want %d", result.Index, next)
+				}
+				if want := fmt.Sprintf("dir%d/file%04d.txt", next%13, next); !result.Success || result.DecryptedFilename != want {
+					t.Errorf("result %d = %q (success %v), want %q", next, result.DecryptedFilename, result.Success, want)
+				}
+				next++
+			}
+			if next != len(fileInfos) {
+				t.Errorf("stream delivered %d results, want %d", next, len(fileInfos))
+			}
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stream := NewFilenameDecryptor(testPassword, 4).DecryptStream(ctx, fileInfos, 8)
+		received := 0
+		for result := range stream {
+			if result.Index != received {
+				t.Fatalf("got result %d, want %d", result.Index, received)
+			}
+			received++
+			if received == 10 {
+				cancel()
+			}
+		}
+		// Results already buffered may still arrive, but the stream must close early
+		if received == len(fileInfos) {
+			t.Errorf("stream delivered every result after being cancelled")
+		}
+	})
+}
+
+func TestClassifyDecryption(t *testing.T) {
+	var entries []ipftest.Entry
+	for i := 0; i < 20; i++ {
+		entries = append(entries, ipftest.Entry{Name: fmt.Sprintf("ui/skin_%04d.tga", i), Data: []byte("x")})
+	}
+	damaged := append(entries[:18:18],
+		ipftest.Entry{Name: "ui/\x01\x02\x03.tga", Data: []byte("x")},
+		ipftest.Entry{Name: "\x7f\x7f", Data: []byte("x")},
+	)
+
+	tests := []struct {
+		name       string
+		entries    []ipftest.Entry
+		password   []byte
+		minEntries int // Overrides WrongPasswordMinEntries when set
+		want       DecryptOutcome
+	}{
+		{"right password", entries, testPassword, 0, DecryptOutcomeOK},
+		{"wrong password", entries, []byte("not the password"), 0, DecryptOutcomeWrongPassword},
+		{"damaged names", damaged, testPassword, 0, DecryptOutcomePartial},
+		{"too few entries to tell", entries, []byte("not the password"), 21, DecryptOutcomePartial},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := openArchive(t, ipftest.Build(t, testPassword, tt.entries...), tt.password)
+			results, err := NewFilenameDecryptor(tt.password, 2).DecryptAllParallel(context.Background(), reader.FileInfos)
+			if err != nil {
+				t.Fatalf("DecryptAllParallel: %v", err)
+			}
+
+			processor := NewDecryptResultProcessor(len(results))
+			if tt.minEntries != 0 {
+				processor.WrongPasswordMinEntries = tt.minEntries
+			}
+			processor.ProcessResults(results)
+			if got := processor.Classify(); got != tt.want {
+				t.Errorf("Classify = %v, want %v (success rate %.0f%%)", got, tt.want, processor.GetSuccessRate())
+			}
+		})
+	}
+}
+
+func TestEncodingBreakdown(t *testing.T) {
+	entries := []struct {
+		stored       string
+		want         string
+		wantEncoding string // Empty when the name does not decode
+	}{
+		{"data/item.xml", "data/item.xml", "utf-8"},
+		{"ui/日本語.png", "ui/日本語.png", "utf-8"},
+		{"\x93\xfa\x96\x7b\x8c\xea.txt", "日本語.txt", "cp932"},
+		{"\x83\x65\x83\x58\x83\x67/\x89\xe6\x91\x9c.png", "テスト/画像.png", "cp932"},
+		{"\x95\x5c\x8e\xa6.xml", "表示.xml", "cp932"},
+		{"\xb1\xb2\xb3.dds", "ｱｲｳ.dds", "cp932"},
+		{"caf\xe9.txt", "café.txt", "cp1252"},
+		{"dir\x01file.txt", "", ""},
+	}
+	var archiveEntries []ipftest.Entry
+	for _, entry := range entries {
+		archiveEntries = append(archiveEntries, ipftest.Entry{Name: entry.stored, Data: []byte("x")})
+	}
+	archive := ipftest.Build(t, testPassword, archiveEntries...)
+
+	reader, err := NewIPFReaderFromReaderAt(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.ReadFileStructure(); err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.ReadEncryptedFilenames(); err != nil {
+		t.Fatal(err)
+	}
+	results, err := NewFilenameDecryptor(testPassword, 2).DecryptAllParallel(context.Background(), reader.FileInfos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processor := NewDecryptResultProcessor(len(entries))
+	processor.ProcessResults(results)
+	wantBreakdown := make(map[string]int)
+	for i, result := range processor.GetResults() {
+		entry := entries[i]
+		if result.Success != (entry.wantEncoding != "") || result.Encoding != entry.wantEncoding {
+			t.Errorf("entry %d: Success %v, Encoding %q, want %q", i, result.Success, result.Encoding, entry.wantEncoding)
+		}
+		if entry.wantEncoding != "" {
+			wantBreakdown[entry.wantEncoding]++
+			if result.DecryptedFilename != entry.want {
+				t.Errorf("entry %d: decrypted %q, want %q", i, result.DecryptedFilename, entry.want)
+			}
+		}
+	}
+
+	breakdown := processor.EncodingBreakdown()
+	if len(breakdown) != len(wantBreakdown) {
+		t.Errorf("EncodingBreakdown = %v, want %v", breakdown, wantBreakdown)
+	}
+	for encoding, count := range wantBreakdown {
+		if breakdown[encoding] != count {
+			t.Errorf("EncodingBreakdown[%q] = %d, want %d", encoding, breakdown[encoding], count)
+		}
+	}
+}
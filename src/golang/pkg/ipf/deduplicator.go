@@ -2,33 +2,82 @@ package ipf
 
 import (
 	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DedupStrategy selects which version of a duplicated file is retained
+type DedupStrategy int
+
+const (
+	// KeepNewest retains the entry with the highest index (matches game patch layering)
+	KeepNewest DedupStrategy = iota
+	// KeepLargest retains the entry with the largest uncompressed size, lowest index winning ties
+	KeepLargest
 )
 
 // Deduplicator handles IPF progressive bloat by keeping only newest version of each file
 type Deduplicator struct {
 	fileInfos []FileInfo
+	Strategy  DedupStrategy
+
+	// ByContent treats entries as duplicates only when both the name and the CRC32 match. Entries
+	// that share a name but differ in content are all retained; all but the newest get the entry
+	// index appended to their SafeFilename so they extract side by side.
+	ByContent bool
+
+	stats         *DeduplicationStats // Counts from the last run, reused by GetStats
+	statsSettings dedupSettings       // Settings that run used
+}
+
+// dedupSettings are the options a cached run depends on
+type dedupSettings struct {
+	strategy  DedupStrategy
+	byContent bool
+}
+
+// dedupKey identifies a group of duplicates; crc is only set in ByContent mode
+type dedupKey struct {
+	name string
+	crc  uint32
 }
 
 // NewDeduplicator creates a new deduplicator from file infos
 func NewDeduplicator(fileInfos []FileInfo) *Deduplicator {
 	return &Deduplicator{
 		fileInfos: fileInfos,
+		Strategy:  KeepNewest,
 	}
 }
 
+// NewDeduplicatorByContent creates a deduplicator that only collapses entries with the same name
+// and the same CRC32, see Deduplicator.ByContent
+func NewDeduplicatorByContent(fileInfos []FileInfo) *Deduplicator {
+	d := NewDeduplicator(fileInfos)
+	d.ByContent = true
+	return d
+}
+
 // Run performs deduplication and returns only newest versions
-// Returns a slice of FileInfo containing only the latest version of each file
+// Returns a slice of FileInfo containing only the retained version of each file, ordered by index
 func (d *Deduplicator) Run() []FileInfo {
-	filenameMap := make(map[string]*FileInfo)
+	deduplicated, _ := d.run()
+	return deduplicated
+}
 
-	for i, fileInfo := range d.fileInfos {
-		existing, exists := filenameMap[fileInfo.SafeFilename]
-		if exists {
-			if i > existing.Index {
-				filenameMap[fileInfo.SafeFilename] = &fileInfo
-			}
-		} else {
-			filenameMap[fileInfo.SafeFilename] = &fileInfo
+// run deduplicates and also returns how many retained entries were renamed because they share
+// a name with different content
+func (d *Deduplicator) run() ([]FileInfo, int) {
+	filenameMap := make(map[dedupKey]*FileInfo)
+
+	// Point into d.fileInfos rather than at the loop variable so every entry stays distinct
+	for i := range d.fileInfos {
+		fileInfo := &d.fileInfos[i]
+		key := d.key(fileInfo)
+		existing, exists := filenameMap[key]
+		if !exists || d.prefer(fileInfo, existing) {
+			filenameMap[key] = fileInfo
 		}
 	}
 
@@ -37,22 +86,104 @@ func (d *Deduplicator) Run() []FileInfo {
 		deduplicated = append(deduplicated, *fileInfo)
 	}
 
-	return deduplicated
-}
+	// Map iteration order is random, so sort to keep output reproducible
+	sort.Slice(deduplicated, func(i, j int) bool {
+		return deduplicated[i].Index < deduplicated[j].Index
+	})
 
-// GetStats returns statistics about deduplication
-func (d *Deduplicator) GetStats() DeduplicationStats {
-	stats := DeduplicationStats{
+	renamed := 0
+	if d.ByContent {
+		renamed = disambiguateNames(deduplicated)
+	}
+
+	d.stats = &DeduplicationStats{
 		TotalFiles:        len(d.fileInfos),
-		UniqueFiles:       0,
-		RemovedDuplicates: 0,
+		UniqueFiles:       len(deduplicated),
+		RemovedDuplicates: len(d.fileInfos) - len(deduplicated),
+		ContentDistinct:   renamed,
+	}
+	d.statsSettings = d.settings()
+
+	return deduplicated, renamed
+}
+
+// settings returns the options the result of run depends on
+func (d *Deduplicator) settings() dedupSettings {
+	return dedupSettings{strategy: d.Strategy, byContent: d.ByContent}
+}
+
+// key returns the duplicate group of a file
+func (d *Deduplicator) key(fileInfo *FileInfo) dedupKey {
+	key := dedupKey{name: fileInfo.SafeFilename}
+	if d.ByContent && fileInfo.ZipInfo != nil {
+		key.crc = fileInfo.ZipInfo.CRC32
+	}
+	return key
+}
+
+// disambiguateNames appends the entry index to every file that shares its SafeFilename with a
+// later one in fileInfos, which must be sorted by index. It returns the number of renamed files.
+func disambiguateNames(fileInfos []FileInfo) int {
+	last := make(map[string]int, len(fileInfos))
+	for i := range fileInfos {
+		last[fileInfos[i].SafeFilename] = i
+	}
+	if len(last) == len(fileInfos) {
+		return 0
 	}
 
-	deduplicated := d.Run()
-	stats.UniqueFiles = len(deduplicated)
-	stats.RemovedDuplicates = len(d.fileInfos) - len(deduplicated)
+	renamed := 0
+	for i := range fileInfos {
+		name := fileInfos[i].SafeFilename
+		if last[name] == i {
+			continue
+		}
 
-	return stats
+		ext := path.Ext(name)
+		candidate := fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), fileInfos[i].Index, ext)
+		for {
+			if _, taken := last[candidate]; !taken {
+				break
+			}
+			candidate = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(candidate, ext), fileInfos[i].Index, ext)
+		}
+		last[candidate] = i
+		fileInfos[i].SafeFilename = candidate
+		renamed++
+	}
+	return renamed
+}
+
+// prefer reports whether candidate should replace the existing entry
+func (d *Deduplicator) prefer(candidate, existing *FileInfo) bool {
+	switch d.Strategy {
+	case KeepLargest:
+		candidateSize, existingSize := uncompressedSize(candidate), uncompressedSize(existing)
+		if candidateSize != existingSize {
+			return candidateSize > existingSize
+		}
+		// Size tie: the lowest index wins
+		return candidate.Index < existing.Index
+	default:
+		return candidate.Index > existing.Index
+	}
+}
+
+// uncompressedSize returns the uncompressed size of a file, or 0 when unknown
+func uncompressedSize(fileInfo *FileInfo) uint64 {
+	if fileInfo.ZipInfo == nil {
+		return 0
+	}
+	return fileInfo.ZipInfo.UncompressedSize64
+}
+
+// GetStats returns statistics about deduplication. The counts of the last Run are reused unless
+// Strategy or ByContent changed since; otherwise deduplication runs once and is cached.
+func (d *Deduplicator) GetStats() DeduplicationStats {
+	if d.stats == nil || d.statsSettings != d.settings() {
+		d.run()
+	}
+	return *d.stats
 }
 
 // DeduplicationStats contains statistics about the deduplication process
@@ -60,6 +191,7 @@ type DeduplicationStats struct {
 	TotalFiles        int
 	UniqueFiles       int
 	RemovedDuplicates int
+	ContentDistinct   int // Same-name entries kept under a new name because their content differs
 }
 
 func (s DeduplicationStats) String() string {
@@ -68,6 +200,10 @@ func (s DeduplicationStats) String() string {
 		percentRemoved = float64(s.RemovedDuplicates) / float64(s.TotalFiles) * 100.0
 	}
 
-	return fmt.Sprintf("Total: %d, Unique: %d, Removed: %d (%.1f%%)",
+	summary := fmt.Sprintf("Total: %d, Unique: %d, Removed: %d (%.1f%%)",
 		s.TotalFiles, s.UniqueFiles, s.RemovedDuplicates, percentRemoved)
+	if s.ContentDistinct > 0 {
+		summary += fmt.Sprintf(", Renamed: %d", s.ContentDistinct)
+	}
+	return summary
 }
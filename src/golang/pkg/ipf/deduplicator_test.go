@@ -0,0 +1,215 @@
+package ipf
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"maps"
+	"math/rand"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+// dedupEntry returns a file info with the given name, size and CRC32
+func dedupEntry(index int, name string, size uint64, crc uint32) FileInfo {
+	return FileInfo{
+		Index:        index,
+		SafeFilename: name,
+		ZipInfo:      &zip.File{FileHeader: zip.FileHeader{UncompressedSize64: size, CRC32: crc}},
+	}
+}
+
+func TestDeduplicatorTieBreak(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  DedupStrategy
+		entries   []FileInfo
+		wantIndex int
+	}{
+		{
+			name:     "newest",
+			strategy: KeepNewest,
+			entries: []FileInfo{
+				dedupEntry(0, "a.txt", 10, 1),
+				dedupEntry(1, "a.txt", 10, 2),
+			},
+			wantIndex: 1,
+		},
+		{
+			name:     "largest",
+			strategy: KeepLargest,
+			entries: []FileInfo{
+				dedupEntry(0, "a.txt", 10, 1),
+				dedupEntry(1, "a.txt", 20, 2),
+				dedupEntry(2, "a.txt", 5, 3),
+			},
+			wantIndex: 1,
+		},
+		{
+			name:     "largest with equal sizes",
+			strategy: KeepLargest,
+			entries: []FileInfo{
+				dedupEntry(3, "a.txt", 10, 1),
+				dedupEntry(5, "a.txt", 10, 2),
+				dedupEntry(8, "a.txt", 10, 3),
+			},
+			wantIndex: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			for run := 0; run < 50; run++ {
+				entries := append([]FileInfo(nil), tt.entries...)
+				rng.Shuffle(len(entries), func(i, j int) { entries[i], entries[j] = entries[j], entries[i] })
+
+				deduplicator := NewDeduplicator(entries)
+				deduplicator.Strategy = tt.strategy
+				retained := deduplicator.Run()
+				if len(retained) != 1 || retained[0].Index != tt.wantIndex {
+					t.Fatalf("run %d retained %+v, want index %d", run, retained, tt.wantIndex)
+				}
+			}
+		})
+	}
+}
+
+func TestDeduplicatorByContent(t *testing.T) {
+	tests := []struct {
+		name          string
+		entries       []FileInfo
+		want          map[int]string // Retained index to its SafeFilename
+		wantDistinct  int
+		wantDuplicate int
+	}{
+		{
+			name:          "identical duplicates collapse",
+			entries:       []FileInfo{dedupEntry(0, "a.txt", 1, 1), dedupEntry(1, "a.txt", 1, 1)},
+			want:          map[int]string{1: "a.txt"},
+			wantDuplicate: 1,
+		},
+		{
+			name:         "different content is kept",
+			entries:      []FileInfo{dedupEntry(0, "a.txt", 1, 1), dedupEntry(1, "a.txt", 1, 2)},
+			want:         map[int]string{0: "a_0.txt", 1: "a.txt"},
+			wantDistinct: 1,
+		},
+		{
+			name: "mixed",
+			entries: []FileInfo{
+				dedupEntry(0, "a.txt", 1, 1),
+				dedupEntry(1, "a.txt", 1, 2),
+				dedupEntry(2, "a.txt", 1, 1),
+				dedupEntry(3, "b.txt", 1, 1),
+			},
+			want:          map[int]string{1: "a_1.txt", 2: "a.txt", 3: "b.txt"},
+			wantDistinct:  1,
+			wantDuplicate: 1,
+		},
+		{
+			name: "renamed file collides again",
+			entries: []FileInfo{
+				dedupEntry(0, "a.txt", 1, 1),
+				dedupEntry(1, "a.txt", 1, 2),
+				dedupEntry(2, "a_0.txt", 1, 3),
+			},
+			want:         map[int]string{0: "a_0_0.txt", 1: "a.txt", 2: "a_0.txt"},
+			wantDistinct: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deduplicator := NewDeduplicatorByContent(tt.entries)
+			got := make(map[int]string)
+			for _, fileInfo := range deduplicator.Run() {
+				got[fileInfo.Index] = fileInfo.SafeFilename
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("retained %v, want %v", got, tt.want)
+			}
+
+			stats := deduplicator.GetStats()
+			if stats.ContentDistinct != tt.wantDistinct || stats.RemovedDuplicates != tt.wantDuplicate {
+				t.Errorf("stats = %+v, want %d content-distinct and %d removed", stats, tt.wantDistinct, tt.wantDuplicate)
+			}
+		})
+	}
+}
+
+func TestExtractDedupByContent(t *testing.T) {
+	archive := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "data/a.xml", Data: []byte("<a>old</a>")},
+		ipftest.Entry{Name: "data/a.xml", Data: []byte("<a>old</a>")},
+		ipftest.Entry{Name: "data/a.xml", Data: []byte("<a>patched</a>")},
+	)
+
+	tests := []struct {
+		byContent bool
+		want      map[string]string
+	}{
+		{false, map[string]string{"data/a.xml": "<a>patched</a>"}},
+		{true, map[string]string{"data/a.xml": "<a>patched</a>", "data/a_1.xml": "<a>old</a>"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("by content %v", tt.byContent), func(t *testing.T) {
+			extractor := NewConcurrentExtractor(openArchive(t, archive, testPassword), nil, 2)
+			extractor.DedupByContent = tt.byContent
+			outputDir := t.TempDir()
+			results, err := extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			requireSuccess(t, results, err)
+
+			if got := readTree(t, outputDir); !maps.Equal(got, tt.want) {
+				t.Errorf("extracted %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeduplicatorKeepsHighestIndex(t *testing.T) {
+	const names = 37
+	entries := make([]FileInfo, 2000)
+	for i := range entries {
+		entries[i] = dedupEntry(i, fmt.Sprintf("dir/file%02d.txt", i%names), uint64(i), uint32(i))
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(entries), func(i, j int) { entries[i], entries[j] = entries[j], entries[i] })
+
+	highest := make(map[string]int)
+	for _, entry := range entries {
+		highest[entry.SafeFilename] = max(highest[entry.SafeFilename], entry.Index)
+	}
+
+	retained := NewDeduplicator(entries).Run()
+	if len(retained) != names {
+		t.Fatalf("retained %d entries, want %d", len(retained), names)
+	}
+	for _, fileInfo := range retained {
+		// Each retained entry must be the element itself, not a copy of whatever the loop saw last
+		if want := highest[fileInfo.SafeFilename]; fileInfo.Index != want {
+			t.Errorf("%s retained index %d, want %d", fileInfo.SafeFilename, fileInfo.Index, want)
+		}
+		if fileInfo.ZipInfo.CRC32 != uint32(fileInfo.Index) {
+			t.Errorf("%s index %d carries the header of entry %d", fileInfo.SafeFilename, fileInfo.Index, fileInfo.ZipInfo.CRC32)
+		}
+	}
+
+	// The newest version also wins end to end
+	var archiveEntries []ipftest.Entry
+	for i := 0; i < 60; i++ {
+		archiveEntries = append(archiveEntries, ipftest.Entry{
+			Name: fmt.Sprintf("file%d.txt", i%6),
+			Data: []byte(fmt.Sprintf("version %d", i)),
+		})
+	}
+	outputDir := t.TempDir()
+	reader := openArchive(t, ipftest.Build(t, testPassword, archiveEntries...), testPassword)
+	results, err := NewConcurrentExtractor(reader, nil, 4).ExtractAllParallel(context.Background(), outputDir, testPassword)
+	requireSuccess(t, results, err)
+	got := readTree(t, outputDir)
+	for i := 0; i < 6; i++ {
+		name, want := fmt.Sprintf("file%d.txt", i), fmt.Sprintf("version %d", 54+i)
+		if got[name] != want {
+			t.Errorf("%s = %q, want %q", name, got[name], want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package ipf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FileChange pairs the old and new versions of a modified entry
+type FileChange struct {
+	Name string
+	Old  FileEntry
+	New  FileEntry
+}
+
+// DiffResult lists how archive b differs from archive a, each list sorted by name
+type DiffResult struct {
+	Added     []FileEntry  // Only in b
+	Removed   []FileEntry  // Only in a
+	Modified  []FileChange // In both with a different CRC32 or size
+	Unchanged int
+}
+
+// Changed reports whether the archives differ at all
+func (d *DiffResult) Changed() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Modified) > 0
+}
+
+// Diff compares the entries of a and b by decrypted name, CRC32 and size, without reading any
+// entry data. Backslashes in names count as slashes, and when a name occurs more than once the
+// newest entry is compared, as on extraction. Both archives are decrypted with password.
+func Diff(a, b *IPFReader, password []byte) (*DiffResult, error) {
+	oldEntries, err := latestEntries(a, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list old archive: %w", err)
+	}
+	newEntries, err := latestEntries(b, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list new archive: %w", err)
+	}
+
+	result := &DiffResult{}
+	for name, newEntry := range newEntries {
+		oldEntry, ok := oldEntries[name]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, newEntry)
+		case oldEntry.CRC32 != newEntry.CRC32 || oldEntry.Size != newEntry.Size:
+			result.Modified = append(result.Modified, FileChange{Name: name, Old: oldEntry, New: newEntry})
+		default:
+			result.Unchanged++
+		}
+	}
+	for name, oldEntry := range oldEntries {
+		if _, ok := newEntries[name]; !ok {
+			result.Removed = append(result.Removed, oldEntry)
+		}
+	}
+
+	sort.Slice(result.Added, func(i, j int) bool { return result.Added[i].Name < result.Added[j].Name })
+	sort.Slice(result.Removed, func(i, j int) bool { return result.Removed[i].Name < result.Removed[j].Name })
+	sort.Slice(result.Modified, func(i, j int) bool { return result.Modified[i].Name < result.Modified[j].Name })
+	return result, nil
+}
+
+// latestEntries lists reader keyed by slash-separated name, keeping the last entry of each name
+func latestEntries(reader *IPFReader, password []byte) (map[string]FileEntry, error) {
+	entries, err := reader.ListFiles(password)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]FileEntry, len(entries))
+	for _, entry := range entries {
+		entry.Name = strings.ReplaceAll(entry.Name, "\\", "/")
+		byName[entry.Name] = entry
+	}
+	return byName, nil
+}
@@ -0,0 +1,22 @@
+package ipf
+
+import (
+	"errors"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// Errors wrapped by ExtractionResult.Error and by the reading functions, so callers can test
+// them with errors.Is without importing zipcipher. They are the zipcipher values, so errors
+// returned by that package match as well.
+var (
+	ErrCRCMismatch          = zipcipher.ErrCRCMismatch          // Decoded data does not match the recorded CRC32
+	ErrSizeMismatch         = zipcipher.ErrSizeMismatch         // Decoded data does not match the recorded size
+	ErrBadHeaderSignature   = zipcipher.ErrBadHeaderSignature   // A local header lacks its signature
+	ErrUnsupportedMethod    = zipcipher.ErrUnsupportedMethod    // The entry uses a compression method other than store or deflate
+	ErrPasswordVerification = zipcipher.ErrPasswordVerification // The encryption header check byte does not match the password
+	ErrCorruptData          = zipcipher.ErrCorruptData          // The compressed data cannot be decompressed
+)
+
+// ErrPanic is wrapped by the result of a file whose extraction panicked under KeepGoing
+var ErrPanic = errors.New("panic while extracting")
@@ -0,0 +1,171 @@
+package ipf
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestExtractionResultErrors(t *testing.T) {
+	data := []byte("typed extraction errors")
+
+	tests := []struct {
+		name     string
+		entry    ipftest.Entry
+		craft    func(t *testing.T, archive []byte)
+		password []byte // Extraction password, testPassword when nil
+		wantErr  error
+	}{
+		{
+			name:  "stored CRC mismatch",
+			entry: ipftest.Entry{Name: "a.bin", Data: data},
+			craft: func(t *testing.T, archive []byte) {
+				archive[ipftest.DataOffset(t, archive, 0)+12+5] ^= 0x01
+			},
+			wantErr: ErrCRCMismatch,
+		},
+		{
+			name:  "deflated CRC mismatch",
+			entry: ipftest.Entry{Name: "a.txt", Data: data, Method: zip.Deflate},
+			craft: func(t *testing.T, archive []byte) {
+				record := ipftest.CentralRecords(t, archive)[0]
+				binary.LittleEndian.PutUint16(record[16:], binary.LittleEndian.Uint16(record[16:])^0xffff)
+			},
+			wantErr: ErrCRCMismatch,
+		},
+		{
+			name:  "size mismatch",
+			entry: ipftest.Entry{Name: "a.txt", Data: data, Method: zip.Deflate},
+			craft: func(t *testing.T, archive []byte) {
+				binary.LittleEndian.PutUint32(ipftest.CentralRecords(t, archive)[0][24:], uint32(len(data)+1))
+			},
+			wantErr: ErrSizeMismatch,
+		},
+		{
+			name:  "corrupt data",
+			entry: ipftest.Entry{Name: "a.txt", Data: data, Method: zip.Deflate, Plain: true},
+			craft: func(t *testing.T, archive []byte) {
+				archive[ipftest.DataOffset(t, archive, 0)] = 0x07 // Reserved block type
+			},
+			wantErr: ErrCorruptData,
+		},
+		{
+			name:    "unsupported method",
+			entry:   ipftest.Entry{Name: "a.bz2", Data: data, Method: 12},
+			wantErr: ErrUnsupportedMethod,
+		},
+		{
+			name:  "bad header signature",
+			entry: ipftest.Entry{Name: "a.txt", Data: data, Method: zip.Deflate},
+			craft: func(t *testing.T, archive []byte) {
+				archive[binary.LittleEndian.Uint32(ipftest.CentralRecords(t, archive)[0][42:])] = 'X'
+			},
+			wantErr: ErrBadHeaderSignature,
+		},
+		{
+			// The check byte is not verified, so a wrong password shows up in the content
+			name:     "wrong password",
+			entry:    ipftest.Entry{Name: "a.bin", Data: data},
+			password: []byte("wrong password"),
+			wantErr:  ErrCRCMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A healthy entry alongside shows the failure stays with its own result
+			archive := ipftest.Build(t, testPassword, tt.entry, ipftest.Entry{Name: "ok.txt", Data: []byte("ok")})
+			if tt.craft != nil {
+				tt.craft(t, archive)
+			}
+			reader := openArchive(t, archive, testPassword)
+			password := tt.password
+			if password == nil {
+				password = testPassword
+			}
+
+			results, err := NewConcurrentExtractor(reader, nil, 2).ExtractAllParallel(context.Background(), t.TempDir(), password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if results[0].Success || !errors.Is(results[0].Error, tt.wantErr) {
+				t.Errorf("result = %v, %v, want %v", results[0].Success, results[0].Error, tt.wantErr)
+			}
+			if tt.password == nil && !results[1].Success {
+				t.Errorf("healthy entry failed: %v", results[1].Error)
+			}
+		})
+	}
+}
+
+func TestNonstandardCheckByte(t *testing.T) {
+	entries := []ipftest.Entry{
+		{Name: "stored.bin", Data: []byte("stored content")},
+		{Name: "deflated.txt", Data: bytes.Repeat([]byte("deflated "), 50), Method: zip.Deflate},
+	}
+	archive := ipftest.Build(t, testPassword, entries...)
+
+	// Packers that stamp the time after encrypting leave a check byte matching neither the
+	// modification time nor the CRC32
+	for i, record := range ipftest.CentralRecords(t, archive) {
+		offset := binary.LittleEndian.Uint32(record[42:])
+		for _, field := range [][]byte{record[12:14], archive[offset+10 : offset+12]} {
+			binary.LittleEndian.PutUint16(field, binary.LittleEndian.Uint16(field)^0x4000)
+		}
+		if check := byte(binary.LittleEndian.Uint16(record[12:]) >> 8); check == byte(crc32.ChecksumIEEE(entries[i].Data)>>24) {
+			t.Fatalf("entry %d: the crafted check byte still matches the CRC32", i)
+		}
+	}
+	reader := openArchive(t, archive, testPassword)
+
+	tests := []struct {
+		name string
+		read func(ce *ConcurrentExtractor, index int) ([]byte, error)
+	}{
+		{"extract", func(ce *ConcurrentExtractor, index int) ([]byte, error) {
+			outputDir := t.TempDir()
+			results, err := ce.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			if err == nil && !results[index].Success {
+				err = results[index].Error
+			}
+			if err != nil {
+				return nil, err
+			}
+			return os.ReadFile(results[index].FilePath)
+		}},
+		{"stream", func(ce *ConcurrentExtractor, index int) ([]byte, error) {
+			var buf bytes.Buffer
+			_, err := ce.ExtractToWriter(&reader.FileInfos[index], testPassword, &buf)
+			return buf.Bytes(), err
+		}},
+		{"read entry", func(_ *ConcurrentExtractor, index int) ([]byte, error) {
+			return reader.ReadEntry(index, testPassword)
+		}},
+		{"verify", func(ce *ConcurrentExtractor, index int) ([]byte, error) {
+			results, err := ce.VerifyAll(context.Background(), testPassword)
+			if err == nil && results[index].Integrity != IntegrityOK {
+				err = fmt.Errorf("integrity %v: %v", results[index].Integrity, results[index].Error)
+			}
+			return entries[index].Data, err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, entry := range entries {
+				got, err := tt.read(NewConcurrentExtractor(reader, nil, 2), i)
+				if err != nil {
+					t.Errorf("%s: %v", entry.Name, err)
+				} else if !bytes.Equal(got, entry.Data) {
+					t.Errorf("%s = %q, want %q", entry.Name, got, entry.Data)
+				}
+			}
+		})
+	}
+}
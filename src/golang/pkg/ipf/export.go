@@ -0,0 +1,151 @@
+package ipf
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// ExportZip writes the archive as a standard ZIP to out, with decrypted filenames and data.
+// Entry data is decrypted while streaming and copied without recompression, so the CRC32 and
+// 64-bit sizes are carried over from the original entry; archive/zip switches to ZIP64 for entries
+// and offsets beyond 4GB. Entries are written sequentially in index order.
+func (ce *ConcurrentExtractor) ExportZip(ctx context.Context, out io.Writer, password []byte) ([]ExtractionResult, error) {
+	tracker := ce.startProgress()
+	defer tracker.finish()
+
+	tasks, skipped := ce.selectTasks("", password)
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Index < tasks[j].Index
+	})
+	tracker.total = len(tasks)
+
+	zipWriter := zip.NewWriter(out)
+	export := tracker.track(func(task ExtractionTask) ExtractionResult {
+		return ce.exportEntry(zipWriter, task)
+	})
+
+	results := make([]ExtractionResult, 0, len(tasks)+len(skipped))
+	for _, task := range tasks {
+		if err := ctx.Err(); err != nil {
+			return append(results, skipped...), err
+		}
+
+		result := export(task)
+		results = append(results, result)
+		if !result.Success {
+			// A failed entry may have left a partial record behind; the ZIP cannot be finished
+			return append(results, skipped...), result.Error
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return append(results, skipped...), fmt.Errorf("failed to finish ZIP: %w", err)
+	}
+
+	return append(results, skipped...), nil
+}
+
+// exportEntry copies one decrypted entry into zipWriter
+func (ce *ConcurrentExtractor) exportEntry(zipWriter *zip.Writer, task ExtractionTask) ExtractionResult {
+	startTime := getTimeMillis()
+	fileInfo := task.FileInfo
+
+	fail := func(err error) ExtractionResult {
+		return ExtractionResult{Index: task.Index, Success: false, Error: err}
+	}
+
+	if fileInfo == nil || fileInfo.ZipInfo == nil {
+		return fail(fmt.Errorf("file %d has no ZIP info", task.Index))
+	}
+
+	raw, err := ce.reader.RawLocalHeader(fileInfo.Index)
+	if err != nil {
+		return fail(err)
+	}
+	encrypted := binary.LittleEndian.Uint16(raw[6:8])&0x1 != 0
+	method := binary.LittleEndian.Uint16(raw[8:10])
+
+	storedSize := int64(fileInfo.ZipInfo.CompressedSize64)
+	payloadSize := storedSize
+	if encrypted {
+		if storedSize < 12 {
+			return fail(fmt.Errorf("file %d: encrypted data too short for encryption header", task.Index))
+		}
+		payloadSize -= 12
+	}
+
+	header := &zip.FileHeader{
+		Name:               task.OutputName,
+		Method:             method,
+		Modified:           entryModTime(fileInfo),
+		CRC32:              fileInfo.ZipInfo.CRC32,
+		CompressedSize64:   uint64(payloadSize),
+		UncompressedSize64: fileInfo.ZipInfo.UncompressedSize64,
+	}
+	if mode, ok := fileInfo.UnixMode(); ok {
+		header.SetMode(mode)
+	}
+
+	writer, err := zipWriter.CreateRaw(header)
+	if err != nil {
+		return fail(fmt.Errorf("failed to create ZIP entry %s: %w", header.Name, err))
+	}
+
+	var data io.Reader = io.NewSectionReader(ce.reader.source, fileInfo.LocalHeaderOffset+int64(len(raw)), storedSize)
+	if encrypted {
+		data = newDecryptingReader(data, task.Password)
+	}
+
+	written, err := io.Copy(writer, data)
+	if err == nil && written != payloadSize {
+		err = fmt.Errorf("short read: expected %d bytes, got %d", payloadSize, written)
+	}
+	if err != nil {
+		return fail(fmt.Errorf("failed to export %s: %w", header.Name, err))
+	}
+
+	return ExtractionResult{
+		Index:      task.Index,
+		Success:    true,
+		Method:     method,
+		FilePath:   header.Name,
+		Size:       int64(header.UncompressedSize64),
+		DurationMs: getTimeMillis() - startTime,
+	}
+}
+
+// decryptingReader decrypts a PKZIP stream-cipher payload on the fly, dropping the
+// 12-byte encryption header
+type decryptingReader struct {
+	source     io.Reader
+	cipher     *zipcipher.ZipCipher
+	headerLeft int
+}
+
+func newDecryptingReader(source io.Reader, password []byte) *decryptingReader {
+	cipher := &zipcipher.ZipCipher{}
+	cipher.InitKeys(password)
+	return &decryptingReader{source: source, cipher: cipher, headerLeft: 12}
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for d.headerLeft > 0 {
+		header := make([]byte, d.headerLeft)
+		n, err := d.source.Read(header)
+		d.cipher.DecryptData(header[:n])
+		d.headerLeft -= n
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := d.source.Read(p)
+	copy(p, d.cipher.DecryptData(p[:n]))
+	return n, err
+}
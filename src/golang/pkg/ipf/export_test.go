@@ -0,0 +1,72 @@
+package ipf
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+// hasZip64Extra reports whether extra holds a ZIP64 extended information block
+func hasZip64Extra(extra []byte) bool {
+	for len(extra) >= 4 {
+		if binary.LittleEndian.Uint16(extra) == 0x0001 {
+			return true
+		}
+		extra = extra[4+int(binary.LittleEndian.Uint16(extra[2:])):]
+	}
+	return false
+}
+
+func TestExportZip(t *testing.T) {
+	entries := []ipftest.Entry{
+		{Name: "a.xml", Data: bytes.Repeat([]byte("<a/>"), 100), Method: zip.Deflate},
+		{Name: "dir/b.bin", Data: []byte{0, 1, 2, 3}},
+		{Name: "huge.bin", Data: []byte("stands in for a 5GB entry")},
+	}
+	const hugeSize = 5 << 30
+
+	reader := openArchive(t, ipftest.Build(t, testPassword, entries...), testPassword)
+	// Only the declared size is large; the exported header must carry it in ZIP64 fields
+	reader.FileInfos[2].ZipInfo.UncompressedSize64 = hugeSize
+
+	var out bytes.Buffer
+	results, err := NewConcurrentExtractor(reader, nil, 2).ExportZip(context.Background(), &out, testPassword)
+	requireSuccess(t, results, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatalf("exported ZIP does not open: %v", err)
+	}
+	if len(zr.File) != len(entries) {
+		t.Fatalf("exported %d entries, want %d", len(zr.File), len(entries))
+	}
+
+	for i, file := range zr.File {
+		entry := entries[i]
+		if file.Name != entry.Name || file.Method != entry.Method || file.Flags&0x1 != 0 {
+			t.Errorf("entry %d = %s, method %d, flags %#x, want %s, method %d, unencrypted",
+				i, file.Name, file.Method, file.Flags, entry.Name, entry.Method)
+		}
+		if entry.Name == "huge.bin" {
+			if file.UncompressedSize64 != hugeSize || !hasZip64Extra(file.Extra) {
+				t.Errorf("huge.bin size = %d, ZIP64 extra %v, want %d with a ZIP64 extra", file.UncompressedSize64, hasZip64Extra(file.Extra), hugeSize)
+			}
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", file.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || !bytes.Equal(data, entry.Data) {
+			t.Errorf("%s = %q, %v, want %q", file.Name, data, err, entry.Data)
+		}
+	}
+}
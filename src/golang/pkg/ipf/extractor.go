@@ -2,13 +2,17 @@ package ipf
 
 import (
 	"archive/zip"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/joao-paulo-santos/GE-Library/pkg/workers"
@@ -17,20 +21,29 @@ import (
 
 // ExtractionTask represents a file extraction task
 type ExtractionTask struct {
-	FileInfo  *FileInfo
-	OutputDir string
-	ZipReader *zip.ReadCloser
-	Index     int
-	Password  []byte
+	FileInfo   *FileInfo
+	OutputDir  string
+	OutputName string          // Path relative to OutputDir (defaults to FileInfo.SafeFilename)
+	ZipReader  *zip.ReadCloser // The reader's ZipReader; nil for readers without a file
+	Index      int
+	Password   []byte
+
+	memory    *memoryBudget  // MaxMemory budget of the run the task belongs to; nil means no limit
+	openFiles *fileSemaphore // MaxOpenFiles bound of the run the task belongs to; nil means no limit
+	verifyCRC bool           // Check the CRC32 even when VerifyCRC is off, as VerifyAll does
 }
 
 // ExtractionResult represents the result of extracting a file
 type ExtractionResult struct {
 	Index      int
 	Success    bool
+	Skipped    bool
+	Method     uint16
 	FilePath   string
 	Size       int64
 	Error      error
+	Warning    string // Non-fatal problem noticed while extracting, e.g. a method mismatch
+	Integrity  Integrity
 	DurationMs int64
 }
 
@@ -41,28 +54,155 @@ type ExtractionTiming struct {
 	IO                time.Duration
 }
 
+// Batch sizing limits used by ExtractBatch
+const (
+	DefaultBatchMemory = 256 * 1024 * 1024 // Target uncompressed bytes per batch
+	MinBatchSize       = 16
+	MaxBatchSize       = 10000
+)
+
 // ConcurrentExtractor handles parallel file extraction
 type ConcurrentExtractor struct {
 	reader      *IPFReader
 	zipReader   *zip.ReadCloser
 	workerCount int
+
+	// BatchMemory is the approximate number of uncompressed bytes a single batch may hold
+	BatchMemory int64
+
+	// DirMode is the permission used for created directories when the archive stores none
+	DirMode os.FileMode
+
+	// Flatten discards directory structure and writes every file into the output root by base name,
+	// suffixing the index on collisions
+	Flatten bool
+
+	// MaxOpenFiles bounds the number of output files open at once across workers. Like MaxMemory
+	// it is applied per extraction run; tasks passed to ExtractSingle directly are not counted.
+	MaxOpenFiles int
+
+	// MaxMemory bounds the bytes held by entries being decrypted and decompressed in memory at
+	// once (0 = no limit). Each entry is weighed by its compressed plus uncompressed size; one
+	// larger than the whole budget still runs, alone. Stored entries stream and are not counted.
+	// The budget is built per extraction run; tasks passed to ExtractSingle directly are not counted.
+	MaxMemory int64
+
+	progress progressState
+
+	// MinSize and MaxSize restrict extraction to entries within an uncompressed size range (0 = no limit)
+	MinSize uint64
+	MaxSize uint64
+
+	// ConcatSeparator is written between entries by ExtractConcat
+	ConcatSeparator []byte
+
+	// AfterExtract, when set, is called by the worker right after a file has been written to path.
+	// It runs concurrently with other extractions; a returned error marks the result failed.
+	AfterExtract func(fi *FileInfo, path string) error
+
+	// Overwrite decides whether files that already exist in the output are replaced
+	Overwrite OverwritePolicy
+
+	// StripSingleRoot removes the top-level directory from output paths when every entry lives
+	// under the same one; archives with several top-level entries are extracted unchanged
+	StripSingleRoot bool
+
+	// Preallocate reserves each output file's full size before writing (fallocate on Linux),
+	// reducing fragmentation for large entries; a no-op elsewhere
+	Preallocate bool
+
+	// ConcatBuffer is the number of decrypted entries ExtractConcat may hold while they wait to be
+	// written in order (0 = twice the worker count). Peak memory grows with ConcatBuffer × average
+	// entry size, in exchange for more overlap between decryption and writes. Other extraction
+	// methods write each entry from the worker that decoded it and are bounded by MaxMemory instead.
+	ConcatBuffer int
+
+	// OffsetOrder schedules files by their position in the archive instead of index order,
+	// so the archive is read sequentially; output paths and result order are unaffected
+	OffsetOrder bool
+
+	// KeepGoing turns a panic while extracting one file into a failed result for that file,
+	// instead of stopping the whole extraction
+	KeepGoing bool
+
+	// OutputTemplate, when set, builds each output path from placeholders such as {ext}/{base}.{ext}
+	// (see ExpandOutputTemplate). Directory entries are skipped, and colliding paths get the index appended.
+	OutputTemplate string
+
+	// IgnoreCase makes the name patterns of ExtractMatching and ExtractConcat case-insensitive
+	IgnoreCase bool
+
+	// DedupByContent keeps same-name entries whose content differs instead of only the newest,
+	// see Deduplicator.ByContent
+	DedupByContent bool
+
+	// VerifyCRC checks every decoded entry against the CRC32 and size in the central directory,
+	// including entries with data descriptors whose local header carries no CRC32, and marks
+	// results IntegrityOK when they match
+	VerifyCRC bool
+}
+
+// concatBuffer returns the effective ConcatBuffer
+func (ce *ConcurrentExtractor) concatBuffer() int {
+	if ce.ConcatBuffer > 0 {
+		return ce.ConcatBuffer
+	}
+	return ce.workerCount * 2
 }
 
-// NewConcurrentExtractor creates a new concurrent extractor
+// NewConcurrentExtractorWithLimit creates a concurrent extractor whose in-memory buffers never
+// exceed maxBytes in total (see MaxMemory)
+func NewConcurrentExtractorWithLimit(reader *IPFReader, zipReader *zip.ReadCloser, workerCount int, maxBytes int64) *ConcurrentExtractor {
+	ce := NewConcurrentExtractor(reader, zipReader, workerCount)
+	ce.MaxMemory = maxBytes
+	return ce
+}
+
+// NewConcurrentExtractor creates a new concurrent extractor. zipReader is only handed on to tasks
+// and may be nil, as it is for readers created by NewIPFReaderFromReaderAt; data is always read
+// through reader.
 func NewConcurrentExtractor(reader *IPFReader, zipReader *zip.ReadCloser, workerCount int) *ConcurrentExtractor {
 	if workerCount <= 0 {
 		workerCount = runtime.NumCPU()
 	}
 
 	return &ConcurrentExtractor{
-		reader:      reader,
-		zipReader:   zipReader,
-		workerCount: workerCount,
+		reader:       reader,
+		zipReader:    zipReader,
+		workerCount:  workerCount,
+		BatchMemory:  DefaultBatchMemory,
+		DirMode:      0755,
+		MaxOpenFiles: DefaultMaxOpenFiles(),
 	}
 }
 
 // ExtractSingle extracts a single file using custom ZIP decryption
-func (ce *ConcurrentExtractor) ExtractSingle(task ExtractionTask) ExtractionResult {
+func (ce *ConcurrentExtractor) ExtractSingle(task ExtractionTask) (result ExtractionResult) {
+	if ce.KeepGoing {
+		defer func() {
+			if value := recover(); value != nil {
+				result = ExtractionResult{
+					Index:   task.Index,
+					Success: false,
+					Error:   fmt.Errorf("%w: file %d: %v", ErrPanic, task.Index, value),
+				}
+			}
+		}()
+	}
+
+	result = ce.extractSingle(task)
+	if task.FileInfo != nil && task.FileInfo.ZipInfo != nil {
+		result.Method = task.FileInfo.Method()
+		if task.FileInfo.MethodMismatch() {
+			result.Warning = fmt.Sprintf("%s: central directory declares %s but local header has %s; using local header",
+				task.FileInfo.SafeFilename, MethodName(task.FileInfo.ZipInfo.Method), MethodName(task.FileInfo.LocalMethod))
+		}
+	}
+	return result
+}
+
+// extractSingle performs the extraction for ExtractSingle
+func (ce *ConcurrentExtractor) extractSingle(task ExtractionTask) ExtractionResult {
 	startTime := getTimeMillis()
 
 	if task.FileInfo == nil || task.FileInfo.ZipInfo == nil {
@@ -74,46 +214,143 @@ func (ce *ConcurrentExtractor) ExtractSingle(task ExtractionTask) ExtractionResu
 	}
 
 	// Build output path
-	finalPath := filepath.Join(task.OutputDir, task.FileInfo.SafeFilename)
+	outputName := task.OutputName
+	if outputName == "" {
+		outputName = task.FileInfo.SafeFilename
+	}
+	finalPath, err := sanitizeExtractPath(task.OutputDir, outputName)
+	if err != nil {
+		return ExtractionResult{
+			Index:   task.Index,
+			Success: false,
+			Error:   fmt.Errorf("refusing to extract file %d: %w", task.Index, err),
+		}
+	}
 
-	// Always use custom decryption for IPF files
-	extractedData, err := ce.extractWithCustomDecryption(task)
+	// Directory entries carry no data, only the directory and its mode
+	if task.FileInfo.IsDir() {
+		return ce.extractDirectory(task.FileInfo, finalPath, task.Index, startTime)
+	}
 
-	if err != nil {
+	if ce.Overwrite == OverwriteIfChanged && unchangedOnDisk(task.FileInfo, finalPath, task.openFiles) {
 		return ExtractionResult{
+			Index:     task.Index,
+			Success:   true,
+			Skipped:   true,
+			FilePath:  finalPath,
+			Size:      int64(task.FileInfo.ZipInfo.UncompressedSize64),
+			Integrity: IntegrityOK,
+		}
+	}
+	if !ce.shouldOverwrite(task.FileInfo, finalPath) {
+		return ExtractionResult{Index: task.Index, Skipped: true, FilePath: finalPath}
+	}
+
+	var result ExtractionResult
+	if stored, ok, err := ce.openStored(task); err != nil {
+		result = ExtractionResult{
 			Index:   task.Index,
 			Success: false,
-			Error:   fmt.Errorf("custom extraction failed: %w", err),
+			Error:   fmt.Errorf("failed to open file %d: %w", task.Index, err),
+		}
+	} else if ok {
+		// Stored entries stream straight from the archive to disk without buffering, and always
+		// have their CRC32 checked
+		result = ce.writeExtractedStream(stored, stored.size, stored.verify, task.openFiles, finalPath, task.Index, startTime)
+		if result.Success {
+			result.Integrity = IntegrityOK
+		} else if errors.Is(result.Error, zipcipher.ErrCRCMismatch) {
+			result.Integrity = IntegrityCRCMismatch
+		}
+	} else {
+		result = ce.extractBuffered(task, finalPath, startTime)
+	}
+
+	// Restore the Unix mode when the archive was created on a Unix host
+	if mode, ok := task.FileInfo.UnixMode(); ok && result.Success {
+		if err := os.Chmod(finalPath, mode); err != nil {
+			result.Success = false
+			result.Error = fmt.Errorf("failed to set mode on %s: %w", finalPath, err)
+		}
+	}
+
+	// Keep the archived timestamp so sync tools comparing mtimes see unchanged files as unchanged
+	if modTime := entryModTime(task.FileInfo); !modTime.IsZero() && result.Success {
+		if err := os.Chtimes(finalPath, modTime, modTime); err != nil {
+			result.Success = false
+			result.Error = fmt.Errorf("failed to set modification time on %s: %w", finalPath, err)
 		}
 	}
 
-	// Write the extracted data
-	return ce.writeExtractedData(extractedData, finalPath, task.Index, startTime)
+	if ce.AfterExtract != nil && result.Success {
+		if err := ce.AfterExtract(task.FileInfo, finalPath); err != nil {
+			result.Success = false
+			result.Error = fmt.Errorf("after-extract hook failed for %s: %w", finalPath, err)
+		}
+	}
+
+	return result
 }
 
-// extractWithCustomDecryption extracts files using custom ZIP decryption without password verification
-func (ce *ConcurrentExtractor) extractWithCustomDecryption(task ExtractionTask) ([]byte, error) {
-	// Open the raw ZIP file for seeking
-	zipFileHandle, err := os.Open(ce.reader.File.Name())
+// extractBuffered decrypts and decompresses an entry in memory within the MaxMemory budget, then writes it
+func (ce *ConcurrentExtractor) extractBuffered(task ExtractionTask, finalPath string, startTime int64) ExtractionResult {
+	// The compressed and decompressed buffers both live until the write completes
+	weight := task.memory.acquire(int64(task.FileInfo.ZipInfo.CompressedSize64 + task.FileInfo.ZipInfo.UncompressedSize64))
+	defer task.memory.release(weight)
+
+	// Always use custom decryption for IPF files
+	extractedData, err := ce.extractWithCustomDecryption(task)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open ZIP file handle: %w", err)
+		return ExtractionResult{
+			Index:     task.Index,
+			Success:   false,
+			Error:     fmt.Errorf("custom extraction failed: %w", err),
+			Integrity: ce.integrityOf(task, err),
+		}
 	}
-	defer zipFileHandle.Close()
 
-	// Seek to the local header offset
-	_, err = zipFileHandle.Seek(task.FileInfo.LocalHeaderOffset, io.SeekStart)
-	if err != nil {
-		return nil, fmt.Errorf("failed to seek to file offset %d: %w", task.FileInfo.LocalHeaderOffset, err)
+	result := ce.writeExtractedData(extractedData, task.openFiles, finalPath, task.Index, startTime)
+	if result.Success {
+		result.Integrity = ce.integrityOf(task, nil)
 	}
+	return result
+}
+
+// extractWithCustomDecryption extracts files using custom ZIP decryption without password verification.
+// Workers share the reader's handle through ReadAt, which is safe for concurrent use, so no file
+// is opened per entry.
+func (ce *ConcurrentExtractor) extractWithCustomDecryption(task ExtractionTask) ([]byte, error) {
+	return ce.decodeVerified(task, ce.reader.entryReader(task.FileInfo, task.Password))
+}
 
-	// Create custom encrypted file reader
-	encryptedReader := zipcipher.NewEncryptedFileReader(zipFileHandle, task.Password)
+// decodeVerified decodes the task's entry from the reader positioned at it and, when the task is
+// verified, checks the result against the central directory
+func (ce *ConcurrentExtractor) decodeVerified(task ExtractionTask, encryptedReader *zipcipher.EncryptedFileReader) ([]byte, error) {
+	data, err := decodeEntry(task.FileInfo, encryptedReader)
+	if err == nil && ce.verifies(task) {
+		err = checkCRC(task.FileInfo, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
 
+// decodeEntry reads the local header and data of the entry the reader is positioned at, then
+// decrypts (without verifying the password check byte) and decompresses it. The sizes and CRC32
+// of the central directory are used, since entries written with data descriptors leave them zero
+// in the local header.
+func decodeEntry(fileInfo *FileInfo, encryptedReader *zipcipher.EncryptedFileReader) ([]byte, error) {
 	// Read and parse the local header
 	header, err := encryptedReader.ReadLocalHeader()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read local header: %w", err)
 	}
+	// Some packers list data descriptor entries with a zero compressed size; those are recovered
+	// from the descriptor instead
+	if zipInfo := fileInfo.ZipInfo; zipInfo != nil && !(zipInfo.Flags&0x8 != 0 && zipInfo.CompressedSize64 == 0 && zipInfo.UncompressedSize64 != 0) {
+		encryptedReader.SetKnownSizes(zipInfo.CRC32, zipInfo.CompressedSize64, zipInfo.UncompressedSize64)
+	}
 
 	// Skip password verification and directly read compressed data
 	compressedData, err := encryptedReader.ReadCompressedData()
@@ -150,11 +387,72 @@ func (ce *ConcurrentExtractor) extractWithCustomDecryption(task ExtractionTask)
 	return decompressedData, nil
 }
 
+// extractDirectory creates the directory for an explicit directory entry. It is created owner-writable
+// so sibling workers can still write into it; the stored mode is applied by applyDirModes once every
+// worker has finished.
+func (ce *ConcurrentExtractor) extractDirectory(fileInfo *FileInfo, dirPath string, index int, startTime int64) ExtractionResult {
+	mode := ce.dirMode(fileInfo) | 0700
+
+	if err := os.MkdirAll(dirPath, mode); err != nil {
+		return ExtractionResult{
+			Index:   index,
+			Success: false,
+			Error:   fmt.Errorf("failed to create directory %s: %w", dirPath, err),
+		}
+	}
+
+	return ExtractionResult{
+		Index:      index,
+		Success:    true,
+		FilePath:   dirPath,
+		DurationMs: getTimeMillis() - startTime,
+	}
+}
+
+// dirMode returns the stored Unix mode of a directory entry, or DirMode when the archive has none
+func (ce *ConcurrentExtractor) dirMode(fileInfo *FileInfo) os.FileMode {
+	if mode, ok := fileInfo.UnixMode(); ok {
+		return mode
+	}
+	return ce.DirMode
+}
+
+// applyDirModes sets the final mode of every directory extracted by tasks. It runs after all workers
+// are done, since a read-only mode applied earlier would make other workers fail to write into the
+// directory. Deeper directories go first so a parent losing its search bit cannot block them.
+// results must be in the same order as tasks.
+func (ce *ConcurrentExtractor) applyDirModes(tasks []ExtractionTask, results []ExtractionResult) {
+	var dirs []int
+	for i := range results {
+		if i < len(tasks) && tasks[i].FileInfo != nil && tasks[i].FileInfo.IsDir() && results[i].Success && results[i].FilePath != "" {
+			dirs = append(dirs, i)
+		}
+	}
+	sort.SliceStable(dirs, func(a, b int) bool {
+		return len(results[dirs[a]].FilePath) > len(results[dirs[b]].FilePath)
+	})
+
+	for _, i := range dirs {
+		// MkdirAll is subject to the umask and leaves existing directories untouched
+		if err := os.Chmod(results[i].FilePath, ce.dirMode(tasks[i].FileInfo)); err != nil {
+			results[i].Success = false
+			results[i].Error = fmt.Errorf("failed to set mode on directory %s: %w", results[i].FilePath, err)
+		}
+	}
+}
+
 // writeExtractedData writes extracted data to file
-func (ce *ConcurrentExtractor) writeExtractedData(data []byte, finalPath string, index int, startTime int64) ExtractionResult {
+func (ce *ConcurrentExtractor) writeExtractedData(data []byte, openFiles *fileSemaphore, finalPath string, index int, startTime int64) ExtractionResult {
+	return ce.writeExtractedStream(bytes.NewReader(data), int64(len(data)), nil, openFiles, finalPath, index, startTime)
+}
+
+// writeExtractedStream copies size bytes from src into finalPath, holding a slot of openFiles
+// while the file is open. When verify is set it runs after the copy, and a failure removes the
+// file like any other write error.
+func (ce *ConcurrentExtractor) writeExtractedStream(src io.Reader, size int64, verify func() error, openFiles *fileSemaphore, finalPath string, index int, startTime int64) ExtractionResult {
 	// Create parent directories if they don't exist
 	parentDir := filepath.Dir(finalPath)
-	if err := os.MkdirAll(parentDir, 0755); err != nil {
+	if err := os.MkdirAll(parentDir, ce.DirMode); err != nil {
 		return ExtractionResult{
 			Index:   index,
 			Success: false,
@@ -162,6 +460,9 @@ func (ce *ConcurrentExtractor) writeExtractedData(data []byte, finalPath string,
 		}
 	}
 
+	openFiles.acquire()
+	defer openFiles.release()
+
 	outFile, err := os.OpenFile(finalPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return ExtractionResult{
@@ -172,7 +473,24 @@ func (ce *ConcurrentExtractor) writeExtractedData(data []byte, finalPath string,
 	}
 	defer outFile.Close()
 
-	written, err := outFile.Write(data)
+	if ce.Preallocate {
+		if err := preallocate(outFile, size); err != nil {
+			os.Remove(finalPath)
+			return ExtractionResult{
+				Index:   index,
+				Success: false,
+				Error:   fmt.Errorf("failed to preallocate %s: %w", finalPath, err),
+			}
+		}
+	}
+
+	written, err := io.Copy(outFile, src)
+	if err == nil && written != size {
+		err = fmt.Errorf("short write: expected %d bytes, got %d", size, written)
+	}
+	if err == nil && verify != nil {
+		err = verify()
+	}
 	if err != nil {
 		os.Remove(finalPath) // Clean up partial file
 		return ExtractionResult{
@@ -198,56 +516,327 @@ func (ce *ConcurrentExtractor) writeExtractedData(data []byte, finalPath string,
 		Index:      index,
 		Success:    true,
 		FilePath:   finalPath,
-		Size:       int64(written),
+		Size:       written,
 		DurationMs: duration,
 	}
 }
 
 // ExtractAllParallel extracts all files using parallel processing
 func (ce *ConcurrentExtractor) ExtractAllParallel(ctx context.Context, outputDir string, password []byte) ([]ExtractionResult, error) {
-	fileInfos := ce.reader.GetFileInfos()
-	if len(fileInfos) == 0 {
-		return []ExtractionResult{}, nil
+	tracker := ce.startProgress()
+	defer tracker.finish()
+
+	tasks, skipped, err := ce.prepareTasks(outputDir, password)
+	if err != nil {
+		return nil, err
+	}
+
+	tracker.total = len(tasks)
+
+	if len(tasks) == 0 {
+		return skipped, nil
+	}
+
+	// Process all tasks in parallel
+	results, err := workers.Map(ctx, tasks, ce.workerCount, tracker.track(ce.ExtractSingle))
+	ce.applyDirModes(tasks, results)
+	ce.restoreIndexOrder(results)
+
+	return append(results, skipped...), err
+}
+
+// ExtractMatching extracts only the files whose names match one of patterns (see MatchGlob), e.g.
+// "data/item/*.xml" or "ui/**/*.png". Files that do not match are returned as skipped results,
+// not failures.
+func (ce *ConcurrentExtractor) ExtractMatching(ctx context.Context, outputDir string, patterns []string, password []byte) ([]ExtractionResult, error) {
+	tracker := ce.startProgress()
+	defer tracker.finish()
+
+	candidates, skipped, err := ce.prepareTasks(outputDir, password)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]ExtractionTask, 0, len(candidates))
+	for _, task := range candidates {
+		if !matchesAny(patterns, matchName(task.FileInfo), ce.IgnoreCase) {
+			skipped = append(skipped, ExtractionResult{Index: task.Index, Skipped: true})
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+
+	tracker.total = len(tasks)
+
+	results, err := workers.Map(ctx, tasks, ce.workerCount, tracker.track(ce.ExtractSingle))
+	ce.applyDirModes(tasks, results)
+	ce.restoreIndexOrder(results)
+
+	return append(results, skipped...), err
+}
+
+// prepareTasks creates the output directory and builds extraction tasks for the deduplicated file set.
+// Files rejected by the extractor's filters are returned as skipped results.
+func (ce *ConcurrentExtractor) prepareTasks(outputDir string, password []byte) ([]ExtractionTask, []ExtractionResult, error) {
+	if len(ce.reader.GetFileInfos()) == 0 {
+		return []ExtractionTask{}, []ExtractionResult{}, nil
 	}
 
 	// Ensure output directory exists
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	if err := os.MkdirAll(outputDir, ce.DirMode); err != nil {
+		return nil, nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	tasks, skipped := ce.selectTasks(outputDir, password)
+	return tasks, skipped, nil
+}
+
+// selectTasks builds extraction tasks for the deduplicated, filtered file set
+func (ce *ConcurrentExtractor) selectTasks(outputDir string, password []byte) ([]ExtractionTask, []ExtractionResult) {
+	fileInfos := ce.reader.GetFileInfos()
+
 	// Handle IPF progressive bloat: keep only newest version of each file
 	// Use Deduplicator module to filter duplicate filenames
 	deduplicator := NewDeduplicator(fileInfos)
+	deduplicator.ByContent = ce.DedupByContent
 	deduplicatedFileInfos := deduplicator.Run()
 
-	// Create extraction tasks only for files we want to keep (unique, newest versions)
+	// Create extraction tasks only for files we want to keep (unique, newest versions), sharing
+	// one memory budget and open file bound for the run
+	memory := newMemoryBudget(ce.MaxMemory)
+	openFiles := newFileSemaphore(ce.MaxOpenFiles)
 	tasks := make([]ExtractionTask, 0, len(deduplicatedFileInfos))
+	skipped := make([]ExtractionResult, 0)
 	for _, fileInfo := range deduplicatedFileInfos {
+		if !ce.shouldExtract(&fileInfo) || ((ce.Flatten || ce.OutputTemplate != "") && fileInfo.IsDir()) {
+			skipped = append(skipped, ExtractionResult{
+				Index:   fileInfo.Index,
+				Skipped: true,
+			})
+			continue
+		}
 		tasks = append(tasks, ExtractionTask{
-			FileInfo:  &fileInfo,
-			OutputDir: outputDir,
-			ZipReader: ce.zipReader,
-			Index:     fileInfo.Index,
-			Password:  password,
+			FileInfo:   &fileInfo,
+			OutputDir:  outputDir,
+			OutputName: fileInfo.SafeFilename,
+			ZipReader:  ce.zipReader,
+			Index:      fileInfo.Index,
+			Password:   password,
+			memory:     memory,
+			openFiles:  openFiles,
 		})
 	}
 
-	// Create parallel processor
-	processor := workers.NewParallelProcessor[ExtractionTask, ExtractionResult](
-		ce.workerCount,
-		len(tasks),
-	)
+	if ce.OffsetOrder {
+		sort.SliceStable(tasks, func(i, j int) bool {
+			return tasks[i].FileInfo.LocalHeaderOffset < tasks[j].FileInfo.LocalHeaderOffset
+		})
+	}
 
-	// Process all tasks in parallel
-	results := processor.Process(ctx, tasks, ce.ExtractSingle)
+	if ce.StripSingleRoot {
+		var rootSkipped []ExtractionResult
+		tasks, rootSkipped = stripSingleRoot(tasks)
+		skipped = append(skipped, rootSkipped...)
+	}
 
-	return results, nil
+	if ce.Flatten {
+		flattenOutputNames(tasks)
+	}
+
+	if ce.OutputTemplate != "" {
+		applyOutputTemplate(tasks, ce.OutputTemplate)
+	}
+
+	return tasks, skipped
+}
+
+// restoreIndexOrder sorts results back into entry index order after OffsetOrder reordered the
+// tasks, so callers always see results in the same order regardless of how entries were read
+func (ce *ConcurrentExtractor) restoreIndexOrder(results []ExtractionResult) {
+	if !ce.OffsetOrder {
+		return
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Index < results[j].Index
+	})
+}
+
+// flattenOutputNames rewrites task output names to their base names, keeping every file at a
+// distinct path with uniqueOutputName
+func flattenOutputNames(tasks []ExtractionTask) {
+	taken := make(map[string]bool, len(tasks))
+	for i := range tasks {
+		name := path.Base(filepath.ToSlash(tasks[i].OutputName))
+		tasks[i].OutputName = uniqueOutputName(taken, name, tasks[i].Index)
+	}
 }
 
-// ExtractBatch extracts files in batches for better memory management
+// uniqueOutputName returns name, or name with the entry index appended when it is already in
+// taken, and records the result. A suffixed name can itself be taken by an earlier entry named
+// that way, in which case a counter is added as well. Names are compared case-insensitively.
+func uniqueOutputName(taken map[string]bool, name string, index int) string {
+	if taken[strings.ToLower(name)] {
+		ext := path.Ext(name)
+		stem := strings.TrimSuffix(name, ext)
+		name = fmt.Sprintf("%s_%d%s", stem, index, ext)
+		for n := 2; taken[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s_%d_%d%s", stem, index, n, ext)
+		}
+	}
+	taken[strings.ToLower(name)] = true
+	return name
+}
+
+// ExtractBatch extracts files in batches for better memory management.
+// The effective batch size is derived from the average uncompressed entry size so that
+// each batch holds roughly BatchMemory bytes; batchSize is only used when sizes are unknown.
 func (ce *ConcurrentExtractor) ExtractBatch(ctx context.Context, outputDir string, batchSize int, password []byte) ([]ExtractionResult, error) {
-	// For simplicity, delegate to the main parallel extraction function
-	return ce.ExtractAllParallel(ctx, outputDir, password)
+	tracker := ce.startProgress()
+	defer tracker.finish()
+
+	tasks, skipped, err := ce.prepareTasks(outputDir, password)
+	if err != nil {
+		return nil, err
+	}
+
+	tracker.total = len(tasks)
+
+	if len(tasks) == 0 {
+		return skipped, nil
+	}
+
+	var totalSize int64
+	for _, task := range tasks {
+		if task.FileInfo.ZipInfo != nil {
+			totalSize += int64(task.FileInfo.ZipInfo.UncompressedSize64)
+		}
+	}
+	effectiveBatchSize := EffectiveBatchSize(batchSize, totalSize/int64(len(tasks)), ce.BatchMemory)
+
+	// Process batches sequentially so only one batch worth of data is in flight
+	results := make([]ExtractionResult, 0, len(tasks))
+	for i := 0; i < len(tasks); i += effectiveBatchSize {
+		end := i + effectiveBatchSize
+		if end > len(tasks) {
+			end = len(tasks)
+		}
+		batchResults, err := workers.Map(ctx, tasks[i:end], ce.workerCount, tracker.track(ce.ExtractSingle))
+		results = append(results, batchResults...)
+		if err != nil {
+			ce.applyDirModes(tasks, results)
+			ce.restoreIndexOrder(results)
+			return append(results, skipped...), err
+		}
+	}
+	ce.applyDirModes(tasks, results)
+	ce.restoreIndexOrder(results)
+
+	return append(results, skipped...), nil
+}
+
+// ExtractAllTo extracts all files into writers supplied by factory instead of the local filesystem.
+// The factory is called once per file from worker goroutines; each returned writer is closed after
+// the decrypted, decompressed data has been written to it. Directory entries are skipped.
+func (ce *ConcurrentExtractor) ExtractAllTo(ctx context.Context, factory func(*FileInfo) (io.WriteCloser, error), password []byte) ([]ExtractionResult, error) {
+	return ce.extractAllTo(ctx, factory, password, false)
+}
+
+// extractAllTo implements ExtractAllTo; verifyCRC checks every entry regardless of VerifyCRC
+func (ce *ConcurrentExtractor) extractAllTo(ctx context.Context, factory func(*FileInfo) (io.WriteCloser, error), password []byte, verifyCRC bool) ([]ExtractionResult, error) {
+	tracker := ce.startProgress()
+	defer tracker.finish()
+
+	tasks, skipped := ce.selectTasks("", password)
+	for i := range tasks {
+		tasks[i].verifyCRC = verifyCRC
+	}
+	tracker.total = len(tasks)
+
+	results, err := workers.Map(ctx, tasks, ce.workerCount, tracker.track(func(task ExtractionTask) ExtractionResult {
+		return ce.extractToWriter(task, factory)
+	}))
+	ce.restoreIndexOrder(results)
+
+	return append(results, skipped...), err
+}
+
+// extractToWriter extracts a single file into a writer obtained from factory
+func (ce *ConcurrentExtractor) extractToWriter(task ExtractionTask, factory func(*FileInfo) (io.WriteCloser, error)) ExtractionResult {
+	startTime := getTimeMillis()
+
+	if task.FileInfo == nil || task.FileInfo.ZipInfo == nil {
+		return ExtractionResult{
+			Index:   task.Index,
+			Success: false,
+			Error:   fmt.Errorf("file %d has no ZIP info", task.Index),
+		}
+	}
+
+	if task.FileInfo.IsDir() {
+		return ExtractionResult{Index: task.Index, Skipped: true}
+	}
+
+	data, err := ce.extractWithCustomDecryption(task)
+	if err != nil {
+		return ExtractionResult{
+			Index:     task.Index,
+			Success:   false,
+			Error:     fmt.Errorf("custom extraction failed: %w", err),
+			Integrity: ce.integrityOf(task, err),
+		}
+	}
+
+	writer, err := factory(task.FileInfo)
+	if err != nil {
+		return ExtractionResult{
+			Index:   task.Index,
+			Success: false,
+			Error:   fmt.Errorf("failed to open writer for %s: %w", task.FileInfo.SafeFilename, err),
+		}
+	}
+
+	written, err := writer.Write(data)
+	closeErr := writer.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return ExtractionResult{
+			Index:   task.Index,
+			Success: false,
+			Error:   fmt.Errorf("failed to write %s: %w", task.FileInfo.SafeFilename, err),
+		}
+	}
+
+	return ExtractionResult{
+		Index:      task.Index,
+		Success:    true,
+		FilePath:   task.FileInfo.SafeFilename,
+		Size:       int64(written),
+		DurationMs: getTimeMillis() - startTime,
+		Integrity:  ce.integrityOf(task, nil),
+	}
+}
+
+// EffectiveBatchSize computes how many entries of the given average size fit in memoryBudget bytes,
+// clamped to [MinBatchSize, MaxBatchSize]. It falls back to batchSize when the average is unknown.
+func EffectiveBatchSize(batchSize int, averageSize int64, memoryBudget int64) int {
+	if memoryBudget <= 0 {
+		memoryBudget = DefaultBatchMemory
+	}
+
+	size := batchSize
+	if averageSize > 0 {
+		size = int(memoryBudget / averageSize)
+	}
+
+	if size < MinBatchSize {
+		return MinBatchSize
+	}
+	if size > MaxBatchSize {
+		return MaxBatchSize
+	}
+	return size
 }
 
 // getTimeMillis returns current time in milliseconds
@@ -259,18 +848,31 @@ func getTimeMillis() int64 {
 type ExtractionStats struct {
 	TotalFiles      int64
 	ExtractedFiles  int64
+	SkippedFiles    int64
 	TotalSize       int64
 	SuccessRate     float64
 	AverageSpeedMBs float64
+	MethodCounts    map[uint16]int // Compression method of every processed (non-skipped) entry
 	Errors          []error
+	Warnings        []string
 }
 
 // CalculateStats calculates extraction statistics from results
 func CalculateStats(results []ExtractionResult, durationMs int64) ExtractionStats {
-	var extractedFiles, totalSize int64
+	var extractedFiles, skippedFiles, totalSize int64
 	var errors []error
+	var warnings []string
+	methodCounts := make(map[uint16]int)
 
 	for _, result := range results {
+		if result.Warning != "" {
+			warnings = append(warnings, result.Warning)
+		}
+		if result.Skipped {
+			skippedFiles++
+			continue
+		}
+		methodCounts[result.Method]++
 		if result.Success {
 			extractedFiles++
 			totalSize += result.Size
@@ -279,8 +881,12 @@ func CalculateStats(results []ExtractionResult, durationMs int64) ExtractionStat
 		}
 	}
 
-	totalFiles := int64(len(results))
-	successRate := float64(extractedFiles) / float64(totalFiles) * 100.0
+	// Skipped files are neither successes nor failures
+	totalFiles := int64(len(results)) - skippedFiles
+	successRate := 100.0
+	if totalFiles > 0 {
+		successRate = float64(extractedFiles) / float64(totalFiles) * 100.0
+	}
 
 	// Calculate speed in MB/s
 	var averageSpeedMBs float64
@@ -291,10 +897,35 @@ func CalculateStats(results []ExtractionResult, durationMs int64) ExtractionStat
 	return ExtractionStats{
 		TotalFiles:      totalFiles,
 		ExtractedFiles:  extractedFiles,
+		SkippedFiles:    skippedFiles,
 		TotalSize:       totalSize,
 		SuccessRate:     successRate,
 		AverageSpeedMBs: averageSpeedMBs,
+		MethodCounts:    methodCounts,
 		Errors:          errors,
+		Warnings:        warnings,
+	}
+}
+
+// MethodName returns a human-readable name for a ZIP compression method
+func MethodName(method uint16) string {
+	switch method {
+	case 0:
+		return "stored"
+	case 8:
+		return "deflate"
+	case 9:
+		return "deflate64"
+	case 12:
+		return "bzip2"
+	case 14:
+		return "lzma"
+	case 93:
+		return "zstd"
+	case 99:
+		return "aes"
+	default:
+		return fmt.Sprintf("method %d", method)
 	}
 }
 
@@ -0,0 +1,548 @@
+package ipf
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+func TestExtractShuffledArchive(t *testing.T) {
+	archive := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "data/a.xml", Data: []byte("<a/>"), Method: zip.Deflate},
+		ipftest.Entry{Name: "data/b.txt", Data: []byte(strings.Repeat("b", 300)), Method: zip.Deflate},
+		ipftest.Entry{Name: "c.bin", Data: []byte("stored c")},
+		ipftest.Entry{Name: "d.txt", Data: []byte("descriptor d"), Method: zip.Deflate, Descriptor: true},
+		ipftest.Entry{Name: "data/a.xml", Data: []byte("<a>patched</a>"), Method: zip.Deflate},
+	)
+	// The central directory no longer follows storage order, and the a.xml kept by index (3)
+	// is stored before the copy at index 1
+	shuffled := ipftest.ReorderCentralDirectory(t, archive, []int{3, 4, 2, 0, 1})
+	want := map[string]string{
+		"data/a.xml": "<a/>",
+		"data/b.txt": strings.Repeat("b", 300),
+		"c.bin":      "stored c",
+		"d.txt":      "descriptor d",
+	}
+
+	tests := []struct {
+		name        string
+		offsetOrder bool
+	}{
+		{"index order", false},
+		{"offset order", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := openArchive(t, shuffled, testPassword)
+			if entries := reader.EntriesByOffset(); entries[0].Index != 3 || entries[4].Index != 1 {
+				t.Fatalf("archive is not shuffled: first %d, last %d", entries[0].Index, entries[4].Index)
+			}
+
+			extractor := NewConcurrentExtractor(reader, nil, 3)
+			extractor.OffsetOrder = tt.offsetOrder
+			outputDir := t.TempDir()
+			results, err := extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			requireSuccess(t, results[:4], err)
+
+			for i, result := range results[:4] {
+				if i > 0 && result.Index < results[i-1].Index {
+					t.Errorf("results out of index order: %d after %d", result.Index, results[i-1].Index)
+				}
+			}
+
+			got := readTree(t, outputDir)
+			if len(got) != len(want) {
+				t.Errorf("extracted %d files, want %d", len(got), len(want))
+			}
+			for name, content := range want {
+				if got[name] != content {
+					t.Errorf("%s = %q, want %q", name, got[name], content)
+				}
+			}
+		})
+	}
+}
+
+// sizedEntries returns count entries of size bytes each
+func sizedEntries(count, size int) []ipftest.Entry {
+	entries := make([]ipftest.Entry, count)
+	for i := range entries {
+		entries[i] = ipftest.Entry{
+			Name:   fmt.Sprintf("files/%03d.bin", i),
+			Data:   bytes.Repeat([]byte{byte(i)}, size),
+			Method: zip.Deflate,
+		}
+	}
+	return entries
+}
+
+func TestEffectiveBatchSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int
+		average   int64
+		budget    int64
+		want      int
+	}{
+		{"unknown sizes use batch size", 100, 0, 1 << 20, 100},
+		{"budget divided by average", 100, 1 << 10, 64 << 10, 64},
+		{"clamped to minimum", 100, 1 << 20, 1 << 20, MinBatchSize},
+		{"clamped to maximum", 100, 1, 1 << 30, MaxBatchSize},
+		{"default budget", 100, 1 << 20, 0, DefaultBatchMemory >> 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EffectiveBatchSize(tt.batchSize, tt.average, tt.budget); got != tt.want {
+				t.Errorf("EffectiveBatchSize(%d, %d, %d) = %d, want %d", tt.batchSize, tt.average, tt.budget, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractBatchAdaptsToEntrySize(t *testing.T) {
+	const budget = 1 << 20
+	tests := []struct {
+		name    string
+		entries []ipftest.Entry
+		want    int
+	}{
+		{"many small files", sizedEntries(40, 256), budget / 256},
+		{"few large files", sizedEntries(4, 32<<10), budget / (32 << 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := openArchive(t, ipftest.Build(t, testPassword, tt.entries...), testPassword)
+
+			average := reader.GetTotalUncompressedSize() / int64(reader.GetFileCount())
+			if got := EffectiveBatchSize(0, average, budget); got != tt.want {
+				t.Errorf("batch size = %d, want %d", got, tt.want)
+			}
+
+			extractor := NewConcurrentExtractor(reader, nil, 2)
+			extractor.BatchMemory = budget
+			outputDir := t.TempDir()
+			results, err := extractor.ExtractBatch(context.Background(), outputDir, 0, testPassword)
+			requireSuccess(t, results, err)
+			if got := len(readTree(t, outputDir)); got != len(tt.entries) {
+				t.Errorf("extracted %d files, want %d", got, len(tt.entries))
+			}
+		})
+	}
+}
+
+func TestExtractDirectoryModes(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry ipftest.Entry
+		want  os.FileMode
+	}{
+		{
+			name:  "unix mode",
+			entry: ipftest.Entry{Name: "dir/", CreatorVersion: 3<<8 | 20, ExternalAttrs: (040000 | 0750) << 16},
+			want:  0750,
+		},
+		{
+			name:  "read-only unix mode",
+			entry: ipftest.Entry{Name: "dir/", CreatorVersion: 3<<8 | 20, ExternalAttrs: (040000 | 0555) << 16},
+			want:  0555,
+		},
+		{
+			name:  "msdos attributes",
+			entry: ipftest.Entry{Name: "dir/", ExternalAttrs: 0x10},
+			want:  0711,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := ipftest.Entry{Name: "dir/file.txt", Data: []byte("inside")}
+			reader := openArchive(t, ipftest.Build(t, testPassword, tt.entry, file), testPassword)
+
+			extractor := NewConcurrentExtractor(reader, nil, 2)
+			extractor.DirMode = 0711
+			outputDir := t.TempDir()
+			results, err := extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			requireSuccess(t, results, err)
+
+			dirPath := filepath.Join(outputDir, "dir")
+			t.Cleanup(func() { os.Chmod(dirPath, 0755) })
+			info, err := os.Stat(dirPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode().Perm(); got != tt.want {
+				t.Errorf("directory mode = %v, want %v", got, tt.want)
+			}
+			if data, err := os.ReadFile(filepath.Join(dirPath, "file.txt")); err != nil || string(data) != "inside" {
+				t.Errorf("file.txt = %q, %v", data, err)
+			}
+		})
+	}
+}
+
+// memoryWriter collects written bytes and records whether it was closed
+type memoryWriter struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (w *memoryWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestExtractAllToWriters(t *testing.T) {
+	entries := []ipftest.Entry{
+		{Name: "dir/", Data: nil},
+		{Name: "dir/deflated.txt", Data: bytes.Repeat([]byte("deflate "), 100), Method: zip.Deflate},
+		{Name: "stored.bin", Data: []byte{0, 1, 2, 3}},
+		{Name: "descriptor.txt", Data: []byte("descriptor"), Method: zip.Deflate, Descriptor: true},
+	}
+	reader := openArchive(t, ipftest.Build(t, testPassword, entries...), testPassword)
+
+	var mu sync.Mutex
+	writers := make(map[string]*memoryWriter)
+	factory := func(fi *FileInfo) (io.WriteCloser, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		w := &memoryWriter{}
+		writers[fi.SafeFilename] = w
+		return w, nil
+	}
+
+	results, err := NewConcurrentExtractor(reader, nil, 3).ExtractAllTo(context.Background(), factory, testPassword)
+	if err != nil {
+		t.Fatalf("ExtractAllTo: %v", err)
+	}
+	for _, result := range results {
+		if !result.Success && !result.Skipped {
+			t.Errorf("file %d failed: %v", result.Index, result.Error)
+		}
+	}
+
+	if _, ok := writers["dir/"]; ok {
+		t.Errorf("a writer was requested for the directory entry")
+	}
+	for _, entry := range entries[1:] {
+		w, ok := writers[entry.Name]
+		if !ok {
+			t.Errorf("no writer for %s", entry.Name)
+			continue
+		}
+		if !bytes.Equal(w.Bytes(), entry.Data) {
+			t.Errorf("%s = %q, want %q", entry.Name, w.Bytes(), entry.Data)
+		}
+		if !w.closed {
+			t.Errorf("writer of %s was not closed", entry.Name)
+		}
+	}
+}
+
+func TestCalculateStatsMethodCounts(t *testing.T) {
+	archive := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "a.bin", Data: []byte("stored a")},
+		ipftest.Entry{Name: "b.bin", Data: []byte("stored b")},
+		ipftest.Entry{Name: "c.txt", Data: []byte("deflated c"), Method: zip.Deflate},
+		ipftest.Entry{Name: "d.txt", Data: []byte("deflated d"), Method: zip.Deflate},
+		ipftest.Entry{Name: "e.txt", Data: []byte("deflated e"), Method: zip.Deflate},
+		ipftest.Entry{Name: "f.bz2", Data: []byte("not really bzip2"), Method: 12},
+		ipftest.Entry{Name: "skipped.txt", Data: bytes.Repeat([]byte("s"), 1000), Method: zip.Deflate},
+	)
+	extractor := NewConcurrentExtractor(openArchive(t, archive, testPassword), nil, 2)
+	extractor.MaxSize = 100
+	results, err := extractor.ExtractAllParallel(context.Background(), t.TempDir(), testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := CalculateStats(results, 1)
+	want := map[uint16]int{zip.Store: 2, zip.Deflate: 3, 12: 1}
+	if len(stats.MethodCounts) != len(want) {
+		t.Errorf("MethodCounts = %v, want %v", stats.MethodCounts, want)
+	}
+	for method, count := range want {
+		if got := stats.MethodCounts[method]; got != count {
+			t.Errorf("%s entries = %d, want %d", MethodName(method), got, count)
+		}
+	}
+	if stats.ExtractedFiles != 5 || stats.SkippedFiles != 1 || len(stats.Errors) != 1 {
+		t.Fatalf("extracted %d, skipped %d, errors %v", stats.ExtractedFiles, stats.SkippedFiles, stats.Errors)
+	}
+	if !errors.Is(stats.Errors[0], ErrUnsupportedMethod) {
+		t.Errorf("error = %v, want ErrUnsupportedMethod", stats.Errors[0])
+	}
+}
+
+func TestAfterExtractHook(t *testing.T) {
+	entries := []ipftest.Entry{
+		{Name: "tex/a.dds", Data: []byte("texture a"), Method: zip.Deflate},
+		{Name: "tex/b.dds", Data: []byte("texture b")},
+		{Name: "c.xml", Data: []byte("<c/>"), Method: zip.Deflate},
+		{Name: "d.xml", Data: []byte("<d/>")},
+	}
+	reader := openArchive(t, ipftest.Build(t, testPassword, entries...), testPassword)
+
+	t.Run("rename by extension", func(t *testing.T) {
+		var mu sync.Mutex
+		calls := make(map[string]int)
+		extractor := NewConcurrentExtractor(reader, nil, 3)
+		extractor.AfterExtract = func(fi *FileInfo, path string) error {
+			mu.Lock()
+			calls[fi.SafeFilename]++
+			mu.Unlock()
+			if filepath.Ext(path) != ".dds" {
+				return nil
+			}
+			return os.Rename(path, strings.TrimSuffix(path, ".dds")+".png")
+		}
+
+		outputDir := t.TempDir()
+		results, err := extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+		requireSuccess(t, results, err)
+
+		for _, entry := range entries {
+			if calls[entry.Name] != 1 {
+				t.Errorf("hook ran %d times for %s, want 1", calls[entry.Name], entry.Name)
+			}
+		}
+		want := map[string]string{
+			"tex/a.png": "texture a",
+			"tex/b.png": "texture b",
+			"c.xml":     "<c/>",
+			"d.xml":     "<d/>",
+		}
+		got := readTree(t, outputDir)
+		if len(got) != len(want) {
+			t.Errorf("output has %d files, want %d: %v", len(got), len(want), got)
+		}
+		for name, content := range want {
+			if got[name] != content {
+				t.Errorf("%s = %q, want %q", name, got[name], content)
+			}
+		}
+	})
+
+	t.Run("hook error", func(t *testing.T) {
+		errConvert := errors.New("conversion failed")
+		extractor := NewConcurrentExtractor(reader, nil, 3)
+		extractor.AfterExtract = func(fi *FileInfo, path string) error {
+			if fi.SafeFilename == "c.xml" {
+				return errConvert
+			}
+			return nil
+		}
+
+		results, err := extractor.ExtractAllParallel(context.Background(), t.TempDir(), testPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, result := range results {
+			failed := reader.FileInfos[result.Index].SafeFilename == "c.xml"
+			if result.Success == failed {
+				t.Errorf("file %d success = %v, want %v", result.Index, result.Success, !failed)
+			}
+			if failed && !errors.Is(result.Error, errConvert) {
+				t.Errorf("file %d error = %v, want the hook error", result.Index, result.Error)
+			}
+		}
+	})
+}
+
+func TestExtractMethodMismatch(t *testing.T) {
+	content := bytes.Repeat([]byte("method mismatch "), 20)
+
+	tests := []struct {
+		name        string
+		stored      uint16 // Method the data is stored with, kept in the local header
+		central     uint16 // Method declared by the central directory
+		wantWarning bool
+	}{
+		{"central deflate, local store", zip.Store, zip.Deflate, true},
+		{"central store, local deflate", zip.Deflate, zip.Store, true},
+		{"methods agree", zip.Deflate, zip.Deflate, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := ipftest.Build(t, testPassword, ipftest.Entry{Name: "a.txt", Data: content, Method: tt.stored})
+			binary.LittleEndian.PutUint16(ipftest.CentralRecords(t, archive)[0][10:], tt.central)
+			reader := openArchive(t, archive, testPassword)
+
+			if got := reader.FileInfos[0].Method(); got != tt.stored {
+				t.Errorf("Method() = %s, want the local header's %s", MethodName(got), MethodName(tt.stored))
+			}
+
+			outputDir := t.TempDir()
+			results, err := NewConcurrentExtractor(reader, nil, 1).ExtractAllParallel(context.Background(), outputDir, testPassword)
+			requireSuccess(t, results, err)
+			if got := readTree(t, outputDir)["a.txt"]; got != string(content) {
+				t.Errorf("a.txt = %q, want %q", got, content)
+			}
+			if (results[0].Warning != "") != tt.wantWarning {
+				t.Errorf("Warning = %q, want a warning: %v", results[0].Warning, tt.wantWarning)
+			}
+			if stats := CalculateStats(results, 1); (len(stats.Warnings) == 1) != tt.wantWarning {
+				t.Errorf("stats warnings = %q, want a warning: %v", stats.Warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+// BenchmarkExtractOrder compares extraction in central directory order with extraction in
+// physical offset order on an archive whose central directory is shuffled. The archive is read
+// from a file, so on spinning disks with a cold cache the offset order saves the seeks.
+func BenchmarkExtractOrder(b *testing.B) {
+	const count = 200
+	archive := ipftest.Build(b, testPassword, sizedEntries(count, 16<<10)...)
+	order := make([]int, count)
+	for i := range order {
+		order[i] = (i * 37) % count
+	}
+	path := ipftest.WriteFile(b, b.TempDir(), "shuffled.ipf", ipftest.ReorderCentralDirectory(b, archive, order))
+
+	for _, offsetOrder := range []bool{false, true} {
+		name := "index order"
+		if offsetOrder {
+			name = "offset order"
+		}
+		b.Run(name, func(b *testing.B) {
+			reader, err := NewIPFReader(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer reader.Close()
+			if _, err := reader.ListFiles(testPassword); err != nil {
+				b.Fatal(err)
+			}
+
+			b.SetBytes(reader.GetTotalUncompressedSize())
+			for i := 0; i < b.N; i++ {
+				extractor := NewConcurrentExtractor(reader, nil, 4)
+				extractor.OffsetOrder = offsetOrder
+				results, err := extractor.ExtractAllParallel(context.Background(), b.TempDir(), testPassword)
+				requireSuccess(b, results, err)
+			}
+		})
+	}
+}
+
+func TestExtractModTime(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry ipftest.Entry
+		want  time.Time
+	}{
+		{
+			name:  "stored",
+			entry: ipftest.Entry{Name: "a.txt", Data: []byte("a"), ModTime: 0x8d4f, ModDate: 0x4469},
+			want:  time.Date(2014, time.March, 9, 17, 42, 30, 0, time.Local),
+		},
+		{
+			name:  "deflated",
+			entry: ipftest.Entry{Name: "b.txt", Data: []byte("b"), Method: zip.Deflate, ModTime: 0x0000, ModDate: 0x0021},
+			want:  time.Date(1980, time.January, 1, 0, 0, 0, 0, time.Local),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := openArchive(t, ipftest.Build(t, testPassword, tt.entry), testPassword)
+			outputDir := t.TempDir()
+			results, err := NewConcurrentExtractor(reader, nil, 1).ExtractAllParallel(context.Background(), outputDir, testPassword)
+			requireSuccess(t, results, err)
+
+			info, err := os.Stat(filepath.Join(outputDir, tt.entry.Name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !info.ModTime().Equal(tt.want) {
+				t.Errorf("%s modified %v, want %v", tt.entry.Name, info.ModTime(), tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractWithoutReopening(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("open files cannot be removed on Windows")
+	}
+	entries := sizedEntries(50, 1000)
+	entries[0].Method = zip.Store
+	path := ipftest.WriteFile(t, t.TempDir(), "archive.ipf", ipftest.Build(t, testPassword, entries...))
+
+	reader, err := NewIPFReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err := reader.ListFiles(testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	// Opening the archive by name fails from here on; the handle the reader holds still works
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	outputDir := t.TempDir()
+	results, err := NewConcurrentExtractor(reader, nil, 4).ExtractAllParallel(context.Background(), outputDir, testPassword)
+	requireSuccess(t, results, err)
+	if files := readTree(t, outputDir); len(files) != len(entries) {
+		t.Errorf("extracted %d files, want %d", len(files), len(entries))
+	}
+}
+
+// BenchmarkEntryHandles compares decoding every entry through the reader's one handle with the
+// previous design, which opened the archive again for each entry. That the shared handle opens
+// nothing is checked by TestExtractWithoutReopening.
+func BenchmarkEntryHandles(b *testing.B) {
+	const count = 2000
+	path := ipftest.WriteFile(b, b.TempDir(), "archive.ipf", ipftest.Build(b, testPassword, sizedEntries(count, 512)...))
+	reader, err := NewIPFReader(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err := reader.ListFiles(testPassword); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("open per entry", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := range reader.FileInfos {
+				fileInfo := &reader.FileInfos[j]
+				file, err := os.Open(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				section := io.NewSectionReader(file, fileInfo.LocalHeaderOffset, reader.size-fileInfo.LocalHeaderOffset)
+				_, err = decodeEntry(fileInfo, zipcipher.NewEncryptedFileReader(section, testPassword))
+				file.Close()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("shared handle", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := range reader.FileInfos {
+				fileInfo := &reader.FileInfos[j]
+				if _, err := decodeEntry(fileInfo, reader.entryReader(fileInfo, testPassword)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
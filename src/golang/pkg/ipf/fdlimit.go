@@ -0,0 +1,50 @@
+package ipf
+
+// Open file limits used when bounding concurrent extraction I/O
+const (
+	fallbackMaxOpenFiles = 512
+	reservedOpenFiles    = 64 // Descriptors kept free for stdio, the archive handle and the runtime
+	minOpenFiles         = 16
+)
+
+// clampMaxOpenFiles turns a descriptor limit into a usable extraction bound
+func clampMaxOpenFiles(limit int64) int {
+	limit -= reservedOpenFiles
+	if limit < minOpenFiles {
+		return minOpenFiles
+	}
+	if limit > 1<<16 {
+		return 1 << 16
+	}
+	return int(limit)
+}
+
+// fileSemaphore bounds the number of output files held open at once across workers. Each
+// extraction run gets its own semaphore, so changing MaxOpenFiles takes effect on the next run.
+type fileSemaphore struct {
+	slots chan struct{}
+}
+
+// newFileSemaphore returns a semaphore of limit slots, or DefaultMaxOpenFiles when limit <= 0
+func newFileSemaphore(limit int) *fileSemaphore {
+	if limit <= 0 {
+		limit = DefaultMaxOpenFiles()
+	}
+	return &fileSemaphore{slots: make(chan struct{}, limit)}
+}
+
+// acquire blocks until a file slot is available. A nil semaphore never blocks.
+func (fs *fileSemaphore) acquire() {
+	if fs == nil {
+		return
+	}
+	fs.slots <- struct{}{}
+}
+
+// release frees a file slot taken by acquire
+func (fs *fileSemaphore) release() {
+	if fs == nil {
+		return
+	}
+	<-fs.slots
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package ipf
+
+// DefaultMaxOpenFiles returns a conservative bound on concurrently open files
+// for platforms without RLIMIT_NOFILE
+func DefaultMaxOpenFiles() int {
+	return fallbackMaxOpenFiles
+}
@@ -0,0 +1,108 @@
+package ipf
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestClampMaxOpenFiles(t *testing.T) {
+	tests := []struct {
+		limit int64
+		want  int
+	}{
+		{0, minOpenFiles},
+		{reservedOpenFiles + 1, minOpenFiles},
+		{1024, 1024 - reservedOpenFiles},
+		{1 << 20, 1 << 16},
+	}
+	for _, tt := range tests {
+		if got := clampMaxOpenFiles(tt.limit); got != tt.want {
+			t.Errorf("clampMaxOpenFiles(%d) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestFileSemaphoreBound(t *testing.T) {
+	const limit = 3
+	semaphore := newFileSemaphore(limit)
+	var open, peak atomic.Int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore.acquire()
+			defer semaphore.release()
+
+			n := open.Add(1)
+			for {
+				max := peak.Load()
+				if n <= max || peak.CompareAndSwap(max, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			open.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > limit {
+		t.Errorf("%d files open at once, limit %d", got, limit)
+	}
+}
+
+func TestExtractUnderOpenFileLimit(t *testing.T) {
+	reader := openArchive(t, ipftest.Build(t, testPassword, sizedEntries(200, 512)...), testPassword)
+
+	tests := []struct {
+		name     string
+		workers  int
+		maxFiles int
+	}{
+		{"one file", 16, 1},
+		{"two files", 32, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractor := NewConcurrentExtractor(reader, nil, tt.workers)
+			extractor.MaxOpenFiles = tt.maxFiles
+			outputDir := t.TempDir()
+			results, err := extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			requireSuccess(t, results, err)
+			if got := len(readTree(t, outputDir)); got != 200 {
+				t.Errorf("extracted %d files, want 200", got)
+			}
+
+			// Overwriting unchanged files reads each of them back under the same bound
+			extractor.Overwrite = OverwriteIfChanged
+			results, err = extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			requireSuccess(t, results, err)
+		})
+	}
+}
+
+func TestOpenFileLimitPerRun(t *testing.T) {
+	reader := openArchive(t, ipftest.Build(t, testPassword, sizedEntries(4, 16)...), testPassword)
+	extractor := NewConcurrentExtractor(reader, nil, 4)
+
+	// A changed limit applies to the next run instead of being fixed by the first one
+	for _, limit := range []int{2, 5} {
+		extractor.MaxOpenFiles = limit
+		tasks, _ := extractor.selectTasks(t.TempDir(), testPassword)
+		for _, task := range tasks {
+			if task.openFiles != tasks[0].openFiles {
+				t.Fatalf("task %d has its own semaphore, want one per run", task.Index)
+			}
+		}
+		if got := cap(tasks[0].openFiles.slots); got != limit {
+			t.Errorf("semaphore holds %d slots, want %d", got, limit)
+		}
+	}
+}
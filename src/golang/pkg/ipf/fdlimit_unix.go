@@ -0,0 +1,15 @@
+//go:build unix
+
+package ipf
+
+import "golang.org/x/sys/unix"
+
+// DefaultMaxOpenFiles derives a bound on concurrently open files from the soft RLIMIT_NOFILE,
+// leaving headroom for descriptors the process already holds
+func DefaultMaxOpenFiles() int {
+	var rlimit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rlimit); err != nil || rlimit.Cur == unix.RLIM_INFINITY {
+		return fallbackMaxOpenFiles
+	}
+	return clampMaxOpenFiles(int64(rlimit.Cur))
+}
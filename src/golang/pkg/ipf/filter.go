@@ -0,0 +1,92 @@
+package ipf
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// InSizeRange reports whether the uncompressed size of fileInfo lies within [minSize, maxSize].
+// A zero bound disables that side of the range.
+func InSizeRange(fileInfo *FileInfo, minSize, maxSize uint64) bool {
+	if fileInfo.ZipInfo == nil {
+		return minSize == 0 && maxSize == 0
+	}
+
+	size := fileInfo.ZipInfo.UncompressedSize64
+	if minSize > 0 && size < minSize {
+		return false
+	}
+	if maxSize > 0 && size > maxSize {
+		return false
+	}
+	return true
+}
+
+// shouldExtract applies the extractor's filters to a file
+func (ce *ConcurrentExtractor) shouldExtract(fileInfo *FileInfo) bool {
+	return InSizeRange(fileInfo, ce.MinSize, ce.MaxSize)
+}
+
+// matchName returns the name patterns are matched against: the decrypted name with backslashes
+// turned into slashes, or the safe name when decryption failed. Sanitizing would otherwise hide
+// characters such as "+" or "(" from the patterns.
+func matchName(fileInfo *FileInfo) string {
+	return lookupName(fileInfoName(fileInfo))
+}
+
+// matchesAny reports whether name matches one of the slash-separated glob patterns (see MatchGlob).
+// An empty pattern list matches every name.
+func matchesAny(patterns []string, name string, ignoreCase bool) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	name = filepath.ToSlash(name)
+	if ignoreCase {
+		name = strings.ToLower(name)
+	}
+	for _, pattern := range patterns {
+		if ignoreCase {
+			pattern = strings.ToLower(pattern)
+		}
+		if MatchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob reports whether the slash-separated name matches pattern. Segments follow path.Match,
+// and a "**" segment matches any number of directories, including none: "data/**/*.xml" matches
+// both data/a.xml and data/item/weapon/a.xml. Malformed patterns match nothing.
+func MatchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches pattern segments against name segments, backtracking over "**"
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse repeated "**" and try every possible number of consumed segments
+			for len(pattern) > 1 && pattern[1] == "**" {
+				pattern = pattern[1:]
+			}
+			for skip := 0; skip <= len(name); skip++ {
+				if matchSegments(pattern[1:], name[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if matched, err := path.Match(pattern[0], name[0]); err != nil || !matched {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,172 @@
+package ipf
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestExtractSizeWindow(t *testing.T) {
+	archive := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "tiny.txt", Data: bytes.Repeat([]byte("t"), 10)},
+		ipftest.Entry{Name: "small.txt", Data: bytes.Repeat([]byte("s"), 100)},
+		ipftest.Entry{Name: "medium.txt", Data: bytes.Repeat([]byte("m"), 1000)},
+		ipftest.Entry{Name: "large.txt", Data: bytes.Repeat([]byte("l"), 10000)},
+	)
+
+	tests := []struct {
+		name     string
+		min, max uint64
+		want     []string
+	}{
+		{"no limits", 0, 0, []string{"large.txt", "medium.txt", "small.txt", "tiny.txt"}},
+		{"minimum only", 100, 0, []string{"large.txt", "medium.txt", "small.txt"}},
+		{"maximum only", 0, 999, []string{"small.txt", "tiny.txt"}},
+		{"window", 11, 1000, []string{"medium.txt", "small.txt"}},
+		{"empty window", 2000, 5000, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := openArchive(t, archive, testPassword)
+			extractor := NewConcurrentExtractor(reader, nil, 2)
+			extractor.MinSize, extractor.MaxSize = tt.min, tt.max
+
+			outputDir := t.TempDir()
+			results, err := extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			if err != nil {
+				t.Fatalf("ExtractAllParallel: %v", err)
+			}
+
+			skipped := 0
+			for _, result := range results {
+				if result.Skipped {
+					skipped++
+				} else if !result.Success {
+					t.Errorf("file %d failed: %v", result.Index, result.Error)
+				}
+			}
+			if want := 4 - len(tt.want); skipped != want {
+				t.Errorf("%d results skipped, want %d", skipped, want)
+			}
+
+			var got []string
+			for name := range readTree(t, outputDir) {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("extracted %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"data/item/*.xml", "data/item/sword.xml", true},
+		{"data/item/*.xml", "data/item/weapon/sword.xml", false},
+		{"data/**/*.xml", "data/sword.xml", true},
+		{"data/**/*.xml", "data/item/weapon/sword.xml", true},
+		{"data/**/*.xml", "ui/item/sword.xml", false},
+		{"**/*.png", "button.png", true},
+		{"**", "any/depth/at/all.bin", true},
+		{"data/**/**/*.xml", "data/a/b.xml", true},
+		{"data/*", "data/item/sword.xml", false},
+		{"data/[a-c]*.xml", "data/b.xml", true},
+		{"data/[", "data/[", false},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtractMatching(t *testing.T) {
+	archive := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "data/item/sword.xml", Data: []byte("sword")},
+		ipftest.Entry{Name: "data/item/Shield.XML", Data: []byte("shield")},
+		ipftest.Entry{Name: "data/item/weapon/bow.xml", Data: []byte("bow")},
+		ipftest.Entry{Name: "data/skill.xml", Data: []byte("skill")},
+		ipftest.Entry{Name: "ui/button.png", Data: []byte("button")},
+	)
+
+	tests := []struct {
+		name       string
+		patterns   []string
+		ignoreCase bool
+		want       []string
+	}{
+		{"no patterns", nil, false, []string{"data/item/Shield.XML", "data/item/sword.xml", "data/item/weapon/bow.xml", "data/skill.xml", "ui/button.png"}},
+		{"single directory", []string{"data/item/*.xml"}, false, []string{"data/item/sword.xml"}},
+		{"ignore case", []string{"data/item/*.xml"}, true, []string{"data/item/Shield.XML", "data/item/sword.xml"}},
+		{"recursive", []string{"data/**/*.xml"}, false, []string{"data/item/sword.xml", "data/item/weapon/bow.xml", "data/skill.xml"}},
+		{"several patterns", []string{"ui/*.png", "data/*.xml"}, false, []string{"data/skill.xml", "ui/button.png"}},
+		{"case sensitive pattern", []string{"UI/*.PNG"}, false, nil},
+		{"case insensitive pattern", []string{"UI/*.PNG"}, true, []string{"ui/button.png"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := openArchive(t, archive, testPassword)
+			extractor := NewConcurrentExtractor(reader, nil, 2)
+			extractor.IgnoreCase = tt.ignoreCase
+
+			outputDir := t.TempDir()
+			results, err := extractor.ExtractMatching(context.Background(), outputDir, tt.patterns, testPassword)
+			requireSuccess(t, results, err)
+
+			skipped := 0
+			for _, result := range results {
+				if result.Skipped {
+					skipped++
+				}
+			}
+			if want := 5 - len(tt.want); skipped != want || len(results) != 5 {
+				t.Errorf("%d of %d results skipped, want %d of 5", skipped, len(results), want)
+			}
+
+			var got []string
+			for name := range readTree(t, outputDir) {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("extracted %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchDecryptedNames(t *testing.T) {
+	// The safe names are sound/hit_1.wav and data/map/town.xml, which the patterns must not need
+	archive := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "sound/hit+1.wav", Data: []byte("hit")},
+		ipftest.Entry{Name: "data\\map\\town.xml", Data: []byte("town")},
+		ipftest.Entry{Name: "sound/hit_1.wav.bak", Data: []byte("backup")},
+	)
+	reader := openArchive(t, archive, testPassword)
+	patterns := []string{"sound/hit+*.wav", "data/map/*.xml"}
+
+	outputDir := t.TempDir()
+	results, err := NewConcurrentExtractor(reader, nil, 2).ExtractMatching(context.Background(), outputDir, patterns, testPassword)
+	requireSuccess(t, results, err)
+	got := readTree(t, outputDir)
+	if len(got) != 2 || got["sound/hit_1.wav"] != "hit" || got["data/map/town.xml"] != "town" {
+		t.Errorf("ExtractMatching extracted %v", got)
+	}
+
+	var out bytes.Buffer
+	if _, err := NewConcurrentExtractor(reader, nil, 2).ExtractConcat(context.Background(), &out, patterns, testPassword); err != nil {
+		t.Fatalf("ExtractConcat: %v", err)
+	}
+	if out.String() != "hittown" {
+		t.Errorf("ExtractConcat wrote %q, want %q", out.String(), "hittown")
+	}
+}
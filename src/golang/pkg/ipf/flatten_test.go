@@ -0,0 +1,72 @@
+package ipf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestExtractFlatten(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []ipftest.Entry
+		want    map[string]string
+	}{
+		{
+			name: "shared basename in different folders",
+			entries: []ipftest.Entry{
+				{Name: "ui/icon.png", Data: []byte("ui")},
+				{Name: "data/items/icon.png", Data: []byte("items")},
+			},
+			want: map[string]string{"icon.png": "ui", "icon_1.png": "items"},
+		},
+		{
+			// The names collide on case-insensitive file systems
+			name: "basenames differing in case",
+			entries: []ipftest.Entry{
+				{Name: "a/Tex.dds", Data: []byte("a")},
+				{Name: "b/tex.dds", Data: []byte("b")},
+			},
+			want: map[string]string{"Tex.dds": "a", "tex_1.dds": "b"},
+		},
+		{
+			name: "suffixed name already taken",
+			entries: []ipftest.Entry{
+				{Name: "a/icon.png", Data: []byte("a")},
+				{Name: "b/icon_2.png", Data: []byte("b")},
+				{Name: "c/icon.png", Data: []byte("c")},
+			},
+			want: map[string]string{"icon.png": "a", "icon_2.png": "b", "icon_2_2.png": "c"},
+		},
+		{
+			name: "directory entries and extensionless names",
+			entries: []ipftest.Entry{
+				{Name: "a/"},
+				{Name: "a/README", Data: []byte("a")},
+				{Name: "b/README", Data: []byte("b")},
+			},
+			want: map[string]string{"README": "a", "README_2": "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := openArchive(t, ipftest.Build(t, testPassword, tt.entries...), testPassword)
+			extractor := NewConcurrentExtractor(reader, nil, 4)
+			extractor.Flatten = true
+			outputDir := t.TempDir()
+			results, err := extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			requireSuccess(t, results, err)
+
+			got := readTree(t, outputDir)
+			if len(got) != len(tt.want) {
+				t.Errorf("extracted %v, want %v", got, tt.want)
+			}
+			for name, content := range tt.want {
+				if got[name] != content {
+					t.Errorf("%s = %q, want %q", name, got[name], content)
+				}
+			}
+		})
+	}
+}
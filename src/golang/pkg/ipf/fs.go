@@ -0,0 +1,248 @@
+package ipf
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// FS returns a read-only fs.FS over the archive keyed by decrypted filenames, e.g. for
+// http.FileServer(http.FS(...)), template.ParseFS or fs.WalkDir. Directories are synthesized from
+// the "/" separators in names, and when a name occurs more than once the newest entry wins, as on
+// extraction. File contents are decrypted and decompressed on first read and kept until Close.
+// ReadFileStructure and ReadEncryptedFilenames must have been called.
+func (r *IPFReader) FS() (fs.FS, error) {
+	return r.FSWithPassword(zipcipher.GetIPFPassword())
+}
+
+// FSWithPassword is FS for archives encrypted with a password other than the IPF default
+func (r *IPFReader) FSWithPassword(password []byte) (fs.FS, error) {
+	if len(r.FileInfos) == 0 && r.Zip != nil && len(r.Zip.File) > 0 {
+		return nil, errors.New("file structure has not been read")
+	}
+
+	archive := &archiveFS{
+		reader:   r,
+		password: password,
+		files:    make(map[string]*FileInfo),
+		dirs:     map[string]map[string]bool{".": {}},
+	}
+
+	for i := range r.FileInfos {
+		fileInfo := &r.FileInfos[i]
+		name := fileInfo.DecryptedFilename
+		if name == "" && len(fileInfo.EncryptedFilename) > 0 {
+			name, _ = zipcipher.DecryptFilename(fileInfo.EncryptedFilename, password)
+		}
+		if name == "" {
+			name = fileInfo.SafeFilename
+		}
+		name = cleanRelativePath(strings.ReplaceAll(name, "\\", "/"))
+
+		if fileInfo.IsDir() {
+			archive.addDir(name)
+			continue
+		}
+		archive.addDir(path.Dir(name))
+		archive.dirs[path.Dir(name)][path.Base(name)] = true
+		archive.files[name] = fileInfo
+	}
+
+	return archive, nil
+}
+
+// archiveFS implements fs.FS over an IPFReader
+type archiveFS struct {
+	reader   *IPFReader
+	password []byte
+	files    map[string]*FileInfo
+	dirs     map[string]map[string]bool // Directory -> names of its children
+}
+
+// addDir registers dir and all of its parents
+func (a *archiveFS) addDir(dir string) {
+	if _, ok := a.dirs[dir]; ok {
+		return
+	}
+	a.dirs[dir] = make(map[string]bool)
+	parent := path.Dir(dir)
+	a.addDir(parent)
+	a.dirs[parent][path.Base(dir)] = true
+}
+
+// Open opens the named file or directory
+func (a *archiveFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if fileInfo, ok := a.files[name]; ok {
+		return &archiveFile{archive: a, fileInfo: fileInfo, stat: a.fileStat(name, fileInfo)}, nil
+	}
+	if _, ok := a.dirs[name]; ok {
+		return &archiveDir{stat: dirStat{name: path.Base(name)}, entries: a.dirEntries(name)}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// fileStat describes a file entry
+func (a *archiveFS) fileStat(name string, fileInfo *FileInfo) fileStat {
+	mode := fs.FileMode(0444)
+	if unixMode, ok := fileInfo.UnixMode(); ok {
+		mode = unixMode.Perm()
+	}
+	var size int64
+	if fileInfo.ZipInfo != nil {
+		size = int64(fileInfo.ZipInfo.UncompressedSize64)
+	}
+	return fileStat{name: path.Base(name), size: size, mode: mode, modTime: entryModTime(fileInfo)}
+}
+
+// dirEntries lists the children of dir sorted by name
+func (a *archiveFS) dirEntries(dir string) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(a.dirs[dir]))
+	for child := range a.dirs[dir] {
+		full := child
+		if dir != "." {
+			full = dir + "/" + child
+		}
+		if fileInfo, ok := a.files[full]; ok {
+			entries = append(entries, fs.FileInfoToDirEntry(a.fileStat(full, fileInfo)))
+		} else {
+			entries = append(entries, fs.FileInfoToDirEntry(dirStat{name: child}))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries
+}
+
+// fileStat implements fs.FileInfo for file entries
+type fileStat struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (s fileStat) Name() string       { return s.name }
+func (s fileStat) Size() int64        { return s.size }
+func (s fileStat) Mode() fs.FileMode  { return s.mode }
+func (s fileStat) ModTime() time.Time { return s.modTime }
+func (s fileStat) IsDir() bool        { return false }
+func (s fileStat) Sys() any           { return nil }
+
+// dirStat implements fs.FileInfo for synthesized directories
+type dirStat struct {
+	name string
+}
+
+func (s dirStat) Name() string       { return s.name }
+func (s dirStat) Size() int64        { return 0 }
+func (s dirStat) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (s dirStat) ModTime() time.Time { return time.Time{} }
+func (s dirStat) IsDir() bool        { return true }
+func (s dirStat) Sys() any           { return nil }
+
+// archiveFile is an open file entry. It implements io.Seeker and io.ReaderAt so http.FS can
+// serve ranges from it.
+type archiveFile struct {
+	archive  *archiveFS
+	fileInfo *FileInfo
+	stat     fileStat
+
+	once    sync.Once
+	data    *bytes.Reader
+	loadErr error
+	closed  bool
+}
+
+// load decrypts and decompresses the entry on first use
+func (f *archiveFile) load() error {
+	if f.closed {
+		return fs.ErrClosed
+	}
+	f.once.Do(func() {
+		data, err := f.archive.reader.ReadEntry(f.fileInfo.Index, f.archive.password)
+		if err != nil {
+			f.loadErr = &fs.PathError{Op: "read", Path: f.stat.name, Err: err}
+			return
+		}
+		f.data = bytes.NewReader(data)
+	})
+	return f.loadErr
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return f.stat, nil }
+
+func (f *archiveFile) Read(p []byte) (int, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.data.Read(p)
+}
+
+func (f *archiveFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.data.Seek(offset, whence)
+}
+
+func (f *archiveFile) ReadAt(p []byte, offset int64) (int, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.data.ReadAt(p, offset)
+}
+
+func (f *archiveFile) Close() error {
+	if f.closed {
+		return fs.ErrClosed
+	}
+	f.closed = true
+	f.data = nil
+	return nil
+}
+
+// archiveDir is an open directory implementing fs.ReadDirFile
+type archiveDir struct {
+	stat    dirStat
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) { return d.stat, nil }
+
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.stat.name, Err: errors.New("is a directory")}
+}
+
+func (d *archiveDir) Close() error { return nil }
+
+// ReadDir follows the fs.ReadDirFile contract: n <= 0 returns everything left, otherwise at most
+// n entries and io.EOF once the directory is exhausted
+func (d *archiveDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
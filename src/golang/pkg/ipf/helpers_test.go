@@ -0,0 +1,77 @@
+package ipf
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+var testPassword = zipcipher.GetIPFPassword()
+
+// openArchive returns a reader over an in-memory archive with its filenames decrypted
+func openArchive(t testing.TB, archive []byte, password []byte) *IPFReader {
+	t.Helper()
+
+	reader, err := NewIPFReaderFromReaderAt(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("NewIPFReaderFromReaderAt: %v", err)
+	}
+	if err := reader.ReadFileStructure(); err != nil {
+		t.Fatalf("ReadFileStructure: %v", err)
+	}
+	if err := reader.ReadEncryptedFilenames(); err != nil {
+		t.Fatalf("ReadEncryptedFilenames: %v", err)
+	}
+
+	results, err := NewFilenameDecryptor(password, 2).DecryptAllParallel(context.Background(), reader.FileInfos)
+	if err != nil {
+		t.Fatalf("DecryptAllParallel: %v", err)
+	}
+	reader.UpdateFileInfos(results)
+	return reader
+}
+
+// readTree returns the contents of every regular file under dir keyed by slash-separated path
+func readTree(t testing.TB, dir string) map[string]string {
+	t.Helper()
+
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", dir, err)
+	}
+	return files
+}
+
+// requireSuccess fails the test for every unsuccessful result that was not skipped on purpose
+func requireSuccess(t testing.TB, results []ExtractionResult, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("extraction failed: %v", err)
+	}
+	for _, result := range results {
+		if !result.Success && !result.Skipped {
+			t.Errorf("file %d failed: %v", result.Index, result.Error)
+		}
+	}
+}
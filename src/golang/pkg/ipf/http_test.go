@@ -0,0 +1,83 @@
+package ipf
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestServeEntry(t *testing.T) {
+	html := []byte("<!DOCTYPE html><html><body>served from an archive</body></html>")
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0x42}, 200)...)
+	reader := openArchive(t, ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "index.html", Data: html, Method: zip.Deflate},
+		ipftest.Entry{Name: "image.png", Data: png},
+		ipftest.Entry{Name: "dir/"},
+	), testPassword)
+
+	tests := []struct {
+		name       string
+		index      int
+		rangeValue string
+		wantStatus int
+		wantType   string
+		wantBody   []byte
+	}{
+		{"deflated html", 0, "", http.StatusOK, "text/html; charset=utf-8", html},
+		{"stored png", 1, "", http.StatusOK, "image/png", png},
+		{"byte range", 1, "bytes=1-3", http.StatusPartialContent, "image/png", png[1:4]},
+		{"open-ended range", 0, "bytes=-8", http.StatusPartialContent, "text/html; charset=utf-8", html[len(html)-8:]},
+		{"directory", 2, "", http.StatusNotFound, "", nil},
+		{"unknown index", 7, "", http.StatusNotFound, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				reader.ServeEntry(w, req, tt.index, testPassword)
+			}))
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.rangeValue != "" {
+				req.Header.Set("Range", tt.rangeValue)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantBody == nil {
+				return
+			}
+			if got := resp.Header.Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if got := resp.Header.Get("Content-Length"); got != strconv.Itoa(len(tt.wantBody)) {
+				t.Errorf("Content-Length = %s, want %d", got, len(tt.wantBody))
+			}
+			if resp.Header.Get("Last-Modified") == "" {
+				t.Errorf("Last-Modified is missing")
+			}
+			if !bytes.Equal(body, tt.wantBody) {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
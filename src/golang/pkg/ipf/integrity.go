@@ -0,0 +1,82 @@
+package ipf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// Integrity tells what is known about the content of an extracted entry
+type Integrity int
+
+const (
+	IntegrityUnchecked   Integrity = iota // The CRC32 was not compared, e.g. VerifyCRC is off or the entry was skipped
+	IntegrityOK                           // The content matches the recorded CRC32 and size
+	IntegrityCRCMismatch                  // The data decoded but its CRC32 or size differs from the recorded one
+	IntegrityCorrupt                      // The data could not be decrypted or decompressed at all
+)
+
+func (i Integrity) String() string {
+	switch i {
+	case IntegrityUnchecked:
+		return "unchecked"
+	case IntegrityOK:
+		return "ok"
+	case IntegrityCRCMismatch:
+		return "CRC mismatch"
+	case IntegrityCorrupt:
+		return "corrupt"
+	default:
+		return fmt.Sprintf("Integrity(%d)", int(i))
+	}
+}
+
+// integrityOf classifies the error of decoding the task's entry; err is nil when the data decoded
+func (ce *ConcurrentExtractor) integrityOf(task ExtractionTask, err error) Integrity {
+	switch {
+	case err == nil && ce.verifies(task):
+		return IntegrityOK
+	case err == nil:
+		return IntegrityUnchecked
+	case errors.Is(err, zipcipher.ErrCRCMismatch), errors.Is(err, zipcipher.ErrSizeMismatch):
+		return IntegrityCRCMismatch
+	default:
+		return IntegrityCorrupt
+	}
+}
+
+// verifies reports whether the task's decoded data is checked against its CRC32 and size
+func (ce *ConcurrentExtractor) verifies(task ExtractionTask) bool {
+	return ce.VerifyCRC || task.verifyCRC
+}
+
+// checkCRC compares decoded data against the CRC32 and size of the central directory. The local
+// header check in zipcipher is skipped for entries with data descriptors, whose local CRC32 is zero.
+func checkCRC(fileInfo *FileInfo, data []byte) error {
+	if got := crc32.ChecksumIEEE(data); got != fileInfo.ZipInfo.CRC32 {
+		return fmt.Errorf("%w: expected 0x%08x, got 0x%08x", zipcipher.ErrCRCMismatch, fileInfo.ZipInfo.CRC32, got)
+	}
+	if uint64(len(data)) != fileInfo.ZipInfo.UncompressedSize64 {
+		return fmt.Errorf("%w: expected %d, got %d", zipcipher.ErrSizeMismatch, fileInfo.ZipInfo.UncompressedSize64, len(data))
+	}
+	return nil
+}
+
+// VerifyAll decrypts and decompresses every selected entry without writing anything and checks
+// its CRC32 and size, as if VerifyCRC were set. Each result's Integrity tells a checksum mismatch
+// apart from data that could not be decoded; directory entries are skipped.
+func (ce *ConcurrentExtractor) VerifyAll(ctx context.Context, password []byte) ([]ExtractionResult, error) {
+	return ce.extractAllTo(ctx, func(*FileInfo) (io.WriteCloser, error) {
+		return discardCloser{}, nil
+	}, password, true)
+}
+
+// discardCloser is an io.WriteCloser that drops everything written to it
+type discardCloser struct{}
+
+func (discardCloser) Write(p []byte) (int, error) { return len(p), nil }
+func (discardCloser) Close() error                { return nil }
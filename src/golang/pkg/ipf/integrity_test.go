@@ -0,0 +1,117 @@
+package ipf
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestExtractIntegrity(t *testing.T) {
+	// Random data does not compress, so deflate keeps it in stored blocks where a flipped byte
+	// still decodes, only to the wrong content
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	tests := []struct {
+		name      string
+		entry     ipftest.Entry
+		corrupt   func(payload []byte)
+		verifyCRC bool
+		want      Integrity
+		wantErr   error
+	}{
+		{
+			name:  "intact unchecked",
+			entry: ipftest.Entry{Name: "a.bin", Data: random, Method: zip.Deflate, Plain: true},
+			want:  IntegrityUnchecked,
+		},
+		{
+			name:      "intact verified",
+			entry:     ipftest.Entry{Name: "a.bin", Data: random, Method: zip.Deflate, Plain: true},
+			verifyCRC: true,
+			want:      IntegrityOK,
+		},
+		{
+			name:      "flipped literal byte",
+			entry:     ipftest.Entry{Name: "a.bin", Data: random, Method: zip.Deflate, Plain: true},
+			corrupt:   func(payload []byte) { payload[len(payload)/2] ^= 0x01 },
+			verifyCRC: true,
+			want:      IntegrityCRCMismatch,
+			wantErr:   ErrCRCMismatch,
+		},
+		{
+			// BFINAL set with the reserved block type 3, which no inflater accepts
+			name:      "invalid block type",
+			entry:     ipftest.Entry{Name: "a.bin", Data: random, Method: zip.Deflate, Plain: true},
+			corrupt:   func(payload []byte) { payload[0] = 0x07 },
+			verifyCRC: true,
+			want:      IntegrityCorrupt,
+			wantErr:   ErrCorruptData,
+		},
+		{
+			name:    "invalid block type unchecked",
+			entry:   ipftest.Entry{Name: "a.bin", Data: random, Method: zip.Deflate, Plain: true},
+			corrupt: func(payload []byte) { payload[0] = 0x07 },
+			want:    IntegrityCorrupt,
+			wantErr: ErrCorruptData,
+		},
+		{
+			// Stored entries are always checked, VerifyCRC or not
+			name:    "flipped stored byte",
+			entry:   ipftest.Entry{Name: "a.bin", Data: random},
+			corrupt: func(payload []byte) { payload[12+100] ^= 0x01 },
+			want:    IntegrityCRCMismatch,
+			wantErr: ErrCRCMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := ipftest.Build(t, testPassword, tt.entry)
+			if tt.corrupt != nil {
+				tt.corrupt(archive[ipftest.DataOffset(t, archive, 0):])
+			}
+			reader := openArchive(t, archive, testPassword)
+
+			extractor := NewConcurrentExtractor(reader, nil, 1)
+			extractor.VerifyCRC = tt.verifyCRC
+			results, err := extractor.ExtractAllParallel(context.Background(), t.TempDir(), testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkIntegrity(t, results[0], tt.want, tt.wantErr)
+
+			// VerifyAll decodes the same way without writing, and always checks the CRC32
+			want := tt.want
+			if want == IntegrityUnchecked {
+				want = IntegrityOK
+			}
+			results, err = NewConcurrentExtractor(reader, nil, 1).VerifyAll(context.Background(), testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkIntegrity(t, results[0], want, tt.wantErr)
+		})
+	}
+}
+
+// checkIntegrity fails the test unless result has the wanted integrity and error
+func checkIntegrity(t *testing.T, result ExtractionResult, want Integrity, wantErr error) {
+	t.Helper()
+
+	if result.Integrity != want {
+		t.Errorf("Integrity = %v, want %v (error %v)", result.Integrity, want, result.Error)
+	}
+	if wantErr == nil {
+		if !result.Success {
+			t.Errorf("extraction failed: %v", result.Error)
+		}
+		return
+	}
+	if result.Success || !errors.Is(result.Error, wantErr) {
+		t.Errorf("result = %v, %v, want %v", result.Success, result.Error, wantErr)
+	}
+}
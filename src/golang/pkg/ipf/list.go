@@ -0,0 +1,60 @@
+package ipf
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// FileEntry describes one archive entry for listings
+type FileEntry struct {
+	Name           string // Decrypted name, or the fallback name when it could not be decrypted
+	Size           int64
+	CompressedSize int64 // Stored size, including the 12-byte encryption header
+	CRC32          uint32
+	Modified       time.Time
+	Encrypted      bool
+}
+
+// ListFiles returns the entries of the archive in central directory order with decrypted names,
+// without reading any entry data. The file structure and encrypted filenames are read first when
+// that has not happened yet, and the decrypted names are stored on the reader's FileInfos as
+// UpdateFileInfos would.
+func (r *IPFReader) ListFiles(password []byte) ([]FileEntry, error) {
+	if len(r.FileInfos) == 0 {
+		if err := r.ReadFileStructure(); err != nil {
+			return nil, fmt.Errorf("failed to read file structure: %w", err)
+		}
+	}
+	if len(r.FileInfos) > 0 && !r.FileInfos[0].localHeaderRead {
+		if err := r.ReadEncryptedFilenames(); err != nil {
+			return nil, fmt.Errorf("failed to read encrypted filenames: %w", err)
+		}
+	}
+
+	decryptor := NewFilenameDecryptor(password, 0)
+	results, err := decryptor.DecryptAllParallel(context.Background(), r.FileInfos)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt filenames: %w", err)
+	}
+	r.UpdateFileInfos(results)
+
+	entries := make([]FileEntry, len(r.FileInfos))
+	for i := range r.FileInfos {
+		fileInfo := &r.FileInfos[i]
+		name := fileInfo.DecryptedFilename
+		if name == "" {
+			name = fileInfo.SafeFilename
+		}
+
+		entries[i] = FileEntry{
+			Name:           name,
+			Size:           int64(fileInfo.ZipInfo.UncompressedSize64),
+			CompressedSize: int64(fileInfo.ZipInfo.CompressedSize64),
+			CRC32:          fileInfo.ZipInfo.CRC32,
+			Modified:       entryModTime(fileInfo),
+			Encrypted:      fileInfo.GenPurpose&0x1 != 0,
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,61 @@
+package ipf
+
+import "sync"
+
+// memoryBudget is a weighted semaphore bounding the bytes buffered by in-flight extractions.
+// Waiters are served in arrival order so a large entry is not starved by a stream of small ones.
+// Each extraction run gets its own budget, so changing MaxMemory takes effect on the next run.
+type memoryBudget struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	limit   int64
+	used    int64
+	next    uint64 // Ticket handed to the next caller of acquire
+	serving uint64 // Ticket allowed to take bytes once they are free
+}
+
+// newMemoryBudget returns a budget of limit bytes, or nil (no limit) when limit <= 0
+func newMemoryBudget(limit int64) *memoryBudget {
+	if limit <= 0 {
+		return nil
+	}
+	b := &memoryBudget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire blocks until weight bytes fit in the budget and returns the weight actually taken,
+// to be passed to release. Weights above the whole budget are reduced to it, so such an entry
+// waits for every other buffer to drain and then runs alone. A nil budget never blocks.
+func (b *memoryBudget) acquire(weight int64) int64 {
+	if b == nil {
+		return 0
+	}
+	if weight > b.limit {
+		weight = b.limit
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ticket := b.next
+	b.next++
+	for ticket != b.serving || b.used+weight > b.limit {
+		b.cond.Wait()
+	}
+	b.serving++
+	b.used += weight
+	b.cond.Broadcast()
+	return weight
+}
+
+// release returns bytes taken by acquire
+func (b *memoryBudget) release(weight int64) {
+	if b == nil || weight == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.used -= weight
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
@@ -0,0 +1,115 @@
+package ipf
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestMemoryBudgetBound(t *testing.T) {
+	const limit = 1000
+	budget := newMemoryBudget(limit)
+	var used, peak atomic.Int64
+
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(weight int64) {
+			defer wg.Done()
+			taken := budget.acquire(weight)
+			defer budget.release(taken)
+
+			n := used.Add(taken)
+			for {
+				max := peak.Load()
+				if n <= max || peak.CompareAndSwap(max, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			used.Add(-taken)
+		}(int64(i%7) * 300) // Includes weights of 0 and above the whole budget
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > limit {
+		t.Errorf("%d bytes held at once, limit %d", got, limit)
+	}
+	if budget.used != 0 {
+		t.Errorf("%d bytes still held after every release", budget.used)
+	}
+	if newMemoryBudget(0) != nil || newMemoryBudget(0).acquire(1<<40) != 0 {
+		t.Errorf("a zero limit should disable the budget")
+	}
+}
+
+// concurrentReader counts the ReadAt calls in progress at once, holding each briefly so that
+// workers running in parallel overlap
+type concurrentReader struct {
+	io.ReaderAt
+	active, peak atomic.Int32
+}
+
+func (r *concurrentReader) ReadAt(p []byte, off int64) (int, error) {
+	n := r.active.Add(1)
+	defer r.active.Add(-1)
+	for {
+		max := r.peak.Load()
+		if n <= max || r.peak.CompareAndSwap(max, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	return r.ReaderAt.ReadAt(p, off)
+}
+
+func TestExtractMaxMemory(t *testing.T) {
+	// Deflated entries take the buffered path, where each holds about 4KB of the budget
+	var entries []ipftest.Entry
+	for i := 0; i < 16; i++ {
+		data := bytes.Repeat([]byte(fmt.Sprintf("entry %02d ", i)), 455)
+		entries = append(entries, ipftest.Entry{Name: fmt.Sprintf("e%02d.txt", i), Data: data, Method: zip.Deflate})
+	}
+	archive := ipftest.Build(t, testPassword, entries...)
+
+	tests := []struct {
+		name     string
+		limit    int64
+		wantPeak int32 // Most entries read at once; 0 expects them to overlap
+	}{
+		{"no limit", 0, 0},
+		{"one entry at a time", 5000, 1},
+		{"limit below every entry", 100, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := openArchive(t, archive, testPassword)
+			source := &concurrentReader{ReaderAt: reader.source}
+			reader.source = source
+
+			extractor := NewConcurrentExtractorWithLimit(reader, nil, 8, tt.limit)
+			outputDir := t.TempDir()
+			results, err := extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			requireSuccess(t, results, err)
+			if got := len(readTree(t, outputDir)); got != len(entries) {
+				t.Errorf("extracted %d files, want %d", got, len(entries))
+			}
+
+			peak := source.peak.Load()
+			if tt.wantPeak == 0 && peak < 2 {
+				t.Errorf("entries never overlapped without a limit")
+			}
+			if tt.wantPeak != 0 && peak > tt.wantPeak {
+				t.Errorf("%d entries read at once, want at most %d", peak, tt.wantPeak)
+			}
+		})
+	}
+}
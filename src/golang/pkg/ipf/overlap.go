@@ -0,0 +1,111 @@
+package ipf
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// Overlap describes two entries whose stored byte ranges intersect
+type Overlap struct {
+	First  int   // Index of the entry starting earlier
+	Second int   // Index of the entry starting inside the first one's range
+	Start  int64 // Start of the shared range
+	End    int64 // End (exclusive) of the shared range
+}
+
+// String formats the overlap for reports
+func (o Overlap) String() string {
+	return fmt.Sprintf("files %d and %d overlap in bytes [%d, %d)", o.First, o.Second, o.Start, o.End)
+}
+
+// FindOverlaps reports entries whose [local header, end of data) ranges intersect, as produced by
+// corrupt or crafted archives pointing several central directory entries at the same data.
+// Header sizes come from ReadEncryptedFilenames; without it only the 30-byte fixed header is counted.
+func (r *IPFReader) FindOverlaps() []Overlap {
+	type span struct {
+		index      int
+		start, end int64
+	}
+
+	spans := make([]span, 0, len(r.FileInfos))
+	for _, fileInfo := range r.FileInfos {
+		headerSize := int64(fileInfo.HeaderSize)
+		if headerSize == 0 {
+			headerSize = 30
+		}
+		var dataSize int64
+		if fileInfo.ZipInfo != nil {
+			dataSize = int64(fileInfo.ZipInfo.CompressedSize64)
+		}
+		spans = append(spans, span{
+			index: fileInfo.Index,
+			start: fileInfo.LocalHeaderOffset,
+			end:   fileInfo.LocalHeaderOffset + headerSize + dataSize,
+		})
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].start < spans[j].start
+	})
+
+	// Sweep in offset order, tracking the span reaching furthest so far
+	var overlaps []Overlap
+	furthest := -1
+	for i, current := range spans {
+		if furthest >= 0 && current.start < spans[furthest].end {
+			end := current.end
+			if spans[furthest].end < end {
+				end = spans[furthest].end
+			}
+			overlaps = append(overlaps, Overlap{
+				First:  spans[furthest].index,
+				Second: current.index,
+				Start:  current.start,
+				End:    end,
+			})
+		}
+		if furthest < 0 || current.end > spans[furthest].end {
+			furthest = i
+		}
+	}
+
+	return overlaps
+}
+
+// nextHeaderOffset returns the offset of the first local header or central directory after
+// offset, or the end of the archive. Offsets are searched in a sorted copy, since entries need
+// not be stored in index order.
+func (r *IPFReader) nextHeaderOffset(offset int64) int64 {
+	r.offsetsMu.Lock()
+	defer r.offsetsMu.Unlock()
+
+	if r.headerOffsets == nil {
+		offsets := make([]int64, 0, len(r.FileInfos)+1)
+		for i := range r.FileInfos {
+			offsets = append(offsets, r.FileInfos[i].LocalHeaderOffset)
+		}
+		if cdOffset, _, _, err := r.CentralDirectory(); err == nil {
+			offsets = append(offsets, cdOffset)
+		}
+		sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+		r.headerOffsets = offsets
+	}
+
+	i := sort.Search(len(r.headerOffsets), func(i int) bool { return r.headerOffsets[i] > offset })
+	if i == len(r.headerOffsets) {
+		return r.size
+	}
+	return r.headerOffsets[i]
+}
+
+// entryReader returns a reader positioned at the entry's local header. When the sizes must be
+// recovered from the data descriptor, the search stops at the next header.
+func (r *IPFReader) entryReader(fileInfo *FileInfo, password []byte) *zipcipher.EncryptedFileReader {
+	section := io.NewSectionReader(r.source, fileInfo.LocalHeaderOffset, r.size-fileInfo.LocalHeaderOffset)
+	encryptedReader := zipcipher.NewEncryptedFileReader(section, password)
+	encryptedReader.SetScanLimit(r.nextHeaderOffset(fileInfo.LocalHeaderOffset) - fileInfo.LocalHeaderOffset)
+	return encryptedReader
+}
@@ -0,0 +1,66 @@
+package ipf
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestFindOverlaps(t *testing.T) {
+	tests := []struct {
+		name  string
+		craft func(records [][]byte) // Corrupts the central directory records of a clean archive
+		want  []Overlap              // Only First and Second are compared
+	}{
+		{
+			name:  "clean archive",
+			craft: func([][]byte) {},
+		},
+		{
+			name: "shared local header",
+			craft: func(records [][]byte) {
+				copy(records[2][42:46], records[0][42:46])
+			},
+			want: []Overlap{{First: 0, Second: 2}},
+		},
+		{
+			name: "data running into the next entry",
+			craft: func(records [][]byte) {
+				size := binary.LittleEndian.Uint32(records[0][20:])
+				binary.LittleEndian.PutUint32(records[0][20:], size+10)
+			},
+			want: []Overlap{{First: 0, Second: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := ipftest.Build(t, testPassword,
+				ipftest.Entry{Name: "a.txt", Data: []byte("first entry")},
+				ipftest.Entry{Name: "b.txt", Data: []byte("second entry")},
+				ipftest.Entry{Name: "c.txt", Data: []byte("third entry")},
+			)
+			tt.craft(ipftest.CentralRecords(t, archive))
+			reader := openArchive(t, archive, testPassword)
+
+			overlaps := reader.FindOverlaps()
+			if len(overlaps) != len(tt.want) {
+				t.Fatalf("FindOverlaps = %v, want %d overlaps", overlaps, len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := overlaps[i]
+				if got.First != want.First || got.Second != want.Second {
+					t.Errorf("overlap %d = %v, want files %d and %d", i, got, want.First, want.Second)
+				}
+				if got.Start >= got.End {
+					t.Errorf("overlap %d has an empty range [%d, %d)", i, got.Start, got.End)
+				}
+			}
+
+			err := reader.ValidateIPF()
+			if (err != nil) != (len(tt.want) > 0) {
+				t.Errorf("ValidateIPF = %v, want an error: %v", err, len(tt.want) > 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package ipf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestExtractTwice(t *testing.T) {
+	const count, size = 8, 100
+	archive := ipftest.Build(t, testPassword, sizedEntries(count, size)...)
+	const edited = "files/000.bin"
+
+	tests := []struct {
+		name       string
+		policy     OverwritePolicy
+		wantSkips  int  // Entries skipped on the second pass
+		keepEdited bool // Whether the edited file survives the second pass
+	}{
+		{"always", OverwriteAlways, 0, false},
+		{"skip", OverwriteSkip, count, true},
+		{"if newer", OverwriteIfNewer, count, true},
+		{"if changed", OverwriteIfChanged, count - 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := openArchive(t, archive, testPassword)
+			outputDir := t.TempDir()
+
+			extractor := NewConcurrentExtractor(reader, nil, 2)
+			extractor.Overwrite = tt.policy
+			results, err := extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			requireSuccess(t, results, err)
+			for _, result := range results {
+				if result.Skipped {
+					t.Errorf("file %d skipped on the first pass", result.Index)
+				}
+			}
+
+			// Same size and timestamp, different content: only a CRC32 check notices
+			path := filepath.Join(outputDir, filepath.FromSlash(edited))
+			stat, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(strings.Repeat("e", size)), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, stat.ModTime(), stat.ModTime()); err != nil {
+				t.Fatal(err)
+			}
+
+			results, err = extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			skips := 0
+			for _, result := range results {
+				if result.Skipped {
+					skips++
+					// Files found intact count as extracted, unlike files kept without a check
+					if result.Success != (tt.policy == OverwriteIfChanged) {
+						t.Errorf("skipped file %d Success = %t", result.Index, result.Success)
+					}
+				} else if !result.Success {
+					t.Errorf("file %d failed: %v", result.Index, result.Error)
+				}
+			}
+			if skips != tt.wantSkips {
+				t.Errorf("second pass skipped %d files, want %d", skips, tt.wantSkips)
+			}
+
+			files := readTree(t, outputDir)
+			if len(files) != count {
+				t.Errorf("output has %d files, want %d", len(files), count)
+			}
+			if kept := files[edited] == strings.Repeat("e", size); kept != tt.keepEdited {
+				t.Errorf("edited file kept = %t, want %t", kept, tt.keepEdited)
+			}
+		})
+	}
+}
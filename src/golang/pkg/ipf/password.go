@@ -0,0 +1,158 @@
+package ipf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// passwordSampleCount is the number of encrypted entries each DetectPassword candidate is checked against
+const passwordSampleCount = 8
+
+// recoverSampleCount is the number of encrypted filenames each RecoverPassword candidate decrypts
+const recoverSampleCount = 64
+
+// minRecoverScore is the share of plausible names a RecoverPassword candidate must exceed. Wrong
+// keys still turn around a fifth of short names into printable CP1252, well above the rate at
+// which DecryptResultProcessor reports a wrong password, while the right key decodes nearly all.
+const minRecoverScore = 0.5
+
+// ErrNoPasswordMatch is returned by DetectPassword and RecoverPassword when no candidate validates
+var ErrNoPasswordMatch = errors.New("no candidate password matches the archive")
+
+// DetectPassword returns the first candidate whose decrypted encryption header check byte matches
+// on a spread of encrypted entries. Each wrong password passes a single check with probability
+// 1/128, so several samples are required before a candidate is accepted. Only ReadFileStructure
+// needs to have been called. Archives without encrypted entries accept the first candidate.
+func DetectPassword(r *IPFReader, candidates [][]byte) ([]byte, error) {
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no candidate passwords given")
+	}
+
+	samples, err := passwordSamples(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		matched := true
+		for _, sample := range samples {
+			if !sample.matches(candidate) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return candidate, nil
+		}
+	}
+
+	return nil, ErrNoPasswordMatch
+}
+
+// RecoverPassword scores each candidate by the fraction of a spread of encrypted filenames it
+// decrypts to a plausible name and returns the best one with its score; ties keep the earlier
+// candidate. Unlike DetectPassword it does not depend on the packer's check byte, so it also
+// works on archives with nonstandard encryption headers. A best score of half the names or less
+// returns ErrNoPasswordMatch, since wrong keys still decrypt some names to something plausible.
+// ReadEncryptedFilenames must have been called on the reader the file infos come from.
+func RecoverPassword(fileInfos []FileInfo, candidates [][]byte) ([]byte, float64, error) {
+	if len(candidates) == 0 {
+		return nil, 0, fmt.Errorf("no candidate passwords given")
+	}
+
+	step := len(fileInfos) / recoverSampleCount
+	if step == 0 {
+		step = 1
+	}
+	var names [][]byte
+	for index := 0; index < len(fileInfos) && len(names) < recoverSampleCount; index += step {
+		if name := fileInfos[index].EncryptedFilename; len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, 0, fmt.Errorf("no encrypted filenames to test")
+	}
+
+	var best []byte
+	bestScore := 0.0
+	for _, candidate := range candidates {
+		valid := 0
+		for _, name := range names {
+			if _, ok := zipcipher.DecryptFilename(name, candidate); ok {
+				valid++
+			}
+		}
+		if score := float64(valid) / float64(len(names)); score > bestScore {
+			best, bestScore = candidate, score
+			if valid == len(names) {
+				break
+			}
+		}
+	}
+
+	if best == nil || bestScore <= minRecoverScore {
+		return nil, bestScore, ErrNoPasswordMatch
+	}
+	return best, bestScore, nil
+}
+
+// passwordSample is the encryption header of one entry with the values its check byte may equal
+type passwordSample struct {
+	header    []byte
+	timeCheck byte
+	crcCheck  byte
+}
+
+// matches decrypts the sample header with password and compares the check byte. Depending on the
+// packer the check byte is the high byte of either the modification time or the CRC32.
+func (s passwordSample) matches(password []byte) bool {
+	cipher := &zipcipher.ZipCipher{}
+	cipher.InitKeys(password)
+
+	decrypted := cipher.DecryptData(s.header)
+
+	check := decrypted[11]
+	return check == s.timeCheck || check == s.crcCheck
+}
+
+// passwordSamples reads the encryption headers of up to passwordSampleCount encrypted entries,
+// spread evenly across the archive
+func passwordSamples(r *IPFReader) ([]passwordSample, error) {
+	count := len(r.FileInfos)
+	step := count / passwordSampleCount
+	if step == 0 {
+		step = 1
+	}
+
+	var samples []passwordSample
+	for index := 0; index < count && len(samples) < passwordSampleCount; index += step {
+		raw, err := r.RawLocalHeader(index)
+		if err != nil {
+			return nil, err
+		}
+
+		flags := binary.LittleEndian.Uint16(raw[6:8])
+		if flags&0x1 == 0 {
+			continue
+		}
+
+		header := make([]byte, 12)
+		offset := r.FileInfos[index].LocalHeaderOffset + int64(len(raw))
+		if _, err := r.source.ReadAt(header, offset); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read encryption header for file %d: %w", index, err)
+		}
+
+		samples = append(samples, passwordSample{
+			header:    header,
+			timeCheck: raw[11],                                            // High byte of the modification time
+			crcCheck:  byte(binary.LittleEndian.Uint32(raw[14:18]) >> 24), // High byte of the CRC32
+		})
+	}
+
+	return samples, nil
+}
@@ -0,0 +1,122 @@
+package ipf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestDetectPassword(t *testing.T) {
+	regional := []byte("regional key")
+	wrong := [][]byte{[]byte("wrong"), []byte("also wrong"), {0x00, 0xff, 0x10}}
+	entries := sizedEntries(12, 100)
+
+	tests := []struct {
+		name       string
+		password   []byte
+		candidates [][]byte
+		wantErr    error
+	}{
+		{"regional key among wrong ones", regional, append(append([][]byte{}, wrong...), regional, testPassword), nil},
+		{"default key last", testPassword, append(append([][]byte{}, wrong...), testPassword), nil},
+		{"no matching key", regional, append(append([][]byte{}, wrong...), testPassword), ErrNoPasswordMatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := ipftest.Build(t, tt.password, entries...)
+			reader, err := NewIPFReaderFromReaderAt(bytes.NewReader(archive), int64(len(archive)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := reader.ReadFileStructure(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := DetectPassword(reader, tt.candidates)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("DetectPassword error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !bytes.Equal(got, tt.password) {
+				t.Errorf("DetectPassword = %q, %v, want %q", got, err, tt.password)
+			}
+		})
+	}
+
+	t.Run("no candidates", func(t *testing.T) {
+		reader := openArchive(t, ipftest.Build(t, testPassword, entries...), testPassword)
+		if _, err := DetectPassword(reader, nil); err == nil {
+			t.Errorf("DetectPassword accepted an empty candidate list")
+		}
+	})
+}
+
+func TestRecoverPassword(t *testing.T) {
+	regional := []byte("regional key")
+	wrong := [][]byte{[]byte("wrong"), []byte("also wrong"), {0x00, 0xff, 0x10}}
+	var entries []ipftest.Entry
+	for i := 0; i < 100; i++ {
+		entries = append(entries, ipftest.Entry{Name: fmt.Sprintf("ui/skin/button_%03d.tga", i), Data: []byte("tga")})
+	}
+
+	tests := []struct {
+		name       string
+		password   []byte
+		candidates [][]byte
+		badHeaders bool // Damage every check byte, which DetectPassword relies on
+		wantErr    error
+	}{
+		{name: "regional key among wrong ones", password: regional, candidates: append(append([][]byte{}, wrong...), regional, testPassword)},
+		{name: "default key first", password: testPassword, candidates: append([][]byte{testPassword}, wrong...)},
+		{name: "nonstandard encryption headers", password: regional, candidates: append(append([][]byte{}, wrong...), regional), badHeaders: true},
+		{name: "no matching key", password: regional, candidates: append(append([][]byte{}, wrong...), testPassword), wantErr: ErrNoPasswordMatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := ipftest.Build(t, tt.password, entries...)
+			if tt.badHeaders {
+				for i := range entries {
+					archive[ipftest.DataOffset(t, archive, i)+11] ^= 0x5a
+				}
+			}
+			reader, err := NewIPFReaderFromReaderAt(bytes.NewReader(archive), int64(len(archive)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := reader.ReadFileStructure(); err != nil {
+				t.Fatal(err)
+			}
+			if err := reader.ReadEncryptedFilenames(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, score, err := RecoverPassword(reader.FileInfos, tt.candidates)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || score > minRecoverScore {
+					t.Errorf("RecoverPassword = %v with score %.2f, want %v", err, score, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !bytes.Equal(got, tt.password) || score != 1 {
+				t.Errorf("RecoverPassword = %q, %.2f, %v, want %q with score 1", got, score, err, tt.password)
+			}
+			if tt.badHeaders {
+				if _, err := DetectPassword(reader, tt.candidates); !errors.Is(err, ErrNoPasswordMatch) {
+					t.Errorf("DetectPassword error = %v, want ErrNoPasswordMatch for damaged headers", err)
+				}
+			}
+		})
+	}
+
+	t.Run("no candidates", func(t *testing.T) {
+		reader := openArchive(t, ipftest.Build(t, testPassword, entries...), testPassword)
+		if _, _, err := RecoverPassword(reader.FileInfos, nil); err == nil {
+			t.Errorf("RecoverPassword accepted an empty candidate list")
+		}
+	})
+}
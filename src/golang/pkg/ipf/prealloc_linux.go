@@ -0,0 +1,30 @@
+//go:build linux
+
+package ipf
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocate reserves size bytes for file so large outputs are written contiguously.
+// Filesystems without fallocate support are silently skipped.
+func preallocate(file *os.File, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	for {
+		err := unix.Fallocate(int(file.Fd()), 0, 0, size)
+		if errors.Is(err, unix.EINTR) {
+			// Interrupted by a signal before anything was reserved; try again
+			continue
+		}
+		if errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOSYS) {
+			return nil
+		}
+		return err
+	}
+}
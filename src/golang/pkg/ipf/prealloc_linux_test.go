@@ -0,0 +1,77 @@
+//go:build linux
+
+package ipf
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestPreallocate(t *testing.T) {
+	large := bytes.Repeat([]byte("preallocated output "), 1<<16)
+	entries := []ipftest.Entry{
+		{Name: "large.bin", Data: large, Method: zip.Deflate},
+		{Name: "stored.bin", Data: large[:1<<12]},
+		{Name: "empty.txt"},
+	}
+	reader := openArchive(t, ipftest.Build(t, testPassword, entries...), testPassword)
+
+	tests := []struct {
+		name        string
+		preallocate bool
+	}{
+		{"preallocated", true},
+		{"not preallocated", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractor := NewConcurrentExtractor(reader, nil, 2)
+			extractor.Preallocate = tt.preallocate
+			outputDir := t.TempDir()
+			results, err := extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			requireSuccess(t, results, err)
+
+			for _, entry := range entries {
+				path := filepath.Join(outputDir, entry.Name)
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Size() != int64(len(entry.Data)) {
+					t.Errorf("%s is %d bytes, want %d", entry.Name, info.Size(), len(entry.Data))
+				}
+				if data, _ := os.ReadFile(path); !bytes.Equal(data, entry.Data) {
+					t.Errorf("%s content differs", entry.Name)
+				}
+			}
+		})
+	}
+
+	t.Run("reserves the size", func(t *testing.T) {
+		file, err := os.Create(filepath.Join(t.TempDir(), "reserved.bin"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		if err := preallocate(file, 1<<20); err != nil {
+			t.Fatalf("preallocate: %v", err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() == 0 {
+			t.Skip("filesystem does not support fallocate")
+		}
+		if info.Size() != 1<<20 {
+			t.Errorf("preallocated file is %d bytes, want %d", info.Size(), 1<<20)
+		}
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package ipf
+
+import "os"
+
+// preallocate is a no-op on platforms without fallocate
+func preallocate(file *os.File, size int64) error {
+	return nil
+}
@@ -0,0 +1,194 @@
+package ipf
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// ArchiveProfile summarizes which ZIP features an archive uses
+type ArchiveProfile struct {
+	Entries          int
+	Encryption       string         // "none", "zipcrypto", "aes" or "mixed"
+	EncryptedNames   bool           // Filenames are scrambled with the IPF password
+	Methods          map[uint16]int // Entry count per compression method
+	Zip64            bool
+	DataDescriptors  bool
+	SFXStub          bool
+	StubSize         int64  // Bytes before the first local header
+	FilenameEncoding string // "ascii", "utf-8", "legacy" (e.g. CP932) or "unknown"
+}
+
+// String formats the profile as an indented multi-line report
+func (p ArchiveProfile) String() string {
+	methods := make([]int, 0, len(p.Methods))
+	for method := range p.Methods {
+		methods = append(methods, int(method))
+	}
+	sort.Ints(methods)
+	names := make([]string, 0, len(methods))
+	for _, method := range methods {
+		names = append(names, fmt.Sprintf("%s (%d)", MethodName(uint16(method)), p.Methods[uint16(method)]))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "   Entries:           %d\n", p.Entries)
+	fmt.Fprintf(&b, "   Encryption:        %s\n", p.Encryption)
+	fmt.Fprintf(&b, "   Encrypted names:   %t\n", p.EncryptedNames)
+	fmt.Fprintf(&b, "   Methods:           %s\n", strings.Join(names, ", "))
+	fmt.Fprintf(&b, "   ZIP64:             %t\n", p.Zip64)
+	fmt.Fprintf(&b, "   Data descriptors:  %t\n", p.DataDescriptors)
+	if p.SFXStub {
+		fmt.Fprintf(&b, "   SFX stub:          %d bytes\n", p.StubSize)
+	} else {
+		fmt.Fprintf(&b, "   SFX stub:          none\n")
+	}
+	fmt.Fprintf(&b, "   Filename encoding: %s\n", p.FilenameEncoding)
+	return b.String()
+}
+
+// Probe inspects the headers of the archive at path and reports its profile without extracting
+// anything. The password is used to tell scrambled filenames from plain ones.
+func Probe(path string, password []byte) (ArchiveProfile, error) {
+	profile := ArchiveProfile{
+		Encryption:       "none",
+		Methods:          make(map[uint16]int),
+		FilenameEncoding: "unknown",
+	}
+
+	reader, err := NewIPFReader(path)
+	if err != nil {
+		return profile, err
+	}
+	defer reader.Close()
+
+	if err := reader.ReadFileStructure(); err != nil {
+		return profile, fmt.Errorf("failed to read file structure: %w", err)
+	}
+	if err := reader.ReadEncryptedFilenames(); err != nil {
+		return profile, fmt.Errorf("failed to read encrypted filenames: %w", err)
+	}
+
+	profile.Entries = len(reader.FileInfos)
+	if profile.Entries == 0 {
+		return profile, nil
+	}
+
+	var zipCrypto, aes, plain int
+	firstOffset := reader.FileInfos[0].LocalHeaderOffset
+	var rawNames, decryptedNames [][]byte
+
+	for i := range reader.FileInfos {
+		fileInfo := &reader.FileInfos[i]
+		zipFile := fileInfo.ZipInfo
+
+		var decryptedName []byte
+		if len(fileInfo.EncryptedFilename) > 0 {
+			decryptedName = zipcipher.DecryptFilenameBytes(fileInfo.EncryptedFilename, password)
+			rawNames = append(rawNames, fileInfo.EncryptedFilename)
+			decryptedNames = append(decryptedNames, decryptedName)
+		}
+
+		method := fileInfo.Method()
+		profile.Methods[method]++
+		switch {
+		case zipFile.UncompressedSize64 == 0 && isDirEntry(zipFile.ExternalAttrs, fileInfo.EncryptedFilename, decryptedName):
+			// Directory entries carry no data and are often left unencrypted in encrypted archives
+		case method == 99:
+			aes++
+		case zipFile.Flags&0x1 != 0:
+			zipCrypto++
+		default:
+			plain++
+		}
+
+		if zipFile.Flags&0x8 != 0 {
+			profile.DataDescriptors = true
+		}
+		if zipcipher.HasZip64Extra(zipFile.Extra) || zipcipher.HasZip64Extra(fileInfo.ExtraField) {
+			profile.Zip64 = true
+		}
+		if fileInfo.LocalHeaderOffset < firstOffset {
+			firstOffset = fileInfo.LocalHeaderOffset
+		}
+
+	}
+
+	switch {
+	case aes > 0 && zipCrypto == 0 && plain == 0:
+		profile.Encryption = "aes"
+	case zipCrypto > 0 && aes == 0 && plain == 0:
+		profile.Encryption = "zipcrypto"
+	case aes > 0 || zipCrypto > 0:
+		profile.Encryption = "mixed"
+	}
+
+	// Anything before the first local header is a prepended stub, typically a self-extractor
+	if firstOffset > 0 {
+		profile.SFXStub = true
+		profile.StubSize = firstOffset
+	}
+
+	// Names are scrambled when decrypting them with the password reads better than the stored bytes
+	if len(rawNames) > 0 {
+		names := rawNames
+		if printableBytes(decryptedNames) > printableBytes(rawNames) {
+			profile.EncryptedNames = true
+			names = decryptedNames
+		}
+		profile.FilenameEncoding = classifyNameEncoding(names)
+	}
+
+	return profile, nil
+}
+
+// isDirEntry reports whether an entry is a directory, from its MS-DOS directory attribute or a
+// trailing slash on either its stored or its decrypted name
+func isDirEntry(externalAttrs uint32, names ...[]byte) bool {
+	if externalAttrs&0x10 != 0 {
+		return true
+	}
+	for _, name := range names {
+		if len(name) > 0 && (name[len(name)-1] == '/' || name[len(name)-1] == '\\') {
+			return true
+		}
+	}
+	return false
+}
+
+// printableBytes counts printable ASCII bytes across names. Even CP932 names keep their
+// separators and extensions in ASCII, while scrambled bytes are spread over the whole range.
+func printableBytes(names [][]byte) int {
+	count := 0
+	for _, name := range names {
+		for _, b := range name {
+			if b >= 0x20 && b < 0x7F {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// classifyNameEncoding reports the narrowest encoding that fits every name
+func classifyNameEncoding(names [][]byte) string {
+	ascii := true
+	for _, name := range names {
+		if !utf8.Valid(name) {
+			return "legacy"
+		}
+		for _, r := range string(name) {
+			if r > unicode.MaxASCII {
+				ascii = false
+			}
+		}
+	}
+	if ascii {
+		return "ascii"
+	}
+	return "utf-8"
+}
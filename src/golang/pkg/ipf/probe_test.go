@@ -0,0 +1,88 @@
+package ipf
+
+import (
+	"archive/zip"
+	"reflect"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestProbe(t *testing.T) {
+	zip64Extra := append([]byte{0x01, 0x00, 0x10, 0x00}, make([]byte, 16)...)
+	data := []byte("probe fixture data")
+
+	tests := []struct {
+		name    string
+		entries []ipftest.Entry
+		stub    int // Bytes prepended to the archive
+		want    ArchiveProfile
+	}{
+		{
+			name: "standard IPF",
+			entries: []ipftest.Entry{
+				{Name: "a.xml", Data: data, Method: zip.Deflate},
+				{Name: "b.bin", Data: data},
+			},
+			want: ArchiveProfile{Entries: 2, Encryption: "zipcrypto", EncryptedNames: true,
+				Methods: map[uint16]int{zip.Store: 1, zip.Deflate: 1}, FilenameEncoding: "ascii"},
+		},
+		{
+			name: "plain ZIP",
+			entries: []ipftest.Entry{
+				{Name: "a.xml", Data: data, Method: zip.Deflate, Plain: true},
+				{Name: "ü.txt", Data: data, Method: zip.Deflate, Plain: true},
+			},
+			want: ArchiveProfile{Entries: 2, Encryption: "none",
+				Methods: map[uint16]int{zip.Deflate: 2}, FilenameEncoding: "utf-8"},
+		},
+		{
+			name: "mixed encryption",
+			entries: []ipftest.Entry{
+				{Name: "a.xml", Data: data},
+				{Name: "b.xml", Data: data},
+				{Name: "c.xml", Data: data, Plain: true},
+			},
+			// Decrypting the plain name scrambles it, so the names no longer all fit one encoding
+			want: ArchiveProfile{Entries: 3, Encryption: "mixed", EncryptedNames: true,
+				Methods: map[uint16]int{zip.Store: 3}, FilenameEncoding: "legacy"},
+		},
+		{
+			name:    "AES",
+			entries: []ipftest.Entry{{Name: "a.xml", Data: data, Method: 99}},
+			want: ArchiveProfile{Entries: 1, Encryption: "aes", EncryptedNames: true,
+				Methods: map[uint16]int{99: 1}, FilenameEncoding: "ascii"},
+		},
+		{
+			name: "data descriptors and ZIP64",
+			entries: []ipftest.Entry{
+				{Name: "a.xml", Data: data, Method: zip.Deflate, Descriptor: true},
+				{Name: "b.bin", Data: data, Extra: zip64Extra},
+			},
+			want: ArchiveProfile{Entries: 2, Encryption: "zipcrypto", EncryptedNames: true, Zip64: true, DataDescriptors: true,
+				Methods: map[uint16]int{zip.Store: 1, zip.Deflate: 1}, FilenameEncoding: "ascii"},
+		},
+		{
+			name:    "SFX stub and CP932 name",
+			entries: []ipftest.Entry{{Name: "\x83\x65\x83\x58\x83\x67.txt", Data: data}},
+			stub:    512,
+			want: ArchiveProfile{Entries: 1, Encryption: "zipcrypto", EncryptedNames: true, SFXStub: true, StubSize: 512,
+				Methods: map[uint16]int{zip.Store: 1}, FilenameEncoding: "legacy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := append(make([]byte, tt.stub), ipftest.Build(t, testPassword, tt.entries...)...)
+			path := ipftest.WriteFile(t, t.TempDir(), "probe.ipf", archive)
+
+			got, err := Probe(path, testPassword)
+			if err != nil {
+				t.Fatalf("Probe: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Probe =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package ipf
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// PhaseExtract is the progress phase reported while files are being extracted
+const PhaseExtract = "extract"
+
+// progressBuffer is the number of events buffered before new events are dropped
+const progressBuffer = 256
+
+// ProgressEvent describes extraction progress at a point in time
+type ProgressEvent struct {
+	Phase string
+	Done  int
+	Total int
+	Bytes int64
+}
+
+// ProgressFunc is called after each file of an extraction finishes with the number of finished
+// files, the total and the name of the file just finished
+type ProgressFunc func(done, total int, current string)
+
+// progressState holds the event channel and callback of a ConcurrentExtractor
+type progressState struct {
+	mu     sync.Mutex
+	events chan ProgressEvent
+	fn     ProgressFunc
+}
+
+// SetProgressFunc registers fn to be called after each extracted file, for all following
+// extractions; nil removes it. fn runs on the worker goroutines, concurrently and in completion
+// order, so it must be safe for concurrent use and return quickly.
+func (ce *ConcurrentExtractor) SetProgressFunc(fn ProgressFunc) {
+	ce.progress.mu.Lock()
+	defer ce.progress.mu.Unlock()
+	ce.progress.fn = fn
+}
+
+// ProgressEvents returns a channel receiving progress events for the next extraction.
+// The channel is closed when that extraction finishes. Events are dropped rather than
+// blocking workers when the consumer falls behind or never reads.
+func (ce *ConcurrentExtractor) ProgressEvents() <-chan ProgressEvent {
+	ce.progress.mu.Lock()
+	defer ce.progress.mu.Unlock()
+
+	if ce.progress.events == nil {
+		ce.progress.events = make(chan ProgressEvent, progressBuffer)
+	}
+	return ce.progress.events
+}
+
+// progressTracker counts completed tasks for a single extraction run
+type progressTracker struct {
+	events chan ProgressEvent
+	fn     ProgressFunc
+	total  int
+	done   atomic.Int64
+	bytes  atomic.Int64
+}
+
+// startProgress claims the current event channel (if any) for an extraction run.
+// The caller sets total once the task list is known, before any task runs.
+func (ce *ConcurrentExtractor) startProgress() *progressTracker {
+	ce.progress.mu.Lock()
+	defer ce.progress.mu.Unlock()
+
+	tracker := &progressTracker{events: ce.progress.events, fn: ce.progress.fn}
+	ce.progress.events = nil
+	return tracker
+}
+
+// track wraps an extraction function so each completed task updates progress
+func (pt *progressTracker) track(fn func(ExtractionTask) ExtractionResult) func(ExtractionTask) ExtractionResult {
+	return func(task ExtractionTask) ExtractionResult {
+		result := fn(task)
+		done := pt.done.Add(1)
+		bytes := pt.bytes.Add(result.Size)
+
+		if pt.events != nil {
+			select {
+			case pt.events <- ProgressEvent{Phase: PhaseExtract, Done: int(done), Total: pt.total, Bytes: bytes}:
+			default:
+			}
+		}
+		if pt.fn != nil {
+			pt.fn(int(done), pt.total, taskName(task))
+		}
+		return result
+	}
+}
+
+// taskName returns the output name of a task for progress reporting
+func taskName(task ExtractionTask) string {
+	if task.OutputName != "" {
+		return task.OutputName
+	}
+	if task.FileInfo != nil {
+		return task.FileInfo.SafeFilename
+	}
+	return ""
+}
+
+// finish closes the event channel once the extraction is complete
+func (pt *progressTracker) finish() {
+	if pt.events != nil {
+		close(pt.events)
+	}
+}
@@ -0,0 +1,141 @@
+package ipf
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestProgressEvents(t *testing.T) {
+	const count, size = 12, 100
+	archive := ipftest.Build(t, testPassword, sizedEntries(count, size)...)
+
+	tests := []struct {
+		name    string
+		extract func(ce *ConcurrentExtractor, outputDir string) ([]ExtractionResult, error)
+	}{
+		{"parallel", func(ce *ConcurrentExtractor, outputDir string) ([]ExtractionResult, error) {
+			return ce.ExtractAllParallel(context.Background(), outputDir, testPassword)
+		}},
+		{"batch", func(ce *ConcurrentExtractor, outputDir string) ([]ExtractionResult, error) {
+			return ce.ExtractBatch(context.Background(), outputDir, 0, testPassword)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractor := NewConcurrentExtractor(openArchive(t, archive, testPassword), nil, 4)
+			events := extractor.ProgressEvents()
+
+			received := make(chan []ProgressEvent)
+			go func() {
+				var all []ProgressEvent
+				for event := range events {
+					all = append(all, event)
+				}
+				received <- all
+			}()
+
+			results, err := tt.extract(extractor, t.TempDir())
+			requireSuccess(t, results, err)
+
+			all := <-received
+			if len(all) != count {
+				t.Fatalf("received %d events, want %d", len(all), count)
+			}
+			seen := make(map[int]bool)
+			for _, event := range all {
+				if event.Phase != PhaseExtract || event.Total != count || event.Done < 1 || event.Done > count {
+					t.Errorf("unexpected event %+v", event)
+				}
+				seen[event.Done] = true
+			}
+			if len(seen) != count {
+				t.Errorf("done counts %v are not distinct", seen)
+			}
+			var maxBytes int64
+			for _, event := range all {
+				if event.Bytes > maxBytes {
+					maxBytes = event.Bytes
+				}
+			}
+			if maxBytes != count*size {
+				t.Errorf("bytes reached %d, want %d", maxBytes, count*size)
+			}
+
+			// The channel belongs to one run; the next run does not report to it
+			if _, err := extractor.ExtractAllParallel(context.Background(), t.TempDir(), testPassword); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestProgressFunc(t *testing.T) {
+	const count = 12
+	entries := sizedEntries(count, 100)
+	archive := ipftest.Build(t, testPassword, entries...)
+
+	tests := []struct {
+		name    string
+		workers int
+		extract func(ce *ConcurrentExtractor, outputDir string) ([]ExtractionResult, error)
+	}{
+		{"parallel", 4, func(ce *ConcurrentExtractor, outputDir string) ([]ExtractionResult, error) {
+			return ce.ExtractAllParallel(context.Background(), outputDir, testPassword)
+		}},
+		{"single worker", 1, func(ce *ConcurrentExtractor, outputDir string) ([]ExtractionResult, error) {
+			return ce.ExtractAllParallel(context.Background(), outputDir, testPassword)
+		}},
+		{"batch", 4, func(ce *ConcurrentExtractor, outputDir string) ([]ExtractionResult, error) {
+			return ce.ExtractBatch(context.Background(), outputDir, 0, testPassword)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractor := NewConcurrentExtractor(openArchive(t, archive, testPassword), nil, tt.workers)
+
+			var mu sync.Mutex
+			var calls int
+			done := make(map[int]bool)
+			names := make(map[string]bool)
+			extractor.SetProgressFunc(func(d, total int, current string) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls++
+				done[d] = true
+				names[current] = true
+				if total != count {
+					t.Errorf("total = %d, want %d", total, count)
+				}
+			})
+
+			// The callback stays registered across runs
+			for run := 1; run <= 2; run++ {
+				results, err := tt.extract(extractor, t.TempDir())
+				requireSuccess(t, results, err)
+				if calls != run*count {
+					t.Errorf("run %d: %d callbacks, want %d", run, calls, run*count)
+				}
+			}
+			for i := 1; i <= count; i++ {
+				if !done[i] {
+					t.Errorf("done count %d was never reported", i)
+				}
+			}
+			for _, entry := range entries {
+				if !names[entry.Name] {
+					t.Errorf("%s was never reported", entry.Name)
+				}
+			}
+
+			extractor.SetProgressFunc(nil)
+			results, err := tt.extract(extractor, t.TempDir())
+			requireSuccess(t, results, err)
+			if calls != 2*count {
+				t.Errorf("%d callbacks after removing the callback, want %d", calls, 2*count)
+			}
+		})
+	}
+}
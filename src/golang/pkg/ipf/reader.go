@@ -2,11 +2,17 @@ package ipf
 
 import (
 	"archive/zip"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"os"
-	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
 )
 
 // FileInfo represents a file within the IPF archive
@@ -24,13 +30,83 @@ type FileInfo struct {
 	VersionNeeded     uint16
 	VersionMadeBy     uint16
 	GenPurpose        uint16
+	InternalAttrs     uint16 // From the central directory; archive/zip does not expose it
+	LocalMethod       uint16 // Compression method from the local header
+
+	localHeaderRead bool
+}
+
+// Method returns the compression method used to decode the entry. The local header is trusted
+// over the central directory, since it describes the data that actually follows it.
+func (fi *FileInfo) Method() uint16 {
+	if fi.localHeaderRead || fi.ZipInfo == nil {
+		return fi.LocalMethod
+	}
+	return fi.ZipInfo.Method
 }
 
+// MethodMismatch reports whether the local header and central directory disagree on the method
+func (fi *FileInfo) MethodMismatch() bool {
+	return fi.localHeaderRead && fi.ZipInfo != nil && fi.LocalMethod != fi.ZipInfo.Method
+}
+
+// IsDir reports whether the entry is an explicit directory entry (name ending in a slash)
+func (fi *FileInfo) IsDir() bool {
+	name := fi.DecryptedFilename
+	if name == "" {
+		name = fi.SafeFilename
+	}
+	return strings.HasSuffix(name, "/") || strings.HasSuffix(name, "\\")
+}
+
+// UnixMode returns the Unix permission bits stored in the external attributes,
+// and false when the entry was not created on a Unix host
+func (fi *FileInfo) UnixMode() (os.FileMode, bool) {
+	if fi.ZipInfo == nil {
+		return 0, false
+	}
+
+	hostSystem := fi.ZipInfo.CreatorVersion >> 8
+	if hostSystem != 3 && hostSystem != 19 { // Unix, macOS
+		return 0, false
+	}
+
+	mode := os.FileMode(fi.ZipInfo.ExternalAttrs>>16) & os.ModePerm
+	if mode == 0 {
+		return 0, false
+	}
+	return mode, true
+}
+
+// flagMaskedHeaders is general purpose bit 13, set by PKWARE strong encryption when the central
+// directory is encrypted and local header values are masked
+const flagMaskedHeaders = 0x2000
+
+// ErrEncryptedCentralDirectory is returned for archives whose central directory is encrypted.
+// This is unrelated to the IPF filename encryption, which applies the ZIP stream cipher to names
+// in otherwise plain headers: with bit 13 set the stored names are garbage before that cipher
+// is even applied, so they can never be decrypted.
+var ErrEncryptedCentralDirectory = errors.New("encrypted central directory unsupported")
+
+// ErrFileNotFound is returned by GetFileByName for names that are not in the archive
+var ErrFileNotFound = errors.New("file not found in archive")
+
 // IPFReader provides high-performance reading of IPF files
 type IPFReader struct {
-	File      *os.File
-	ZipReader *zip.ReadCloser
+	File      *os.File        // Owned archive file; nil for readers created by NewIPFReaderFromReaderAt
+	ZipReader *zip.ReadCloser // Owned ZIP reader; nil for readers created by NewIPFReaderFromReaderAt
+	Zip       *zip.Reader     // Parsed ZIP structure, set for every reader
 	FileInfos []FileInfo
+	Warnings  []string // Structural inconsistencies noticed while reading headers
+
+	source io.ReaderAt
+	size   int64
+
+	nameMu    sync.Mutex
+	nameIndex map[string]*FileInfo // Built by GetFileByName, dropped when the names change
+
+	offsetsMu     sync.Mutex
+	headerOffsets []int64 // Sorted local header and central directory offsets, built by nextHeaderOffset
 }
 
 // NewIPFReader creates a new IPF reader for the given file path
@@ -52,55 +128,313 @@ func NewIPFReader(filename string) (*IPFReader, error) {
 		return nil, fmt.Errorf("IPF file is empty")
 	}
 
-	// Create ZIP reader
 	zipReader, err := zip.OpenReader(filename)
 	if err != nil {
 		file.Close()
 		return nil, fmt.Errorf("failed to open ZIP reader: %w", err)
 	}
 
-	reader := &IPFReader{
-		File:      file,
-		ZipReader: zipReader,
+	reader := newIPFReader(&zipReader.Reader, file, stat.Size())
+	reader.File = file
+	reader.ZipReader = zipReader
+
+	return reader, nil
+}
+
+// NewIPFReaderFromReaderAt creates an IPF reader over size bytes of r, e.g. an archive embedded
+// in a larger blob, an mmap'd region or a ranged network reader. All reads go through ReadAt, so
+// r must stay valid until the reader is no longer used; Close does not close it. There is no
+// file to own, so File and ZipReader stay nil and the ZIP structure is only available as Zip.
+func NewIPFReaderFromReaderAt(r io.ReaderAt, size int64) (*IPFReader, error) {
+	if size == 0 {
+		return nil, fmt.Errorf("IPF file is empty")
+	}
+
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ZIP reader: %w", err)
+	}
+
+	return newIPFReader(zipReader, r, size), nil
+}
+
+// newIPFReader creates a reader over a parsed ZIP structure and the data it was read from
+func newIPFReader(zipReader *zip.Reader, source io.ReaderAt, size int64) *IPFReader {
+	return &IPFReader{
+		Zip:       zipReader,
 		FileInfos: make([]FileInfo, 0, len(zipReader.File)),
+		source:    source,
+		size:      size,
 	}
+}
 
-	return reader, nil
+// ReaderAt returns the underlying archive data
+func (r *IPFReader) ReaderAt() io.ReaderAt {
+	return r.source
 }
 
 // ReadFileStructure reads the ZIP file structure and prepares file info
 func (r *IPFReader) ReadFileStructure() error {
 	r.FileInfos = r.FileInfos[:0] // Reset slice but keep capacity
+	r.Warnings = nil
+	r.dropNameIndex()
+	r.headerOffsets = nil
+
+	eocd, eocdOffset, ok := r.readEndRecord()
+	if !ok {
+		return fmt.Errorf("end of central directory record not found")
+	}
+	entries, err := r.readCentralDirectory(eocd, eocdOffset)
+	if err != nil {
+		return fmt.Errorf("failed to parse central directory: %w", err)
+	}
+	if len(entries) != len(r.Zip.File) {
+		return fmt.Errorf("central directory has %d entries, ZIP reader found %d", len(entries), len(r.Zip.File))
+	}
+
+	for i, zipFile := range r.Zip.File {
+		if zipFile.Flags&flagMaskedHeaders != 0 {
+			return fmt.Errorf("file %d: %w", i, ErrEncryptedCentralDirectory)
+		}
 
-	for i, zipFile := range r.ZipReader.File {
-		// Use reflection to access unexported headerOffset field
-		headerOffset := getHeaderOffset(zipFile)
 		fileInfo := FileInfo{
 			Index:             i,
 			ZipInfo:           zipFile,
-			LocalHeaderOffset: int64(headerOffset),
+			LocalHeaderOffset: entries[i].localHeaderOffset,
 			SafeFilename:      fmt.Sprintf("file_%04d.bin", i), // Fallback name
+			VersionMadeBy:     zipFile.CreatorVersion,
+			InternalAttrs:     entries[i].internalAttrs,
 		}
 		r.FileInfos = append(r.FileInfos, fileInfo)
 	}
 
+	r.checkEntryCounts(eocd)
+
 	return nil
 }
 
+// readEndRecord locates the end of central directory record and returns its 22 fixed bytes
+// along with its offset in the archive
+func (r *IPFReader) readEndRecord() ([]byte, int64, bool) {
+	// The EOCD record is 22 bytes plus a comment of up to 65535 bytes
+	tailSize := int64(22 + 65535)
+	if tailSize > r.size {
+		tailSize = r.size
+	}
+	tail := make([]byte, tailSize)
+	if _, err := r.source.ReadAt(tail, r.size-tailSize); err != nil && err != io.EOF {
+		return nil, 0, false
+	}
+
+	for i := len(tail) - 22; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(tail[i:i+4]) == 0x06054b50 {
+			return tail[i : i+22], r.size - tailSize + int64(i), true
+		}
+	}
+	return nil, 0, false
+}
+
+// checkEntryCounts warns when the EOCD's "entries on this disk" and "total entries" fields
+// disagree, which points at a spliced directory. A total that disagrees with the central
+// directory itself never gets here, as archive/zip already rejects it with ErrFormat.
+func (r *IPFReader) checkEntryCounts(eocd []byte) {
+	diskEntries := binary.LittleEndian.Uint16(eocd[8:10])
+	totalEntries := binary.LittleEndian.Uint16(eocd[10:12])
+
+	if diskEntries != totalEntries {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("end record entries on this disk (%d) differ from total entries (%d)", diskEntries, totalEntries))
+	}
+}
+
+// centralEntry holds the central directory fields archive/zip does not expose
+type centralEntry struct {
+	localHeaderOffset int64 // Absolute, including any data prepended to the archive
+	internalAttrs     uint16
+}
+
+// directoryLocation is where an archive's central directory lives
+type directoryLocation struct {
+	entryCount uint64
+	size       uint64
+	offset     uint64 // As recorded in the end record, relative to the start of the ZIP data
+	baseOffset int64  // Bytes prepended to the ZIP data, e.g. a self-extractor stub
+}
+
+// locateCentralDirectory resolves the central directory described by the end record at eocdOffset,
+// following the ZIP64 end record when the classic fields overflowed. The base offset is derived
+// the same way archive/zip does, so archives with a prepended stub resolve to their real headers.
+func (r *IPFReader) locateCentralDirectory(eocd []byte, eocdOffset int64) (directoryLocation, error) {
+	location := directoryLocation{
+		entryCount: uint64(binary.LittleEndian.Uint16(eocd[10:12])),
+		size:       uint64(binary.LittleEndian.Uint32(eocd[12:16])),
+		offset:     uint64(binary.LittleEndian.Uint32(eocd[16:20])),
+	}
+
+	endOffset := eocdOffset
+	if location.entryCount == 0xFFFF || location.size == 0xFFFFFFFF || location.offset == 0xFFFFFFFF {
+		zip64Offset, ok, err := r.readZip64EndRecord(eocdOffset, &location.entryCount, &location.size, &location.offset)
+		if err != nil {
+			return location, err
+		}
+		if ok {
+			endOffset = zip64Offset
+		}
+	}
+	if location.size > uint64(endOffset) || location.offset > uint64(endOffset) {
+		return location, fmt.Errorf("central directory (offset %d, size %d) lies outside the archive", location.offset, location.size)
+	}
+
+	// Data prepended to the archive shifts every offset by the same amount
+	location.baseOffset = endOffset - int64(location.size) - int64(location.offset)
+	if location.baseOffset < 0 {
+		return location, fmt.Errorf("central directory (offset %d, size %d) overlaps its end record", location.offset, location.size)
+	}
+	if location.baseOffset > 0 && r.hasSignatureAt(int64(location.offset), 0x02014b50) {
+		// Trust the recorded offset when it already points at a directory entry
+		location.baseOffset = 0
+	}
+
+	return location, nil
+}
+
+// CentralDirectory returns the absolute offset and size of the central directory along with the
+// archive comment, following the ZIP64 end record when the classic fields overflowed
+func (r *IPFReader) CentralDirectory() (int64, int64, string, error) {
+	eocd, eocdOffset, ok := r.readEndRecord()
+	if !ok {
+		return 0, 0, "", fmt.Errorf("end of central directory record not found")
+	}
+	location, err := r.locateCentralDirectory(eocd, eocdOffset)
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("failed to locate central directory: %w", err)
+	}
+
+	comment := make([]byte, binary.LittleEndian.Uint16(eocd[20:22]))
+	if _, err := r.source.ReadAt(comment, eocdOffset+22); err != nil && err != io.EOF {
+		return 0, 0, "", fmt.Errorf("failed to read archive comment: %w", err)
+	}
+
+	return location.baseOffset + int64(location.offset), int64(location.size), string(comment), nil
+}
+
+// readCentralDirectory walks the central directory described by the end record at eocdOffset
+// (see locateCentralDirectory), returning offsets made absolute
+func (r *IPFReader) readCentralDirectory(eocd []byte, eocdOffset int64) ([]centralEntry, error) {
+	location, err := r.locateCentralDirectory(eocd, eocdOffset)
+	if err != nil {
+		return nil, err
+	}
+	baseOffset := location.baseOffset
+	cdSize := location.size
+	cdOffset := location.offset
+	entryCount := location.entryCount
+
+	cd := make([]byte, cdSize)
+	if _, err := r.source.ReadAt(cd, baseOffset+int64(cdOffset)); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read central directory: %w", err)
+	}
+
+	entries := make([]centralEntry, 0, entryCount)
+	pos := 0
+	for pos+46 <= len(cd) && binary.LittleEndian.Uint32(cd[pos:pos+4]) == 0x02014b50 {
+		header := cd[pos : pos+46]
+		nameLen := int(binary.LittleEndian.Uint16(header[28:30]))
+		extraLen := int(binary.LittleEndian.Uint16(header[30:32]))
+		commentLen := int(binary.LittleEndian.Uint16(header[32:34]))
+		if pos+46+nameLen+extraLen+commentLen > len(cd) {
+			return nil, fmt.Errorf("central directory entry %d overruns the directory", len(entries))
+		}
+
+		offset := uint64(binary.LittleEndian.Uint32(header[42:46]))
+		if offset == 0xFFFFFFFF {
+			extra := cd[pos+46+nameLen : pos+46+nameLen+extraLen]
+			needUncompressed := binary.LittleEndian.Uint32(header[24:28]) == 0xFFFFFFFF
+			needCompressed := binary.LittleEndian.Uint32(header[20:24]) == 0xFFFFFFFF
+			zip64, found, err := zipcipher.ParseZip64Extra(extra, needUncompressed, needCompressed, true, false)
+			if err != nil {
+				return nil, fmt.Errorf("central directory entry %d: %w", len(entries), err)
+			}
+			if !found {
+				return nil, fmt.Errorf("central directory entry %d has no ZIP64 extra field for its local header offset", len(entries))
+			}
+			offset = zip64.LocalHeaderOffset
+		}
+
+		entries = append(entries, centralEntry{
+			localHeaderOffset: baseOffset + int64(offset),
+			internalAttrs:     binary.LittleEndian.Uint16(header[36:38]),
+		})
+		pos += 46 + nameLen + extraLen + commentLen
+	}
+
+	return entries, nil
+}
+
+// readZip64EndRecord reads the ZIP64 end of central directory record through the locator that
+// precedes the classic end record at eocdOffset, replacing the overflowed counts. It returns the
+// ZIP64 record's offset, or false when the archive has no locator.
+func (r *IPFReader) readZip64EndRecord(eocdOffset int64, entryCount, cdSize, cdOffset *uint64) (int64, bool, error) {
+	if eocdOffset < 20 {
+		return 0, false, nil
+	}
+	locator := make([]byte, 20)
+	if _, err := r.source.ReadAt(locator, eocdOffset-20); err != nil {
+		return 0, false, fmt.Errorf("failed to read ZIP64 end record locator: %w", err)
+	}
+	if binary.LittleEndian.Uint32(locator[0:4]) != 0x07064b50 {
+		return 0, false, nil
+	}
+
+	recordOffset := int64(binary.LittleEndian.Uint64(locator[8:16]))
+	if recordOffset < 0 || recordOffset+56 > eocdOffset {
+		return 0, false, fmt.Errorf("ZIP64 end record offset %d is out of range", recordOffset)
+	}
+	record := make([]byte, 56)
+	if _, err := r.source.ReadAt(record, recordOffset); err != nil {
+		return 0, false, fmt.Errorf("failed to read ZIP64 end record: %w", err)
+	}
+	if binary.LittleEndian.Uint32(record[0:4]) != 0x06064b50 {
+		return 0, false, fmt.Errorf("invalid ZIP64 end record signature at offset %d", recordOffset)
+	}
+
+	*entryCount = binary.LittleEndian.Uint64(record[32:40])
+	*cdSize = binary.LittleEndian.Uint64(record[40:48])
+	*cdOffset = binary.LittleEndian.Uint64(record[48:56])
+	return recordOffset, true, nil
+}
+
+// hasSignatureAt reports whether the 4 bytes at offset hold signature
+func (r *IPFReader) hasSignatureAt(offset int64, signature uint32) bool {
+	b := make([]byte, 4)
+	if _, err := r.source.ReadAt(b, offset); err != nil {
+		return false
+	}
+	return binary.LittleEndian.Uint32(b) == signature
+}
+
+// cancelCheckInterval is how many local headers ReadEncryptedFilenamesCtx reads between checks
+// of its context
+const cancelCheckInterval = 4096
+
 // ReadEncryptedFilenames reads encrypted filenames from local headers
 // This is optimized to read all headers in a single pass
 func (r *IPFReader) ReadEncryptedFilenames() error {
-	// Get file size
-	fileInfo, err := r.File.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to get file size: %w", err)
-	}
-	fileSize := fileInfo.Size()
+	return r.ReadEncryptedFilenamesCtx(context.Background())
+}
 
+// ReadEncryptedFilenamesCtx is ReadEncryptedFilenames with cancellation. The context is checked
+// every few thousand headers; entries read before it was cancelled keep their filenames.
+func (r *IPFReader) ReadEncryptedFilenamesCtx(ctx context.Context) error {
 	// Use SectionReader for efficient random access
-	mmap := io.NewSectionReader(r.File, 0, fileSize)
+	mmap := io.NewSectionReader(r.source, 0, r.size)
 
 	for i := range r.FileInfos {
+		if i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("stopped after %d of %d local headers: %w", i, len(r.FileInfos), err)
+			}
+		}
+
 		headerOffset := r.FileInfos[i].LocalHeaderOffset
 
 		// Seek to start of header
@@ -118,18 +452,29 @@ func (r *IPFReader) ReadEncryptedFilenames() error {
 		// Parse version fields
 		r.FileInfos[i].VersionNeeded = binary.LittleEndian.Uint16(headerBytes[4:6])
 		r.FileInfos[i].GenPurpose = binary.LittleEndian.Uint16(headerBytes[6:8])
+		r.FileInfos[i].LocalMethod = binary.LittleEndian.Uint16(headerBytes[8:10])
+		r.FileInfos[i].localHeaderRead = true
+		validSignature := binary.LittleEndian.Uint32(headerBytes[0:4]) == 0x04034b50
+		if r.FileInfos[i].GenPurpose&flagMaskedHeaders != 0 {
+			return fmt.Errorf("file %d: %w", i, ErrEncryptedCentralDirectory)
+		}
 
 		// Parse filename and extra field lengths
 		nameLen := binary.LittleEndian.Uint16(headerBytes[26:28])
 		extraLen := binary.LittleEndian.Uint16(headerBytes[28:30])
 
+		// Both headers store the same (encrypted) name, so their lengths must agree
+		if cdNameLen := len(r.FileInfos[i].ZipInfo.Name); validSignature && int(nameLen) != cdNameLen {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("file %d: local header filename length %d differs from central directory length %d", i, nameLen, cdNameLen))
+		}
+
 		// Validate filename length
 		if nameLen == 0 || nameLen > 512 {
 			continue
 		}
 
 		// Check if header fits in file
-		if headerOffset+30+int64(nameLen)+int64(extraLen) > fileSize {
+		if headerOffset+30+int64(nameLen)+int64(extraLen) > r.size {
 			continue
 		}
 
@@ -161,11 +506,61 @@ func (r *IPFReader) ReadEncryptedFilenames() error {
 	return nil
 }
 
+// RawLocalHeader returns the local header bytes of the file at index exactly as stored on disk,
+// including the 30-byte fixed header, the filename and the extra field
+func (r *IPFReader) RawLocalHeader(index int) ([]byte, error) {
+	fileInfo, err := r.GetFileByIndex(index)
+	if err != nil {
+		return nil, err
+	}
+
+	fileSize, err := r.GetFileSize()
+	if err != nil {
+		return nil, err
+	}
+
+	section := io.NewSectionReader(r.source, 0, fileSize)
+
+	headerBytes := make([]byte, 30)
+	if _, err := section.ReadAt(headerBytes, fileInfo.LocalHeaderOffset); err != nil {
+		return nil, fmt.Errorf("failed to read local header for file %d: %w", index, err)
+	}
+
+	signature := binary.LittleEndian.Uint32(headerBytes[0:4])
+	if signature != 0x04034b50 {
+		return nil, fmt.Errorf("file %d: %w: 0x%08x", index, zipcipher.ErrBadHeaderSignature, signature)
+	}
+
+	nameLen := binary.LittleEndian.Uint16(headerBytes[26:28])
+	extraLen := binary.LittleEndian.Uint16(headerBytes[28:30])
+
+	raw := make([]byte, 30+int(nameLen)+int(extraLen))
+	if _, err := section.ReadAt(raw, fileInfo.LocalHeaderOffset); err != nil {
+		return nil, fmt.Errorf("failed to read local header for file %d: %w", index, err)
+	}
+
+	return raw, nil
+}
+
 // GetFileInfos returns all file information
 func (r *IPFReader) GetFileInfos() []FileInfo {
 	return r.FileInfos
 }
 
+// EntriesByOffset returns pointers to all file infos sorted by LocalHeaderOffset, the order in
+// which their data is physically stored. Reading in this order minimizes seeks on spinning disks.
+func (r *IPFReader) EntriesByOffset() []*FileInfo {
+	entries := make([]*FileInfo, len(r.FileInfos))
+	for i := range r.FileInfos {
+		entries[i] = &r.FileInfos[i]
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LocalHeaderOffset < entries[j].LocalHeaderOffset
+	})
+	return entries
+}
+
 // GetFileCount returns the number of files in the IPF
 func (r *IPFReader) GetFileCount() int {
 	return len(r.FileInfos)
@@ -179,6 +574,63 @@ func (r *IPFReader) GetFileByIndex(index int) (*FileInfo, error) {
 	return &r.FileInfos[index], nil
 }
 
+// GetFileByName returns file info by decrypted path, falling back to the safe name for entries
+// whose name could not be decrypted. Backslashes and slashes are interchangeable, and when a name
+// occurs more than once the newest entry is returned. Filenames must have been decrypted (see
+// IPFReader.UpdateFileInfos); the lookup map is built on first use and rebuilt only after
+// ReadFileStructure or UpdateFileInfos changed the names.
+func (r *IPFReader) GetFileByName(name string) (*FileInfo, error) {
+	r.nameMu.Lock()
+	defer r.nameMu.Unlock()
+
+	if r.nameIndex == nil {
+		decrypted := false
+		index := make(map[string]*FileInfo, len(r.FileInfos))
+		for i := range r.FileInfos {
+			fileInfo := &r.FileInfos[i]
+			if fileInfo.DecryptedFilename != "" {
+				decrypted = true
+			}
+			index[lookupName(fileInfoName(fileInfo))] = fileInfo
+		}
+		if !decrypted && len(r.FileInfos) > 0 {
+			return nil, fmt.Errorf("filenames have not been decrypted")
+		}
+		r.nameIndex = index
+	}
+
+	if fileInfo, ok := r.nameIndex[lookupName(name)]; ok {
+		return fileInfo, nil
+	}
+	return nil, fmt.Errorf("%s: %w", name, ErrFileNotFound)
+}
+
+// UpdateFileInfos stores decrypted names on the reader's FileInfos, see the UpdateFileInfos function
+func (r *IPFReader) UpdateFileInfos(results []DecryptionResult) {
+	UpdateFileInfos(r.FileInfos, results)
+	r.dropNameIndex()
+}
+
+// dropNameIndex makes the next GetFileByName rebuild its lookup map
+func (r *IPFReader) dropNameIndex() {
+	r.nameMu.Lock()
+	defer r.nameMu.Unlock()
+	r.nameIndex = nil
+}
+
+// fileInfoName returns the decrypted name, or the safe name when decryption failed
+func fileInfoName(fileInfo *FileInfo) string {
+	if fileInfo.DecryptedFilename != "" {
+		return fileInfo.DecryptedFilename
+	}
+	return fileInfo.SafeFilename
+}
+
+// lookupName normalizes path separators for GetFileByName
+func lookupName(name string) string {
+	return strings.ReplaceAll(name, "\\", "/")
+}
+
 // ExtractFile extracts a single file to the output directory
 func (r *IPFReader) ExtractFile(fileInfo *FileInfo, outputDir string, password []byte) error {
 	if fileInfo.ZipInfo == nil {
@@ -193,7 +645,10 @@ func (r *IPFReader) ExtractFile(fileInfo *FileInfo, outputDir string, password [
 	defer rc.Close()
 
 	// Create output file path
-	outputPath := fmt.Sprintf("%s/%s", outputDir, fileInfo.SafeFilename)
+	outputPath, err := sanitizeExtractPath(outputDir, fileInfo.SafeFilename)
+	if err != nil {
+		return fmt.Errorf("refusing to extract file %d: %w", fileInfo.Index, err)
+	}
 
 	// Create output file
 	outFile, err := os.Create(outputPath)
@@ -213,6 +668,7 @@ func (r *IPFReader) ExtractFile(fileInfo *FileInfo, outputDir string, password [
 
 // Close closes the IPF reader and releases resources
 func (r *IPFReader) Close() error {
+	// Readers over a caller's io.ReaderAt own nothing, so both fields are nil
 	var firstErr error
 
 	if r.ZipReader != nil {
@@ -230,37 +686,13 @@ func (r *IPFReader) Close() error {
 	return firstErr
 }
 
-// getHeaderOffset uses reflection to access the unexported headerOffset field
-func getHeaderOffset(f *zip.File) uint32 {
-	// Use reflection to access the unexported headerOffset field
-	v := reflect.ValueOf(f).Elem()
-	field := v.FieldByName("headerOffset")
-	if !field.IsValid() {
-		return 0
-	}
-	// Handle both uint32 and int64 types
-	switch field.Kind() {
-	case reflect.Uint32, reflect.Uint, reflect.Uintptr:
-		return uint32(field.Uint())
-	case reflect.Int32, reflect.Int, reflect.Int64:
-		return uint32(field.Int())
-	default:
-		return 0
-	}
-}
-
 // GetFileSize returns the size of the IPF file
 func (r *IPFReader) GetFileSize() (int64, error) {
-	if r.File == nil {
+	if r.source == nil {
 		return 0, fmt.Errorf("file is not open")
 	}
 
-	stat, err := r.File.Stat()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get file stats: %w", err)
-	}
-
-	return stat.Size(), nil
+	return r.size, nil
 }
 
 // GetTotalUncompressedSize returns the total uncompressed size of all files
@@ -293,5 +725,10 @@ func (r *IPFReader) ValidateIPF() error {
 		}
 	}
 
+	// Reject entries sharing stored bytes
+	if overlaps := r.FindOverlaps(); len(overlaps) > 0 {
+		return fmt.Errorf("%s (%d overlapping entries in total)", overlaps[0], len(overlaps))
+	}
+
 	return nil
 }
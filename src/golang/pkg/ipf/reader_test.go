@@ -0,0 +1,382 @@
+package ipf
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// localHeader assembles the local header the archive/zip writer produces for a raw entry
+func localHeader(flags, method uint16, crc, compressed, uncompressed uint32, name, extra []byte) []byte {
+	header := make([]byte, 30, 30+len(name)+len(extra))
+	binary.LittleEndian.PutUint32(header[0:], 0x04034b50)
+	binary.LittleEndian.PutUint16(header[6:], flags)
+	binary.LittleEndian.PutUint16(header[8:], method)
+	binary.LittleEndian.PutUint16(header[10:], ipftest.DefaultModTime)
+	binary.LittleEndian.PutUint16(header[12:], ipftest.DefaultModDate)
+	binary.LittleEndian.PutUint32(header[14:], crc)
+	binary.LittleEndian.PutUint32(header[18:], compressed)
+	binary.LittleEndian.PutUint32(header[22:], uncompressed)
+	binary.LittleEndian.PutUint16(header[26:], uint16(len(name)))
+	binary.LittleEndian.PutUint16(header[28:], uint16(len(extra)))
+	return append(append(header, name...), extra...)
+}
+
+func TestRawLocalHeader(t *testing.T) {
+	cipher := &zipcipher.ZipCipher{}
+	cipher.InitKeys(testPassword)
+	name := cipher.EncryptData([]byte("a.txt"))
+	data := []byte("raw header fixture")
+	crc := crc32.ChecksumIEEE(data)
+	extra := []byte{0xfe, 0xca, 0x02, 0x00, 0x01, 0x02}
+
+	tests := []struct {
+		name  string
+		entry ipftest.Entry
+		want  []byte
+	}{
+		{
+			name:  "stored",
+			entry: ipftest.Entry{Name: "a.txt", Data: data},
+			want:  localHeader(0x1, zip.Store, crc, uint32(len(data)+12), uint32(len(data)), name, nil),
+		},
+		{
+			name:  "extra field",
+			entry: ipftest.Entry{Name: "a.txt", Data: data, Extra: extra},
+			want:  localHeader(0x1, zip.Store, crc, uint32(len(data)+12), uint32(len(data)), name, extra),
+		},
+		{
+			name:  "data descriptor",
+			entry: ipftest.Entry{Name: "a.txt", Data: data, Descriptor: true},
+			want:  localHeader(0x9, zip.Store, 0, 0, 0, name, nil),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filler := ipftest.Entry{Name: "first.txt", Data: []byte("moves the header off offset 0")}
+			reader := openArchive(t, ipftest.Build(t, testPassword, filler, tt.entry), testPassword)
+
+			raw, err := reader.RawLocalHeader(1)
+			if err != nil {
+				t.Fatalf("RawLocalHeader: %v", err)
+			}
+			if !bytes.Equal(raw, tt.want) {
+				t.Errorf("RawLocalHeader =\n% x\nwant\n% x", raw, tt.want)
+			}
+		})
+	}
+
+	t.Run("bad signature", func(t *testing.T) {
+		archive := ipftest.Build(t, testPassword, ipftest.Entry{Name: "a.txt", Data: data})
+		archive[0] = 'X'
+		reader := openArchive(t, archive, testPassword)
+		if _, err := reader.RawLocalHeader(0); !errors.Is(err, ErrBadHeaderSignature) {
+			t.Errorf("RawLocalHeader error = %v, want ErrBadHeaderSignature", err)
+		}
+	})
+}
+
+func TestMaskedHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		central bool // Set bit 13 in the central directory, otherwise only in the local header
+	}{
+		{"central directory", true},
+		{"local header", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := ipftest.Build(t, testPassword,
+				ipftest.Entry{Name: "a.txt", Data: []byte("plain a")},
+				ipftest.Entry{Name: "b.txt", Data: []byte("masked b")},
+			)
+			if tt.central {
+				record := ipftest.CentralRecords(t, archive)[1]
+				binary.LittleEndian.PutUint16(record[8:], binary.LittleEndian.Uint16(record[8:])|flagMaskedHeaders)
+			} else {
+				offset := binary.LittleEndian.Uint32(ipftest.CentralRecords(t, archive)[1][42:])
+				binary.LittleEndian.PutUint16(archive[offset+6:], binary.LittleEndian.Uint16(archive[offset+6:])|flagMaskedHeaders)
+			}
+
+			reader, err := NewIPFReaderFromReaderAt(bytes.NewReader(archive), int64(len(archive)))
+			if err != nil {
+				t.Fatalf("NewIPFReaderFromReaderAt: %v", err)
+			}
+			err = reader.ReadFileStructure()
+			if err == nil {
+				err = reader.ReadEncryptedFilenames()
+			}
+			if !errors.Is(err, ErrEncryptedCentralDirectory) {
+				t.Errorf("error = %v, want ErrEncryptedCentralDirectory", err)
+			}
+		})
+	}
+}
+
+func TestEntriesByOffset(t *testing.T) {
+	archive := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "a.txt", Data: []byte("a")},
+		ipftest.Entry{Name: "b.txt", Data: []byte("b")},
+		ipftest.Entry{Name: "c.txt", Data: []byte("c")},
+		ipftest.Entry{Name: "d.txt", Data: []byte("d")},
+	)
+	reader := openArchive(t, ipftest.ReorderCentralDirectory(t, archive, []int{2, 0, 3, 1}), testPassword)
+
+	entries := reader.EntriesByOffset()
+	var names []string
+	for i, entry := range entries {
+		names = append(names, entry.SafeFilename)
+		if i > 0 && entry.LocalHeaderOffset <= entries[i-1].LocalHeaderOffset {
+			t.Errorf("entry %d at offset %d follows offset %d", i, entry.LocalHeaderOffset, entries[i-1].LocalHeaderOffset)
+		}
+		if entry != &reader.FileInfos[entry.Index] {
+			t.Errorf("entry %d does not point into FileInfos", entry.Index)
+		}
+	}
+	if want := []string{"a.txt", "b.txt", "c.txt", "d.txt"}; !slices.Equal(names, want) {
+		t.Errorf("EntriesByOffset names = %v, want %v", names, want)
+	}
+}
+
+func TestStructureWarnings(t *testing.T) {
+	tests := []struct {
+		name        string
+		craft       func(t *testing.T, archive []byte)
+		wantWarning string // Substring of the only expected warning; empty expects none
+	}{
+		{
+			name:  "consistent archive",
+			craft: func(*testing.T, []byte) {},
+		},
+		{
+			name: "local filename length differs",
+			craft: func(t *testing.T, archive []byte) {
+				offset := binary.LittleEndian.Uint32(ipftest.CentralRecords(t, archive)[1][42:])
+				length := binary.LittleEndian.Uint16(archive[offset+26:])
+				binary.LittleEndian.PutUint16(archive[offset+26:], length-1)
+			},
+			wantWarning: "file 1: local header filename length",
+		},
+		{
+			name: "entries on this disk differ",
+			craft: func(t *testing.T, archive []byte) {
+				_, _, eocd := ipftest.CentralDirectory(t, archive)
+				binary.LittleEndian.PutUint16(archive[eocd+8:], 7)
+			},
+			wantWarning: "entries on this disk (7) differ from total entries (3)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := ipftest.Build(t, testPassword,
+				ipftest.Entry{Name: "a.txt", Data: []byte("a")},
+				ipftest.Entry{Name: "dir/b.txt", Data: []byte("b")},
+				ipftest.Entry{Name: "c.txt", Data: []byte("c")},
+			)
+			tt.craft(t, archive)
+			reader := openArchive(t, archive, testPassword)
+
+			if tt.wantWarning == "" {
+				if len(reader.Warnings) != 0 {
+					t.Errorf("Warnings = %q, want none", reader.Warnings)
+				}
+				return
+			}
+			if len(reader.Warnings) != 1 || !strings.Contains(reader.Warnings[0], tt.wantWarning) {
+				t.Errorf("Warnings = %q, want one containing %q", reader.Warnings, tt.wantWarning)
+			}
+		})
+	}
+
+	// archive/zip itself rejects a total entry count that disagrees with the central directory
+	t.Run("total entries differ", func(t *testing.T) {
+		archive := ipftest.Build(t, testPassword, ipftest.Entry{Name: "a.txt", Data: []byte("a")})
+		_, _, eocd := ipftest.CentralDirectory(t, archive)
+		binary.LittleEndian.PutUint16(archive[eocd+10:], 2)
+		if _, err := NewIPFReaderFromReaderAt(bytes.NewReader(archive), int64(len(archive))); err == nil {
+			t.Errorf("NewIPFReaderFromReaderAt accepted a truncated central directory")
+		}
+	})
+}
+
+func TestLocalHeaderOffsets(t *testing.T) {
+	entries := []ipftest.Entry{
+		{Name: "a.txt", Data: []byte("first")},
+		{Name: "dir/b.xml", Data: bytes.Repeat([]byte("<b/>"), 100), Method: zip.Deflate},
+		{Name: "c.txt", Data: []byte("extra field"), Extra: []byte{0xfe, 0xca, 0x02, 0x00, 0x01, 0x02}},
+		{Name: "d.txt", Data: []byte("descriptor"), Descriptor: true},
+	}
+
+	tests := []struct {
+		name  string
+		craft func(t *testing.T) []byte
+	}{
+		{
+			name: "plain archive",
+			craft: func(t *testing.T) []byte {
+				return ipftest.Build(t, testPassword, entries...)
+			},
+		},
+		{
+			name: "prepended stub",
+			craft: func(t *testing.T) []byte {
+				return append(bytes.Repeat([]byte("MZ"), 300), ipftest.Build(t, testPassword, entries...)...)
+			},
+		},
+		{
+			// The last entry records its offset in a ZIP64 extra field instead of the header
+			name: "zip64 offset",
+			craft: func(t *testing.T) []byte {
+				zip64 := append(entries[:3:3], ipftest.Entry{
+					Name:  "e.txt",
+					Data:  []byte("zip64"),
+					Extra: []byte{0x01, 0x00, 0x08, 0x00, 0, 0, 0, 0, 0, 0, 0, 0},
+				})
+				archive := ipftest.Build(t, testPassword, zip64...)
+				record := ipftest.CentralRecords(t, archive)[3]
+				offset := binary.LittleEndian.Uint32(record[42:])
+				binary.LittleEndian.PutUint32(record[42:], 0xFFFFFFFF)
+				extra := record[46+binary.LittleEndian.Uint16(record[28:]):]
+				binary.LittleEndian.PutUint64(extra[4:], uint64(offset))
+				return archive
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := openArchive(t, tt.craft(t), testPassword)
+
+			// archive/zip keeps the offset it parsed in an unexported field, which reflection can
+			// still read for comparison
+			for i, fileInfo := range reader.FileInfos {
+				want := reflect.ValueOf(reader.Zip.File[i]).Elem().FieldByName("headerOffset").Int()
+				if fileInfo.LocalHeaderOffset != want {
+					t.Errorf("file %d: LocalHeaderOffset = %d, archive/zip parsed %d", i, fileInfo.LocalHeaderOffset, want)
+				}
+				if _, err := reader.RawLocalHeader(i); err != nil {
+					t.Errorf("file %d: RawLocalHeader: %v", i, err)
+				}
+			}
+		})
+	}
+}
+
+// cancellingReader cancels a context once a read reaches the given offset
+type cancellingReader struct {
+	io.ReaderAt
+	offset int64
+	cancel context.CancelFunc
+}
+
+func (r *cancellingReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.offset {
+		r.cancel()
+	}
+	return r.ReaderAt.ReadAt(p, off)
+}
+
+func TestReadEncryptedFilenamesCancel(t *testing.T) {
+	const count = 3*cancelCheckInterval - 100
+	entries := make([]ipftest.Entry, count)
+	for i := range entries {
+		entries[i] = ipftest.Entry{Name: fmt.Sprintf("f%05d.txt", i)}
+	}
+	archive := ipftest.Build(t, testPassword, entries...)
+
+	tests := []struct {
+		name     string
+		cancelAt int // Entry whose local header cancels the read; -1 cancels before it starts
+		wantRead int // Entries with their local header read
+		wantErr  bool
+	}{
+		{name: "not cancelled", cancelAt: count, wantRead: count},
+		{name: "cancelled before reading", cancelAt: -1, wantRead: 0, wantErr: true},
+		{name: "cancelled mid-read", cancelAt: cancelCheckInterval + 10, wantRead: 2 * cancelCheckInterval, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewIPFReaderFromReaderAt(bytes.NewReader(archive), int64(len(archive)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := reader.ReadFileStructure(); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			offset := int64(len(archive))
+			if tt.cancelAt < 0 {
+				cancel()
+			} else if tt.cancelAt < count {
+				offset = reader.FileInfos[tt.cancelAt].LocalHeaderOffset
+			}
+			reader.source = &cancellingReader{ReaderAt: reader.source, offset: offset, cancel: cancel}
+
+			err = reader.ReadEncryptedFilenamesCtx(ctx)
+			if tt.wantErr != errors.Is(err, context.Canceled) {
+				t.Errorf("ReadEncryptedFilenamesCtx error = %v, want cancelled %t", err, tt.wantErr)
+			}
+			for i, fileInfo := range reader.FileInfos {
+				if fileInfo.localHeaderRead != (i < tt.wantRead) {
+					t.Fatalf("entry %d read = %t, want the first %d entries read", i, fileInfo.localHeaderRead, tt.wantRead)
+				}
+			}
+		})
+	}
+}
+
+func TestGetFileByNameIndexPerReader(t *testing.T) {
+	archive := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "data/item.xml", Data: []byte("item")},
+		ipftest.Entry{Name: "ui\\icon.png", Data: []byte("icon")},
+	)
+	first := openArchive(t, archive, testPassword)
+	second := openArchive(t, archive, testPassword)
+	for _, reader := range []*IPFReader{first, second} {
+		if _, err := reader.GetFileByName("data/item.xml"); err != nil {
+			t.Fatalf("GetFileByName before renaming: %v", err)
+		}
+	}
+
+	// Renaming through one reader refreshes its own index and leaves the other one alone
+	results := make([]DecryptionResult, len(first.FileInfos))
+	for i, fileInfo := range first.FileInfos {
+		results[i] = DecryptionResult{Index: i, DecryptedFilename: "renamed/" + fileInfo.DecryptedFilename, SafeFilename: fileInfo.SafeFilename, Success: true}
+	}
+	first.UpdateFileInfos(results)
+
+	tests := []struct {
+		reader *IPFReader
+		name   string
+		found  bool
+	}{
+		{first, "renamed/data/item.xml", true},
+		{first, "renamed/ui/icon.png", true},
+		{first, "data/item.xml", false},
+		{second, "data/item.xml", true},
+		{second, "ui/icon.png", true},
+		{second, "renamed/data/item.xml", false},
+	}
+	for _, tt := range tests {
+		fileInfo, err := tt.reader.GetFileByName(tt.name)
+		if tt.found && (err != nil || fileInfo == nil) {
+			t.Errorf("GetFileByName(%q) = %v, want a match", tt.name, err)
+		}
+		if !tt.found && !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("GetFileByName(%q) error = %v, want ErrFileNotFound", tt.name, err)
+		}
+	}
+}
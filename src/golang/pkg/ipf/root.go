@@ -0,0 +1,64 @@
+package ipf
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// SingleRoot returns the top-level directory shared by every slash-separated name, and false when
+// names have more than one top-level segment or any name sits directly at the top level.
+// A bare entry for the root directory itself ("root/") does not prevent a match.
+func SingleRoot(names []string) (string, bool) {
+	root := ""
+	hasChild := false
+
+	for _, name := range names {
+		name = strings.TrimPrefix(filepath.ToSlash(name), "/")
+		slash := strings.Index(name, "/")
+		if slash <= 0 {
+			return "", false
+		}
+
+		segment := name[:slash]
+		if root == "" {
+			root = segment
+		} else if segment != root {
+			return "", false
+		}
+
+		if slash < len(name)-1 {
+			hasChild = true
+		}
+	}
+
+	return root, root != "" && hasChild
+}
+
+// stripSingleRoot removes the single top-level directory shared by all tasks from their output
+// names. The entry for the root directory itself has nothing left to create and is returned as skipped.
+func stripSingleRoot(tasks []ExtractionTask) ([]ExtractionTask, []ExtractionResult) {
+	names := make([]string, len(tasks))
+	for i, task := range tasks {
+		names[i] = task.OutputName
+	}
+
+	root, ok := SingleRoot(names)
+	if !ok {
+		return tasks, nil
+	}
+
+	prefix := root + "/"
+	kept := tasks[:0]
+	var skipped []ExtractionResult
+	for _, task := range tasks {
+		name := strings.TrimPrefix(strings.TrimPrefix(filepath.ToSlash(task.OutputName), "/"), prefix)
+		if name == "" {
+			skipped = append(skipped, ExtractionResult{Index: task.Index, Skipped: true})
+			continue
+		}
+		task.OutputName = name
+		kept = append(kept, task)
+	}
+
+	return kept, skipped
+}
@@ -0,0 +1,87 @@
+package ipf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestSingleRoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		names    []string
+		wantRoot string
+		wantOK   bool
+	}{
+		{"single root", []string{"root/a.txt", "root/sub/b.txt"}, "root", true},
+		{"root directory entry", []string{"root/", "root/a.txt"}, "root", true},
+		{"two roots", []string{"root/a.txt", "other/b.txt"}, "", false},
+		{"file at top level", []string{"root/a.txt", "c.txt"}, "", false},
+		{"only the root directory", []string{"root/"}, "", false},
+		{"leading slash", []string{"/root/a.txt", "root/b.txt"}, "root", true},
+		{"no names", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, ok := SingleRoot(tt.names)
+			if ok != tt.wantOK || (ok && root != tt.wantRoot) {
+				t.Errorf("SingleRoot(%q) = %q, %v, want %q, %v", tt.names, root, ok, tt.wantRoot, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestExtractStripSingleRoot(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []ipftest.Entry
+		want    map[string]string
+	}{
+		{
+			name: "single root stripped",
+			entries: []ipftest.Entry{
+				{Name: "root/"},
+				{Name: "root/a.txt", Data: []byte("a")},
+				{Name: "root/sub/b.txt", Data: []byte("b")},
+			},
+			want: map[string]string{"a.txt": "a", "sub/b.txt": "b"},
+		},
+		{
+			name: "multiple roots untouched",
+			entries: []ipftest.Entry{
+				{Name: "root/a.txt", Data: []byte("a")},
+				{Name: "other/b.txt", Data: []byte("b")},
+			},
+			want: map[string]string{"root/a.txt": "a", "other/b.txt": "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := openArchive(t, ipftest.Build(t, testPassword, tt.entries...), testPassword)
+			extractor := NewConcurrentExtractor(reader, nil, 2)
+			extractor.StripSingleRoot = true
+
+			outputDir := t.TempDir()
+			results, err := extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, result := range results {
+				if !result.Success && !result.Skipped {
+					t.Errorf("file %d failed: %v", result.Index, result.Error)
+				}
+			}
+
+			got := readTree(t, outputDir)
+			if len(got) != len(tt.want) {
+				t.Errorf("extracted %v, want %v", got, tt.want)
+			}
+			for name, content := range tt.want {
+				if got[name] != content {
+					t.Errorf("%s = %q, want %q", name, got[name], content)
+				}
+			}
+		})
+	}
+}
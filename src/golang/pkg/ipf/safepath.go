@@ -0,0 +1,29 @@
+package ipf
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafePath is returned for entries whose name would resolve outside the output directory
+var ErrUnsafePath = errors.New("path escapes output directory")
+
+// sanitizeExtractPath joins an entry name onto outputDir and rejects names that are absolute or
+// climb out of it through ".." segments, in either slash or backslash form (Zip Slip)
+func sanitizeExtractPath(outputDir, name string) (string, error) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(slashed, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" ||
+		(len(slashed) >= 2 && slashed[1] == ':') {
+		return "", fmt.Errorf("%q is absolute: %w", name, ErrUnsafePath)
+	}
+
+	root := filepath.Clean(outputDir)
+	finalPath := filepath.Join(root, filepath.FromSlash(slashed))
+	rel, err := filepath.Rel(root, finalPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q resolves outside %s: %w", name, root, ErrUnsafePath)
+	}
+	return finalPath, nil
+}
@@ -0,0 +1,99 @@
+package ipf
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestSanitizeExtractPath(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "out")
+
+	tests := []struct {
+		name string
+		want string // Path below root; empty expects ErrUnsafePath
+	}{
+		{"a.txt", "a.txt"},
+		{"dir/sub/a.txt", "dir/sub/a.txt"},
+		{"dir/../a.txt", "a.txt"},
+		{"..a/b..txt", "..a/b..txt"},
+		{"../a.txt", ""},
+		{"../../etc/cron.d/evil", ""},
+		{"dir/../../a.txt", ""},
+		{"..", ""},
+		{`..\a.txt`, ""},
+		{`dir\..\..\a.txt`, ""},
+		{"/etc/passwd", ""},
+		{`\windows\system32\a.dll`, ""},
+		{`C:\windows\a.dll`, ""},
+		{"c:a.dll", ""},
+	}
+	for _, tt := range tests {
+		got, err := sanitizeExtractPath(root, tt.name)
+		if tt.want == "" {
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("sanitizeExtractPath(%q) = %q, %v, want ErrUnsafePath", tt.name, got, err)
+			}
+			continue
+		}
+		if want := filepath.Join(root, filepath.FromSlash(tt.want)); err != nil || got != want {
+			t.Errorf("sanitizeExtractPath(%q) = %q, %v, want %q", tt.name, got, err, want)
+		}
+	}
+}
+
+func TestExtractZipSlip(t *testing.T) {
+	names := []string{"../escape.txt", "../../etc/cron.d/evil", `..\..\win.txt`, "/abs/path.txt", "inside/ok.txt"}
+	var entries []ipftest.Entry
+	for _, name := range names {
+		entries = append(entries, ipftest.Entry{Name: name, Data: []byte(name)})
+	}
+	reader := openArchive(t, ipftest.Build(t, testPassword, entries...), testPassword)
+
+	t.Run("decrypted names", func(t *testing.T) {
+		parent := t.TempDir()
+		outputDir := filepath.Join(parent, "out")
+		results, err := NewConcurrentExtractor(reader, nil, 2).ExtractAllParallel(context.Background(), outputDir, testPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, result := range results {
+			if !result.Success && !errors.Is(result.Error, ErrUnsafePath) {
+				t.Errorf("file %d failed: %v", result.Index, result.Error)
+			}
+		}
+
+		// Names are either neutralized below the output directory or refused, never written outside
+		for name := range readTree(t, parent) {
+			if !strings.HasPrefix(name, "out/") {
+				t.Errorf("%s was written outside the output directory", name)
+			}
+		}
+	})
+
+	// Output names bypass the decryptor's sanitizing, e.g. through templates or callers building tasks
+	for _, name := range []string{"../escape.txt", `..\escape.txt`, "/abs.txt"} {
+		t.Run(name, func(t *testing.T) {
+			parent := t.TempDir()
+			outputDir := filepath.Join(parent, "out")
+			result := NewConcurrentExtractor(reader, nil, 1).ExtractSingle(ExtractionTask{
+				FileInfo:   &reader.FileInfos[4],
+				OutputDir:  outputDir,
+				OutputName: name,
+				Index:      4,
+				Password:   testPassword,
+			})
+			if result.Success || !errors.Is(result.Error, ErrUnsafePath) {
+				t.Errorf("ExtractSingle = %v, %v, want ErrUnsafePath", result.Success, result.Error)
+			}
+			if _, err := os.Stat(filepath.Join(parent, "escape.txt")); !os.IsNotExist(err) {
+				t.Errorf("escape.txt was written next to the output directory")
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package ipf
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestSampleValidate(t *testing.T) {
+	const count = 20
+
+	tests := []struct {
+		name        string
+		n           int
+		corrupt     int
+		wantSampled int // Exact number of sampled entries; 0 checks the range n..n+2
+		wantRate    float64
+	}{
+		{"first entry always sampled", 5, 0, 0, 0},
+		{"last entry always sampled", 5, count - 1, 0, 0},
+		{"every entry", count, 7, count, 95},
+		{"only first and last", 0, 3, 2, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := sizedEntries(count, 64)
+			for i := range entries {
+				entries[i].Method = 0 // Stored, so a flipped byte only breaks the CRC32
+			}
+			archive := ipftest.Build(t, testPassword, entries...)
+			archive[ipftest.DataOffset(t, archive, tt.corrupt)+12+10] ^= 0xff
+
+			reader := openArchive(t, archive, testPassword)
+			report, err := reader.SampleValidate(context.Background(), testPassword, tt.n)
+			if err != nil {
+				t.Fatalf("SampleValidate: %v", err)
+			}
+
+			if tt.wantSampled > 0 && len(report.Sampled) != tt.wantSampled {
+				t.Errorf("sampled %d entries, want %d", len(report.Sampled), tt.wantSampled)
+			}
+			if tt.wantSampled == 0 && (len(report.Sampled) < tt.n || len(report.Sampled) > tt.n+2) {
+				t.Errorf("sampled %d entries, want %d to %d", len(report.Sampled), tt.n, tt.n+2)
+			}
+			if !slices.IsSorted(report.Sampled) || !slices.Contains(report.Sampled, 0) || !slices.Contains(report.Sampled, count-1) {
+				t.Errorf("Sampled = %v, want sorted indices including the first and last", report.Sampled)
+			}
+
+			if slices.Contains(report.Sampled, tt.corrupt) {
+				if len(report.Failures) != 1 || !errors.Is(report.Failures[tt.corrupt], ErrCRCMismatch) {
+					t.Errorf("Failures = %v, want a CRC32 mismatch for entry %d", report.Failures, tt.corrupt)
+				}
+			} else if len(report.Failures) != 0 {
+				t.Errorf("Failures = %v, want none", report.Failures)
+			}
+			if tt.wantRate > 0 && report.PassRate != tt.wantRate {
+				t.Errorf("PassRate = %v, want %v", report.PassRate, tt.wantRate)
+			}
+
+			again, err := reader.SampleValidate(context.Background(), testPassword, tt.n)
+			if err != nil || !slices.Equal(again.Sampled, report.Sampled) {
+				t.Errorf("second run sampled %v, want %v", again.Sampled, report.Sampled)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package ipf
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestExtractStored(t *testing.T) {
+	data := bytes.Repeat([]byte("stored fast path "), 1000)
+
+	tests := []struct {
+		name     string
+		entry    ipftest.Entry
+		corrupt  bool
+		wantFast bool
+	}{
+		{"encrypted", ipftest.Entry{Name: "a.bin", Data: data}, false, true},
+		{"plain", ipftest.Entry{Name: "a.bin", Data: data, Plain: true}, false, true},
+		{"empty", ipftest.Entry{Name: "a.bin"}, false, true},
+		{"deflated", ipftest.Entry{Name: "a.bin", Data: data, Method: zip.Deflate}, false, false},
+		{"corrupt", ipftest.Entry{Name: "a.bin", Data: data}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := ipftest.Build(t, testPassword, tt.entry)
+			if tt.corrupt {
+				archive[ipftest.DataOffset(t, archive, 0)+12+100] ^= 0x01
+			}
+			reader := openArchive(t, archive, testPassword)
+			extractor := NewConcurrentExtractor(reader, nil, 1)
+
+			task := ExtractionTask{Index: 0, FileInfo: &reader.FileInfos[0], Password: testPassword}
+			if _, fast, err := extractor.openStored(task); fast != tt.wantFast || err != nil {
+				t.Errorf("openStored = %v, %v, want %v", fast, err, tt.wantFast)
+			}
+
+			outputDir := t.TempDir()
+			results, err := extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.corrupt {
+				if results[0].Success || !errors.Is(results[0].Error, ErrCRCMismatch) {
+					t.Errorf("result = %v, %v, want a CRC32 mismatch", results[0].Success, results[0].Error)
+				}
+				if files := readTree(t, outputDir); len(files) != 0 {
+					t.Errorf("corrupt output was left behind: %v", files)
+				}
+				return
+			}
+			requireSuccess(t, results, nil)
+
+			// Plain names come out garbled by the name cipher, so the single file is taken as is
+			files := readTree(t, outputDir)
+			if len(files) != 1 {
+				t.Fatalf("extracted %d files, want 1", len(files))
+			}
+			for name, got := range files {
+				if got != string(tt.entry.Data) {
+					t.Errorf("%s has %d bytes, want %d", name, len(got), len(tt.entry.Data))
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkExtractStored compares extracting the same content stored and deflated; stored
+// entries stream straight to disk without a decompressor or an in-memory copy
+func BenchmarkExtractStored(b *testing.B) {
+	for _, method := range []uint16{zip.Store, zip.Deflate} {
+		entries := sizedEntries(64, 64<<10)
+		for i := range entries {
+			entries[i].Method = method
+		}
+		reader := openArchive(b, ipftest.Build(b, testPassword, entries...), testPassword)
+
+		b.Run(MethodName(method), func(b *testing.B) {
+			b.SetBytes(reader.GetTotalUncompressedSize())
+			for i := 0; i < b.N; i++ {
+				results, err := NewConcurrentExtractor(reader, nil, 4).ExtractAllParallel(context.Background(), b.TempDir(), testPassword)
+				requireSuccess(b, results, err)
+			}
+		})
+	}
+}
+
+func TestDecodeStoredEntry(t *testing.T) {
+	// Stored data can hold the data descriptor signature, here followed by a plausible descriptor
+	fake := binary.LittleEndian.AppendUint32([]byte("before "), 0x08074b50)
+	fake = binary.LittleEndian.AppendUint32(fake, crc32.ChecksumIEEE([]byte("before ")))
+	fake = binary.LittleEndian.AppendUint32(fake, 7)
+	fake = binary.LittleEndian.AppendUint32(fake, 7)
+	data := append(fake, " and after"...)
+
+	tests := []struct {
+		name    string
+		entry   ipftest.Entry
+		craft   func(record []byte)
+		wantErr error
+	}{
+		{name: "encrypted", entry: ipftest.Entry{Name: "a.bin", Data: data}},
+		{name: "plain", entry: ipftest.Entry{Name: "a.bin", Data: data, Plain: true}},
+		{name: "empty", entry: ipftest.Entry{Name: "a.bin"}},
+		{name: "encrypted descriptor", entry: ipftest.Entry{Name: "a.bin", Data: data, Descriptor: true}},
+		{name: "plain descriptor", entry: ipftest.Entry{Name: "a.bin", Data: data, Plain: true, Descriptor: true}},
+		{
+			name:    "uncompressed size too large",
+			entry:   ipftest.Entry{Name: "a.bin", Data: data},
+			craft:   func(record []byte) { binary.LittleEndian.PutUint32(record[24:], uint32(len(data)+1)) },
+			wantErr: ErrSizeMismatch,
+		},
+		{
+			name:    "uncompressed size too large with descriptor",
+			entry:   ipftest.Entry{Name: "a.bin", Data: data, Descriptor: true},
+			craft:   func(record []byte) { binary.LittleEndian.PutUint32(record[24:], uint32(len(data)+1)) },
+			wantErr: ErrSizeMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := ipftest.Build(t, testPassword, tt.entry)
+			if tt.craft != nil {
+				tt.craft(ipftest.CentralRecords(t, archive)[0])
+			}
+			reader := openArchive(t, archive, testPassword)
+
+			// The buffered path, which openStored bypasses during extraction
+			task := ExtractionTask{Index: 0, FileInfo: &reader.FileInfos[0], Password: testPassword}
+			got, err := NewConcurrentExtractor(reader, nil, 1).extractWithCustomDecryption(task)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("extractWithCustomDecryption: %v", err)
+			}
+			if !bytes.Equal(got, tt.entry.Data) {
+				t.Errorf("data = %q, want %q", got, tt.entry.Data)
+			}
+		})
+	}
+}
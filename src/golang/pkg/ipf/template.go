@@ -0,0 +1,72 @@
+package ipf
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// ExpandOutputTemplate expands the placeholders of an output-path template for one entry:
+//
+//	{name}  output path relative to the output directory, e.g. ui/skin/button.png
+//	{base}  file name without extension, e.g. button
+//	{ext}   extension without the dot, e.g. png
+//	{dir}   directory part of {name}, empty at the top level
+//	{index} entry index in the archive
+//	{crc}   CRC-32 of the uncompressed data as 8 hex digits
+//
+// The expansion is sanitized like decrypted names and may not leave the output directory
+func ExpandOutputTemplate(template string, name string, index int, crc uint32) string {
+	name = filepath.ToSlash(name)
+	file := path.Base(name)
+	ext := path.Ext(file)
+	dir := path.Dir(name)
+	if dir == "." {
+		dir = ""
+	}
+
+	replacer := strings.NewReplacer(
+		"{name}", name,
+		"{base}", strings.TrimSuffix(file, ext),
+		"{ext}", strings.TrimPrefix(ext, "."),
+		"{dir}", dir,
+		"{index}", strconv.Itoa(index),
+		"{crc}", fmt.Sprintf("%08x", crc),
+	)
+	return cleanRelativePath(zipcipher.MakeSafeFilename(replacer.Replace(template)))
+}
+
+// cleanRelativePath drops empty, "." and ".." segments so a path always stays below its root
+func cleanRelativePath(name string) string {
+	segments := strings.Split(filepath.ToSlash(name), "/")
+	kept := segments[:0]
+	for _, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		kept = append(kept, segment)
+	}
+	if len(kept) == 0 {
+		return "unnamed_file"
+	}
+	return strings.Join(kept, "/")
+}
+
+// applyOutputTemplate rewrites task output names through template, keeping every file at a
+// distinct path with uniqueOutputName
+func applyOutputTemplate(tasks []ExtractionTask, template string) {
+	taken := make(map[string]bool, len(tasks))
+	for i := range tasks {
+		var crc uint32
+		if tasks[i].FileInfo.ZipInfo != nil {
+			crc = tasks[i].FileInfo.ZipInfo.CRC32
+		}
+
+		name := ExpandOutputTemplate(template, tasks[i].OutputName, tasks[i].Index, crc)
+		tasks[i].OutputName = uniqueOutputName(taken, name, tasks[i].Index)
+	}
+}
@@ -0,0 +1,103 @@
+package ipf
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestExpandOutputTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		name     string
+		want     string
+	}{
+		{"{name}", "ui/skin/button.png", "ui/skin/button.png"},
+		{"{ext}/{base}.{ext}", "ui/skin/button.png", "png/button.png"},
+		{"{dir}/{index}_{crc}", "ui/skin/button.png", "ui/skin/42_0badf00d"},
+		{"{dir}/{base}", "button.png", "button"},
+		{"{ext}/{name}", "README", "README"},
+		{"../../{name}", "ui/button.png", "ui/button.png"},
+		{"/{dir}/./{base}", "ui/button.png", "ui/button"},
+		{"{dir}", "button.png", "unnamed_file"},
+	}
+	for _, tt := range tests {
+		if got := ExpandOutputTemplate(tt.template, tt.name, 42, 0x0badf00d); got != tt.want {
+			t.Errorf("ExpandOutputTemplate(%q, %q) = %q, want %q", tt.template, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExtractOutputTemplate(t *testing.T) {
+	entries := []ipftest.Entry{
+		{Name: "ui/a.png", Data: []byte("ui a")},
+		{Name: "ui/b.txt", Data: []byte("ui b")},
+		{Name: "ui/"},
+		{Name: "data/A.png", Data: []byte("data A")},
+	}
+	crc := func(i int) string { return fmt.Sprintf("%08x", crc32.ChecksumIEEE(entries[i].Data)) }
+	reader := openArchive(t, ipftest.Build(t, testPassword, entries...), testPassword)
+
+	tests := []struct {
+		template string
+		want     map[string]string
+	}{
+		{
+			template: "{ext}/{name}",
+			want:     map[string]string{"png/ui/a.png": "ui a", "txt/ui/b.txt": "ui b", "png/data/A.png": "data A"},
+		},
+		{
+			template: "{crc}_{base}.{ext}",
+			want:     map[string]string{crc(0) + "_a.png": "ui a", crc(1) + "_b.txt": "ui b", crc(3) + "_A.png": "data A"},
+		},
+		{
+			// a.png and A.png collide on case-insensitive file systems, so the later one is renamed
+			template: "{base}.{ext}",
+			want:     map[string]string{"a.png": "ui a", "b.txt": "ui b", "A_3.png": "data A"},
+		},
+		{
+			// Every entry maps to the same path
+			template: "out.bin",
+			want:     map[string]string{"out.bin": "ui a", "out_1.bin": "ui b", "out_3.bin": "data A"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			extractor := NewConcurrentExtractor(reader, nil, 4)
+			extractor.OutputTemplate = tt.template
+			outputDir := t.TempDir()
+			results, err := extractor.ExtractAllParallel(context.Background(), outputDir, testPassword)
+			requireSuccess(t, results, err)
+
+			got := readTree(t, outputDir)
+			if len(got) != len(tt.want) {
+				t.Errorf("extracted %v, want %v", got, tt.want)
+			}
+			for name, content := range tt.want {
+				if got[name] != content {
+					t.Errorf("%s = %q, want %q", name, got[name], content)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyOutputTemplateSuffixTaken(t *testing.T) {
+	// Entry 2 would be renamed to a_2.png, which entry 0 already took
+	tasks := []ExtractionTask{
+		{Index: 0, OutputName: "x/a_2.png", FileInfo: &FileInfo{}},
+		{Index: 1, OutputName: "y/a.png", FileInfo: &FileInfo{}},
+		{Index: 2, OutputName: "z/a.png", FileInfo: &FileInfo{}},
+	}
+	applyOutputTemplate(tasks, "{base}.{ext}")
+
+	want := []string{"a_2.png", "a.png", "a_2_2.png"}
+	for i, task := range tasks {
+		if task.OutputName != want[i] {
+			t.Errorf("task %d = %q, want %q", i, task.OutputName, want[i])
+		}
+	}
+}
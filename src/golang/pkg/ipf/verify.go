@@ -0,0 +1,106 @@
+package ipf
+
+import (
+	"compress/flate"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// EntryVerifier consumes raw entry bytes (as stored after the local header) and checks the CRC32 of the decoded content
+type EntryVerifier struct {
+	pipe       *io.PipeWriter
+	cipher     *zipcipher.ZipCipher
+	headerLeft int
+	done       chan error
+}
+
+// NewEntryVerifier starts a verifier for the given entry. Close must always be called to
+// release the decoding goroutine. Entries using unsupported compression methods are accepted
+// without verification
+func NewEntryVerifier(file *FileInfo, password []byte) *EntryVerifier {
+	pipeReader, pipeWriter := io.Pipe()
+	verifier := &EntryVerifier{
+		pipe: pipeWriter,
+		done: make(chan error, 1),
+	}
+
+	if file.GenPurpose&0x1 != 0 {
+		verifier.cipher = &zipcipher.ZipCipher{}
+		verifier.cipher.InitKeys(password)
+		verifier.headerLeft = 12
+	}
+
+	method := file.Method()
+	expectedCRC := file.ZipInfo.CRC32
+
+	go func() {
+		var content io.Reader
+		switch method {
+		case 0:
+			content = pipeReader
+		case 8:
+			inflater := flate.NewReader(pipeReader)
+			defer inflater.Close()
+			content = inflater
+		default:
+			io.Copy(io.Discard, pipeReader)
+			verifier.done <- nil
+			return
+		}
+
+		hash := crc32.NewIEEE()
+		_, err := io.Copy(hash, content)
+
+		// Keep draining so the writer never blocks on a failed decoder
+		io.Copy(io.Discard, pipeReader)
+
+		if err != nil {
+			verifier.done <- fmt.Errorf("failed to decode entry: %w", err)
+			return
+		}
+		if hash.Sum32() != expectedCRC {
+			verifier.done <- fmt.Errorf("%w: expected 0x%08x, got 0x%08x", zipcipher.ErrCRCMismatch, expectedCRC, hash.Sum32())
+			return
+		}
+		verifier.done <- nil
+	}()
+
+	return verifier
+}
+
+// Write decrypts the incoming bytes (skipping the 12-byte encryption header) and feeds the decoder
+func (v *EntryVerifier) Write(p []byte) (int, error) {
+	n := len(p)
+	if v.cipher == nil {
+		if _, err := v.pipe.Write(p); err != nil {
+			return 0, err
+		}
+		return n, nil
+	}
+
+	decrypted := v.cipher.DecryptData(p)
+	if v.headerLeft > 0 {
+		skip := v.headerLeft
+		if skip > len(decrypted) {
+			skip = len(decrypted)
+		}
+		decrypted = decrypted[skip:]
+		v.headerLeft -= skip
+	}
+
+	if len(decrypted) > 0 {
+		if _, err := v.pipe.Write(decrypted); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Close finishes the stream and returns the verification result
+func (v *EntryVerifier) Close() error {
+	v.pipe.Close()
+	return <-v.done
+}
@@ -0,0 +1,55 @@
+package ipf
+
+import "runtime"
+
+// MaxRecommendedWorkers caps RecommendWorkers; past this, extra goroutines only add contention
+const MaxRecommendedWorkers = 32
+
+// RecommendWorkers suggests a worker count for extracting the archive read by r, based on its
+// entry count, average entry size and compression-method mix. Archives of many small files are
+// dominated by per-file syscalls and benefit from more workers than CPUs, while a few huge entries
+// are each decompressed in memory and favor fewer. Entries that are mostly stored need no
+// inflation, so they do not get workers beyond the CPU count. The result is only a suggestion.
+func RecommendWorkers(r *IPFReader) int {
+	cpus := runtime.NumCPU()
+	entries := len(r.FileInfos)
+	if entries == 0 {
+		return 1
+	}
+
+	var totalSize int64
+	var stored int
+	for i := range r.FileInfos {
+		fileInfo := &r.FileInfos[i]
+		if fileInfo.ZipInfo != nil {
+			totalSize += int64(fileInfo.ZipInfo.UncompressedSize64)
+		}
+		if fileInfo.Method() == 0 {
+			stored++
+		}
+	}
+	averageSize := totalSize / int64(entries)
+
+	workers := cpus
+	switch {
+	case averageSize < 64*1024:
+		workers = cpus * 2
+	case averageSize >= 16*1024*1024:
+		workers = cpus / 2
+	}
+
+	if stored*4 >= entries*3 && workers > cpus {
+		workers = cpus
+	}
+
+	if workers > entries {
+		workers = entries
+	}
+	if workers > MaxRecommendedWorkers {
+		workers = MaxRecommendedWorkers
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
@@ -0,0 +1,67 @@
+package ipf
+
+import (
+	"archive/zip"
+	"runtime"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestRecommendWorkers(t *testing.T) {
+	cpus := runtime.NumCPU()
+
+	// withSize declares every entry of reader to be size bytes, standing in for large fixtures
+	withSize := func(reader *IPFReader, size uint64) *IPFReader {
+		for i := range reader.FileInfos {
+			reader.FileInfos[i].ZipInfo.UncompressedSize64 = size
+		}
+		return reader
+	}
+	stored := func(entries []ipftest.Entry) []ipftest.Entry {
+		for i := range entries {
+			entries[i].Method = zip.Store
+		}
+		return entries
+	}
+
+	tests := []struct {
+		name   string
+		reader *IPFReader
+		want   int
+	}{
+		{
+			name:   "many tiny deflated files",
+			reader: openArchive(t, ipftest.Build(t, testPassword, sizedEntries(200, 100)...), testPassword),
+			want:   min(cpus*2, MaxRecommendedWorkers),
+		},
+		{
+			name:   "many tiny stored files",
+			reader: openArchive(t, ipftest.Build(t, testPassword, stored(sizedEntries(200, 100))...), testPassword),
+			want:   min(cpus, MaxRecommendedWorkers),
+		},
+		{
+			name:   "few huge files",
+			reader: withSize(openArchive(t, ipftest.Build(t, testPassword, sizedEntries(40, 100)...), testPassword), 64<<20),
+			want:   max(min(cpus/2, MaxRecommendedWorkers), 1),
+		},
+		{
+			name:   "fewer files than workers",
+			reader: openArchive(t, ipftest.Build(t, testPassword, sizedEntries(1, 100)...), testPassword),
+			want:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RecommendWorkers(tt.reader); got != tt.want {
+				t.Errorf("RecommendWorkers = %d, want %d on %d CPUs", got, tt.want, cpus)
+			}
+		})
+	}
+
+	tiny := RecommendWorkers(tests[0].reader)
+	huge := RecommendWorkers(tests[2].reader)
+	if tiny < huge {
+		t.Errorf("tiny files get %d workers, fewer than the %d for huge files", tiny, huge)
+	}
+}
@@ -0,0 +1,97 @@
+package optimize
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// OptimizeReport describes what optimizing an archive would change
+type OptimizeReport struct {
+	Stats          ipf.DeduplicationStats
+	RemovedNames   []string // Names of the superseded entries, in archive order
+	ReclaimedBytes int64    // Local headers, data and central directory records of removed entries
+	FinalFiles     int
+	// RepairedEntries counts retained entries whose sizes were recovered from their data
+	// descriptors. It is only filled in by OptimizeIPFWithReport, which does the repair.
+	RepairedEntries int
+
+	retained []ipf.FileInfo
+	comment  string
+}
+
+// AnalyzeIPF runs the read, decrypt and deduplicate steps of OptimizeIPF without writing
+// anything, so callers can decide whether a rewrite is worth the I/O
+func AnalyzeIPF(filePath string) (*OptimizeReport, error) {
+	return AnalyzeIPFWithPassword(filePath, zipcipher.GetIPFPassword())
+}
+
+// AnalyzeIPFWithPassword is AnalyzeIPF for archives using a password other than the IPF default
+func AnalyzeIPFWithPassword(filePath string, password []byte) (*OptimizeReport, error) {
+	reader, err := ipf.NewIPFReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open IPF reader: %w", err)
+	}
+	defer reader.Close()
+
+	if err := reader.ReadFileStructure(); err != nil {
+		return nil, fmt.Errorf("failed to read file structure: %w", err)
+	}
+	if err := reader.ReadEncryptedFilenames(); err != nil {
+		return nil, fmt.Errorf("failed to read encrypted filenames: %w", err)
+	}
+
+	fileInfos := reader.GetFileInfos()
+
+	decryptor := ipf.NewFilenameDecryptor(password, 4)
+	decryptionResults, err := decryptor.DecryptAllParallel(context.Background(), fileInfos)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt filenames: %w", err)
+	}
+	ipf.UpdateFileInfos(fileInfos, decryptionResults)
+
+	deduplicator := ipf.NewDeduplicator(fileInfos)
+	retained := deduplicator.Run()
+
+	// Sort retained files by their original Index to preserve order
+	sort.Slice(retained, func(i, j int) bool {
+		return retained[i].Index < retained[j].Index
+	})
+
+	report := &OptimizeReport{
+		Stats:      deduplicator.GetStats(),
+		FinalFiles: len(retained),
+		retained:   retained,
+		comment:    reader.Zip.Comment,
+	}
+
+	kept := make(map[int]bool, len(retained))
+	for i := range retained {
+		kept[retained[i].Index] = true
+	}
+	for i := range fileInfos {
+		if kept[fileInfos[i].Index] {
+			continue
+		}
+		report.RemovedNames = append(report.RemovedNames, fileInfos[i].SafeFilename)
+		report.ReclaimedBytes += entryFootprint(&fileInfos[i])
+	}
+
+	return report, nil
+}
+
+// entryFootprint returns the bytes an entry occupies in the archive: its local header, data and
+// data descriptor (assumed to carry the optional signature) plus its central directory record
+func entryFootprint(fileInfo *ipf.FileInfo) int64 {
+	size := int64(fileInfo.HeaderSize) + 46 + int64(len(fileInfo.EncryptedFilename))
+	if zipFile := fileInfo.ZipInfo; zipFile != nil {
+		size += int64(zipFile.CompressedSize64) + int64(len(zipFile.Extra)) + int64(len(zipFile.Comment))
+		if zipFile.Flags&0x8 != 0 {
+			size += 4 + int64(zipcipher.DataDescriptorSize(zipcipher.HasZip64Extra(zipFile.Extra)))
+		}
+	}
+	return size
+}
@@ -0,0 +1,66 @@
+package optimize
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+// withComment returns archive with comment stored in its end of central directory record
+func withComment(t *testing.T, archive []byte, comment string) []byte {
+	t.Helper()
+
+	_, _, eocd := ipftest.CentralDirectory(t, archive)
+	binary.LittleEndian.PutUint16(archive[eocd+20:], uint16(len(comment)))
+	return append(archive[:eocd+22], comment...)
+}
+
+func TestOptimizeComment(t *testing.T) {
+	const original = "original archive comment"
+	cleared, replaced, tooLong := "", "replacement comment", strings.Repeat("x", 0x10000)
+
+	tests := []struct {
+		name    string
+		comment *string
+		want    string
+		wantErr bool
+	}{
+		{"preserved", nil, original, false},
+		{"replaced", &replaced, replaced, false},
+		{"cleared", &cleared, "", false},
+		{"too long", &tooLong, original, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := withComment(t, ipftest.Build(t, testPassword,
+				ipftest.Entry{Name: "a.txt", Data: []byte("old a")},
+				ipftest.Entry{Name: "b.txt", Data: []byte("b")},
+				ipftest.Entry{Name: "a.txt", Data: []byte("new a")},
+			), original)
+			path := ipftest.WriteFile(t, t.TempDir(), "commented.ipf", archive)
+
+			err := OptimizeIPFWithOptions(path, OptimizeOptions{Comment: tt.comment})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OptimizeIPFWithOptions = %v, want an error: %v", err, tt.wantErr)
+			}
+
+			zr, err := zip.OpenReader(path)
+			if err != nil {
+				t.Fatalf("zip.OpenReader: %v", err)
+			}
+			defer zr.Close()
+			if zr.Comment != tt.want {
+				t.Errorf("comment = %q, want %q", zr.Comment, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := readEntries(t, path); got["a.txt"] != "new a" || got["b.txt"] != "b" {
+				t.Errorf("entries = %v", got)
+			}
+		})
+	}
+}
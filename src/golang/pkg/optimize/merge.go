@@ -0,0 +1,99 @@
+package optimize
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+)
+
+// Merge combines several IPF archives into a single optimized archive at out.
+// Inputs are layered in order, so when a file exists in several inputs the copy from the
+// latest input wins, mirroring how the game applies patch IPFs on top of base IPFs.
+// Entry data is copied as-is without recompression. The returned stats describe the duplicates
+// dropped while layering the inputs.
+func Merge(out string, inputs []string, password []byte) (ipf.DeduplicationStats, error) {
+	if len(inputs) == 0 {
+		return ipf.DeduplicationStats{}, fmt.Errorf("no input archives given")
+	}
+
+	// Creating out truncates it, which would destroy an input before its data is copied
+	if outInfo, err := os.Stat(out); err == nil {
+		for _, inputPath := range inputs {
+			if inputInfo, err := os.Stat(inputPath); err == nil && os.SameFile(outInfo, inputInfo) {
+				return ipf.DeduplicationStats{}, fmt.Errorf("output %s is also an input", out)
+			}
+		}
+	}
+
+	var combined []ipf.FileInfo
+	var sourceOf []int
+
+	for inputIndex, inputPath := range inputs {
+		fileInfos, err := readDecryptedFileInfos(inputPath, password)
+		if err != nil {
+			return ipf.DeduplicationStats{}, fmt.Errorf("failed to read %s: %w", inputPath, err)
+		}
+
+		// Renumber entries so indices increase across inputs and later inputs win deduplication
+		for _, fileInfo := range fileInfos {
+			fileInfo.Index = len(combined)
+			combined = append(combined, fileInfo)
+			sourceOf = append(sourceOf, inputIndex)
+		}
+	}
+
+	deduplicator := ipf.NewDeduplicator(combined)
+	retained := deduplicator.Run()
+
+	stats := deduplicator.GetStats()
+
+	sources := make([]*os.File, len(inputs))
+	for i, inputPath := range inputs {
+		file, err := os.Open(inputPath)
+		if err != nil {
+			return stats, fmt.Errorf("failed to open %s: %w", inputPath, err)
+		}
+		defer file.Close()
+		sources[i] = file
+	}
+
+	if err := writeRetainedIPF(out, retained, func(i int) io.ReadSeeker {
+		return sources[sourceOf[retained[i].Index]]
+	}, OptimizeOptions{}); err != nil {
+		return stats, fmt.Errorf("failed to write merged IPF: %w", err)
+	}
+
+	return stats, nil
+}
+
+// readDecryptedFileInfos reads the structure of an IPF and decrypts its filenames
+func readDecryptedFileInfos(filePath string, password []byte) ([]ipf.FileInfo, error) {
+	reader, err := ipf.NewIPFReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open IPF reader: %w", err)
+	}
+	defer reader.Close()
+
+	if err := reader.ReadFileStructure(); err != nil {
+		return nil, fmt.Errorf("failed to read file structure: %w", err)
+	}
+
+	if err := reader.ReadEncryptedFilenames(); err != nil {
+		return nil, fmt.Errorf("failed to read encrypted filenames: %w", err)
+	}
+
+	fileInfos := reader.GetFileInfos()
+
+	decryptor := ipf.NewFilenameDecryptor(password, 4)
+	decryptionResults, err := decryptor.DecryptAllParallel(context.Background(), fileInfos)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt filenames: %w", err)
+	}
+
+	ipf.UpdateFileInfos(fileInfos, decryptionResults)
+
+	return fileInfos, nil
+}
@@ -0,0 +1,157 @@
+package optimize
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+)
+
+func TestMergeBaseAndPatch(t *testing.T) {
+	base := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "data/item.xml", Data: []byte("<item v=1/>"), Method: zip.Deflate},
+		ipftest.Entry{Name: "data/skill.xml", Data: []byte("<skill/>"), Method: zip.Deflate},
+		ipftest.Entry{Name: "ui/icon.png", Data: []byte("png")},
+	)
+	patch := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "data/item.xml", Data: []byte("<item v=2/>"), Method: zip.Deflate},
+		ipftest.Entry{Name: "data/new.xml", Data: []byte("<new/>"), Method: zip.Deflate},
+	)
+
+	tests := []struct {
+		name        string
+		inputs      [][]byte
+		want        map[string]string
+		wantRemoved int
+	}{
+		{
+			name:        "patch over base",
+			inputs:      [][]byte{base, patch},
+			want:        map[string]string{"data/item.xml": "<item v=2/>", "data/skill.xml": "<skill/>", "ui/icon.png": "png", "data/new.xml": "<new/>"},
+			wantRemoved: 1,
+		},
+		{
+			name:        "base over patch",
+			inputs:      [][]byte{patch, base},
+			want:        map[string]string{"data/item.xml": "<item v=1/>", "data/skill.xml": "<skill/>", "ui/icon.png": "png", "data/new.xml": "<new/>"},
+			wantRemoved: 1,
+		},
+		{
+			name:   "single input",
+			inputs: [][]byte{base},
+			want:   map[string]string{"data/item.xml": "<item v=1/>", "data/skill.xml": "<skill/>", "ui/icon.png": "png"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var inputs []string
+			for i, archive := range tt.inputs {
+				inputs = append(inputs, ipftest.WriteFile(t, dir, fmt.Sprintf("input%d.ipf", i), archive))
+			}
+
+			out := filepath.Join(dir, "merged.ipf")
+			stats, err := Merge(out, inputs, testPassword)
+			if err != nil {
+				t.Fatalf("Merge: %v", err)
+			}
+			if stats.RemovedDuplicates != tt.wantRemoved {
+				t.Errorf("removed %d duplicates, want %d", stats.RemovedDuplicates, tt.wantRemoved)
+			}
+
+			got := readEntries(t, out)
+			if len(got) != len(tt.want) {
+				t.Errorf("merged archive has %d entries, want %d", len(got), len(tt.want))
+			}
+			for name, content := range tt.want {
+				if got[name] != content {
+					t.Errorf("%s = %q, want %q", name, got[name], content)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeRejectsOutputAsInput(t *testing.T) {
+	dir := t.TempDir()
+	input := ipftest.WriteFile(t, dir, "base.ipf", ipftest.Build(t, testPassword, ipftest.Entry{Name: "a.txt", Data: []byte("a")}))
+
+	if _, err := Merge(input, []string{input}, testPassword); err == nil {
+		t.Fatal("Merge into its own input succeeded")
+	}
+	if got := readEntries(t, input); got["a.txt"] != "a" {
+		t.Errorf("input was modified: %v", got)
+	}
+}
+
+func TestMergeCopiesRawData(t *testing.T) {
+	layers := [][]ipftest.Entry{
+		{
+			{Name: "a.xml", Data: []byte("<a v=1/>"), Method: zip.Deflate},
+			{Name: "b.txt", Data: []byte("base b")},
+		},
+		{
+			{Name: "a.xml", Data: []byte("<a v=2/>"), Method: zip.Deflate},
+			{Name: "a.xml", Data: []byte("<a v=3/>"), Method: zip.Deflate},
+		},
+		{
+			{Name: "c.txt", Data: []byte("patch c"), Descriptor: true},
+		},
+	}
+
+	// The retained payload of every name, exactly as stored in its input
+	dir := t.TempDir()
+	var inputs []string
+	payloads := make(map[string][]byte)
+	for i, entries := range layers {
+		archive := ipftest.Build(t, testPassword, entries...)
+		inputs = append(inputs, ipftest.WriteFile(t, dir, fmt.Sprintf("layer%d.ipf", i), archive))
+		for j, record := range ipftest.CentralRecords(t, archive) {
+			start := ipftest.DataOffset(t, archive, j)
+			size := int(binary.LittleEndian.Uint32(record[20:]))
+			payloads[entries[j].Name] = archive[start : start+size]
+		}
+	}
+
+	out := filepath.Join(dir, "merged.ipf")
+	stats, err := Merge(out, inputs, testPassword)
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if stats.RemovedDuplicates != 2 {
+		t.Errorf("removed %d duplicates, want 2", stats.RemovedDuplicates)
+	}
+
+	merged, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := ipf.NewIPFReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	entries, err := reader.ListFiles(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(payloads) {
+		t.Errorf("merged archive has %d entries, want %d", len(entries), len(payloads))
+	}
+	for i, entry := range entries {
+		start := ipftest.DataOffset(t, merged, i)
+		got := merged[start : start+int(reader.FileInfos[i].ZipInfo.CompressedSize64)]
+		if !bytes.Equal(got, payloads[entry.Name]) {
+			t.Errorf("%s was not copied byte for byte from its input", entry.Name)
+		}
+	}
+	if got := readEntries(t, out); got["a.xml"] != "<a v=3/>" || got["b.txt"] != "base b" || got["c.txt"] != "patch c" {
+		t.Errorf("merged contents = %v", got)
+	}
+}
@@ -0,0 +1,137 @@
+package optimize
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+// centralFields returns the central directory records of the archive at path keyed by stored
+// name, with the local header offsets zeroed since optimization moves the data
+func centralFields(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+
+	archive, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[string][]byte)
+	for _, record := range ipftest.CentralRecords(t, archive) {
+		record = bytes.Clone(record)
+		binary.LittleEndian.PutUint32(record[42:], 0)
+		nameLength := int(binary.LittleEndian.Uint16(record[28:]))
+		fields[string(record[46:46+nameLength])] = record
+	}
+	return fields
+}
+
+func TestOptimizePreservesCentralDirectory(t *testing.T) {
+	archive := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "dir/", CreatorVersion: 3<<8 | 20, ExternalAttrs: (040000 | 0755) << 16},
+		ipftest.Entry{Name: "dir/a.xml", Data: []byte("<a/>"), Method: zip.Deflate, CreatorVersion: 0, ExternalAttrs: 0x20},
+		ipftest.Entry{Name: "b.bin", Data: []byte("stored b"), CreatorVersion: 0x0b14, ExternalAttrs: 0x81a40000},
+		ipftest.Entry{Name: "c.txt", Data: []byte("descriptor c"), Method: zip.Deflate, Descriptor: true},
+	)
+	// Internal attributes are not exposed by archive/zip, so set them on the records directly
+	for i, record := range ipftest.CentralRecords(t, archive) {
+		binary.LittleEndian.PutUint16(record[36:], uint16(i))
+	}
+
+	tests := []struct {
+		name     string
+		override *HeaderOverride
+	}{
+		{"verbatim", nil},
+		{"override", &HeaderOverride{VersionMadeBy: 0x0014, GenPurpose: 0x0009}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			original := centralFields(t, ipftest.WriteFile(t, dir, "original.ipf", archive))
+			path := ipftest.WriteFile(t, dir, "optimized.ipf", archive)
+			if err := OptimizeIPFWithOptions(path, OptimizeOptions{Override: tt.override}); err != nil {
+				t.Fatalf("OptimizeIPFWithOptions: %v", err)
+			}
+
+			optimized := centralFields(t, path)
+			if len(optimized) != len(original) {
+				t.Fatalf("optimized archive has %d entries, want %d", len(optimized), len(original))
+			}
+			for name, want := range original {
+				got, ok := optimized[name]
+				if !ok {
+					t.Errorf("entry %x missing after optimization", name)
+					continue
+				}
+				if tt.override != nil {
+					want = bytes.Clone(want)
+					binary.LittleEndian.PutUint16(want[4:], tt.override.VersionMadeBy)
+					binary.LittleEndian.PutUint16(want[8:], tt.override.GenPurpose)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("central directory record changed:\n got % x\nwant % x", got, want)
+				}
+			}
+		})
+	}
+}
+
+// localFields returns the time, date and extra field of every local header of the archive at
+// path keyed by stored name
+func localFields(t *testing.T, path string) map[string][]byte {
+	t.Helper()
+
+	archive, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[string][]byte)
+	for _, record := range ipftest.CentralRecords(t, archive) {
+		offset := int(binary.LittleEndian.Uint32(record[42:]))
+		nameLength := int(binary.LittleEndian.Uint16(archive[offset+26:]))
+		extraLength := int(binary.LittleEndian.Uint16(archive[offset+28:]))
+		name := archive[offset+30 : offset+30+nameLength]
+		extra := archive[offset+30+nameLength : offset+30+nameLength+extraLength]
+		fields[string(name)] = append(bytes.Clone(archive[offset+10:offset+14]), extra...)
+	}
+	return fields
+}
+
+func TestOptimizePreservesTimestamps(t *testing.T) {
+	// Extended timestamp (0x5455) with a modification time, and NTFS (0x000a) with all three times
+	unixExtra := []byte{0x55, 0x54, 0x05, 0x00, 0x01, 0x78, 0x56, 0x34, 0x12}
+	ntfsExtra := append([]byte{0x0a, 0x00, 0x20, 0x00, 0, 0, 0, 0, 0x01, 0x00, 0x18, 0x00},
+		bytes.Repeat([]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x01}, 3)...)
+
+	archive := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "a.xml", Data: []byte("<a/>"), Method: zip.Deflate, ModTime: 0x1234, ModDate: 0x4a21, Extra: unixExtra},
+		ipftest.Entry{Name: "b.bin", Data: []byte("stored b"), ModTime: 0xbeef, ModDate: 0x5821, Extra: ntfsExtra},
+		ipftest.Entry{Name: "c.txt", Data: []byte("both"), Method: zip.Deflate, ModTime: 0x0001, ModDate: 0x0021,
+			Extra: append(bytes.Clone(unixExtra), ntfsExtra...)},
+		ipftest.Entry{Name: "a.xml", Data: []byte("<a>patched</a>"), Method: zip.Deflate, ModTime: 0x4321, ModDate: 0x5b50, Extra: unixExtra},
+	)
+
+	dir := t.TempDir()
+	original := ipftest.WriteFile(t, dir, "original.ipf", archive)
+	path := ipftest.WriteFile(t, dir, "optimized.ipf", archive)
+	if err := OptimizeIPFWithOptions(path, OptimizeOptions{}); err != nil {
+		t.Fatalf("OptimizeIPFWithOptions: %v", err)
+	}
+
+	// Duplicate names map to the kept copy, the last one, in both archives
+	for _, fields := range []func(*testing.T, string) map[string][]byte{centralFields, localFields} {
+		want, got := fields(t, original), fields(t, path)
+		if len(got) != len(want) {
+			t.Errorf("optimized archive has %d names, want %d", len(got), len(want))
+		}
+		for name, wantFields := range want {
+			if !bytes.Equal(got[name], wantFields) {
+				t.Errorf("fields of %x changed:\n got % x\nwant % x", name, got[name], wantFields)
+			}
+		}
+	}
+}
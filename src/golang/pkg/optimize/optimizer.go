@@ -1,92 +1,108 @@
 package optimize
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"os"
-	"sort"
 
 	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
 	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
 	"github.com/joao-paulo-santos/GE-Library/pkg/zipwriter"
 )
 
+// OptimizeOptions controls how OptimizeIPFWithOptions rewrites an archive
+type OptimizeOptions struct {
+	CreateBackup bool
+	// Scrub decrypts and inflates every retained entry while copying it and aborts on a CRC32
+	// mismatch, so optimization doubles as an integrity check at the cost of extra CPU
+	Scrub bool
+	// Override replaces the version-made-by and general purpose fields of every retained entry.
+	// When nil the original central directory and local header values are copied verbatim.
+	Override *HeaderOverride
+	// Comment replaces the archive comment; an empty string clears it. When nil the original
+	// comment is kept.
+	Comment *string
+	// Password decrypts names and, with Scrub, data. When nil the default IPF password is used.
+	Password []byte
+}
+
+// password returns the configured password or the IPF default
+func (opts OptimizeOptions) password() []byte {
+	if opts.Password != nil {
+		return opts.Password
+	}
+	return zipcipher.GetIPFPassword()
+}
+
+// HeaderOverride holds header field values forced onto every entry of an optimized archive
+type HeaderOverride struct {
+	VersionMadeBy uint16
+	GenPurpose    uint16
+}
+
+// headerFields returns the version-made-by, central directory and local header general purpose
+// values written for file
+func (opts OptimizeOptions) headerFields(file *ipf.FileInfo) (versionMadeBy, cdGenPurpose, localGenPurpose uint16) {
+	if opts.Override != nil {
+		return opts.Override.VersionMadeBy, opts.Override.GenPurpose, opts.Override.GenPurpose
+	}
+	return file.ZipInfo.CreatorVersion, file.ZipInfo.Flags, file.GenPurpose
+}
+
 func OptimizeIPF(filePath string, createBackup bool) error {
+	return OptimizeIPFWithOptions(filePath, OptimizeOptions{CreateBackup: createBackup})
+}
+
+func OptimizeIPFWithOptions(filePath string, opts OptimizeOptions) error {
+	_, err := OptimizeIPFWithReport(filePath, opts)
+	return err
+}
+
+// OptimizeIPFWithReport is OptimizeIPFWithOptions returning the report of the rewrite, including
+// the number of entries whose sizes had to be repaired
+func OptimizeIPFWithReport(filePath string, opts OptimizeOptions) (*OptimizeReport, error) {
 	fmt.Printf("Optimizing: %s\n", filePath)
 
+	if opts.Comment != nil && len(*opts.Comment) > 0xFFFF {
+		return nil, fmt.Errorf("archive comment too long: %d bytes (maximum 65535)", len(*opts.Comment))
+	}
+
+	createBackup := opts.CreateBackup
+
 	var backupPath string
 
 	if createBackup {
 		backupPath = filePath + ".bak"
 		if err := os.Rename(filePath, backupPath); err != nil {
-			return fmt.Errorf("failed to create backup: %w", err)
+			return nil, fmt.Errorf("failed to create backup: %w", err)
 		}
 		filePath = backupPath
 	}
 
 	tempPath := filePath + ".tmp"
 
-	reader, err := ipf.NewIPFReader(filePath)
+	report, err := AnalyzeIPFWithPassword(filePath, opts.password())
 	if err != nil {
 		if createBackup {
 			os.Rename(backupPath, filePath)
 		}
-		return fmt.Errorf("failed to open IPF reader: %w", err)
+		return nil, err
 	}
+	retained := report.retained
 
-	if err := reader.ReadFileStructure(); err != nil {
-		reader.Close()
-		if createBackup {
-			os.Rename(backupPath, filePath)
-		}
-		return fmt.Errorf("failed to read file structure: %w", err)
-	}
+	fmt.Printf("Deduplication: %s\n", report.Stats.String())
 
-	if err := reader.ReadEncryptedFilenames(); err != nil {
-		reader.Close()
-		if createBackup {
-			os.Rename(backupPath, filePath)
-		}
-		return fmt.Errorf("failed to read encrypted filenames: %w", err)
+	if opts.Comment == nil {
+		opts.Comment = &report.comment
 	}
 
-	fileInfos := reader.GetFileInfos()
-
-	password := zipcipher.GetIPFPassword()
-	decryptor := ipf.NewFilenameDecryptor(password, 4)
-
-	ctx := context.Background()
-	decryptionResults, err := decryptor.DecryptAllParallel(ctx, fileInfos)
+	report.RepairedEntries, err = createOptimizedIPF(filePath, tempPath, retained, opts)
 	if err != nil {
-		reader.Close()
-		if createBackup {
-			os.Rename(backupPath, filePath)
-		}
-		return fmt.Errorf("failed to decrypt filenames: %w", err)
-	}
-
-	ipf.UpdateFileInfos(fileInfos, decryptionResults)
-
-	deduplicator := ipf.NewDeduplicator(fileInfos)
-	retained := deduplicator.Run()
-
-	// Sort retained files by their original Index to preserve order
-	sort.Slice(retained, func(i, j int) bool {
-		return retained[i].Index < retained[j].Index
-	})
-
-	stats := deduplicator.GetStats()
-	fmt.Printf("Deduplication: %s\n", stats.String())
-
-	reader.Close()
-
-	if err := createOptimizedIPF(filePath, tempPath, retained); err != nil {
+		os.Remove(tempPath)
 		if createBackup {
 			os.Rename(backupPath, filePath)
-			os.Remove(tempPath)
 		}
-		return fmt.Errorf("failed to create optimized IPF: %w", err)
+		return nil, fmt.Errorf("failed to create optimized IPF: %w", err)
 	}
 
 	finalPath := filePath
@@ -99,23 +115,38 @@ func OptimizeIPF(filePath string, createBackup bool) error {
 			os.Rename(backupPath, filePath)
 			os.Remove(tempPath)
 		}
-		return fmt.Errorf("failed to rename temp file: %w", err)
+		return nil, fmt.Errorf("failed to rename temp file: %w", err)
 	}
 
 	if createBackup {
 		os.Remove(backupPath)
 	}
 
-	return nil
+	return report, nil
 }
 
-func createOptimizedIPF(originalIPFPath, outputPath string, retained []ipf.FileInfo) error {
+// createOptimizedIPF writes the retained entries of the original archive to outputPath and
+// returns the number of entries whose sizes were repaired from their data descriptors
+func createOptimizedIPF(originalIPFPath, outputPath string, retained []ipf.FileInfo, opts OptimizeOptions) (int, error) {
 	originalFile, err := os.Open(originalIPFPath)
 	if err != nil {
-		return fmt.Errorf("failed to open original file: %w", err)
+		return 0, fmt.Errorf("failed to open original file: %w", err)
 	}
 	defer originalFile.Close()
 
+	repaired, err := repairDescriptorSizes(originalFile, retained)
+	if err != nil {
+		return 0, fmt.Errorf("failed to repair data descriptor sizes: %w", err)
+	}
+
+	return repaired, writeRetainedIPF(outputPath, retained, func(int) io.ReadSeeker {
+		return originalFile
+	}, opts)
+}
+
+// writeRetainedIPF writes the retained entries to outputPath, copying each entry's raw
+// compressed data from the archive returned by source for its position in retained
+func writeRetainedIPF(outputPath string, retained []ipf.FileInfo, source func(i int) io.ReadSeeker, opts OptimizeOptions) error {
 	outputFile, err := os.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
@@ -129,20 +160,26 @@ func createOptimizedIPF(originalIPFPath, outputPath string, retained []ipf.FileI
 		file := &retained[i]
 		localHeaderOffsets[i] = currentOffset
 
-		if err := zipwriter.WriteLocalFileHeaderFromIPF(outputFile, file, 0x0009); err != nil {
+		_, _, localGenPurpose := opts.headerFields(file)
+		if err := zipwriter.WriteLocalFileHeaderFromIPF(outputFile, file, localGenPurpose); err != nil {
 			return fmt.Errorf("failed to write local header for file %d: %w", i, err)
 		}
 
+		originalFile := source(i)
 		dataOffset := int64(file.LocalHeaderOffset) + int64(file.HeaderSize)
 		if _, err := originalFile.Seek(dataOffset, io.SeekStart); err != nil {
 			return fmt.Errorf("failed to seek to data offset for file %d: %w", i, err)
 		}
 
-		if err := copyCompressedData(outputFile, originalFile, file.ZipInfo.CompressedSize64); err != nil {
+		if opts.Scrub {
+			if err := copyAndScrub(outputFile, originalFile, file, opts.password()); err != nil {
+				return fmt.Errorf("scrub failed for file %d (%s): %w", i, file.SafeFilename, err)
+			}
+		} else if err := copyCompressedData(outputFile, originalFile, file.ZipInfo.CompressedSize64); err != nil {
 			return fmt.Errorf("failed to copy compressed data for file %d: %w", i, err)
 		}
 
-		currentOffset += uint64(file.HeaderSize) + file.ZipInfo.CompressedSize64
+		currentOffset += zipwriter.LocalHeaderSize(file) + file.ZipInfo.CompressedSize64
 	}
 
 	cdOffset := currentOffset
@@ -151,16 +188,22 @@ func createOptimizedIPF(originalIPFPath, outputPath string, retained []ipf.FileI
 		file := &retained[i]
 		localHeaderOffset := localHeaderOffsets[i]
 
-		if err := zipwriter.WriteCentralDirectoryEntryFromIPF(outputFile, file, localHeaderOffset, 0x0014, 0x0009); err != nil {
+		versionMadeBy, genPurpose, _ := opts.headerFields(file)
+		if err := zipwriter.WriteCentralDirectoryEntryFromIPF(outputFile, file, localHeaderOffset, versionMadeBy, genPurpose); err != nil {
 			return fmt.Errorf("failed to write central directory entry for file %d: %w", i, err)
 		}
 
-		currentOffset += 46 + uint64(file.EncryptedNameLen) + uint64(file.ExtraLen)
+		currentOffset += zipwriter.CentralDirectoryEntrySize(file, localHeaderOffset)
 	}
 
 	cdSize := currentOffset - cdOffset
 
-	if err := zipwriter.WriteEndOfCentralDirectory(outputFile, cdOffset, cdSize, uint16(len(retained))); err != nil {
+	var comment string
+	if opts.Comment != nil {
+		comment = *opts.Comment
+	}
+
+	if err := zipwriter.WriteEndOfCentralDirectory64(outputFile, cdOffset, cdSize, len(retained), comment); err != nil {
 		return fmt.Errorf("failed to write end of central directory: %w", err)
 	}
 
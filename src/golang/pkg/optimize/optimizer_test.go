@@ -0,0 +1,84 @@
+package optimize
+
+import (
+	"archive/zip"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+var testPassword = zipcipher.GetIPFPassword()
+
+// readEntries returns the decrypted contents of every entry of the archive at path by name
+func readEntries(t *testing.T, path string) map[string]string {
+	t.Helper()
+
+	reader, err := ipf.NewIPFReader(path)
+	if err != nil {
+		t.Fatalf("NewIPFReader: %v", err)
+	}
+	defer reader.Close()
+
+	entries, err := reader.ListFiles(testPassword)
+	if err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+	contents := make(map[string]string, len(entries))
+	for i, entry := range entries {
+		if _, ok := contents[entry.Name]; ok {
+			t.Errorf("%s is stored twice", entry.Name)
+		}
+		data, err := reader.ReadEntry(i, testPassword)
+		if err != nil {
+			t.Fatalf("ReadEntry(%d): %v", i, err)
+		}
+		contents[entry.Name] = string(data)
+	}
+	return contents
+}
+
+func TestOptimizeShuffledArchive(t *testing.T) {
+	archive := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "a.xml", Data: []byte("<a/>"), Method: zip.Deflate},
+		ipftest.Entry{Name: "b.txt", Data: []byte("b")},
+		ipftest.Entry{Name: "c.txt", Data: []byte("descriptor c"), Method: zip.Deflate, Descriptor: true},
+		ipftest.Entry{Name: "a.xml", Data: []byte("<a>patched</a>"), Method: zip.Deflate},
+	)
+
+	tests := []struct {
+		name  string
+		order []int
+		want  map[string]string
+	}{
+		{
+			name:  "reversed",
+			order: []int{3, 2, 1, 0},
+			want:  map[string]string{"a.xml": "<a/>", "b.txt": "b", "c.txt": "descriptor c"},
+		},
+		{
+			name:  "interleaved",
+			order: []int{2, 0, 3, 1},
+			want:  map[string]string{"a.xml": "<a>patched</a>", "b.txt": "b", "c.txt": "descriptor c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ipftest.WriteFile(t, t.TempDir(), "shuffled.ipf", ipftest.ReorderCentralDirectory(t, archive, tt.order))
+			if err := OptimizeIPFWithOptions(path, OptimizeOptions{}); err != nil {
+				t.Fatalf("OptimizeIPFWithOptions: %v", err)
+			}
+
+			got := readEntries(t, path)
+			if len(got) != len(tt.want) {
+				t.Errorf("optimized archive has %d entries, want %d", len(got), len(tt.want))
+			}
+			for name, content := range tt.want {
+				if got[name] != content {
+					t.Errorf("%s = %q, want %q", name, got[name], content)
+				}
+			}
+		})
+	}
+}
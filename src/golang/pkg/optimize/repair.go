@@ -0,0 +1,135 @@
+package optimize
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// Signatures that may follow an entry's compressed data
+var (
+	localHeaderSig = []byte{0x50, 0x4b, 0x03, 0x04}
+	centralDirSig  = []byte{0x50, 0x4b, 0x01, 0x02}
+)
+
+// scanChunkSize is the window used when scanning entry data for a data descriptor
+const scanChunkSize = 64 * 1024
+
+// repairDescriptorSizes fixes the sizes and CRC of retained entries that use data descriptors
+// (general purpose bit 3) but whose recorded compressed size does not point at a valid descriptor,
+// as produced by some buggy packers. The true values are recovered by scanning the entry data for
+// its data descriptor. Returns the number of repaired entries.
+func repairDescriptorSizes(file *os.File, retained []ipf.FileInfo) (int, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get file size: %w", err)
+	}
+
+	repaired := 0
+	for i := range retained {
+		entry := &retained[i]
+		if entry.GenPurpose&0x8 == 0 || entry.ZipInfo == nil {
+			continue
+		}
+
+		// ZIP64 entries carry 8-byte sizes in their descriptor
+		zip64 := zipcipher.HasZip64Extra(entry.ExtraField)
+
+		dataStart := entry.LocalHeaderOffset + int64(entry.HeaderSize)
+		if entry.ZipInfo.CompressedSize64 != 0 && descriptorMatches(file, dataStart, entry.ZipInfo.CompressedSize64, zip64) {
+			continue
+		}
+
+		descriptor, found, err := findDataDescriptor(file, dataStart, stat.Size(), zip64)
+		if err != nil {
+			return repaired, fmt.Errorf("failed to scan file %d for data descriptor: %w", entry.Index, err)
+		}
+		if !found {
+			// Leave the recorded sizes alone; scrubbing or extraction will surface real damage
+			continue
+		}
+
+		// Never mutate the reader's zip.File; retained entries get their own copy
+		zipInfo := *entry.ZipInfo
+		zipInfo.CRC32 = descriptor.CRC32
+		zipInfo.CompressedSize64 = descriptor.CompressedSize
+		zipInfo.UncompressedSize64 = descriptor.UncompressedSize
+		entry.ZipInfo = &zipInfo
+		repaired++
+	}
+
+	return repaired, nil
+}
+
+// descriptorMatches reports whether a data descriptor consistent with compressedSize follows the data
+func descriptorMatches(r io.ReaderAt, dataStart int64, compressedSize uint64, zip64 bool) bool {
+	buf := make([]byte, 4+zipcipher.DataDescriptorSize(zip64))
+	n, _ := r.ReadAt(buf, dataStart+int64(compressedSize))
+	buf = buf[:n]
+
+	// Packers that set bit 3 but omit the descriptor go straight to the next header
+	if len(buf) >= 4 && (bytes.Equal(buf[0:4], localHeaderSig) || bytes.Equal(buf[0:4], centralDirSig)) {
+		return true
+	}
+	if zipcipher.IsDataDescriptorSignature(buf) {
+		buf = buf[4:]
+	}
+
+	descriptor, err := zipcipher.ParseDataDescriptor(buf, zip64)
+	return err == nil && descriptor.CompressedSize == compressedSize
+}
+
+// findDataDescriptor scans forward from dataStart for a data descriptor whose compressed size
+// equals its distance from dataStart. Descriptors may carry the optional signature; unsigned
+// descriptors are found through the local header or central directory signature following them.
+func findDataDescriptor(r io.ReaderAt, dataStart, limit int64, zip64 bool) (zipcipher.DataDescriptor, bool, error) {
+	descriptorSize := zipcipher.DataDescriptorSize(zip64)
+	overlap := 4 + descriptorSize
+	buf := make([]byte, scanChunkSize)
+
+	for pos := dataStart; pos < limit; pos += int64(scanChunkSize - overlap) {
+		n, err := r.ReadAt(buf, pos)
+		if err != nil && err != io.EOF {
+			return zipcipher.DataDescriptor{}, false, err
+		}
+		chunk := buf[:n]
+
+		for i := 0; i+4 <= len(chunk); i++ {
+			abs := pos + int64(i)
+
+			switch {
+			case zipcipher.IsDataDescriptorSignature(chunk[i:]):
+				if i+overlap > len(chunk) {
+					continue // Rescanned in the next chunk thanks to the overlap
+				}
+				descriptor, err := zipcipher.ParseDataDescriptor(chunk[i+4:], zip64)
+				if err == nil && int64(descriptor.CompressedSize) == abs-dataStart {
+					return descriptor, true, nil
+				}
+			case bytes.Equal(chunk[i:i+4], localHeaderSig), bytes.Equal(chunk[i:i+4], centralDirSig):
+				descriptorStart := abs - int64(descriptorSize)
+				if descriptorStart < dataStart {
+					continue
+				}
+				fields := make([]byte, descriptorSize)
+				if _, err := r.ReadAt(fields, descriptorStart); err != nil {
+					return zipcipher.DataDescriptor{}, false, err
+				}
+				descriptor, err := zipcipher.ParseDataDescriptor(fields, zip64)
+				if err == nil && int64(descriptor.CompressedSize) == descriptorStart-dataStart {
+					return descriptor, true, nil
+				}
+			}
+		}
+
+		if n < len(buf) {
+			break
+		}
+	}
+
+	return zipcipher.DataDescriptor{}, false, nil
+}
@@ -0,0 +1,80 @@
+package optimize
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+)
+
+func TestRepairDescriptorSizes(t *testing.T) {
+	data := bytes.Repeat([]byte("descriptor entry "), 30)
+
+	tests := []struct {
+		name         string
+		zero         []int // Offsets within the central directory record of the 32-bit fields to zero
+		wantRepaired int
+	}{
+		{"sizes recorded", nil, 0},
+		{"zero compressed size", []int{20}, 1},
+		{"zero crc and sizes", []int{16, 20, 24}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := ipftest.Build(t, testPassword,
+				ipftest.Entry{Name: "before.txt", Data: []byte("before")},
+				ipftest.Entry{Name: "streamed.txt", Data: data, Method: zip.Deflate, Descriptor: true},
+				ipftest.Entry{Name: "after.txt", Data: []byte("after")},
+			)
+			record := ipftest.CentralRecords(t, archive)[1]
+			for _, offset := range tt.zero {
+				binary.LittleEndian.PutUint32(record[offset:], 0)
+			}
+			path := ipftest.WriteFile(t, t.TempDir(), "test.ipf", archive)
+
+			reader, err := ipf.NewIPFReader(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := reader.ListFiles(testPassword); err != nil {
+				t.Fatal(err)
+			}
+			retained := append([]ipf.FileInfo(nil), reader.FileInfos...)
+			repaired, err := repairDescriptorSizes(reader.File, retained)
+			reader.Close()
+			if err != nil {
+				t.Fatalf("repairDescriptorSizes: %v", err)
+			}
+			if repaired != tt.wantRepaired {
+				t.Errorf("repaired %d entries, want %d", repaired, tt.wantRepaired)
+			}
+			if got := retained[1].ZipInfo.UncompressedSize64; got != uint64(len(data)) {
+				t.Errorf("uncompressed size = %d, want %d", got, len(data))
+			}
+
+			report, err := OptimizeIPFWithReport(path, OptimizeOptions{Scrub: true})
+			if err != nil {
+				t.Fatalf("OptimizeIPFWithReport: %v", err)
+			}
+			if report.RepairedEntries != tt.wantRepaired {
+				t.Errorf("report counts %d repaired entries, want %d", report.RepairedEntries, tt.wantRepaired)
+			}
+			got := readEntries(t, path)
+			if got["streamed.txt"] != string(data) || got["before.txt"] != "before" || got["after.txt"] != "after" {
+				t.Errorf("optimized archive holds %v", got)
+			}
+
+			optimized, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := binary.LittleEndian.Uint32(ipftest.CentralRecords(t, optimized)[1][24:]); size != uint32(len(data)) {
+				t.Errorf("optimized central directory records %d bytes, want %d", size, len(data))
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package optimize
+
+import (
+	"io"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+)
+
+// copyAndScrub copies an entry's compressed data like copyCompressedData while teeing it
+// through decryption, decompression and CRC32 verification
+func copyAndScrub(dst io.Writer, src io.Reader, file *ipf.FileInfo, password []byte) error {
+	verifier := ipf.NewEntryVerifier(file, password)
+
+	copyErr := copyCompressedData(io.MultiWriter(dst, verifier), src, file.ZipInfo.CompressedSize64)
+	verifyErr := verifier.Close()
+
+	if copyErr != nil {
+		return copyErr
+	}
+	return verifyErr
+}
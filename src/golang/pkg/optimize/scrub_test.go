@@ -0,0 +1,68 @@
+package optimize
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+)
+
+func TestOptimizeScrub(t *testing.T) {
+	entries := []ipftest.Entry{
+		{Name: "stored.txt", Data: []byte("stored content that gets corrupted")},
+		{Name: "deflated.txt", Data: bytes.Repeat([]byte("deflated content "), 50), Method: zip.Deflate},
+	}
+
+	tests := []struct {
+		name    string
+		corrupt int // Entry whose data gets a flipped byte, or -1
+		scrub   bool
+		wantErr error
+	}{
+		{"intact with scrub", -1, true, nil},
+		{"corrupt stored entry", 0, true, ipf.ErrCRCMismatch},
+		{"corrupt entry without scrub", 0, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := ipftest.Build(t, testPassword, entries...)
+			if tt.corrupt >= 0 {
+				archive[ipftest.DataOffset(t, archive, tt.corrupt)+12+5] ^= 0x20
+			}
+			path := ipftest.WriteFile(t, t.TempDir(), "test.ipf", archive)
+
+			err := OptimizeIPFWithOptions(path, OptimizeOptions{Scrub: tt.scrub})
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("OptimizeIPFWithOptions: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("OptimizeIPFWithOptions error = %v, want %v", err, tt.wantErr)
+			}
+
+			// A failed scrub leaves the original archive in place
+			if got, err := os.ReadFile(path); err != nil || !bytes.Equal(got, archive) {
+				t.Errorf("archive changed after a failed scrub (%v)", err)
+			}
+		})
+	}
+}
+
+func TestOptimizeScrubCorruptDeflate(t *testing.T) {
+	data := bytes.Repeat([]byte("deflated content "), 50)
+	archive := ipftest.Build(t, testPassword, ipftest.Entry{Name: "deflated.txt", Data: data, Method: zip.Deflate})
+	start := ipftest.DataOffset(t, archive, 0)
+	archive[start+12+3] ^= 0xFF
+
+	path := ipftest.WriteFile(t, t.TempDir(), "test.ipf", archive)
+	err := OptimizeIPFWithOptions(path, OptimizeOptions{Scrub: true})
+	if err == nil {
+		t.Fatal("scrub accepted a corrupt deflate stream")
+	}
+}
@@ -0,0 +1,53 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError reports a panic raised by a function run through Map
+type PanicError struct {
+	Value any    // Value passed to panic
+	Stack []byte // Stack of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("worker panicked: %v", e.Value)
+}
+
+// Map applies fn to every item using up to workers goroutines (0 = one per CPU) and returns the
+// results in input order. A panic in fn is recovered, stops further items from starting and is
+// returned as a *PanicError; when ctx ends first its error is returned instead. In both cases
+// results of items that never ran keep the zero value of R.
+func Map[I, R any](ctx context.Context, items []I, workers int, fn func(I) R) ([]R, error) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	mapCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var panicOnce sync.Once
+	var panicErr *PanicError
+
+	processor := NewParallelProcessor[I, R](workers, len(items))
+	results := processor.Process(mapCtx, items, func(item I) (result R) {
+		defer func() {
+			if value := recover(); value != nil {
+				panicOnce.Do(func() {
+					panicErr = &PanicError{Value: value, Stack: debug.Stack()}
+				})
+				cancel()
+			}
+		}()
+		return fn(item)
+	})
+
+	if panicErr != nil {
+		return results, panicErr
+	}
+	return results, ctx.Err()
+}
@@ -0,0 +1,79 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMap(t *testing.T) {
+	items := make([]int, 3000)
+	for i := range items {
+		items[i] = i
+	}
+
+	t.Run("ordering", func(t *testing.T) {
+		for _, workers := range []int{0, 1, 7, 64} {
+			results, err := Map(context.Background(), items, workers, slowSquare)
+			if err != nil {
+				t.Fatalf("Map with %d workers: %v", workers, err)
+			}
+			for i, item := range items {
+				if want := slowSquare(item); results[i] != want {
+					t.Fatalf("Map with %d workers: results[%d] = %s, want %s", workers, i, results[i], want)
+				}
+			}
+		}
+	})
+
+	t.Run("cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var ran atomic.Int64
+		results, err := Map(ctx, items, 4, func(item int) int {
+			if ran.Add(1) == 100 {
+				cancel()
+			}
+			time.Sleep(100 * time.Microsecond)
+			return item + 1
+		})
+
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error = %v, want context.Canceled", err)
+		}
+		if len(results) != len(items) {
+			t.Fatalf("got %d results, want %d", len(results), len(items))
+		}
+		completed := 0
+		for _, result := range results {
+			if result != 0 {
+				completed++
+			}
+		}
+		if completed != int(ran.Load()) || completed >= len(items) {
+			t.Errorf("%d results set for %d items run, want a partial run", completed, ran.Load())
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		results, err := Map(context.Background(), items, 4, func(item int) int {
+			if item == 50 {
+				panic("bad item")
+			}
+			return item + 1
+		})
+
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) {
+			t.Fatalf("error = %v, want a *PanicError", err)
+		}
+		if panicErr.Value != "bad item" || len(panicErr.Stack) == 0 {
+			t.Errorf("PanicError = %v with a %d-byte stack", panicErr.Value, len(panicErr.Stack))
+		}
+		if results[50] != 0 || results[0] != 1 {
+			t.Errorf("results[0] = %d, results[50] = %d, want 1 and 0", results[0], results[50])
+		}
+	})
+}
@@ -2,10 +2,14 @@ package workers
 
 import (
 	"context"
+	"errors"
 	"runtime"
 	"sync"
 )
 
+// ErrPoolStopped is returned by SubmitErr after the WorkerPool is stopped
+var ErrPoolStopped = errors.New("worker pool is stopped")
+
 // Task represents a unit of work to be processed
 type Task[T any] interface {
 	Execute() T
@@ -25,11 +29,25 @@ func NewFunctionTask[T any](fn func() T) *FunctionTask[T] {
 	return &FunctionTask[T]{fn: fn}
 }
 
-// WorkerPool manages a pool of goroutines for concurrent task execution
+// WorkerPool manages a pool of goroutines for concurrent task execution. The value each task
+// returns is sent on the Results channel when one was requested, and dropped otherwise.
 type WorkerPool[T any] struct {
 	workerCount int
 	taskQueue   chan Task[T]
 	wg          sync.WaitGroup
+	ctx         context.Context
+
+	// stopMu keeps Stop from closing the queue while a Submit is sending to it
+	stopMu  sync.RWMutex
+	stopped bool
+
+	// inFlight counts tasks submitted but not yet finished; idle is closed whenever it is zero
+	inFlightMu sync.Mutex
+	inFlight   int
+	idle       chan struct{}
+
+	resultsMu sync.Mutex
+	results   chan T // Created by Results; nil drops results
 }
 
 // NewWorkerPool creates a new worker pool with the specified number of workers
@@ -38,9 +56,14 @@ func NewWorkerPool[T any](workerCount int) *WorkerPool[T] {
 		workerCount = runtime.NumCPU()
 	}
 
+	idle := make(chan struct{})
+	close(idle)
+
 	return &WorkerPool[T]{
 		workerCount: workerCount,
 		taskQueue:   make(chan Task[T], workerCount*2), // Buffer for efficiency
+		ctx:         context.Background(),
+		idle:        idle,
 	}
 }
 
@@ -51,14 +74,20 @@ func NewMaxWorkerPool[T any]() *WorkerPool[T] {
 
 // Start starts the worker pool goroutines
 func (wp *WorkerPool[T]) Start(ctx context.Context) {
+	wp.ctx = ctx
+
+	wp.resultsMu.Lock()
+	results := wp.results
+	wp.resultsMu.Unlock()
+
 	for i := 0; i < wp.workerCount; i++ {
 		wp.wg.Add(1)
-		go wp.worker(ctx)
+		go wp.worker(ctx, results)
 	}
 }
 
-// worker is the individual worker goroutine
-func (wp *WorkerPool[T]) worker(ctx context.Context) {
+// worker is the individual worker goroutine; results is nil when they are dropped
+func (wp *WorkerPool[T]) worker(ctx context.Context, results chan<- T) {
 	defer wp.wg.Done()
 
 	for {
@@ -69,27 +98,140 @@ func (wp *WorkerPool[T]) worker(ctx context.Context) {
 			if !ok {
 				return // Channel closed
 			}
-			task.Execute()
+			result := task.Execute()
+			wp.finished()
+
+			if results != nil {
+				select {
+				case results <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
 		}
 	}
 }
 
-// Submit submits a task to the worker pool
+// Results returns a channel receiving the value of every task executed from now on, in
+// completion order. It must be called before Start. The channel holds as many values as the task
+// queue; once it is full workers wait for the caller to receive, so results must be read while
+// tasks run rather than after Stop. It is closed when Stop returns.
+func (wp *WorkerPool[T]) Results() <-chan T {
+	wp.resultsMu.Lock()
+	defer wp.resultsMu.Unlock()
+
+	if wp.results == nil {
+		wp.results = make(chan T, cap(wp.taskQueue))
+	}
+	return wp.results
+}
+
+// Submit submits a task to the worker pool, blocking while the queue is full. Tasks submitted
+// after Stop, or once the context given to Start is done, are dropped; use SubmitErr to find out.
 func (wp *WorkerPool[T]) Submit(task Task[T]) {
-	wp.taskQueue <- task
+	_ = wp.SubmitErr(task)
 }
 
-// SubmitBatch submits multiple tasks efficiently
+// SubmitBatch submits multiple tasks efficiently, dropping them like Submit once the pool stops
 func (wp *WorkerPool[T]) SubmitBatch(tasks []Task[T]) {
+	_ = wp.SubmitBatchErr(tasks)
+}
+
+// SubmitErr is Submit reporting rejected tasks: it returns ErrPoolStopped after Stop, and the
+// context's error once the context given to Start is done.
+func (wp *WorkerPool[T]) SubmitErr(task Task[T]) error {
+	wp.stopMu.RLock()
+	defer wp.stopMu.RUnlock()
+	if wp.stopped {
+		return ErrPoolStopped
+	}
+	// Checked first since select picks at random when the queue also has room
+	if err := wp.ctx.Err(); err != nil {
+		return err
+	}
+
+	wp.submitted()
+	select {
+	case wp.taskQueue <- task:
+		return nil
+	case <-wp.ctx.Done():
+		wp.finished()
+		return wp.ctx.Err()
+	}
+}
+
+// SubmitBatchErr submits multiple tasks, stopping at the first one SubmitErr rejects
+func (wp *WorkerPool[T]) SubmitBatchErr(tasks []Task[T]) error {
 	for _, task := range tasks {
-		wp.taskQueue <- task
+		if err := wp.SubmitErr(task); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-// Stop gracefully shuts down the worker pool
+// Stop gracefully shuts down the worker pool, waiting for the queued tasks to finish, then
+// closes the Results channel. Calling it again has no effect.
 func (wp *WorkerPool[T]) Stop() {
-	close(wp.taskQueue)
+	wp.stopMu.Lock()
+	stopping := !wp.stopped
+	if stopping {
+		wp.stopped = true
+		close(wp.taskQueue)
+	}
+	wp.stopMu.Unlock()
 	wp.wg.Wait()
+
+	if stopping {
+		wp.resultsMu.Lock()
+		if wp.results != nil {
+			close(wp.results)
+		}
+		wp.resultsMu.Unlock()
+	}
+}
+
+// InFlight returns the number of tasks submitted but not yet finished, queued or running
+func (wp *WorkerPool[T]) InFlight() int {
+	wp.inFlightMu.Lock()
+	defer wp.inFlightMu.Unlock()
+	return wp.inFlight
+}
+
+// Drain waits until every submitted task has finished without closing the queue, so more tasks
+// can be submitted afterwards. It returns ctx's error if ctx is done first. Tasks still queued
+// when the pool's own context was cancelled never run, so Drain then waits for ctx.
+func (wp *WorkerPool[T]) Drain(ctx context.Context) error {
+	wp.inFlightMu.Lock()
+	idle := wp.idle
+	wp.inFlightMu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// submitted counts a new task as in flight
+func (wp *WorkerPool[T]) submitted() {
+	wp.inFlightMu.Lock()
+	defer wp.inFlightMu.Unlock()
+	if wp.inFlight == 0 {
+		wp.idle = make(chan struct{})
+	}
+	wp.inFlight++
+}
+
+// finished counts a task as done, waking Drain when none are left
+func (wp *WorkerPool[T]) finished() {
+	wp.inFlightMu.Lock()
+	defer wp.inFlightMu.Unlock()
+	wp.inFlight--
+	if wp.inFlight == 0 {
+		close(wp.idle)
+	}
 }
 
 // WorkerCount returns the number of workers in the pool
@@ -109,71 +251,101 @@ func NewParallelProcessor[I, R any](workerCount int, itemCount int) *ParallelPro
 	}
 }
 
-// Process processes all items in parallel using the provided function
+// Process processes all items in parallel using the provided function.
+// results[i] always holds the result for items[i], regardless of completion order.
+// Once ctx is done no further items are started; their results keep the zero value of R.
 func (pp *ParallelProcessor[I, R]) Process(ctx context.Context, items []I, processFunc func(I) R) []R {
-	if len(items) == 0 {
+	return processIndexed(ctx, pp.workerCount, len(items), func(index int) R {
+		return processFunc(items[index])
+	})
+}
+
+// ProcessWithError is Process for functions that can fail. It returns the results along with
+// the errors of every failed item joined in input order, followed by ctx's error when
+// cancellation stopped items from starting; nil when everything succeeded.
+func (pp *ParallelProcessor[I, R]) ProcessWithError(ctx context.Context, items []I, processFunc func(I) (R, error)) ([]R, error) {
+	errs := make([]error, len(items))
+	results := processIndexed(ctx, pp.workerCount, len(items), func(index int) R {
+		result, err := processFunc(items[index])
+		errs[index] = err
+		return result
+	})
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return results, errors.Join(errs...)
+}
+
+// processIndexed runs fn for indices 0..count-1 on a fixed pool of at most workerCount goroutines.
+// Each worker writes into its index's slot, so results keep input order.
+func processIndexed[R any](ctx context.Context, workerCount int, count int, fn func(index int) R) []R {
+	if count == 0 {
 		return []R{}
 	}
+	if workerCount <= 0 {
+		workerCount = runtime.NumCPU()
+	}
+	if workerCount > count {
+		workerCount = count
+	}
 
-	results := make([]R, len(items))
+	results := make([]R, count)
+	indices := make(chan int)
 	var wg sync.WaitGroup
 
-	// Create semaphore to limit concurrent workers
-	semaphore := make(chan struct{}, pp.workerCount)
-
-	for i, item := range items {
+	for w := 0; w < workerCount; w++ {
 		wg.Add(1)
-		go func(index int, itm I) {
+		go func() {
 			defer wg.Done()
+			for index := range indices {
+				results[index] = fn(index)
+			}
+		}()
+	}
 
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			// Process item
-			results[index] = processFunc(itm)
-		}(i, item)
+	// Indices are handed out only to idle workers, so cancellation stops new items promptly
+feed:
+	for i := 0; i < count; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case indices <- i:
+		case <-ctx.Done():
+			break feed
+		}
 	}
+	close(indices)
 
 	wg.Wait()
 	return results
 }
 
-// ProcessBatch processes items in batches for better memory management
+// ProcessBatch processes items in batches for better memory management.
+// Batches run one after another with at most workerCount items in flight; like Process,
+// results[i] always holds the result for items[i].
 func (pp *ParallelProcessor[I, R]) ProcessBatch(ctx context.Context, items []I, processFunc func(I) R, batchSize int) []R {
 	if batchSize <= 0 {
 		batchSize = len(items)
 	}
 
-	var mu sync.Mutex
 	results := make([]R, 0, len(items))
 
-	// Process in batches to control memory usage
+	// Process in batches to control memory usage; each batch writes its results by index
+	// and batches are appended in input order, so ordering is preserved end to end
 	for i := 0; i < len(items); i += batchSize {
 		end := i + batchSize
 		if end > len(items) {
 			end = len(items)
 		}
 
-		batch := items[i:end]
-		batchResults := make([]R, len(batch))
-
-		// Process batch
-		var batchWg sync.WaitGroup
-		for j, item := range batch {
-			batchWg.Add(1)
-			go func(index int, itm I) {
-				defer batchWg.Done()
-				batchResults[index] = processFunc(itm)
-			}(j, item)
+		if ctx.Err() != nil {
+			// Remaining batches never start; keep one zero result per item
+			results = append(results, make([]R, len(items)-i)...)
+			break
 		}
-
-		batchWg.Wait()
-
-		// Add batch results to final results
-		mu.Lock()
-		results = append(results, batchResults...)
-		mu.Unlock()
+		results = append(results, pp.Process(ctx, items[i:end], processFunc)...)
 	}
 
 	return results
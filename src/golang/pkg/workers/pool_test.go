@@ -0,0 +1,364 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowSquare squares n after yielding a varying number of times, so items finish out of order
+func slowSquare(n int) string {
+	for i := 0; i < n%7; i++ {
+		runtime.Gosched()
+	}
+	return fmt.Sprint(n * n)
+}
+
+func TestParallelProcessorOrder(t *testing.T) {
+	items := make([]int, 5000)
+	for i := range items {
+		items[i] = len(items) - i
+	}
+
+	tests := []struct {
+		name      string
+		workers   int
+		batchSize int // 0 uses Process
+	}{
+		{"process", 16, 0},
+		{"process single worker", 1, 0},
+		{"batches", 16, 64},
+		{"uneven batches", 7, 333},
+		{"one batch", 32, len(items)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := NewParallelProcessor[int, string](tt.workers, len(items))
+			var results []string
+			if tt.batchSize == 0 {
+				results = processor.Process(context.Background(), items, slowSquare)
+			} else {
+				results = processor.ProcessBatch(context.Background(), items, slowSquare, tt.batchSize)
+			}
+
+			if len(results) != len(items) {
+				t.Fatalf("got %d results, want %d", len(results), len(items))
+			}
+			for i, item := range items {
+				if want := fmt.Sprint(item * item); results[i] != want {
+					t.Fatalf("results[%d] = %s, want %s (the result of items[%d] = %d)", i, results[i], want, i, item)
+				}
+			}
+		})
+	}
+}
+
+func TestParallelProcessorCancel(t *testing.T) {
+	const cancelAfter, workers = 20, 4
+
+	tests := []struct {
+		name    string
+		process func(ctx context.Context, items []int, fn func(int) (int, error)) ([]int, error)
+	}{
+		{"process", func(ctx context.Context, items []int, fn func(int) (int, error)) ([]int, error) {
+			return NewParallelProcessor[int, int](workers, len(items)).Process(ctx, items, func(n int) int {
+				result, _ := fn(n)
+				return result
+			}), ctx.Err()
+		}},
+		{"process with error", func(ctx context.Context, items []int, fn func(int) (int, error)) ([]int, error) {
+			return NewParallelProcessor[int, int](workers, len(items)).ProcessWithError(ctx, items, fn)
+		}},
+		{"batches", func(ctx context.Context, items []int, fn func(int) (int, error)) ([]int, error) {
+			return NewParallelProcessor[int, int](workers, len(items)).ProcessBatch(ctx, items, func(n int) int {
+				result, _ := fn(n)
+				return result
+			}, 8), ctx.Err()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := make([]int, 10000)
+			for i := range items {
+				items[i] = i + 1
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var started atomic.Int32
+			results, err := tt.process(ctx, items, func(n int) (int, error) {
+				if started.Add(1) == cancelAfter {
+					cancel()
+				}
+				time.Sleep(100 * time.Microsecond)
+				return n, nil
+			})
+
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("error = %v, want context.Canceled", err)
+			}
+			if len(results) != len(items) {
+				t.Fatalf("got %d results, want %d", len(results), len(items))
+			}
+			// Items already handed to a worker finish; nothing new starts after the cancel
+			if got := started.Load(); got > cancelAfter+workers {
+				t.Errorf("%d items started, want at most %d after cancelling", got, cancelAfter+workers)
+			}
+			done := 0
+			for i, result := range results {
+				if result != 0 && result != items[i] {
+					t.Errorf("results[%d] = %d, want %d or zero", i, result, items[i])
+				}
+				if result != 0 {
+					done++
+				}
+			}
+			if done != int(started.Load()) {
+				t.Errorf("%d results filled in, %d items started", done, started.Load())
+			}
+		})
+	}
+}
+
+func TestProcessWithError(t *testing.T) {
+	errOdd := errors.New("odd item")
+	items := []int{1, 2, 3, 4, 5, 6}
+	results, err := NewParallelProcessor[int, int](3, len(items)).ProcessWithError(context.Background(), items, func(n int) (int, error) {
+		if n%2 == 1 {
+			return 0, fmt.Errorf("item %d: %w", n, errOdd)
+		}
+		return n * 10, nil
+	})
+
+	if !errors.Is(err, errOdd) {
+		t.Fatalf("error = %v, want errOdd", err)
+	}
+	if want := "item 1: odd item\nitem 3: odd item\nitem 5: odd item"; err.Error() != want {
+		t.Errorf("error = %q, want errors joined in input order %q", err, want)
+	}
+	if want := []int{0, 20, 0, 40, 0, 60}; !slices.Equal(results, want) {
+		t.Errorf("results = %v, want %v", results, want)
+	}
+}
+
+// processPerItem is the previous design of Process, one goroutine per item limited by a
+// semaphore, kept to compare goroutine counts against
+func processPerItem[I, R any](workerCount int, items []I, processFunc func(I) R) []R {
+	results := make([]R, len(items))
+	semaphore := make(chan struct{}, workerCount)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		go func(index int, item I) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			results[index] = processFunc(item)
+		}(i, item)
+	}
+	wg.Wait()
+	return results
+}
+
+// goroutinePeak wraps fn to record the most goroutines seen while it runs
+func goroutinePeak(fn func(int) int) (func(int) int, *atomic.Int64) {
+	var peak atomic.Int64
+	return func(n int) int {
+		count := int64(runtime.NumGoroutine())
+		for {
+			max := peak.Load()
+			if count <= max || peak.CompareAndSwap(max, count) {
+				break
+			}
+		}
+		return fn(n)
+	}, &peak
+}
+
+func TestProcessGoroutineBound(t *testing.T) {
+	const workers = 8
+	items := make([]int, 20000)
+	baseline := runtime.NumGoroutine()
+
+	fn, peak := goroutinePeak(func(n int) int { return n })
+	NewParallelProcessor[int, int](workers, len(items)).Process(context.Background(), items, fn)
+
+	if got := peak.Load() - int64(baseline); got > workers {
+		t.Errorf("Process ran %d extra goroutines for %d items, want at most %d", got, len(items), workers)
+	}
+}
+
+func BenchmarkProcessGoroutines(b *testing.B) {
+	const workers = 8
+	items := make([]int, 20000)
+
+	designs := []struct {
+		name    string
+		process func(fn func(int) int)
+	}{
+		{"per item", func(fn func(int) int) { processPerItem(workers, items, fn) }},
+		{"worker pool", func(fn func(int) int) {
+			NewParallelProcessor[int, int](workers, len(items)).Process(context.Background(), items, fn)
+		}},
+	}
+	for _, design := range designs {
+		b.Run(design.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var peak int64
+			for i := 0; i < b.N; i++ {
+				fn, p := goroutinePeak(func(n int) int { return n })
+				design.process(fn)
+				peak = max(peak, p.Load())
+			}
+			b.ReportMetric(float64(peak), "peak-goroutines")
+		})
+	}
+}
+
+func TestWorkerPoolResults(t *testing.T) {
+	const tasks = 1000
+
+	tests := []struct {
+		name    string
+		workers int
+		collect bool // Request the Results channel
+	}{
+		{"single worker", 1, true},
+		{"several workers", 4, true},
+		{"more workers than queue", 64, true},
+		{"results dropped", 4, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewWorkerPool[int](tt.workers)
+			var results <-chan int
+			if tt.collect {
+				results = pool.Results()
+			}
+			pool.Start(context.Background())
+
+			var executed atomic.Int64
+			submitAll := func() {
+				for i := 1; i <= tasks; i++ {
+					n := i
+					pool.Submit(NewFunctionTask(func() int {
+						executed.Add(1)
+						return n
+					}))
+				}
+				pool.Stop()
+			}
+
+			if !tt.collect {
+				// Without a reader the results go nowhere, so workers never block on them
+				submitAll()
+				if got := executed.Load(); got != tasks {
+					t.Errorf("executed %d tasks, want %d", got, tasks)
+				}
+				return
+			}
+
+			// Results fill up as fast as the queue does, so they are read while tasks are submitted
+			go submitAll()
+			sum, count := 0, 0
+			for result := range results {
+				sum += result
+				count++
+			}
+			if count != tasks {
+				t.Errorf("received %d results, want %d", count, tasks)
+			}
+			if want := tasks * (tasks + 1) / 2; sum != want {
+				t.Errorf("results sum to %d, want %d", sum, want)
+			}
+		})
+	}
+}
+
+func TestWorkerPoolSubmitAfterShutdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		shutdown func(pool *WorkerPool[int], cancel context.CancelFunc)
+		wantErr  error
+	}{
+		{"stopped", func(pool *WorkerPool[int], _ context.CancelFunc) { pool.Stop() }, ErrPoolStopped},
+		{"stopped twice", func(pool *WorkerPool[int], _ context.CancelFunc) { pool.Stop(); pool.Stop() }, ErrPoolStopped},
+		{"context cancelled", func(_ *WorkerPool[int], cancel context.CancelFunc) { cancel() }, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			pool := NewWorkerPool[int](2)
+			pool.Start(ctx)
+			tt.shutdown(pool, cancel)
+
+			var ran atomic.Bool
+			task := NewFunctionTask(func() int { ran.Store(true); return 1 })
+
+			if err := pool.SubmitErr(task); !errors.Is(err, tt.wantErr) {
+				t.Errorf("SubmitErr error = %v, want %v", err, tt.wantErr)
+			}
+			if err := pool.SubmitBatchErr([]Task[int]{task, task}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("SubmitBatchErr error = %v, want %v", err, tt.wantErr)
+			}
+			pool.Submit(task) // Dropped rather than panicking on the closed queue
+			pool.SubmitBatch([]Task[int]{task})
+
+			pool.Stop()
+			if ran.Load() {
+				t.Errorf("a task submitted after shutdown ran")
+			}
+			if got := pool.InFlight(); got != 0 {
+				t.Errorf("InFlight = %d after rejected submits, want 0", got)
+			}
+		})
+	}
+}
+
+func TestWorkerPoolDrain(t *testing.T) {
+	const workers, tasks = 2, 6
+	pool := NewWorkerPool[int](workers)
+	pool.Start(context.Background())
+	defer pool.Stop()
+
+	release := make(chan struct{})
+	blocked := func() int { <-release; return 0 }
+	for i := 0; i < tasks; i++ {
+		if err := pool.SubmitErr(NewFunctionTask(blocked)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := pool.InFlight(); got != tasks {
+		t.Errorf("InFlight = %d with every task blocked, want %d", got, tasks)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain with blocked tasks = %v, want context.DeadlineExceeded", err)
+	}
+
+	close(release)
+	if err := pool.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if got := pool.InFlight(); got != 0 {
+		t.Errorf("InFlight = %d after Drain, want 0", got)
+	}
+
+	// Drain leaves the queue open
+	var ran atomic.Bool
+	if err := pool.SubmitErr(NewFunctionTask(func() int { ran.Store(true); return 0 })); err != nil {
+		t.Fatalf("SubmitErr after Drain: %v", err)
+	}
+	if err := pool.Drain(context.Background()); err != nil || !ran.Load() {
+		t.Errorf("task submitted after Drain ran = %t, Drain error %v", ran.Load(), err)
+	}
+}
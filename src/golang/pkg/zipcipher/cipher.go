@@ -1,7 +1,14 @@
 package zipcipher
 
 import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"unicode"
 	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
 )
 
 // ZipCipher implements the PKZIP stream cipher for filename decryption
@@ -51,6 +58,45 @@ func (z *ZipCipher) DecryptData(encryptedData []byte) []byte {
 	return decrypted
 }
 
+// EncryptByte encrypts a single byte and advances the cipher state. Encryption uses the same
+// keystream as decryption but updates the keys with the plaintext byte, which is the input here.
+func (z *ZipCipher) EncryptByte(byteVal byte) byte {
+	encrypted := z.DecryptByte(byteVal)
+	z.UpdateCipher(byteVal)
+	return encrypted
+}
+
+// EncryptData encrypts a byte slice using the PKZIP stream cipher
+func (z *ZipCipher) EncryptData(plaintext []byte) []byte {
+	if len(plaintext) == 0 {
+		return []byte{}
+	}
+
+	encrypted := make([]byte, len(plaintext))
+	for i, byteVal := range plaintext {
+		encrypted[i] = z.EncryptByte(byteVal)
+	}
+	return encrypted
+}
+
+// EncryptPayload encrypts data with the PKZIP stream cipher behind a 12-byte encryption header
+// whose last byte is checkByte, conventionally the high byte of the entry's MS-DOS time
+func EncryptPayload(data []byte, password []byte, checkByte byte) ([]byte, error) {
+	cipher := &ZipCipher{}
+	cipher.InitKeys(password)
+
+	header := make([]byte, 12)
+	if _, err := rand.Read(header[:11]); err != nil {
+		return nil, fmt.Errorf("failed to generate random header: %w", err)
+	}
+	header[11] = checkByte
+
+	result := make([]byte, 0, 12+len(data))
+	result = append(result, cipher.EncryptData(header)...)
+	result = append(result, cipher.EncryptData(data)...)
+	return result, nil
+}
+
 // ResetCipher resets the cipher to its initial state
 func (z *ZipCipher) ResetCipher() {
 	z.Keys[0] = 305419896 // 0x12345678
@@ -60,34 +106,47 @@ func (z *ZipCipher) ResetCipher() {
 
 // DecryptFilename decrypts an encrypted filename and attempts to decode it
 func DecryptFilename(encryptedData []byte, password []byte) (string, bool) {
+	decoded, _, ok := DecryptFilenameWithEncoding(encryptedData, password)
+	return decoded, ok
+}
+
+// DecryptFilenameWithEncoding is DecryptFilename that also returns the name of the encoding that
+// decoded the filename: "utf-8", "cp932" or "cp1252"
+func DecryptFilenameWithEncoding(encryptedData []byte, password []byte) (string, string, bool) {
+	return DecryptFilenameWithRatio(encryptedData, password, DefaultMinValidFilenameRatio)
+}
+
+// DecryptFilenameWithRatio is DecryptFilenameWithEncoding with the share of printable runes a
+// decoded name needs to count as valid, see DefaultMinValidFilenameRatio
+func DecryptFilenameWithRatio(encryptedData []byte, password []byte, minValidRatio float64) (string, string, bool) {
 	if len(encryptedData) == 0 {
-		return "", false
+		return "", "", false
 	}
 
-	cipher := &ZipCipher{}
-	cipher.InitKeys(password)
-	decrypted := cipher.DecryptData(encryptedData)
+	decrypted := DecryptFilenameBytes(encryptedData, password)
 
-	// Try different encodings to decode the filename
+	// Try different encodings to decode the filename. CP932 goes before CP1252 because many
+	// KR/JP assets use it, and CP1252 decodes almost any byte sequence.
 	encodings := []string{
 		"utf-8",
-		"latin-1",
+		"cp932",
 		"cp1252",
-		"ascii",
 	}
 
 	for _, encoding := range encodings {
-		if decoded, ok := tryDecode(decrypted, encoding); ok && isValidFilename(decoded) {
-			return decoded, true
+		if decoded, ok := tryDecode(decrypted, encoding); ok && isValidFilename(decoded, minValidRatio) {
+			return decoded, encoding, true
 		}
 	}
 
-	// Try Japanese encoding as fallback
-	if decoded, ok := tryDecodeCP932(decrypted); ok && len(decoded) > 1 {
-		return decoded, true
-	}
+	return "", "", false
+}
 
-	return "", false
+// DecryptFilenameBytes decrypts an encrypted filename without decoding it
+func DecryptFilenameBytes(encryptedData []byte, password []byte) []byte {
+	cipher := &ZipCipher{}
+	cipher.InitKeys(password)
+	return cipher.DecryptData(encryptedData)
 }
 
 // tryDecode attempts to decode bytes using the specified encoding
@@ -98,50 +157,95 @@ func tryDecode(data []byte, encoding string) (string, bool) {
 			return "", false
 		}
 		return string(data), true
-	case "latin-1", "cp1252", "ascii":
-		// For these encodings, we can just convert directly
-		result := make([]rune, len(data))
-		for i, b := range data {
-			result[i] = rune(b)
+	case "cp932":
+		return tryDecodeCP932(data)
+	case "cp1252":
+		decoded, err := charmap.Windows1252.NewDecoder().Bytes(data)
+		if err != nil {
+			return "", false
 		}
-		return string(result), true
+		return string(decoded), true
 	default:
 		return "", false
 	}
 }
 
-// tryDecodeCP932 attempts to decode using Japanese CP932 encoding
+// tryDecodeCP932 attempts to decode using Japanese CP932 (Shift-JIS) encoding. The decoder
+// substitutes U+FFFD for invalid sequences, so any replacement character rejects the input.
 func tryDecodeCP932(data []byte) (string, bool) {
-	// Simplified CP932 decoding - in a real implementation, you'd use
-	// golang.org/x/text/encoding/japanese.ShiftJIS
-	// For now, just treat as extended Latin-1
-	result := make([]rune, len(data))
-	for i, b := range data {
-		if b < 128 {
-			result[i] = rune(b)
-		} else {
-			result[i] = rune(b) // Simplified handling
+	decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", false
+	}
+	for _, r := range string(decoded) {
+		if r == utf8.RuneError {
+			return "", false
 		}
 	}
-	return string(result), true
+	return string(decoded), true
 }
 
-// isValidFilename checks if a decoded string is likely to be a valid filename
-func isValidFilename(filename string) bool {
+// DefaultMinValidFilenameRatio is the share of runes in a decrypted filename that must be
+// printable for it to count as decoded. Lowering it accepts more unusual names at the cost of
+// more garbage passing with a wrong password.
+const DefaultMinValidFilenameRatio = 0.8
+
+// maxExtensionLength is the longest extension isValidFilename accepts
+const maxExtensionLength = 16
+
+// isValidFilename checks if a decoded string is likely to be a valid filename: free of control
+// characters, made of path segments that are neither empty nor "..", with a short alphanumeric
+// extension if any, and with at least minValidRatio of its runes printable
+func isValidFilename(filename string, minValidRatio float64) bool {
 	if len(filename) == 0 {
 		return false
 	}
 
-	// Check if the filename contains reasonable characters
+	// Check if the filename contains reasonable characters; printable non-ASCII counts so
+	// Japanese and accented names qualify
 	validCharCount := 0
 	for _, r := range filename {
-		if (r >= 32 && r <= 126) || r == '_' || r == '-' || r == '.' || r == '/' {
+		if r == utf8.RuneError || unicode.IsControl(r) {
+			return false
+		}
+		if (r >= 32 && r <= 126) || (r > unicode.MaxASCII && unicode.IsPrint(r)) {
 			validCharCount++
 		}
 	}
+	if float64(validCharCount)/float64(utf8.RuneCountInString(filename)) < minValidRatio {
+		return false
+	}
+
+	// Directory entries end in a separator; every other segment must name something
+	path := strings.ReplaceAll(filename, "\\", "/")
+	segments := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	for _, segment := range segments {
+		if segment == "" || segment == ".." {
+			return false
+		}
+	}
+
+	return validExtension(segments[len(segments)-1])
+}
+
+// validExtension reports whether the extension of name, if it has one, is plausible: short and
+// made of letters, digits, underscores and dashes. Dotfiles have no extension.
+func validExtension(name string) bool {
+	dot := strings.LastIndexByte(name, '.')
+	if dot <= 0 {
+		return true
+	}
 
-	// At least 80% of characters should be valid
-	return float64(validCharCount)/float64(len(filename)) >= 0.8
+	ext := name[dot+1:]
+	if len(ext) == 0 || len(ext) > maxExtensionLength {
+		return false
+	}
+	for _, r := range ext {
+		if !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
 }
 
 // MakeSafeFilename creates a safe filename for filesystem storage
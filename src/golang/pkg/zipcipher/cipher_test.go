@@ -0,0 +1,162 @@
+package zipcipher
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// encryptFilename encrypts a stored name the way IPF archives do
+func encryptFilename(name []byte, password []byte) []byte {
+	cipher := &ZipCipher{}
+	cipher.InitKeys(password)
+	return cipher.EncryptData(name)
+}
+
+func TestDecryptFilenameEncodings(t *testing.T) {
+	password := GetIPFPassword()
+
+	tests := []struct {
+		name         string
+		stored       []byte
+		want         string
+		wantEncoding string
+	}{
+		{"ascii", []byte("data/item.xml"), "data/item.xml", "utf-8"},
+		{"utf-8", []byte("日本語.txt"), "日本語.txt", "utf-8"},
+		{"shift-jis kanji", []byte("\x93\xfa\x96\x7b\x8c\xea.txt"), "日本語.txt", "cp932"},
+		{"shift-jis directories", []byte("\x83\x65\x83\x58\x83\x67/\x89\xe6\x91\x9c.png"), "テスト/画像.png", "cp932"},
+		{"shift-jis trail byte 0x5c", []byte("\x95\x5c\x8e\xa6.xml"), "表示.xml", "cp932"},
+		{"half-width katakana", []byte("\xb1\xb2\xb3.dds"), "ｱｲｳ.dds", "cp932"},
+		{"cp1252", []byte("caf\xe9.txt"), "café.txt", "cp1252"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, encoding, ok := DecryptFilenameWithEncoding(encryptFilename(tt.stored, password), password)
+			if !ok || got != tt.want || encoding != tt.wantEncoding {
+				t.Errorf("DecryptFilenameWithEncoding = %q (%q, %v), want %q (%q)", got, encoding, ok, tt.want, tt.wantEncoding)
+			}
+		})
+	}
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	allBytes := make([]byte, 256)
+	for i := range allBytes {
+		allBytes[i] = byte(i)
+	}
+	random := make([]byte, 64<<10)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		password []byte
+	}{
+		{"empty", nil, GetIPFPassword()},
+		{"single byte", []byte{0}, GetIPFPassword()},
+		{"every byte value", allBytes, GetIPFPassword()},
+		{"random", random, GetIPFPassword()},
+		{"empty password", allBytes, nil},
+		{"binary password", allBytes, []byte{0x00, 0xff, 0x10, 0x80}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypter := &ZipCipher{}
+			encrypter.InitKeys(tt.password)
+			encrypted := encrypter.EncryptData(tt.data)
+			if len(encrypted) != len(tt.data) {
+				t.Fatalf("EncryptData returned %d bytes for %d", len(encrypted), len(tt.data))
+			}
+			if len(tt.data) > 16 && bytes.Equal(encrypted, tt.data) {
+				t.Errorf("EncryptData left the data unchanged")
+			}
+
+			decrypter := &ZipCipher{}
+			decrypter.InitKeys(tt.password)
+			if got := decrypter.DecryptData(encrypted); !bytes.Equal(got, tt.data) {
+				t.Errorf("DecryptData(EncryptData(data)) differs from data")
+			}
+
+			// The cipher is a stream: byte at a time and split calls produce the same output
+			byByte := &ZipCipher{}
+			byByte.InitKeys(tt.password)
+			split := &ZipCipher{}
+			split.InitKeys(tt.password)
+			half := len(tt.data) / 2
+			chunked := append(split.EncryptData(tt.data[:half]), split.EncryptData(tt.data[half:])...)
+			for i, b := range tt.data {
+				if got := byByte.EncryptByte(b); got != encrypted[i] || chunked[i] != encrypted[i] {
+					t.Fatalf("byte %d encrypts to %#x byte at a time and %#x split, want %#x", i, got, chunked[i], encrypted[i])
+				}
+			}
+			if byByte.Keys != encrypter.Keys || split.Keys != encrypter.Keys {
+				t.Errorf("cipher states diverged after encrypting the same data")
+			}
+		})
+	}
+
+	t.Run("payload", func(t *testing.T) {
+		password := GetIPFPassword()
+		payload, err := EncryptPayload(random, password, 0x6a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cipher := &ZipCipher{}
+		cipher.InitKeys(password)
+		decrypted := cipher.DecryptData(payload)
+		if err := CheckHeader(decrypted[:12], 0x6a21, 0); err != nil {
+			t.Errorf("CheckHeader: %v", err)
+		}
+		if !bytes.Equal(decrypted[12:], random) {
+			t.Errorf("payload does not decrypt to the original data")
+		}
+	})
+}
+
+func TestIsValidFilename(t *testing.T) {
+	tests := []struct {
+		name  string
+		ratio float64 // DefaultMinValidFilenameRatio when zero
+		want  bool
+	}{
+		// Near misses a wrong password can produce
+		{name: "", want: false},
+		{name: "data/item\x00.xml", want: false},
+		{name: "file\x7f.txt", want: false},
+		{name: "tab\tname.txt", want: false},
+		{name: "bad\ufffd.bin", want: false},
+		{name: "a//b.txt", want: false},
+		{name: "/absolute.txt", want: false},
+		{name: "../escape.txt", want: false},
+		{name: "dir/../escape.txt", want: false},
+		{name: "trailing.", want: false},
+		{name: "name.x$y", want: false},
+		{name: "image.pég", want: false},
+		{name: "a.thisextensionistoolong", want: false},
+		{name: "ab\u00ad\u00ad.txt", want: false}, // Soft hyphens are neither printable nor control
+
+		// Unusual names that are still valid
+		{name: "README", want: true},
+		{name: ".gitignore", want: true},
+		{name: "...hidden", want: true},
+		{name: "dir/", want: true},
+		{name: "dir\\sub\\texture.dds", want: true},
+		{name: "café menu (1).txt", want: true},
+		{name: "テスト/画像.png", want: true},
+		{name: "ｱｲｳ.dds", want: true},
+		{name: "archive.tar.gz", want: true},
+		{name: "backup.DDS_2", want: true},
+		{name: "map.x-y", want: true},
+		{name: "ab\u00ad\u00ad.txt", ratio: 0.5, want: true},
+	}
+	for _, tt := range tests {
+		ratio := tt.ratio
+		if ratio == 0 {
+			ratio = DefaultMinValidFilenameRatio
+		}
+		if got := isValidFilename(tt.name, ratio); got != tt.want {
+			t.Errorf("isValidFilename(%q, %v) = %v, want %v", tt.name, ratio, got, tt.want)
+		}
+	}
+}
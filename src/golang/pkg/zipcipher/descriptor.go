@@ -0,0 +1,61 @@
+package zipcipher
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// DataDescriptor holds the values written after an entry's data when general purpose bit 3 is set
+type DataDescriptor struct {
+	CRC32            uint32
+	CompressedSize   uint64
+	UncompressedSize uint64
+}
+
+// DataDescriptorSize returns the length of a data descriptor, excluding the optional signature.
+// ZIP64 entries store 8-byte sizes instead of 4-byte ones.
+func DataDescriptorSize(zip64 bool) int {
+	if zip64 {
+		return 4 + 8 + 8
+	}
+	return 4 + 4 + 4
+}
+
+// HasZip64Extra reports whether extra contains a ZIP64 extended information block, which
+// determines the size width of the entry's data descriptor
+func HasZip64Extra(extra []byte) bool {
+	for len(extra) >= 4 {
+		tag := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if tag == zip64ExtraID {
+			return true
+		}
+		if 4+size > len(extra) {
+			return false
+		}
+		extra = extra[4+size:]
+	}
+	return false
+}
+
+// IsDataDescriptorSignature reports whether b starts with the optional data descriptor signature
+func IsDataDescriptorSignature(b []byte) bool {
+	return len(b) >= 4 && binary.LittleEndian.Uint32(b[0:4]) == dataDescriptorSignature
+}
+
+// ParseDataDescriptor decodes a data descriptor without its signature
+func ParseDataDescriptor(b []byte, zip64 bool) (DataDescriptor, error) {
+	if len(b) < DataDescriptorSize(zip64) {
+		return DataDescriptor{}, fmt.Errorf("data descriptor too short: %d bytes", len(b))
+	}
+
+	descriptor := DataDescriptor{CRC32: binary.LittleEndian.Uint32(b[0:4])}
+	if zip64 {
+		descriptor.CompressedSize = binary.LittleEndian.Uint64(b[4:12])
+		descriptor.UncompressedSize = binary.LittleEndian.Uint64(b[12:20])
+	} else {
+		descriptor.CompressedSize = uint64(binary.LittleEndian.Uint32(b[4:8]))
+		descriptor.UncompressedSize = uint64(binary.LittleEndian.Uint32(b[8:12]))
+	}
+	return descriptor, nil
+}
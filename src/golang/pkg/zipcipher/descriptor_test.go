@@ -0,0 +1,169 @@
+package zipcipher
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"testing"
+)
+
+// streamedEntry encodes a stored, unencrypted entry whose CRC32 and sizes follow the data in a
+// data descriptor, followed by the signature of a central directory record. ZIP64 entries carry
+// a ZIP64 extra field in the local header and 8-byte sizes in the descriptor.
+func streamedEntry(data []byte, zip64 bool) []byte {
+	var extra []byte
+	if zip64 {
+		extra = zip64Block(Zip64Extra{}, true, true, false, false)
+	}
+
+	entry := binary.LittleEndian.AppendUint32(nil, localFileHeaderSignature)
+	entry = binary.LittleEndian.AppendUint16(entry, 45)  // Version needed
+	entry = binary.LittleEndian.AppendUint16(entry, 0x8) // Data descriptor
+	entry = binary.LittleEndian.AppendUint16(entry, 0)   // Stored
+	entry = binary.LittleEndian.AppendUint32(entry, 0)   // Modification time and date
+	entry = binary.LittleEndian.AppendUint32(entry, 0)   // CRC32
+	if zip64 {
+		entry = binary.LittleEndian.AppendUint32(entry, zip64Marker)
+		entry = binary.LittleEndian.AppendUint32(entry, zip64Marker)
+	} else {
+		entry = binary.LittleEndian.AppendUint64(entry, 0)
+	}
+	entry = binary.LittleEndian.AppendUint16(entry, 1)
+	entry = binary.LittleEndian.AppendUint16(entry, uint16(len(extra)))
+	entry = append(append(append(entry, 'a'), extra...), data...)
+
+	entry = binary.LittleEndian.AppendUint32(entry, dataDescriptorSignature)
+	entry = binary.LittleEndian.AppendUint32(entry, crc32.ChecksumIEEE(data))
+	if zip64 {
+		entry = binary.LittleEndian.AppendUint64(entry, uint64(len(data)))
+		entry = binary.LittleEndian.AppendUint64(entry, uint64(len(data)))
+	} else {
+		entry = binary.LittleEndian.AppendUint32(entry, uint32(len(data)))
+		entry = binary.LittleEndian.AppendUint32(entry, uint32(len(data)))
+	}
+	return binary.LittleEndian.AppendUint32(entry, centralDirSignature)
+}
+
+// readStreamed reads the entry at the start of archive, returning its data and header
+func readStreamed(t *testing.T, archive []byte) ([]byte, LocalFileHeader) {
+	t.Helper()
+
+	reader := NewEncryptedFileReader(bytes.NewReader(archive), nil)
+	if _, err := reader.ReadLocalHeader(); err != nil {
+		t.Fatalf("ReadLocalHeader: %v", err)
+	}
+	compressed, err := reader.ReadEncryptedData()
+	if err != nil {
+		t.Fatalf("ReadEncryptedData: %v", err)
+	}
+	data, err := reader.DecompressData(compressed)
+	if err != nil {
+		t.Fatalf("DecompressData: %v", err)
+	}
+	return data, reader.header
+}
+
+func TestStreamedDescriptorWidth(t *testing.T) {
+	data := bytes.Repeat([]byte("streamed "), 50)
+
+	tests := []struct {
+		name  string
+		zip64 bool
+	}{
+		{"classic", false},
+		{"zip64", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, header := readStreamed(t, streamedEntry(data, tt.zip64))
+			if !bytes.Equal(got, data) {
+				t.Errorf("data = %q, want %q", got, data)
+			}
+			if header.CompressedSize64 != uint64(len(data)) || header.UncompressedSize64 != uint64(len(data)) {
+				t.Errorf("sizes = %d, %d, want %d", header.CompressedSize64, header.UncompressedSize64, len(data))
+			}
+			if header.CRC32 != crc32.ChecksumIEEE(data) {
+				t.Errorf("CRC32 = %08x, want %08x", header.CRC32, crc32.ChecksumIEEE(data))
+			}
+		})
+	}
+}
+
+func TestParseDataDescriptor(t *testing.T) {
+	tests := []struct {
+		name  string
+		zip64 bool
+		raw   []byte
+		want  DataDescriptor
+	}{
+		{
+			name: "classic",
+			raw:  []byte{1, 2, 3, 4, 0x10, 0, 0, 0, 0x20, 0, 0, 0},
+			want: DataDescriptor{CRC32: 0x04030201, CompressedSize: 0x10, UncompressedSize: 0x20},
+		},
+		{
+			name:  "zip64",
+			zip64: true,
+			raw:   []byte{1, 2, 3, 4, 0x10, 0, 0, 0, 1, 0, 0, 0, 0x20, 0, 0, 0, 2, 0, 0, 0},
+			want:  DataDescriptor{CRC32: 0x04030201, CompressedSize: 0x1_0000_0010, UncompressedSize: 0x2_0000_0020},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.raw) != DataDescriptorSize(tt.zip64) {
+				t.Fatalf("DataDescriptorSize(%t) = %d, want %d", tt.zip64, DataDescriptorSize(tt.zip64), len(tt.raw))
+			}
+			got, err := ParseDataDescriptor(tt.raw, tt.zip64)
+			if err != nil || got != tt.want {
+				t.Errorf("ParseDataDescriptor = %+v, %v, want %+v", got, err, tt.want)
+			}
+			if _, err := ParseDataDescriptor(tt.raw[:len(tt.raw)-1], tt.zip64); err == nil {
+				t.Errorf("ParseDataDescriptor accepted a truncated descriptor")
+			}
+		})
+	}
+}
+
+func TestReadDataWithDescriptorSignatures(t *testing.T) {
+	signature := binary.LittleEndian.AppendUint32(nil, dataDescriptorSignature)
+
+	// A fake descriptor whose compressed size matches its offset, only missing the header after it
+	prefix := []byte("prefix ")
+	plausible := append(append([]byte(nil), prefix...), signature...)
+	plausible = binary.LittleEndian.AppendUint32(plausible, crc32.ChecksumIEEE(prefix))
+	plausible = binary.LittleEndian.AppendUint32(plausible, uint32(len(prefix)))
+	plausible = binary.LittleEndian.AppendUint32(plausible, uint32(len(prefix)))
+	plausible = append(plausible, "suffix"...)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"signature mid-stream", append(append([]byte("compressed "), signature...), " data"...)},
+		{"repeated signatures", bytes.Repeat(signature, 2000)},
+		{"plausible descriptor mid-stream", plausible},
+		{"signature at the start", append(append([]byte(nil), signature...), "data"...)},
+	}
+	// Reads are 4096 bytes from the start of the data, so the real signature at these offsets is
+	// split across two reads or starts the second one
+	for _, length := range []int{4093, 4094, 4095, 4096} {
+		tests = append(tests, struct {
+			name string
+			data []byte
+		}{fmt.Sprintf("signature at offset %d", length), bytes.Repeat([]byte("x"), length)})
+	}
+	for _, tt := range tests {
+		for _, zip64 := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s zip64=%t", tt.name, zip64), func(t *testing.T) {
+				got, header := readStreamed(t, streamedEntry(tt.data, zip64))
+				if !bytes.Equal(got, tt.data) {
+					t.Errorf("read %d bytes, want %d", len(got), len(tt.data))
+				}
+				if header.CompressedSize64 != uint64(len(tt.data)) {
+					t.Errorf("compressed size = %d, want %d", header.CompressedSize64, len(tt.data))
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package zipcipher
+
+import "time"
+
+// MSDOSTimestamp converts a time to the MS-DOS time and date fields of a ZIP header. The format
+// has 2-second resolution and no time zone; IPF packers store local time.
+func MSDOSTimestamp(t time.Time) (uint16, uint16) {
+	date := uint16(t.Day()) | uint16(t.Month())<<5 | uint16(t.Year()-1980)<<9
+	timeVal := uint16(t.Second()/2) | uint16(t.Minute())<<5 | uint16(t.Hour())<<11
+	return timeVal, date
+}
+
+// MSDOSTime converts MS-DOS date and time fields, taken as local time, back to a time. This is the
+// reverse of MSDOSTimestamp; a zero date (no timestamp) yields the zero Time.
+func MSDOSTime(date, clock uint16) time.Time {
+	if date == 0 {
+		return time.Time{}
+	}
+	return time.Date(
+		int(date>>9)+1980,
+		time.Month(date>>5&0x0F),
+		int(date&0x1F),
+		int(clock>>11),
+		int(clock>>5&0x3F),
+		int(clock&0x1F)*2,
+		0,
+		time.Local,
+	)
+}
@@ -0,0 +1,47 @@
+package zipcipher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMSDOSTimeRoundTrip(t *testing.T) {
+	tests := []struct {
+		in   time.Time
+		want time.Time // in truncated to the format's 2-second resolution
+	}{
+		{
+			time.Date(2014, time.March, 9, 17, 42, 30, 0, time.Local),
+			time.Date(2014, time.March, 9, 17, 42, 30, 0, time.Local),
+		},
+		{
+			time.Date(2014, time.March, 9, 17, 42, 31, 999, time.Local),
+			time.Date(2014, time.March, 9, 17, 42, 30, 0, time.Local),
+		},
+		{
+			time.Date(1980, time.January, 1, 0, 0, 0, 0, time.Local),
+			time.Date(1980, time.January, 1, 0, 0, 0, 0, time.Local),
+		},
+		{
+			time.Date(2107, time.December, 31, 23, 59, 59, 0, time.Local),
+			time.Date(2107, time.December, 31, 23, 59, 58, 0, time.Local),
+		},
+		{
+			time.Date(2024, time.February, 29, 12, 0, 1, 0, time.Local),
+			time.Date(2024, time.February, 29, 12, 0, 0, 0, time.Local),
+		},
+	}
+	for _, tt := range tests {
+		clock, date := MSDOSTimestamp(tt.in)
+		if got := MSDOSTime(date, clock); !got.Equal(tt.want) {
+			t.Errorf("MSDOSTime(MSDOSTimestamp(%v)) = %v, want %v", tt.in, got, tt.want)
+		}
+		if d := tt.in.Sub(MSDOSTime(date, clock)); d < 0 || d >= 2*time.Second {
+			t.Errorf("%v round trips %v away", tt.in, d)
+		}
+	}
+
+	if got := MSDOSTime(0, 0x6a21); !got.IsZero() {
+		t.Errorf("MSDOSTime with a zero date = %v, want the zero Time", got)
+	}
+}
@@ -1,5 +1,11 @@
 package zipcipher
 
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
 // GetIPFPassword returns the static 48-byte password used for all IPF files
 // This is the same password found in ez.exe and ipfpassword.txt files
 func GetIPFPassword() []byte {
@@ -9,3 +15,23 @@ func GetIPFPassword() []byte {
 		0x20, 0x68, 0x20, 0x25, 0x73, 0x20, 0x2E, 0x3F, 0x2E, 0x20, 0x20, 0x20, 0x58, 0xFF, 0x24, 0x24,
 	}
 }
+
+// ParsePassword parses a password given on the command line. Values starting with "hex:" are
+// hex-decoded so binary keys can be passed; anything else is used as-is. An empty value selects
+// the default IPF password.
+func ParsePassword(value string) ([]byte, error) {
+	if value == "" {
+		return GetIPFPassword(), nil
+	}
+	if hexValue, ok := strings.CutPrefix(value, "hex:"); ok {
+		decoded, err := hex.DecodeString(strings.TrimSpace(hexValue))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex password: %w", err)
+		}
+		if len(decoded) == 0 {
+			return nil, fmt.Errorf("hex password is empty")
+		}
+		return decoded, nil
+	}
+	return []byte(value), nil
+}
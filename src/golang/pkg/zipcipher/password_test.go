@@ -0,0 +1,29 @@
+package zipcipher
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParsePassword(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []byte
+		wantErr bool
+	}{
+		{"", GetIPFPassword(), false},
+		{"ToS key", []byte("ToS key"), false},
+		{"hex:00ff1080", []byte{0x00, 0xff, 0x10, 0x80}, false},
+		{"hex: 0a0B ", []byte{0x0a, 0x0b}, false},
+		{"HEX:00", []byte("HEX:00"), false},
+		{"hex:", nil, true},
+		{"hex:0g", nil, true},
+		{"hex:abc", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePassword(tt.value)
+		if (err != nil) != tt.wantErr || !bytes.Equal(got, tt.want) {
+			t.Errorf("ParsePassword(%q) = %x, %v; want %x, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
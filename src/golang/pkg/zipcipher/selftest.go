@@ -0,0 +1,53 @@
+package zipcipher
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+)
+
+// selfTestFixture is a minimal encrypted IPF containing a single deflated entry
+//
+//go:embed selftest.ipf
+var selfTestFixture []byte
+
+// Expected contents of the self-test fixture
+const (
+	selfTestFilename = "selftest/hello.txt"
+	selfTestContent  = "Granado Espada IPF self-test fixture\n"
+)
+
+// SelfTest runs the filename decryption, data decryption, decompression and CRC32
+// verification pipeline against an embedded fixture archive and reports any mismatch
+func SelfTest() error {
+	password := GetIPFPassword()
+	reader := NewEncryptedFileReader(bytes.NewReader(selfTestFixture), password)
+
+	header, err := reader.ReadLocalHeader()
+	if err != nil {
+		return fmt.Errorf("self-test: %w", err)
+	}
+
+	if !header.IsEncrypted() {
+		return fmt.Errorf("self-test: fixture entry is not encrypted (flags 0x%04x)", header.BitFlag)
+	}
+
+	filename, ok := DecryptFilename(header.Filename, password)
+	if !ok {
+		return fmt.Errorf("self-test: failed to decrypt fixture filename")
+	}
+	if filename != selfTestFilename {
+		return fmt.Errorf("self-test: filename mismatch: expected %q, got %q", selfTestFilename, filename)
+	}
+
+	// ExtractFile verifies the encryption header check byte and the CRC32 of the output
+	data, err := reader.ExtractFile()
+	if err != nil {
+		return fmt.Errorf("self-test: %w", err)
+	}
+	if string(data) != selfTestContent {
+		return fmt.Errorf("self-test: content mismatch: expected %q, got %q", selfTestContent, string(data))
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package zipcipher
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	nameLen := int(binary.LittleEndian.Uint16(selfTestFixture[26:28]))
+	extraLen := int(binary.LittleEndian.Uint16(selfTestFixture[28:30]))
+	dataStart := 30 + nameLen + extraLen
+
+	tests := []struct {
+		name    string
+		corrupt int // Offset of the byte to flip, or -1
+		wantErr bool
+	}{
+		{"intact fixture", -1, false},
+		{"corrupt filename", 30, true},
+		{"corrupt encryption header", dataStart + 11, true},
+		{"corrupt data", dataStart + 14, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := selfTestFixture
+			defer func() { selfTestFixture = original }()
+
+			selfTestFixture = bytes.Clone(original)
+			if tt.corrupt >= 0 {
+				selfTestFixture[tt.corrupt] ^= 0xFF
+			}
+
+			if err := SelfTest(); (err != nil) != tt.wantErr {
+				t.Errorf("SelfTest() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
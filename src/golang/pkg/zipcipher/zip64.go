@@ -0,0 +1,79 @@
+package zipcipher
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ZIP64 constants
+const (
+	zip64ExtraID = 0x0001
+	zip64Marker  = 0xFFFFFFFF
+)
+
+// Zip64Extra holds the values carried by a ZIP64 extended information extra field
+type Zip64Extra struct {
+	UncompressedSize  uint64
+	CompressedSize    uint64
+	LocalHeaderOffset uint64
+	DiskStart         uint32
+}
+
+// ParseZip64Extra locates the ZIP64 extra field and decodes it positionally.
+// Per the appnote a central directory record only carries the fields whose classic value
+// overflowed, always in the order uncompressed size, compressed size, local header offset, disk
+// start number, while a local header carries both sizes; the flags select which fields to expect.
+// Returns false when the extra data has no ZIP64 block.
+func ParseZip64Extra(extra []byte, uncompressed, compressed, offset, disk bool) (Zip64Extra, bool, error) {
+	var result Zip64Extra
+
+	for len(extra) >= 4 {
+		tag := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if 4+size > len(extra) {
+			return result, false, fmt.Errorf("extra field block 0x%04x overruns extra data (%d > %d)", tag, size, len(extra)-4)
+		}
+
+		data := extra[4 : 4+size]
+		extra = extra[4+size:]
+		if tag != zip64ExtraID {
+			continue
+		}
+
+		readUint64 := func(name string) (uint64, error) {
+			if len(data) < 8 {
+				return 0, fmt.Errorf("ZIP64 extra field too short for %s", name)
+			}
+			value := binary.LittleEndian.Uint64(data[:8])
+			data = data[8:]
+			return value, nil
+		}
+
+		var err error
+		if uncompressed {
+			if result.UncompressedSize, err = readUint64("uncompressed size"); err != nil {
+				return result, true, err
+			}
+		}
+		if compressed {
+			if result.CompressedSize, err = readUint64("compressed size"); err != nil {
+				return result, true, err
+			}
+		}
+		if offset {
+			if result.LocalHeaderOffset, err = readUint64("local header offset"); err != nil {
+				return result, true, err
+			}
+		}
+		if disk {
+			if len(data) < 4 {
+				return result, true, fmt.Errorf("ZIP64 extra field too short for disk start number")
+			}
+			result.DiskStart = binary.LittleEndian.Uint32(data[:4])
+		}
+
+		return result, true, nil
+	}
+
+	return result, false, nil
+}
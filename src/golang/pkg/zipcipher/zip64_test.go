@@ -0,0 +1,92 @@
+package zipcipher
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// zip64Block encodes a ZIP64 extra block holding the selected fields in appnote order
+func zip64Block(want Zip64Extra, uncompressed, compressed, offset, disk bool) []byte {
+	var data []byte
+	if uncompressed {
+		data = binary.LittleEndian.AppendUint64(data, want.UncompressedSize)
+	}
+	if compressed {
+		data = binary.LittleEndian.AppendUint64(data, want.CompressedSize)
+	}
+	if offset {
+		data = binary.LittleEndian.AppendUint64(data, want.LocalHeaderOffset)
+	}
+	if disk {
+		data = binary.LittleEndian.AppendUint32(data, want.DiskStart)
+	}
+
+	block := binary.LittleEndian.AppendUint16(nil, zip64ExtraID)
+	block = binary.LittleEndian.AppendUint16(block, uint16(len(data)))
+	return append(block, data...)
+}
+
+func TestParseZip64ExtraSubsets(t *testing.T) {
+	values := Zip64Extra{
+		UncompressedSize:  0x1_0000_0001,
+		CompressedSize:    0x2_0000_0002,
+		LocalHeaderOffset: 0x3_0000_0003,
+		DiskStart:         4,
+	}
+	// An unrelated block (extended timestamp) in front of the ZIP64 one must be skipped
+	timestamp := []byte{0x55, 0x54, 0x05, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}
+
+	for subset := 0; subset < 16; subset++ {
+		uncompressed, compressed, offset, disk := subset&1 != 0, subset&2 != 0, subset&4 != 0, subset&8 != 0
+		t.Run(fmt.Sprintf("u%t_c%t_o%t_d%t", uncompressed, compressed, offset, disk), func(t *testing.T) {
+			extra := append(append([]byte(nil), timestamp...), zip64Block(values, uncompressed, compressed, offset, disk)...)
+
+			got, found, err := ParseZip64Extra(extra, uncompressed, compressed, offset, disk)
+			if err != nil || !found {
+				t.Fatalf("ParseZip64Extra = %v, %v", found, err)
+			}
+
+			var want Zip64Extra
+			if uncompressed {
+				want.UncompressedSize = values.UncompressedSize
+			}
+			if compressed {
+				want.CompressedSize = values.CompressedSize
+			}
+			if offset {
+				want.LocalHeaderOffset = values.LocalHeaderOffset
+			}
+			if disk {
+				want.DiskStart = values.DiskStart
+			}
+			if got != want {
+				t.Errorf("ParseZip64Extra = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseZip64ExtraMalformed(t *testing.T) {
+	values := Zip64Extra{UncompressedSize: 1, CompressedSize: 2}
+
+	tests := []struct {
+		name      string
+		extra     []byte
+		wantFound bool
+		wantErr   bool
+	}{
+		{"no extra", nil, false, false},
+		{"no ZIP64 block", []byte{0x55, 0x54, 0x01, 0x00, 0x00}, false, false},
+		{"block overruns extra", []byte{0x01, 0x00, 0x10, 0x00, 0x00}, false, true},
+		{"missing expected field", zip64Block(values, true, false, false, false), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, found, err := ParseZip64Extra(tt.extra, true, true, false, false)
+			if found != tt.wantFound || (err != nil) != tt.wantErr {
+				t.Errorf("ParseZip64Extra = %v, %v; want found %v, error %v", found, err, tt.wantFound, tt.wantErr)
+			}
+		})
+	}
+}
@@ -15,6 +15,29 @@ const localFileHeaderSignature = 0x04034b50
 const dataDescriptorSignature = 0x08074b50
 const centralDirSignature = 0x02014b50
 
+// ErrCRCMismatch and ErrSizeMismatch are wrapped by errors for data that decoded cleanly but does
+// not match the CRC32 or uncompressed size recorded for the entry
+var (
+	ErrCRCMismatch  = errors.New("CRC32 mismatch")
+	ErrSizeMismatch = errors.New("size mismatch")
+)
+
+var (
+	// ErrBadHeaderSignature is wrapped when a local file header does not start with its signature,
+	// usually because the offset is wrong or the archive is damaged
+	ErrBadHeaderSignature = errors.New("invalid local file header signature")
+
+	// ErrUnsupportedMethod is wrapped for entries compressed with anything but store or deflate
+	ErrUnsupportedMethod = errors.New("unsupported compression method")
+
+	// ErrPasswordVerification is wrapped when the check byte of a decrypted encryption header
+	// does not match, which usually means a wrong password
+	ErrPasswordVerification = errors.New("password verification failed")
+
+	// ErrCorruptData is wrapped when compressed data cannot be decompressed
+	ErrCorruptData = errors.New("corrupt compressed data")
+)
+
 // LocalFileHeader represents a ZIP local file header
 type LocalFileHeader struct {
 	Signature         uint32
@@ -30,6 +53,10 @@ type LocalFileHeader struct {
 	ExtraFieldLength  uint16
 	Filename          []byte
 	ExtraField        []byte
+
+	// 64-bit sizes, taken from the ZIP64 extra field when the classic fields overflow
+	CompressedSize64   uint64
+	UncompressedSize64 uint64
 }
 
 // EncryptedFileReader provides reading and decryption of ZIP files with IPF passwords
@@ -39,6 +66,9 @@ type EncryptedFileReader struct {
 	cipher    *ZipCipher
 	header    LocalFileHeader
 	dataStart int64
+
+	sizesKnown bool  // header sizes come from SetKnownSizes rather than the local header
+	scanLimit  int64 // Bytes from the local header searched for a data descriptor; 0 is unbounded
 }
 
 // NewEncryptedFileReader creates a new reader for password-protected ZIP files
@@ -60,7 +90,7 @@ func (ef *EncryptedFileReader) ReadLocalHeader() (*LocalFileHeader, error) {
 
 	signature := binary.LittleEndian.Uint32(headerBytes[0:4])
 	if signature != localFileHeaderSignature {
-		return nil, fmt.Errorf("invalid local file header signature: 0x%08x", signature)
+		return nil, fmt.Errorf("%w: 0x%08x", ErrBadHeaderSignature, signature)
 	}
 
 	header := &LocalFileHeader{
@@ -95,12 +125,52 @@ func (ef *EncryptedFileReader) ReadLocalHeader() (*LocalFileHeader, error) {
 		}
 	}
 
+	// Resolve ZIP64 sizes
+	header.CompressedSize64 = uint64(header.CompressedSize)
+	header.UncompressedSize64 = uint64(header.UncompressedSize)
+	needUncompressed := header.UncompressedSize == zip64Marker
+	needCompressed := header.CompressedSize == zip64Marker
+	if needUncompressed || needCompressed {
+		// Unlike the central directory, a local header's ZIP64 extra always holds both sizes
+		// (APPNOTE 4.5.3), even when only one of them overflowed
+		zip64, found, err := ParseZip64Extra(header.ExtraField, true, true, false, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ZIP64 extra field: %w", err)
+		}
+		if found {
+			if needUncompressed {
+				header.UncompressedSize64 = zip64.UncompressedSize
+			}
+			if needCompressed {
+				header.CompressedSize64 = zip64.CompressedSize
+			}
+		}
+	}
+
 	ef.header = *header
 	ef.dataStart = 30 + int64(header.FilenameLength) + int64(header.ExtraFieldLength)
 
 	return header, nil
 }
 
+// SetKnownSizes replaces the CRC32 and sizes read from the local header with trusted values, e.g.
+// from the central directory. Entries written with data descriptors carry zeros in the local
+// header; with their real sizes known the data is read by length instead of by scanning for the
+// descriptor signature, which can also occur inside stored data. Call it after ReadLocalHeader.
+func (ef *EncryptedFileReader) SetKnownSizes(crc uint32, compressedSize, uncompressedSize uint64) {
+	ef.header.CRC32 = crc
+	ef.header.CompressedSize64 = compressedSize
+	ef.header.UncompressedSize64 = uncompressedSize
+	ef.sizesKnown = true
+}
+
+// SetScanLimit bounds the search for a data descriptor, done when the sizes are unknown, to limit
+// bytes from the start of the local header, e.g. the distance to the next local header or to the
+// central directory. Zero searches up to the end of the reader.
+func (ef *EncryptedFileReader) SetScanLimit(limit int64) {
+	ef.scanLimit = limit
+}
+
 // IsEncrypted checks if the file is encrypted
 func (ef *EncryptedFileReader) IsEncrypted() bool {
 	return (ef.header.BitFlag & 0x1) != 0
@@ -136,12 +206,8 @@ func (ef *EncryptedFileReader) ReadEncryptedData() ([]byte, error) {
 	headerBytes := compressedData[:12]
 	decryptedHeader := ef.cipher.DecryptData(headerBytes)
 
-	// The last byte of the decrypted header should match the high byte of the file time
-	expectedByte := byte(ef.header.LastModTime >> 8)
-	if decryptedHeader[11] != expectedByte {
-		// Try with alternate password interpretation
-		return nil, fmt.Errorf("password verification failed (expected 0x%02x, got 0x%02x)",
-			expectedByte, decryptedHeader[11])
+	if err := CheckHeader(decryptedHeader, ef.header.LastModTime, ef.header.CRC32); err != nil {
+		return nil, err
 	}
 
 	// Decrypt the actual data
@@ -159,14 +225,32 @@ func (ef *EncryptedFileReader) ReadEncryptedData() ([]byte, error) {
 	return decryptedData, nil
 }
 
-// ReadCompressedData reads the compressed data from the file
+// CheckHeader verifies the check byte of a decrypted 12-byte encryption header. IPF packers store
+// the high byte of the entry's MS-DOS time, while the appnote uses the high byte of the CRC32 for
+// entries without a data descriptor, so either is accepted. A mismatch wraps ErrPasswordVerification.
+func CheckHeader(decryptedHeader []byte, modTime uint16, crc uint32) error {
+	if len(decryptedHeader) < 12 {
+		return errors.New("encrypted data too short for encryption header")
+	}
+	check := decryptedHeader[11]
+	if check != byte(modTime>>8) && check != byte(crc>>24) {
+		return fmt.Errorf("%w (expected 0x%02x, got 0x%02x)", ErrPasswordVerification, byte(modTime>>8), check)
+	}
+	return nil
+}
+
+// ReadCompressedData reads the compressed data from the file. For encrypted entries this includes
+// the 12-byte encryption header.
 func (ef *EncryptedFileReader) ReadCompressedData() ([]byte, error) {
-	if ef.header.CompressedSize == 0 {
-		// Check for data descriptor
+	if ef.header.CompressedSize64 == 0 {
+		// Only entries with a data descriptor defer their sizes; otherwise the entry is empty
+		if ef.sizesKnown || ef.header.BitFlag&0x8 == 0 {
+			return []byte{}, nil
+		}
 		return ef.readDataWithDescriptor()
 	}
 
-	compressedData := make([]byte, ef.header.CompressedSize)
+	compressedData := make([]byte, ef.header.CompressedSize64)
 	_, err := io.ReadFull(ef.reader, compressedData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read compressed data: %w", err)
@@ -175,40 +259,106 @@ func (ef *EncryptedFileReader) ReadCompressedData() ([]byte, error) {
 	return compressedData, nil
 }
 
-// readDataWithDescriptor reads data when size is stored in data descriptor
+// readDataWithDescriptor reads data when size is stored in data descriptor. The signature bytes
+// can also occur inside the data, so a candidate is only taken when its compressed size equals
+// the bytes before it and a local header or central directory signature follows the descriptor,
+// or the descriptor ends exactly where the scan limit or the reader does. Without such a
+// candidate the first size-consistent one is used, leaving damage to the CRC32 check.
 func (ef *EncryptedFileReader) readDataWithDescriptor() ([]byte, error) {
-	// Read data until we find data descriptor signature
-	var data bytes.Buffer
+	zip64 := HasZip64Extra(ef.header.ExtraField)
+	descriptorSize := DataDescriptorSize(zip64)
+
+	var source io.Reader = ef.reader
+	if ef.scanLimit > 0 {
+		source = io.LimitReader(ef.reader, max(ef.scanLimit-ef.dataStart, 0))
+	}
+
+	var data []byte
 	buf := make([]byte, 4096)
+	next := 0 // First offset not yet checked for the signature
+	fallback := -1
+	var fallbackDescriptor DataDescriptor
+	eof := false
 
 	for {
-		bytesRead, err := io.ReadAtLeast(ef.reader, buf, 1)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read data: %w", err)
+		// Offsets are only checked once the signature, the descriptor and the signature after
+		// them are buffered, so a signature split across reads is still found
+		for ; next+4+descriptorSize <= len(data); next++ {
+			end := next + 4 + descriptorSize
+			if end+4 > len(data) && !eof {
+				break
+			}
+			if binary.LittleEndian.Uint32(data[next:next+4]) != dataDescriptorSignature {
+				continue
+			}
+			descriptor, err := ParseDataDescriptor(data[next+4:end], zip64)
+			if err != nil {
+				return nil, err
+			}
+			if descriptor.CompressedSize != uint64(next) {
+				continue // The signature bytes occur inside the data
+			}
+			if end == len(data) || isHeaderSignature(data[end:]) {
+				return ef.adoptDescriptor(data, next, descriptorSize, descriptor)
+			}
+			if fallback < 0 {
+				fallback, fallbackDescriptor = next, descriptor
+			}
 		}
 
-		// Check for data descriptor signature
-		if bytesRead >= 4 && binary.LittleEndian.Uint32(buf[bytesRead-4:bytesRead]) == dataDescriptorSignature {
-			// Remove the signature from data
-			data.Write(buf[:bytesRead-4])
-			break
+		if eof {
+			if fallback >= 0 {
+				return ef.adoptDescriptor(data, fallback, descriptorSize, fallbackDescriptor)
+			}
+			return nil, fmt.Errorf("failed to read data: %w", io.ErrUnexpectedEOF)
 		}
 
-		data.Write(buf[:bytesRead])
+		bytesRead, err := source.Read(buf)
+		data = append(data, buf[:bytesRead]...)
+		if err == io.EOF {
+			eof = true
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read data: %w", err)
+		}
 	}
+}
 
-	return data.Bytes(), nil
+// isHeaderSignature reports whether b starts with the signature of a local header or a central
+// directory record, one of which follows every entry's data descriptor
+func isHeaderSignature(b []byte) bool {
+	if len(b) < 4 {
+		return false
+	}
+	signature := binary.LittleEndian.Uint32(b)
+	return signature == localFileHeaderSignature || signature == centralDirSignature
+}
+
+// adoptDescriptor takes the sizes and CRC32 of the descriptor found at offset in the buffered
+// data and leaves the reader just past the descriptor. It returns the entry's data.
+func (ef *EncryptedFileReader) adoptDescriptor(data []byte, offset, descriptorSize int, descriptor DataDescriptor) ([]byte, error) {
+	end := offset + 4 + descriptorSize
+	if _, err := ef.reader.Seek(int64(end-len(data)), io.SeekCurrent); err != nil {
+		return nil, fmt.Errorf("failed to seek past data descriptor: %w", err)
+	}
+
+	ef.header.CRC32 = descriptor.CRC32
+	ef.header.CompressedSize64 = descriptor.CompressedSize
+	ef.header.UncompressedSize64 = descriptor.UncompressedSize
+	return data[:offset], nil
 }
 
 // DecompressData decompresses the read data based on compression method
 func (ef *EncryptedFileReader) DecompressData(compressedData []byte) ([]byte, error) {
 	switch ef.header.CompressionMethod {
 	case 0: // No compression
+		if err := ef.checkContent(compressedData); err != nil {
+			return nil, err
+		}
 		return compressedData, nil
 	case 8: // Deflate
 		return ef.decompressDeflate(compressedData)
 	default:
-		return nil, fmt.Errorf("unsupported compression method: %d", ef.header.CompressionMethod)
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedMethod, ef.header.CompressionMethod)
 	}
 }
 
@@ -219,25 +369,31 @@ func (ef *EncryptedFileReader) decompressDeflate(compressedData []byte) ([]byte,
 
 	decompressed, err := io.ReadAll(reader)
 	if err != nil {
-		return nil, fmt.Errorf("deflate decompression failed: %w", err)
+		return nil, fmt.Errorf("%w: deflate decompression failed: %w", ErrCorruptData, err)
 	}
 
-	// Verify CRC32 if available
-	if ef.header.CRC32 != 0 {
-		calculatedCRC := crc32.ChecksumIEEE(decompressed)
+	if err := ef.checkContent(decompressed); err != nil {
+		return nil, err
+	}
+	return decompressed, nil
+}
+
+// checkContent verifies decoded data against the header's CRC32 and uncompressed size. Zero
+// values are treated as unknown unless SetKnownSizes supplied them.
+func (ef *EncryptedFileReader) checkContent(data []byte) error {
+	if ef.header.CRC32 != 0 || ef.sizesKnown {
+		calculatedCRC := crc32.ChecksumIEEE(data)
 		if calculatedCRC != ef.header.CRC32 {
-			return nil, fmt.Errorf("CRC32 mismatch: expected 0x%08x, got 0x%08x",
-				ef.header.CRC32, calculatedCRC)
+			return fmt.Errorf("%w: expected 0x%08x, got 0x%08x",
+				ErrCRCMismatch, ef.header.CRC32, calculatedCRC)
 		}
 	}
 
-	// Verify size if available
-	if ef.header.UncompressedSize != 0 && uint32(len(decompressed)) != ef.header.UncompressedSize {
-		return nil, fmt.Errorf("size mismatch: expected %d, got %d",
-			ef.header.UncompressedSize, len(decompressed))
+	if (ef.header.UncompressedSize64 != 0 || ef.sizesKnown) && uint64(len(data)) != ef.header.UncompressedSize64 {
+		return fmt.Errorf("%w: expected %d, got %d",
+			ErrSizeMismatch, ef.header.UncompressedSize64, len(data))
 	}
-
-	return decompressed, nil
+	return nil
 }
 
 // ExtractFile performs a complete file extraction with decryption and decompression
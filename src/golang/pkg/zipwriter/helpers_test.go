@@ -0,0 +1,40 @@
+package zipwriter
+
+import (
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+var testPassword = zipcipher.GetIPFPassword()
+
+// openArchive opens the archive at path with its filenames decrypted
+func openArchive(t *testing.T, path string) *ipf.IPFReader {
+	t.Helper()
+
+	reader, err := ipf.NewIPFReader(path)
+	if err != nil {
+		t.Fatalf("NewIPFReader: %v", err)
+	}
+	t.Cleanup(func() { reader.Close() })
+	if _, err := reader.ListFiles(testPassword); err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+	return reader
+}
+
+// readEntry returns the decrypted content of the newest entry called name
+func readEntry(t *testing.T, reader *ipf.IPFReader, name string) string {
+	t.Helper()
+
+	fileInfo, err := reader.GetFileByName(name)
+	if err != nil {
+		t.Fatalf("GetFileByName(%s): %v", name, err)
+	}
+	data, err := reader.ReadEntry(fileInfo.Index, testPassword)
+	if err != nil {
+		t.Fatalf("ReadEntry(%s): %v", name, err)
+	}
+	return string(data)
+}
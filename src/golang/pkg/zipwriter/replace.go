@@ -0,0 +1,245 @@
+package zipwriter
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+	"github.com/joao-paulo-santos/GE-Library/pkg/zipcipher"
+)
+
+// ReplaceEntry replaces the content of the entry called name inside archive.
+//
+// When the new compressed (and encrypted) payload fits in the space of the old one, the entry is
+// overwritten in place and the leftover bytes are zero-padded, so the archive does not grow.
+// Otherwise the new entry is appended after the existing entries and only the central directory
+// and end record are rewritten; the old entry stays in the archive and is superseded by the newer
+// one, exactly like game patches layer files. Repeated appends fragment the archive, and the dead
+// space can be reclaimed by running the optimizer.
+//
+// The changes are made to a copy of the archive that is renamed over the original once complete,
+// so an interrupted replacement leaves the original archive untouched. The archive comment is kept.
+func (w *Writer) ReplaceEntry(archive string, name string, newData []byte, password []byte) error {
+	fileInfos, cdOffset, comment, err := readArchiveEntries(archive, password)
+	if err != nil {
+		return err
+	}
+
+	// The newest entry with the name is the one readers see
+	target := -1
+	normalized := strings.ReplaceAll(name, "\\", "/")
+	for i := range fileInfos {
+		if strings.ReplaceAll(fileInfos[i].DecryptedFilename, "\\", "/") == normalized {
+			target = i
+		}
+	}
+	if target < 0 {
+		return fmt.Errorf("entry %s not found in %s", name, archive)
+	}
+	old := &fileInfos[target]
+	if old.ZipInfo == nil || old.HeaderSize == 0 {
+		return fmt.Errorf("entry %s has no readable local header", name)
+	}
+
+	modTime, modDate := zipcipher.MSDOSTimestamp(time.Now())
+	method, payload, err := w.encodePayload(newData, old.GenPurpose&0x1 != 0, password, byte(modTime>>8))
+	if err != nil {
+		return err
+	}
+
+	// Describe the replacement entry with a copy of the original headers. Sizes and CRC are known
+	// up front and no data descriptor is written, so the data descriptor flag (bit 3) is cleared.
+	zipInfo := *old.ZipInfo
+	zipInfo.Flags &^= 0x8
+	zipInfo.Method = method
+	zipInfo.ModifiedTime = modTime
+	zipInfo.ModifiedDate = modDate
+	zipInfo.CRC32 = crc32.ChecksumIEEE(newData)
+	zipInfo.CompressedSize64 = uint64(len(payload))
+	zipInfo.UncompressedSize64 = uint64(len(newData))
+	replacement := *old
+	replacement.GenPurpose &^= 0x8
+	replacement.ZipInfo = &zipInfo
+	replacement.LocalMethod = method
+
+	tempPath := archive + ".tmp"
+	file, err := CopyArchive(archive, tempPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempPath)
+	defer file.Close()
+
+	if uint64(len(payload)) <= old.ZipInfo.CompressedSize64 {
+		// Overwrite in place and zero the remainder of the old payload
+		padding := make([]byte, old.ZipInfo.CompressedSize64-uint64(len(payload)))
+		if err := writeEntryAt(file, old.LocalHeaderOffset, &replacement, payload, padding); err != nil {
+			return err
+		}
+		fileInfos[target] = replacement
+	} else {
+		// Append the new entry where the central directory used to start
+		replacement.LocalHeaderOffset = cdOffset
+		if err := writeEntryAt(file, cdOffset, &replacement, payload, nil); err != nil {
+			return err
+		}
+		replacement.Index = len(fileInfos)
+		fileInfos = append(fileInfos, replacement)
+		cdOffset += int64(LocalHeaderSize(&replacement)) + int64(len(payload))
+	}
+
+	if err := rewriteCentralDirectory(file, cdOffset, fileInfos, comment); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync archive: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close archive: %w", err)
+	}
+	if err := os.Rename(tempPath, archive); err != nil {
+		return fmt.Errorf("failed to replace archive: %w", err)
+	}
+	return nil
+}
+
+// CopyArchive copies archive to tempPath with the same permissions and returns the copy opened for
+// writing, so changes can be made to the copy and renamed over the original once complete
+func CopyArchive(archive, tempPath string) (*os.File, error) {
+	source, err := os.Open(archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer source.Close()
+
+	stat, err := source.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archive info: %w", err)
+	}
+
+	file, err := os.OpenFile(tempPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, stat.Mode().Perm())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary archive: %w", err)
+	}
+	if _, err := io.Copy(file, source); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to copy archive: %w", err)
+	}
+	return file, nil
+}
+
+// encodePayload compresses (and optionally encrypts) data, returning the method and raw payload
+func (w *Writer) encodePayload(data []byte, encrypt bool, password []byte, checkByte byte) (uint16, []byte, error) {
+	method := uint16(0)
+	payload := data
+
+	if w.CompressionLevel > 0 {
+		var buf bytes.Buffer
+		compressor, err := flate.NewWriter(&buf, w.CompressionLevel)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to create compressor: %w", err)
+		}
+		if _, err := compressor.Write(data); err != nil {
+			compressor.Close()
+			return 0, nil, fmt.Errorf("failed to compress data: %w", err)
+		}
+		if err := compressor.Close(); err != nil {
+			return 0, nil, fmt.Errorf("failed to close compressor: %w", err)
+		}
+		method = 8
+		payload = buf.Bytes()
+	}
+
+	if encrypt {
+		var err error
+		if payload, err = zipcipher.EncryptPayload(payload, password, checkByte); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return method, payload, nil
+}
+
+// writeEntryAt writes a local header, payload and optional padding at offset
+func writeEntryAt(file *os.File, offset int64, fileInfo *ipf.FileInfo, payload, padding []byte) error {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek to entry offset %d: %w", offset, err)
+	}
+	if err := WriteLocalFileHeaderFromIPF(file, fileInfo, fileInfo.GenPurpose); err != nil {
+		return fmt.Errorf("failed to write local header: %w", err)
+	}
+	if _, err := file.Write(payload); err != nil {
+		return fmt.Errorf("failed to write entry data: %w", err)
+	}
+	if len(padding) > 0 {
+		if _, err := file.Write(padding); err != nil {
+			return fmt.Errorf("failed to write padding: %w", err)
+		}
+	}
+	return nil
+}
+
+// rewriteCentralDirectory writes the central directory and end record at cdOffset and truncates the file after them
+func rewriteCentralDirectory(file *os.File, cdOffset int64, fileInfos []ipf.FileInfo, comment string) error {
+	if _, err := file.Seek(cdOffset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek to central directory: %w", err)
+	}
+
+	var cdSize uint64
+	for i := range fileInfos {
+		entry := &fileInfos[i]
+		if err := WriteCentralDirectoryEntryFromIPF(file, entry, uint64(entry.LocalHeaderOffset), entry.ZipInfo.CreatorVersion, entry.ZipInfo.Flags); err != nil {
+			return fmt.Errorf("failed to write central directory entry %d: %w", i, err)
+		}
+		cdSize += CentralDirectoryEntrySize(entry, uint64(entry.LocalHeaderOffset))
+	}
+
+	if err := WriteEndOfCentralDirectory64(file, uint64(cdOffset), cdSize, len(fileInfos), comment); err != nil {
+		return fmt.Errorf("failed to write end of central directory: %w", err)
+	}
+
+	end, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to get archive end offset: %w", err)
+	}
+	return file.Truncate(end)
+}
+
+// readArchiveEntries reads an archive's entries with decrypted filenames, along with the offset of
+// its central directory and the archive comment
+func readArchiveEntries(archive string, password []byte) ([]ipf.FileInfo, int64, string, error) {
+	reader, err := ipf.NewIPFReader(archive)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to open IPF reader: %w", err)
+	}
+	defer reader.Close()
+
+	if err := reader.ReadFileStructure(); err != nil {
+		return nil, 0, "", fmt.Errorf("failed to read file structure: %w", err)
+	}
+	if err := reader.ReadEncryptedFilenames(); err != nil {
+		return nil, 0, "", fmt.Errorf("failed to read encrypted filenames: %w", err)
+	}
+
+	cdOffset, _, comment, err := reader.CentralDirectory()
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	fileInfos := reader.GetFileInfos()
+	decryptor := ipf.NewFilenameDecryptor(password, 0)
+	results, err := decryptor.DecryptAllParallel(context.Background(), fileInfos)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to decrypt filenames: %w", err)
+	}
+	ipf.UpdateFileInfos(fileInfos, results)
+
+	return fileInfos, cdOffset, comment, nil
+}
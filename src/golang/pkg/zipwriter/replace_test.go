@@ -0,0 +1,106 @@
+package zipwriter
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+)
+
+func TestReplaceEntry(t *testing.T) {
+	original := bytes.Repeat([]byte("original content "), 40)
+	entries := []ipftest.Entry{
+		{Name: "first.txt", Data: []byte("first")},
+		{Name: "data/target.xml", Data: original, Method: zip.Deflate},
+		{Name: "last.txt", Data: []byte("last")},
+	}
+
+	tests := []struct {
+		name         string
+		data         []byte
+		wantEntries  int  // Entries in the archive afterwards
+		wantSameSize bool // Replaced in place without growing the archive
+	}{
+		{"smaller content in place", []byte("<new/>"), 3, true},
+		{"larger content appended", bytes.Repeat([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, 200), 4, false},
+		{"empty content", nil, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := ipftest.Build(t, testPassword, entries...)
+			path := ipftest.WriteFile(t, t.TempDir(), "test.ipf", archive)
+
+			if err := NewWriter().ReplaceEntry(path, "data/target.xml", tt.data, testPassword); err != nil {
+				t.Fatalf("ReplaceEntry: %v", err)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sameSize := info.Size() == int64(len(archive)); sameSize != tt.wantSameSize {
+				t.Errorf("archive size %d -> %d, want unchanged %v", len(archive), info.Size(), tt.wantSameSize)
+			}
+
+			reader := openArchive(t, path)
+			if got := reader.GetFileCount(); got != tt.wantEntries {
+				t.Errorf("archive has %d entries, want %d", got, tt.wantEntries)
+			}
+			if got := readEntry(t, reader, "data/target.xml"); got != string(tt.data) {
+				t.Errorf("replaced entry = %q, want %q", got, tt.data)
+			}
+			for _, name := range []string{"first.txt", "last.txt"} {
+				if got := readEntry(t, reader, name); got != name[:len(name)-4] {
+					t.Errorf("%s = %q after replacement", name, got)
+				}
+			}
+			if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+				t.Errorf("temporary copy left behind: %v", err)
+			}
+		})
+	}
+
+	t.Run("missing entry", func(t *testing.T) {
+		archive := ipftest.Build(t, testPassword, entries...)
+		path := ipftest.WriteFile(t, t.TempDir(), "test.ipf", archive)
+		if err := NewWriter().ReplaceEntry(path, "missing.txt", []byte("x"), testPassword); err == nil {
+			t.Fatal("ReplaceEntry of a missing entry succeeded")
+		}
+		if got, _ := os.ReadFile(path); !bytes.Equal(got, archive) {
+			t.Error("archive changed after a failed replacement")
+		}
+	})
+}
+
+func TestReplaceEntryClearsDescriptorFlag(t *testing.T) {
+	original := bytes.Repeat([]byte("streamed content "), 40)
+	for _, data := range [][]byte{[]byte("<new/>"), bytes.Repeat([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, 200)} {
+		archive := ipftest.Build(t, testPassword,
+			ipftest.Entry{Name: "target.xml", Data: original, Method: zip.Deflate, Descriptor: true},
+			ipftest.Entry{Name: "last.txt", Data: []byte("last")},
+		)
+		path := ipftest.WriteFile(t, t.TempDir(), "test.ipf", archive)
+
+		if err := NewWriter().ReplaceEntry(path, "target.xml", data, testPassword); err != nil {
+			t.Fatalf("ReplaceEntry: %v", err)
+		}
+
+		// The replacement is written without a data descriptor, so neither header may announce one
+		reader := openArchive(t, path)
+		fileInfo, err := reader.GetFileByName("target.xml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fileInfo.GenPurpose&0x8 != 0 || fileInfo.ZipInfo.Flags&0x8 != 0 {
+			t.Errorf("flags = %#x local, %#x central, want bit 3 clear", fileInfo.GenPurpose, fileInfo.ZipInfo.Flags)
+		}
+		if got := readEntry(t, reader, "target.xml"); got != string(data) {
+			t.Errorf("replaced entry = %q, want %q", got, data)
+		}
+		if got := readEntry(t, reader, "last.txt"); got != "last" {
+			t.Errorf("last.txt = %q after replacement", got)
+		}
+	}
+}
@@ -2,27 +2,52 @@ package zipwriter
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 
 	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
 )
 
+// Writer modifies existing IPF archives
+type Writer struct {
+	CompressionLevel int
+}
+
+// NewWriter creates a writer using the default compression level
+func NewWriter() *Writer {
+	return &Writer{
+		CompressionLevel: 6,
+	}
+}
+
 // WriteLocalFileHeaderFromIPF writes a local file header using ipf.FileInfo struct.
-// Use this when writing from existing IPF data (e.g., optimizer).
+// Use this when writing from existing IPF data (e.g., optimizer). Entries of 4GB or more get
+// ZIP64 size markers and extra data; LocalHeaderSize reports the resulting header length.
 func WriteLocalFileHeaderFromIPF(w io.Writer, file *ipf.FileInfo, genPurpose uint16) error {
 	header := make([]byte, 30)
 
+	extra, zip64 := localExtra(file)
+	versionNeeded := file.VersionNeeded
+	if zip64 && versionNeeded < zip64Version {
+		versionNeeded = zip64Version
+	}
+
 	binary.LittleEndian.PutUint32(header[0:4], 0x04034b50)
-	binary.LittleEndian.PutUint16(header[4:6], file.VersionNeeded)
+	binary.LittleEndian.PutUint16(header[4:6], versionNeeded)
 	binary.LittleEndian.PutUint16(header[6:8], genPurpose)
-	binary.LittleEndian.PutUint16(header[8:10], file.ZipInfo.Method)
+	binary.LittleEndian.PutUint16(header[8:10], file.Method())
 	binary.LittleEndian.PutUint16(header[10:12], file.ZipInfo.ModifiedTime)
 	binary.LittleEndian.PutUint16(header[12:14], file.ZipInfo.ModifiedDate)
 	binary.LittleEndian.PutUint32(header[14:18], file.ZipInfo.CRC32)
-	binary.LittleEndian.PutUint32(header[18:22], uint32(file.ZipInfo.CompressedSize64))
-	binary.LittleEndian.PutUint32(header[22:26], uint32(file.ZipInfo.UncompressedSize64))
+	if zip64 {
+		binary.LittleEndian.PutUint32(header[18:22], uint32Max)
+		binary.LittleEndian.PutUint32(header[22:26], uint32Max)
+	} else {
+		binary.LittleEndian.PutUint32(header[18:22], uint32(file.ZipInfo.CompressedSize64))
+		binary.LittleEndian.PutUint32(header[22:26], uint32(file.ZipInfo.UncompressedSize64))
+	}
 	binary.LittleEndian.PutUint16(header[26:28], file.EncryptedNameLen)
-	binary.LittleEndian.PutUint16(header[28:30], file.ExtraLen)
+	binary.LittleEndian.PutUint16(header[28:30], uint16(len(extra)))
 
 	if _, err := w.Write(header); err != nil {
 		return err
@@ -34,8 +59,8 @@ func WriteLocalFileHeaderFromIPF(w io.Writer, file *ipf.FileInfo, genPurpose uin
 		}
 	}
 
-	if len(file.ExtraField) > 0 {
-		if _, err := w.Write(file.ExtraField); err != nil {
+	if len(extra) > 0 {
+		if _, err := w.Write(extra); err != nil {
 			return err
 		}
 	}
@@ -44,7 +69,8 @@ func WriteLocalFileHeaderFromIPF(w io.Writer, file *ipf.FileInfo, genPurpose uin
 }
 
 // WriteLocalFileHeaderFromParams writes a local file header using individual parameters.
-// Use this when building new archives from scratch (e.g., creator).
+// Use this when building new archives from scratch (e.g., creator). Sizes of 4GB or more are
+// written as the ZIP64 marker; extraField must then carry a Zip64LocalExtra block.
 func WriteLocalFileHeaderFromParams(w io.Writer, versionNeeded, genPurpose, method, modifiedTime, modifiedDate uint16, crc32 uint32, compressedSize, uncompressedSize uint64, encryptedNameLen, extraLen uint16, encryptedFilename, extraField []byte) error {
 	header := make([]byte, 30)
 
@@ -55,8 +81,8 @@ func WriteLocalFileHeaderFromParams(w io.Writer, versionNeeded, genPurpose, meth
 	binary.LittleEndian.PutUint16(header[10:12], modifiedTime)
 	binary.LittleEndian.PutUint16(header[12:14], modifiedDate)
 	binary.LittleEndian.PutUint32(header[14:18], crc32)
-	binary.LittleEndian.PutUint32(header[18:22], uint32(compressedSize))
-	binary.LittleEndian.PutUint32(header[22:26], uint32(uncompressedSize))
+	binary.LittleEndian.PutUint32(header[18:22], clamp32(compressedSize))
+	binary.LittleEndian.PutUint32(header[22:26], clamp32(uncompressedSize))
 	binary.LittleEndian.PutUint16(header[26:28], encryptedNameLen)
 	binary.LittleEndian.PutUint16(header[28:30], extraLen)
 
@@ -80,28 +106,37 @@ func WriteLocalFileHeaderFromParams(w io.Writer, versionNeeded, genPurpose, meth
 }
 
 // WriteCentralDirectoryEntryFromIPF writes a central directory entry using ipf.FileInfo struct.
-// Use this when writing from existing IPF data (e.g., optimizer).
+// Use this when writing from existing IPF data (e.g., optimizer). Internal and external attributes,
+// the central directory's own extra field (which may carry timestamps that differ from the local
+// copy) and the file comment are copied from the original entry. Any ZIP64 block is rebuilt: sizes
+// and offsets of 4GB or more get a fresh one, and entries below that limit carry none.
 func WriteCentralDirectoryEntryFromIPF(w io.Writer, file *ipf.FileInfo, localHeaderOffset uint64, versionMadeBy uint16, genPurpose uint16) error {
 	header := make([]byte, 46)
 
+	extra, zip64 := centralExtra(file, localHeaderOffset)
+	versionNeeded := file.VersionNeeded
+	if zip64 && versionNeeded < zip64Version {
+		versionNeeded = zip64Version
+	}
+
 	binary.LittleEndian.PutUint32(header[0:4], 0x02014b50)
 	binary.LittleEndian.PutUint16(header[4:6], versionMadeBy)
 
-	binary.LittleEndian.PutUint16(header[6:8], file.VersionNeeded)
+	binary.LittleEndian.PutUint16(header[6:8], versionNeeded)
 	binary.LittleEndian.PutUint16(header[8:10], genPurpose)
-	binary.LittleEndian.PutUint16(header[10:12], file.ZipInfo.Method)
+	binary.LittleEndian.PutUint16(header[10:12], file.Method())
 	binary.LittleEndian.PutUint16(header[12:14], file.ZipInfo.ModifiedTime)
 	binary.LittleEndian.PutUint16(header[14:16], file.ZipInfo.ModifiedDate)
 	binary.LittleEndian.PutUint32(header[16:20], file.ZipInfo.CRC32)
-	binary.LittleEndian.PutUint32(header[20:24], uint32(file.ZipInfo.CompressedSize64))
-	binary.LittleEndian.PutUint32(header[24:28], uint32(file.ZipInfo.UncompressedSize64))
+	binary.LittleEndian.PutUint32(header[20:24], clamp32(file.ZipInfo.CompressedSize64))
+	binary.LittleEndian.PutUint32(header[24:28], clamp32(file.ZipInfo.UncompressedSize64))
 	binary.LittleEndian.PutUint16(header[28:30], file.EncryptedNameLen)
-	binary.LittleEndian.PutUint16(header[30:32], file.ExtraLen)
-	binary.LittleEndian.PutUint16(header[32:34], 0)
+	binary.LittleEndian.PutUint16(header[30:32], uint16(len(extra)))
+	binary.LittleEndian.PutUint16(header[32:34], uint16(len(file.ZipInfo.Comment)))
 	binary.LittleEndian.PutUint16(header[34:36], 0)
-	binary.LittleEndian.PutUint16(header[36:38], 0)
-	binary.LittleEndian.PutUint32(header[38:42], 0)
-	binary.LittleEndian.PutUint32(header[42:46], uint32(localHeaderOffset))
+	binary.LittleEndian.PutUint16(header[36:38], file.InternalAttrs)
+	binary.LittleEndian.PutUint32(header[38:42], file.ZipInfo.ExternalAttrs)
+	binary.LittleEndian.PutUint32(header[42:46], clamp32(localHeaderOffset))
 
 	if _, err := w.Write(header); err != nil {
 		return err
@@ -113,8 +148,14 @@ func WriteCentralDirectoryEntryFromIPF(w io.Writer, file *ipf.FileInfo, localHea
 		}
 	}
 
-	if len(file.ExtraField) > 0 {
-		if _, err := w.Write(file.ExtraField); err != nil {
+	if len(extra) > 0 {
+		if _, err := w.Write(extra); err != nil {
+			return err
+		}
+	}
+
+	if len(file.ZipInfo.Comment) > 0 {
+		if _, err := io.WriteString(w, file.ZipInfo.Comment); err != nil {
 			return err
 		}
 	}
@@ -122,9 +163,24 @@ func WriteCentralDirectoryEntryFromIPF(w io.Writer, file *ipf.FileInfo, localHea
 	return nil
 }
 
+// CentralDirectoryEntrySize returns the number of bytes WriteCentralDirectoryEntryFromIPF writes for
+// file at localHeaderOffset
+func CentralDirectoryEntrySize(file *ipf.FileInfo, localHeaderOffset uint64) uint64 {
+	extra, _ := centralExtra(file, localHeaderOffset)
+	return 46 + uint64(file.EncryptedNameLen) + uint64(len(extra)) + uint64(len(file.ZipInfo.Comment))
+}
+
 // WriteCentralDirectoryEntryFromParams writes a central directory entry using individual parameters.
-// Use this when building new archives from scratch (e.g., creator).
+// Use this when building new archives from scratch (e.g., creator). Sizes and offsets of 4GB or
+// more are written as the ZIP64 marker; extraField must then carry a Zip64CentralExtra block.
+// The external attributes are left zero; see WriteCentralDirectoryEntryWithAttrs.
 func WriteCentralDirectoryEntryFromParams(w io.Writer, versionNeeded, versionMadeBy, genPurpose, method, modifiedTime, modifiedDate uint16, crc32 uint32, compressedSize, uncompressedSize uint64, encryptedNameLen, extraLen uint16, encryptedFilename, extraField []byte, localHeaderOffset uint64) error {
+	return WriteCentralDirectoryEntryWithAttrs(w, versionNeeded, versionMadeBy, genPurpose, method, modifiedTime, modifiedDate, crc32, compressedSize, uncompressedSize, encryptedNameLen, extraLen, encryptedFilename, extraField, 0, localHeaderOffset)
+}
+
+// WriteCentralDirectoryEntryWithAttrs is WriteCentralDirectoryEntryFromParams with the external
+// file attributes, e.g. a Unix mode in the high 16 bits
+func WriteCentralDirectoryEntryWithAttrs(w io.Writer, versionNeeded, versionMadeBy, genPurpose, method, modifiedTime, modifiedDate uint16, crc32 uint32, compressedSize, uncompressedSize uint64, encryptedNameLen, extraLen uint16, encryptedFilename, extraField []byte, externalAttrs uint32, localHeaderOffset uint64) error {
 	header := make([]byte, 46)
 
 	binary.LittleEndian.PutUint32(header[0:4], 0x02014b50)
@@ -136,15 +192,15 @@ func WriteCentralDirectoryEntryFromParams(w io.Writer, versionNeeded, versionMad
 	binary.LittleEndian.PutUint16(header[12:14], modifiedTime)
 	binary.LittleEndian.PutUint16(header[14:16], modifiedDate)
 	binary.LittleEndian.PutUint32(header[16:20], crc32)
-	binary.LittleEndian.PutUint32(header[20:24], uint32(compressedSize))
-	binary.LittleEndian.PutUint32(header[24:28], uint32(uncompressedSize))
+	binary.LittleEndian.PutUint32(header[20:24], clamp32(compressedSize))
+	binary.LittleEndian.PutUint32(header[24:28], clamp32(uncompressedSize))
 	binary.LittleEndian.PutUint16(header[28:30], encryptedNameLen)
 	binary.LittleEndian.PutUint16(header[30:32], extraLen)
 	binary.LittleEndian.PutUint16(header[32:34], 0)
 	binary.LittleEndian.PutUint16(header[34:36], 0)
 	binary.LittleEndian.PutUint16(header[36:38], 0)
-	binary.LittleEndian.PutUint32(header[38:42], 0)
-	binary.LittleEndian.PutUint32(header[42:46], uint32(localHeaderOffset))
+	binary.LittleEndian.PutUint32(header[38:42], externalAttrs)
+	binary.LittleEndian.PutUint32(header[42:46], clamp32(localHeaderOffset))
 
 	if _, err := w.Write(header); err != nil {
 		return err
@@ -166,6 +222,16 @@ func WriteCentralDirectoryEntryFromParams(w io.Writer, versionNeeded, versionMad
 }
 
 func WriteEndOfCentralDirectory(w io.Writer, cdOffset, cdSize uint64, fileCount uint16) error {
+	return WriteEndOfCentralDirectoryWithComment(w, cdOffset, cdSize, fileCount, "")
+}
+
+// WriteEndOfCentralDirectoryWithComment writes the end of central directory record followed by
+// the archive comment, which must fit the record's 16-bit length field
+func WriteEndOfCentralDirectoryWithComment(w io.Writer, cdOffset, cdSize uint64, fileCount uint16, comment string) error {
+	if len(comment) > 0xFFFF {
+		return fmt.Errorf("archive comment too long: %d bytes (maximum 65535)", len(comment))
+	}
+
 	record := make([]byte, 22)
 
 	binary.LittleEndian.PutUint32(record[0:4], 0x06054b50)
@@ -175,8 +241,17 @@ func WriteEndOfCentralDirectory(w io.Writer, cdOffset, cdSize uint64, fileCount
 	binary.LittleEndian.PutUint16(record[10:12], fileCount)
 	binary.LittleEndian.PutUint32(record[12:16], uint32(cdSize))
 	binary.LittleEndian.PutUint32(record[16:20], uint32(cdOffset))
-	binary.LittleEndian.PutUint16(record[20:22], 0)
+	binary.LittleEndian.PutUint16(record[20:22], uint16(len(comment)))
 
-	_, err := w.Write(record)
-	return err
+	if _, err := w.Write(record); err != nil {
+		return err
+	}
+
+	if len(comment) > 0 {
+		if _, err := io.WriteString(w, comment); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
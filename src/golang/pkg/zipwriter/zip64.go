@@ -0,0 +1,164 @@
+package zipwriter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+)
+
+// ZIP64 limits and markers
+const (
+	uint32Max      = 0xFFFFFFFF
+	uint16Max      = 0xFFFF
+	zip64ExtraID   = 0x0001
+	zip64Version   = Zip64Version
+	zip64EndSize   = 56
+	zip64LocatorSz = 20
+)
+
+// Zip64Version is the version needed to extract an entry described by ZIP64 records
+const Zip64Version = 45
+
+// localExtra returns the extra field written in file's local header. Entries whose sizes fit in
+// 32 bits keep their original extra data; larger ones get a fresh ZIP64 block carrying both sizes,
+// as the local header must.
+func localExtra(file *ipf.FileInfo) ([]byte, bool) {
+	if file.ZipInfo.CompressedSize64 < uint32Max && file.ZipInfo.UncompressedSize64 < uint32Max {
+		return file.ExtraField, false
+	}
+	return appendZip64Extra(withoutZip64Extra(file.ExtraField), file.ZipInfo.UncompressedSize64, file.ZipInfo.CompressedSize64), true
+}
+
+// centralExtra returns the extra field written in file's central directory record, which carries
+// only the values that overflow 32 bits, in the order the spec requires. A ZIP64 block inherited
+// from the source is always dropped, since its values describe the entry's old position.
+func centralExtra(file *ipf.FileInfo, localHeaderOffset uint64) ([]byte, bool) {
+	return Zip64CentralExtra(withoutZip64Extra(file.ZipInfo.Extra), file.ZipInfo.UncompressedSize64, file.ZipInfo.CompressedSize64, localHeaderOffset)
+}
+
+// Zip64LocalExtra returns the ZIP64 extra block of a local header, which carries both sizes.
+// The header's own size fields must then hold the 0xFFFFFFFF marker.
+func Zip64LocalExtra(uncompressedSize, compressedSize uint64) []byte {
+	return appendZip64Extra(nil, uncompressedSize, compressedSize)
+}
+
+// Zip64CentralExtra appends to extra the ZIP64 block of a central directory record, holding only
+// the values that overflow 32 bits in the order the spec requires. It reports whether a block was
+// needed; extra is returned unchanged when not.
+func Zip64CentralExtra(extra []byte, uncompressedSize, compressedSize, localHeaderOffset uint64) ([]byte, bool) {
+	var values []uint64
+	if uncompressedSize >= uint32Max {
+		values = append(values, uncompressedSize)
+	}
+	if compressedSize >= uint32Max {
+		values = append(values, compressedSize)
+	}
+	if localHeaderOffset >= uint32Max {
+		values = append(values, localHeaderOffset)
+	}
+	if len(values) == 0 {
+		return extra, false
+	}
+	return appendZip64Extra(extra, values...), true
+}
+
+// withoutZip64Extra returns extra with any ZIP64 block removed. Malformed trailing data is kept.
+func withoutZip64Extra(extra []byte) []byte {
+	result := make([]byte, 0, len(extra))
+	for len(extra) >= 4 {
+		tag := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if 4+size > len(extra) {
+			break
+		}
+		if tag != zip64ExtraID {
+			result = append(result, extra[:4+size]...)
+		}
+		extra = extra[4+size:]
+	}
+	return append(result, extra...)
+}
+
+// appendZip64Extra appends a ZIP64 extended information block holding values
+func appendZip64Extra(extra []byte, values ...uint64) []byte {
+	block := make([]byte, 4+8*len(values))
+	binary.LittleEndian.PutUint16(block[0:2], zip64ExtraID)
+	binary.LittleEndian.PutUint16(block[2:4], uint16(8*len(values)))
+	for i, value := range values {
+		binary.LittleEndian.PutUint64(block[4+8*i:], value)
+	}
+	return append(extra, block...)
+}
+
+// clamp32 returns value, or the ZIP64 marker when it does not fit in 32 bits
+func clamp32(value uint64) uint32 {
+	if value >= uint32Max {
+		return uint32Max
+	}
+	return uint32(value)
+}
+
+// LocalHeaderSize returns the number of bytes WriteLocalFileHeaderFromIPF writes for file
+func LocalHeaderSize(file *ipf.FileInfo) uint64 {
+	extra, _ := localExtra(file)
+	return 30 + uint64(len(file.EncryptedFilename)) + uint64(len(extra))
+}
+
+// WriteEndOfCentralDirectory64 writes the end of central directory record like
+// WriteEndOfCentralDirectoryWithComment, preceded by a ZIP64 end record and locator when the
+// entry count, directory size or directory offset overflow the classic fields. The ZIP64 record
+// is placed right after the central directory, so cdOffset+cdSize must be the current offset.
+func WriteEndOfCentralDirectory64(w io.Writer, cdOffset, cdSize uint64, fileCount int, comment string) error {
+	if fileCount < uint16Max && cdSize < uint32Max && cdOffset < uint32Max {
+		return WriteEndOfCentralDirectoryWithComment(w, cdOffset, cdSize, uint16(fileCount), comment)
+	}
+	if len(comment) > uint16Max {
+		return fmt.Errorf("archive comment too long: %d bytes (maximum 65535)", len(comment))
+	}
+
+	record := make([]byte, zip64EndSize+zip64LocatorSz+22)
+
+	end := record[:zip64EndSize]
+	binary.LittleEndian.PutUint32(end[0:4], 0x06064b50)
+	binary.LittleEndian.PutUint64(end[4:12], zip64EndSize-12)
+	binary.LittleEndian.PutUint16(end[12:14], zip64Version)
+	binary.LittleEndian.PutUint16(end[14:16], zip64Version)
+	binary.LittleEndian.PutUint32(end[16:20], 0)
+	binary.LittleEndian.PutUint32(end[20:24], 0)
+	binary.LittleEndian.PutUint64(end[24:32], uint64(fileCount))
+	binary.LittleEndian.PutUint64(end[32:40], uint64(fileCount))
+	binary.LittleEndian.PutUint64(end[40:48], cdSize)
+	binary.LittleEndian.PutUint64(end[48:56], cdOffset)
+
+	locator := record[zip64EndSize : zip64EndSize+zip64LocatorSz]
+	binary.LittleEndian.PutUint32(locator[0:4], 0x07064b50)
+	binary.LittleEndian.PutUint32(locator[4:8], 0)
+	binary.LittleEndian.PutUint64(locator[8:16], cdOffset+cdSize)
+	binary.LittleEndian.PutUint32(locator[16:20], 1)
+
+	count := uint16(uint16Max)
+	if fileCount < uint16Max {
+		count = uint16(fileCount)
+	}
+	classic := record[zip64EndSize+zip64LocatorSz:]
+	binary.LittleEndian.PutUint32(classic[0:4], 0x06054b50)
+	binary.LittleEndian.PutUint16(classic[8:10], count)
+	binary.LittleEndian.PutUint16(classic[10:12], count)
+	binary.LittleEndian.PutUint32(classic[12:16], clamp32(cdSize))
+	binary.LittleEndian.PutUint32(classic[16:20], clamp32(cdOffset))
+	binary.LittleEndian.PutUint16(classic[20:22], uint16(len(comment)))
+
+	if _, err := w.Write(record); err != nil {
+		return err
+	}
+
+	if len(comment) > 0 {
+		if _, err := io.WriteString(w, comment); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
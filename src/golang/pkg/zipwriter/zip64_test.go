@@ -0,0 +1,151 @@
+package zipwriter
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joao-paulo-santos/GE-Library/internal/ipftest"
+	"github.com/joao-paulo-santos/GE-Library/pkg/ipf"
+)
+
+func TestZip64Headers(t *testing.T) {
+	const large = 5 << 30
+
+	tests := []struct {
+		name                     string
+		compressed, uncompressed uint64
+		offset                   uint64
+		wantCentral              []uint64 // Values expected in the central ZIP64 block, in order
+	}{
+		{"small", 100, 200, 300, nil},
+		{"large offset", 100, 200, large, []uint64{large}},
+		{"large entry", large, large + 1, 300, []uint64{large + 1, large}},
+		{"everything large", large, large + 1, large + 2, []uint64{large + 1, large, large + 2}},
+		{"exactly the marker", 0xFFFFFFFF, 200, 300, []uint64{0xFFFFFFFF}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &ipf.FileInfo{
+				ZipInfo: &zip.File{FileHeader: zip.FileHeader{
+					CompressedSize64:   tt.compressed,
+					UncompressedSize64: tt.uncompressed,
+				}},
+				EncryptedFilename: []byte("name"),
+				EncryptedNameLen:  4,
+				VersionNeeded:     20,
+			}
+
+			var local bytes.Buffer
+			if err := WriteLocalFileHeaderFromIPF(&local, file, 0); err != nil {
+				t.Fatal(err)
+			}
+			if uint64(local.Len()) != LocalHeaderSize(file) {
+				t.Errorf("local header is %d bytes, LocalHeaderSize says %d", local.Len(), LocalHeaderSize(file))
+			}
+			localZip64 := tt.compressed >= 0xFFFFFFFF || tt.uncompressed >= 0xFFFFFFFF
+			header := local.Bytes()
+			if got := binary.LittleEndian.Uint32(header[18:]) == 0xFFFFFFFF; got != localZip64 {
+				t.Errorf("local size marker = %v, want %v", got, localZip64)
+			}
+			if localZip64 {
+				if got := header[34:]; !bytes.Equal(got, Zip64LocalExtra(tt.uncompressed, tt.compressed)) {
+					t.Errorf("local extra = % x, want both sizes", got)
+				}
+			}
+
+			var central bytes.Buffer
+			if err := WriteCentralDirectoryEntryFromIPF(&central, file, tt.offset, 20, 0); err != nil {
+				t.Fatal(err)
+			}
+			if uint64(central.Len()) != CentralDirectoryEntrySize(file, tt.offset) {
+				t.Errorf("central record is %d bytes, CentralDirectoryEntrySize says %d", central.Len(), CentralDirectoryEntrySize(file, tt.offset))
+			}
+			record := central.Bytes()
+			if got := binary.LittleEndian.Uint16(record[6:]); (got == Zip64Version) != (tt.wantCentral != nil) {
+				t.Errorf("version needed = %d with ZIP64 values %v", got, tt.wantCentral)
+			}
+			extra := record[50:]
+			if tt.wantCentral == nil {
+				if len(extra) != 0 {
+					t.Errorf("central extra = % x, want none", extra)
+				}
+				return
+			}
+			for i, want := range tt.wantCentral {
+				if got := binary.LittleEndian.Uint64(extra[4+8*i:]); got != want {
+					t.Errorf("central ZIP64 value %d = %d, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestZip64Archive rewrites an archive the way the optimizer does, with its second entry moved
+// past 4GB in a sparse file, and reads it back
+func TestZip64Archive(t *testing.T) {
+	const offset = 5 << 30
+
+	source := ipftest.Build(t, testPassword,
+		ipftest.Entry{Name: "a.txt", Data: []byte("before the gap")},
+		ipftest.Entry{Name: "b.xml", Data: bytes.Repeat([]byte("<b/>"), 100), Method: zip.Deflate},
+	)
+	sourcePath := ipftest.WriteFile(t, t.TempDir(), "source.ipf", source)
+	files := openArchive(t, sourcePath).FileInfos
+
+	path := filepath.Join(t.TempDir(), "large.ipf")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	offsets := []uint64{0, offset}
+	for i := range files {
+		if _, err := out.Seek(int64(offsets[i]), io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteLocalFileHeaderFromIPF(out, &files[i], files[i].ZipInfo.Flags); err != nil {
+			t.Fatal(err)
+		}
+		start := ipftest.DataOffset(t, source, i)
+		if _, err := out.Write(source[start : start+int(files[i].ZipInfo.CompressedSize64)]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cdOffset, err := out.Seek(0, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cd bytes.Buffer
+	for i := range files {
+		if err := WriteCentralDirectoryEntryFromIPF(&cd, &files[i], offsets[i], 20, files[i].ZipInfo.Flags); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WriteEndOfCentralDirectory64(&cd, uint64(cdOffset), uint64(cd.Len()), len(files), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := out.Write(cd.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := openArchive(t, path)
+	if got := reader.FileInfos[1].LocalHeaderOffset; got != offset {
+		t.Errorf("second entry at offset %d, want %d", got, offset)
+	}
+	if got := readEntry(t, reader, "a.txt"); got != "before the gap" {
+		t.Errorf("a.txt = %q", got)
+	}
+	if got := readEntry(t, reader, "b.xml"); got != string(bytes.Repeat([]byte("<b/>"), 100)) {
+		t.Errorf("b.xml = %q", got)
+	}
+}